@@ -0,0 +1,237 @@
+// Package dispatcher is the Lambda entrypoint. It detects which AWS service invoked the
+// function from the shape of the payload and hands the event to the matching adapter.
+package dispatcher
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambdacontext"
+	ginadapter "github.com/awslabs/aws-lambda-go-api-proxy/gin"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/logger"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/metrics"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/tracing"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Event sources recognised by the dispatcher
+const (
+	SourceAPIGatewayV1 = "apigateway.v1"
+	SourceAPIGatewayV2 = "apigateway.v2"
+	SourceALB          = "alb"
+	SourceSQS          = "sqs"
+	SourceSchedule     = "schedule"
+	SourceDirect       = "direct"
+)
+
+// ErrUnknownEvent is returned for payloads that match none of the supported sources
+var ErrUnknownEvent = errors.New("unrecognized event")
+
+// Job is a unit of background work run by a schedule or a direct invocation
+type Job func(ctx context.Context) error
+
+// MessageHandler processes one SQS message, a returned error makes the message visible again
+type MessageHandler func(ctx context.Context, message events.SQSMessage) error
+
+// DirectRequest is the payload of a direct invocation, e.g. from the CLI or EventBridge Scheduler
+type DirectRequest struct {
+	Job string `json:"job"`
+}
+
+// DirectResponse reports the outcome of a job run by a direct invocation
+type DirectResponse struct {
+	Job    string `json:"job"`
+	Status string `json:"status"`
+}
+
+type Dispatcher struct {
+	apiGatewayV1 *ginadapter.GinLambda
+	apiGatewayV2 *ginadapter.GinLambdaV2
+	alb          *ginadapter.GinLambdaALB
+	jobs         map[string]Job
+	messages     MessageHandler
+	metrics      metrics.Recorder
+}
+
+// Option configures optional dependencies of Dispatcher
+type Option func(*Dispatcher)
+
+// WithJob registers a job under name. Scheduled events run the job named after their rule
+func WithJob(name string, job Job) Option {
+	return func(d *Dispatcher) {
+		d.jobs[name] = job
+	}
+}
+
+// WithMessageHandler sets the handler of SQS messages
+func WithMessageHandler(handler MessageHandler) Option {
+	return func(d *Dispatcher) {
+		d.messages = handler
+	}
+}
+
+// WithMetrics sets the recorder used for the cold start metric
+func WithMetrics(recorder metrics.Recorder) Option {
+	return func(d *Dispatcher) {
+		d.metrics = recorder
+	}
+}
+
+// New creates a dispatcher serving HTTP style events with router
+func New(router *gin.Engine, opts ...Option) *Dispatcher {
+	d := &Dispatcher{
+		apiGatewayV1: ginadapter.New(router),
+		apiGatewayV2: ginadapter.NewV2(router),
+		alb:          ginadapter.NewALB(router),
+		jobs:         map[string]Job{},
+		metrics:      metrics.NoopRecorder{},
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
+}
+
+// probe holds the fields that tell the event sources apart
+type probe struct {
+	Version        string `json:"version"`
+	HTTPMethod     string `json:"httpMethod"`
+	RequestContext struct {
+		ELB  json.RawMessage `json:"elb"`
+		HTTP json.RawMessage `json:"http"`
+	} `json:"requestContext"`
+	Records []struct {
+		EventSource string `json:"eventSource"`
+	} `json:"Records"`
+	Source     string   `json:"source"`
+	DetailType string   `json:"detail-type"`
+	Resources  []string `json:"resources"`
+	Job        string   `json:"job"`
+}
+
+// Detect returns the source of payload
+func Detect(payload []byte) (string, error) {
+	var p probe
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrUnknownEvent, err)
+	}
+
+	switch {
+	case len(p.RequestContext.ELB) > 0:
+		return SourceALB, nil
+	case p.Version == "2.0" && len(p.RequestContext.HTTP) > 0:
+		return SourceAPIGatewayV2, nil
+	case p.HTTPMethod != "":
+		return SourceAPIGatewayV1, nil
+	case len(p.Records) > 0 && p.Records[0].EventSource == "aws:sqs":
+		return SourceSQS, nil
+	case p.Source == "aws.events" && p.DetailType == "Scheduled Event":
+		return SourceSchedule, nil
+	case p.Job != "":
+		return SourceDirect, nil
+	}
+	return "", ErrUnknownEvent
+}
+
+// Invoke implements lambda.Handler
+func (d *Dispatcher) Invoke(ctx context.Context, payload []byte) ([]byte, error) {
+	metrics.MarkColdStart(d.metrics, metrics.Dimensions{"function": lambdacontext.FunctionName})
+
+	source, err := Detect(payload)
+	if err != nil {
+		logger.FromContext(ctx).WithError(err).Error("Unsupported invocation")
+		return nil, err
+	}
+	logger.FromContext(ctx).WithField("source", source).Debug("Event received")
+
+	switch source {
+	case SourceAPIGatewayV1:
+		return invoke(ctx, payload, d.apiGatewayV1.ProxyWithContext)
+	case SourceAPIGatewayV2:
+		return invoke(ctx, payload, d.apiGatewayV2.ProxyWithContext)
+	case SourceALB:
+		return invoke(ctx, payload, d.alb.ProxyWithContext)
+	case SourceSQS:
+		return invoke(ctx, payload, d.processMessages)
+	case SourceSchedule:
+		return invoke(ctx, payload, d.runScheduled)
+	default:
+		return invoke(ctx, payload, d.runDirect)
+	}
+}
+
+// invoke decodes payload into the event type of handle and encodes its response
+func invoke[Event, Response any](ctx context.Context, payload []byte, handle func(context.Context, Event) (Response, error)) ([]byte, error) {
+	var event Event
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, err
+	}
+	resp, err := handle(ctx, event)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(resp)
+}
+
+// processMessages reports failed messages individually so the rest of the batch is not
+// redelivered. The event source mapping must enable ReportBatchItemFailures.
+func (d *Dispatcher) processMessages(ctx context.Context, event events.SQSEvent) (events.SQSEventResponse, error) {
+	var resp events.SQSEventResponse
+	if d.messages == nil {
+		return resp, errors.New("no handler for SQS messages")
+	}
+
+	for _, message := range event.Records {
+		msgCtx := logger.WithFields(ctx, logrus.Fields{"message_id": message.MessageId})
+		msgCtx, span := tracing.Tracer().Start(msgCtx, "sqs.process", trace.WithSpanKind(trace.SpanKindConsumer),
+			trace.WithAttributes(attribute.String("messaging.message.id", message.MessageId)))
+		err := d.messages(msgCtx, message)
+		tracing.RecordError(span, err)
+		span.End()
+
+		if err != nil {
+			logger.FromContext(msgCtx).WithError(err).Error("Failed to process message")
+			resp.BatchItemFailures = append(resp.BatchItemFailures, events.SQSBatchItemFailure{ItemIdentifier: message.MessageId})
+		}
+	}
+	return resp, nil
+}
+
+// runScheduled runs the job named after the EventBridge rule that fired
+func (d *Dispatcher) runScheduled(ctx context.Context, event events.EventBridgeEvent) (DirectResponse, error) {
+	name := ""
+	if len(event.Resources) > 0 {
+		name = event.Resources[0][strings.LastIndex(event.Resources[0], "/")+1:]
+	}
+	return d.runJob(ctx, name)
+}
+
+func (d *Dispatcher) runDirect(ctx context.Context, req DirectRequest) (DirectResponse, error) {
+	return d.runJob(ctx, req.Job)
+}
+
+func (d *Dispatcher) runJob(ctx context.Context, name string) (DirectResponse, error) {
+	job, ok := d.jobs[name]
+	if !ok {
+		return DirectResponse{}, fmt.Errorf("unknown job %q", name)
+	}
+
+	ctx = logger.WithFields(ctx, logrus.Fields{"job": name})
+	ctx, span := tracing.Tracer().Start(ctx, "job "+name)
+	defer span.End()
+
+	if err := job(ctx); err != nil {
+		tracing.RecordError(span, err)
+		logger.FromContext(ctx).WithError(err).Error("Job failed")
+		return DirectResponse{}, err
+	}
+	logger.FromContext(ctx).Info("Job completed")
+	return DirectResponse{Job: name, Status: "ok"}, nil
+}
@@ -0,0 +1,171 @@
+package dispatcher_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/adapters/dispatcher"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func readEvent(t *testing.T, name string) []byte {
+	payload, err := os.ReadFile(filepath.Join("testdata", name))
+	assert.NoError(t, err)
+	return payload
+}
+
+func setupRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/", func(ctx *gin.Context) {
+		ctx.JSON(http.StatusOK, gin.H{"path": "/"})
+	})
+	router.POST("/hello/world", func(ctx *gin.Context) {
+		ctx.JSON(http.StatusCreated, gin.H{"path": "/hello/world"})
+	})
+	return router
+}
+
+func TestDetect(t *testing.T) {
+	testCases := []struct {
+		file     string
+		expected string
+	}{
+		{file: "apigw-request.json", expected: dispatcher.SourceAPIGatewayV1},
+		{file: "apigw-v2-request-no-authorizer.json", expected: dispatcher.SourceAPIGatewayV2},
+		{file: "alb-lambda-target-request-headers-only.json", expected: dispatcher.SourceALB},
+		{file: "sqs-event.json", expected: dispatcher.SourceSQS},
+		{file: "scheduled-event.json", expected: dispatcher.SourceSchedule},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.file, func(t *testing.T) {
+			source, err := dispatcher.Detect(readEvent(t, tc.file))
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, source)
+		})
+	}
+
+	source, err := dispatcher.Detect([]byte(`{"job":"retry-transactions"}`))
+	assert.NoError(t, err)
+	assert.Equal(t, dispatcher.SourceDirect, source)
+
+	_, err = dispatcher.Detect([]byte(`{"hello":"world"}`))
+	assert.ErrorIs(t, err, dispatcher.ErrUnknownEvent)
+	_, err = dispatcher.Detect([]byte(`not json`))
+	assert.ErrorIs(t, err, dispatcher.ErrUnknownEvent)
+}
+
+func TestInvoke_APIGatewayV1(t *testing.T) {
+	d := dispatcher.New(setupRouter())
+
+	out, err := d.Invoke(context.Background(), readEvent(t, "apigw-request.json"))
+	assert.NoError(t, err)
+
+	var resp events.APIGatewayProxyResponse
+	assert.NoError(t, json.Unmarshal(out, &resp))
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	assert.JSONEq(t, `{"path":"/hello/world"}`, resp.Body)
+}
+
+func TestInvoke_APIGatewayV2(t *testing.T) {
+	d := dispatcher.New(setupRouter())
+
+	out, err := d.Invoke(context.Background(), readEvent(t, "apigw-v2-request-no-authorizer.json"))
+	assert.NoError(t, err)
+
+	var resp events.APIGatewayV2HTTPResponse
+	assert.NoError(t, json.Unmarshal(out, &resp))
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.JSONEq(t, `{"path":"/"}`, resp.Body)
+}
+
+func TestInvoke_ALB(t *testing.T) {
+	d := dispatcher.New(setupRouter())
+
+	out, err := d.Invoke(context.Background(), readEvent(t, "alb-lambda-target-request-headers-only.json"))
+	assert.NoError(t, err)
+
+	var resp events.ALBTargetGroupResponse
+	assert.NoError(t, json.Unmarshal(out, &resp))
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.JSONEq(t, `{"path":"/"}`, resp.Body)
+}
+
+func TestInvoke_SQSReportsPartialFailures(t *testing.T) {
+	var event events.SQSEvent
+	assert.NoError(t, json.Unmarshal(readEvent(t, "sqs-event.json"), &event))
+	failing := event.Records[0]
+	failing.MessageId = "MessageID_2"
+	failing.Body = "poison"
+	event.Records = append(event.Records, failing)
+	payload, _ := json.Marshal(event)
+
+	var processed []string
+	d := dispatcher.New(setupRouter(), dispatcher.WithMessageHandler(func(ctx context.Context, message events.SQSMessage) error {
+		processed = append(processed, message.MessageId)
+		if message.Body == "poison" {
+			return errors.New("cannot process")
+		}
+		return nil
+	}))
+
+	out, err := d.Invoke(context.Background(), payload)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"MessageID_1", "MessageID_2"}, processed)
+
+	var resp events.SQSEventResponse
+	assert.NoError(t, json.Unmarshal(out, &resp))
+	assert.Equal(t, []events.SQSBatchItemFailure{{ItemIdentifier: "MessageID_2"}}, resp.BatchItemFailures)
+}
+
+func TestInvoke_SQSWithoutHandler(t *testing.T) {
+	d := dispatcher.New(setupRouter())
+
+	_, err := d.Invoke(context.Background(), readEvent(t, "sqs-event.json"))
+	assert.Error(t, err)
+}
+
+func TestInvoke_ScheduleRunsJobNamedAfterRule(t *testing.T) {
+	runs := 0
+	d := dispatcher.New(setupRouter(), dispatcher.WithJob("SampleRule", func(ctx context.Context) error {
+		runs++
+		return nil
+	}))
+
+	out, err := d.Invoke(context.Background(), readEvent(t, "scheduled-event.json"))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, runs)
+	assert.JSONEq(t, `{"job":"SampleRule","status":"ok"}`, string(out))
+}
+
+func TestInvoke_Direct(t *testing.T) {
+	d := dispatcher.New(setupRouter(),
+		dispatcher.WithJob("ok", func(ctx context.Context) error { return nil }),
+		dispatcher.WithJob("broken", func(ctx context.Context) error { return errors.New("boom") }),
+	)
+
+	out, err := d.Invoke(context.Background(), []byte(`{"job":"ok"}`))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"job":"ok","status":"ok"}`, string(out))
+
+	_, err = d.Invoke(context.Background(), []byte(`{"job":"broken"}`))
+	assert.EqualError(t, err, "boom")
+
+	_, err = d.Invoke(context.Background(), []byte(`{"job":"missing"}`))
+	assert.EqualError(t, err, `unknown job "missing"`)
+}
+
+func TestInvoke_Unknown(t *testing.T) {
+	d := dispatcher.New(setupRouter())
+
+	_, err := d.Invoke(context.Background(), []byte(`{"hello":"world"}`))
+	assert.ErrorIs(t, err, dispatcher.ErrUnknownEvent)
+}
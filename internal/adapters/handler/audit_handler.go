@@ -0,0 +1,36 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/datphamcode295/go-lambda-pulumi/internal/core/domain"
+	"github.com/gin-gonic/gin"
+)
+
+// ListAuditEntries lists the audit log in sequence order, filtered by the query parameters
+func (h *PatientHandler) ListAuditEntries(ctx *gin.Context) {
+	var filter domain.AuditFilter
+	if err := ctx.ShouldBindQuery(&filter); err != nil {
+		HandleError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	rs, err := h.svc.ListAuditEntries(ctx.Request.Context(), filter)
+	if err != nil {
+		HandleError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, auditEntriesV1(rs))
+}
+
+// VerifyAuditLog checks the hash chain of the audit log, a broken chain still answers 200
+func (h *PatientHandler) VerifyAuditLog(ctx *gin.Context) {
+	rs, err := h.svc.VerifyAuditLog(ctx.Request.Context())
+	if err != nil {
+		HandleError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, auditVerificationV1(rs))
+}
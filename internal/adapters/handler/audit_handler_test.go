@@ -0,0 +1,64 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/datphamcode295/go-lambda-pulumi/internal/core/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPatientHandler_ListAuditEntries(t *testing.T) {
+	from := time.Date(2024, time.June, 1, 0, 0, 0, 0, time.UTC)
+	filter := domain.AuditFilter{
+		Actor:         "user-42",
+		Action:        domain.AuditActionUpdate,
+		EntityType:    domain.AuditEntityTransaction,
+		From:          &from,
+		AfterSequence: 10,
+		Limit:         50,
+	}
+
+	mockService := &MockPatientService{}
+	mockService.On("ListAuditEntries", filter).Return([]domain.AuditEntry{{Sequence: 11, Actor: "user-42"}}, nil)
+	handler := NewPatientHandler(mockService)
+	router := setupTestRouter()
+	router.GET("/admin/audit", handler.ListAuditEntries)
+
+	get := func(query string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(http.MethodGet, "/admin/audit?"+query, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get("actor=user-42&action=update&entity_type=transaction&from=2024-06-01T00:00:00Z&after_sequence=10&limit=50")
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var entries []domain.AuditEntry
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &entries))
+	assert.Len(t, entries, 1)
+
+	assert.Equal(t, http.StatusBadRequest, get("action=delete").Code)
+	assert.Equal(t, http.StatusBadRequest, get("from=yesterday").Code)
+	assert.Equal(t, http.StatusBadRequest, get("limit=5000").Code)
+	mockService.AssertExpectations(t)
+}
+
+func TestPatientHandler_VerifyAuditLog(t *testing.T) {
+	brokenAt := int64(3)
+	mockService := &MockPatientService{}
+	mockService.On("VerifyAuditLog").Return(&domain.AuditVerification{Verified: 2, BrokenAt: &brokenAt, Reason: "hash does not match its content"}, nil)
+	handler := NewPatientHandler(mockService)
+	router := setupTestRouter()
+	router.GET("/admin/audit/verify", handler.VerifyAuditLog)
+
+	req, _ := http.NewRequest(http.MethodGet, "/admin/audit/verify", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"valid":false,"verified":2,"broken_at":3,"reason":"hash does not match its content"}`, w.Body.String())
+}
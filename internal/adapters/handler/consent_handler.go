@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/datphamcode295/go-lambda-pulumi/internal/core/domain"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// RecordConsent records a guardian's consent for the patient and answers 201 with it
+func (h *PatientHandler) RecordConsent(ctx *gin.Context) {
+	patientID, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		HandleError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	var data domain.RecordConsentRequest
+	if err := ctx.ShouldBindJSON(&data); err != nil {
+		HandleError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	rs, err := h.svc.RecordConsent(ctx.Request.Context(), patientID, data)
+	if err != nil {
+		HandleError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, consentV1(rs))
+}
+
+// RevokeConsent revokes a consent of the patient
+func (h *PatientHandler) RevokeConsent(ctx *gin.Context) {
+	patientID, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		HandleError(ctx, http.StatusBadRequest, err)
+		return
+	}
+	consentID, err := uuid.Parse(ctx.Param("consentId"))
+	if err != nil {
+		HandleError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	var data domain.RevokeConsentRequest
+	if err := ctx.ShouldBindJSON(&data); err != nil {
+		HandleError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	rs, err := h.svc.RevokeConsent(ctx.Request.Context(), patientID, consentID, data)
+	if errors.Is(err, domain.ErrConsentNotFound) {
+		HandleError(ctx, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		HandleError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, consentV1(rs))
+}
+
+// ListConsents returns the consents of the patient, revoked and expired ones included
+func (h *PatientHandler) ListConsents(ctx *gin.Context) {
+	patientID, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		HandleError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	rs, err := h.svc.ListConsents(ctx.Request.Context(), patientID)
+	if err != nil {
+		HandleError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, consentsV1(rs))
+}
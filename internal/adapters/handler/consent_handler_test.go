@@ -0,0 +1,144 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/datphamcode295/go-lambda-pulumi/internal/core/domain"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupConsentRouter(mockService *MockPatientService) http.Handler {
+	handler := NewPatientHandler(mockService)
+	router := setupTestRouter()
+	router.GET("/patients/:id/consents", handler.ListConsents)
+	router.POST("/patients/:id/consents", handler.RecordConsent)
+	router.POST("/patients/:id/consents/:consentId/revoke", handler.RevokeConsent)
+	return router
+}
+
+func TestPatientHandler_RecordConsent(t *testing.T) {
+	patientID := uuid.New()
+	request := domain.RecordConsentRequest{
+		GuardianContact:   "guardian@example.com",
+		Relationship:      "parent",
+		EvidenceReference: "s3://consents/form-1.pdf",
+	}
+
+	testCases := []struct {
+		name       string
+		path       string
+		body       interface{}
+		setupMock  func(*MockPatientService)
+		expectCode int
+	}{
+		{
+			name: "Recorded",
+			path: "/patients/" + patientID.String() + "/consents",
+			body: request,
+			setupMock: func(m *MockPatientService) {
+				m.On("RecordConsent", patientID, request).Return(&domain.GuardianConsent{ID: uuid.New(), PatientID: patientID, GrantedAt: time.Now()}, nil)
+			},
+			expectCode: http.StatusCreated,
+		},
+		{
+			name:       "Guardian missing",
+			path:       "/patients/" + patientID.String() + "/consents",
+			body:       domain.RecordConsentRequest{Relationship: "parent", EvidenceReference: "form-1"},
+			expectCode: http.StatusBadRequest,
+		},
+		{
+			name:       "Evidence missing",
+			path:       "/patients/" + patientID.String() + "/consents",
+			body:       domain.RecordConsentRequest{GuardianUserID: "user-1", Relationship: "parent"},
+			expectCode: http.StatusBadRequest,
+		},
+		{
+			name:       "Invalid patient id",
+			path:       "/patients/not-a-uuid/consents",
+			body:       request,
+			expectCode: http.StatusBadRequest,
+		},
+		{
+			name: "Patient not found",
+			path: "/patients/" + patientID.String() + "/consents",
+			body: request,
+			setupMock: func(m *MockPatientService) {
+				m.On("RecordConsent", patientID, request).Return(nil, errors.New("patient not found"))
+			},
+			expectCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockService := &MockPatientService{}
+			if tc.setupMock != nil {
+				tc.setupMock(mockService)
+			}
+			router := setupConsentRouter(mockService)
+
+			body, _ := json.Marshal(tc.body)
+			req, _ := http.NewRequest(http.MethodPost, tc.path, bytes.NewBuffer(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.expectCode, w.Code, w.Body.String())
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestPatientHandler_RevokeConsent(t *testing.T) {
+	patientID := uuid.New()
+	consentID := uuid.New()
+	request := domain.RevokeConsentRequest{Reason: "guardian withdrew"}
+	revokedAt := time.Now()
+
+	mockService := &MockPatientService{}
+	mockService.On("RevokeConsent", patientID, consentID, request).Return(&domain.GuardianConsent{ID: consentID, RevokedAt: &revokedAt}, nil)
+	mockService.On("RevokeConsent", patientID, uuid.Nil, request).Return(nil, domain.ErrConsentNotFound)
+	router := setupConsentRouter(mockService)
+
+	revoke := func(consentID string, body interface{}) *httptest.ResponseRecorder {
+		data, _ := json.Marshal(body)
+		req, _ := http.NewRequest(http.MethodPost, "/patients/"+patientID.String()+"/consents/"+consentID+"/revoke", bytes.NewBuffer(data))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := revoke(consentID.String(), request)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var consent domain.GuardianConsent
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &consent))
+	assert.NotNil(t, consent.RevokedAt)
+
+	assert.Equal(t, http.StatusNotFound, revoke(uuid.Nil.String(), request).Code)
+	assert.Equal(t, http.StatusBadRequest, revoke(consentID.String(), domain.RevokeConsentRequest{}).Code)
+	assert.Equal(t, http.StatusBadRequest, revoke("not-a-uuid", request).Code)
+}
+
+func TestPatientHandler_ListConsents(t *testing.T) {
+	patientID := uuid.New()
+	mockService := &MockPatientService{}
+	mockService.On("ListConsents", patientID).Return([]domain.GuardianConsent{{ID: uuid.New(), PatientID: patientID}}, nil)
+	router := setupConsentRouter(mockService)
+
+	req, _ := http.NewRequest(http.MethodGet, "/patients/"+patientID.String()+"/consents", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var consents []domain.GuardianConsent
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &consents))
+	assert.Len(t, consents, 1)
+}
@@ -1,13 +1,27 @@
 package handler
 
 import (
+	"errors"
 	"fmt"
+	"net/http"
 	"strings"
 
+	"github.com/datphamcode295/go-lambda-pulumi/internal/core/domain"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/logger"
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
 )
 
+// ErrorResponse is the body of error answers
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
+
+// ValidationErrorResponse is the body of answers to requests failing validation
+type ValidationErrorResponse struct {
+	Errors []ValidationError `json:"errors"`
+}
+
 type ValidationError struct {
 	Field   string `json:"field"`
 	Message string `json:"message"`
@@ -44,8 +58,10 @@ func getErrorMsg(fe validator.FieldError) string {
 	switch fe.Tag() {
 	case "required":
 		return "This field is required"
-	case "ddmmyyyy":
-		return "Date must be in DD-MM-YYYY format"
+	case "date":
+		return "Date must be a valid date in a format accepted from the client"
+	case "recordtype":
+		return "Record type must be one of " + strings.Join(domain.RecordTypeNames(), ", ")
 	default:
 		return fmt.Sprintf("Validation failed on %s", fe.Tag())
 	}
@@ -54,13 +70,29 @@ func getErrorMsg(fe validator.FieldError) string {
 func HandleError(ctx *gin.Context, statusCode int, err error) {
 	// check if it is a validation error
 	if validationErrors, ok := err.(validator.ValidationErrors); ok {
-		ctx.JSON(statusCode, gin.H{
-			"errors": formatValidationErrors(validationErrors),
-		})
+		ctx.JSON(statusCode, ValidationErrorResponse{Errors: formatValidationErrors(validationErrors)})
 		return
 	}
 
-	ctx.JSON(statusCode, gin.H{
-		"error": err.Error(),
-	})
+	ctx.JSON(statusCode, ErrorResponse{Error: err.Error()})
+}
+
+// internalErrorMessage answers failures of the service, their detail is only logged
+const internalErrorMessage = "internal server error"
+
+// HandleServiceError answers an error returned by a service. Invalid requests and requests
+// naming an unknown patient are answered 400, unknown transactions and consents 404. Anything
+// else is a failure of the service answered 500 without its detail, which may name hosts,
+// keys or queues. Eligibility and consent rejections are failed transactions, not errors.
+func HandleServiceError(ctx *gin.Context, err error) {
+	var validationErrors validator.ValidationErrors
+	switch {
+	case errors.As(err, &validationErrors), errors.Is(err, domain.ErrPatientNotFound):
+		HandleError(ctx, http.StatusBadRequest, err)
+	case errors.Is(err, domain.ErrTransactionNotFound), errors.Is(err, domain.ErrConsentNotFound):
+		HandleError(ctx, http.StatusNotFound, err)
+	default:
+		logger.FromContext(ctx.Request.Context()).WithError(err).Error("Request failed")
+		ctx.JSON(http.StatusInternalServerError, ErrorResponse{Error: internalErrorMessage})
+	}
 }
@@ -23,13 +23,13 @@ type TestStruct struct {
 	EmailField    string `json:"email_field" validate:"email"`
 	MinField      string `json:"min_field" validate:"min=5"`
 	MaxField      string `json:"max_field" validate:"max=10"`
-	DateField     string `json:"date_field" validate:"ddmmyyyy"`
 }
 
 func setupValidatorForTests() {
 	// Register custom validator like in main.go
 	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
-		v.RegisterValidation("ddmmyyyy", util.ValidateDDMMYYYY)
+		v.RegisterValidation("recordtype", util.ValidateRecordType)
+		v.RegisterValidation("date", util.ValidateDate)
 	}
 }
 
@@ -101,9 +101,9 @@ func TestGetErrorMsg(t *testing.T) {
 		},
 		{
 			name:        "Date format error",
-			tag:         "ddmmyyyy",
+			tag:         "date",
 			param:       "",
-			expected:    "Date must be in DD-MM-YYYY format",
+			expected:    "Date must be a valid date in a format accepted from the client",
 			description: "Should return date format message",
 		},
 		{
@@ -154,7 +154,6 @@ func TestFormatValidationErrors(t *testing.T) {
 	// Setup validator with custom validation
 	setupValidatorForTests()
 	validate := validator.New()
-	validate.RegisterValidation("ddmmyyyy", util.ValidateDDMMYYYY)
 
 	t.Run("Valid validation errors", func(t *testing.T) {
 		// Create a test struct with validation errors
@@ -204,9 +203,7 @@ func TestHandleError_ValidationErrors(t *testing.T) {
 
 		// Create validation error using domain struct
 		validate := validator.New()
-		validate.RegisterValidation("ddmmyyyy", util.ValidateDDMMYYYY)
 
-		// Use a simpler struct without the ddmmyyyy validation
 		type SimpleTestStruct struct {
 			RequiredField string `json:"required_field" validate:"required"`
 		}
@@ -262,6 +259,35 @@ func TestHandleError_RegularErrors(t *testing.T) {
 	})
 }
 
+func TestHandleServiceError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name         string
+		err          error
+		expectedCode int
+		expectedBody string
+	}{
+		{"unknown patient", domain.ErrPatientNotFound, http.StatusBadRequest, "patient not found"},
+		{"unknown transaction", fmt.Errorf("get transaction: %w", domain.ErrTransactionNotFound), http.StatusNotFound, "transaction not found"},
+		{"internal failure", errors.New("dial tcp 10.0.0.5:5432: connection refused"), http.StatusInternalServerError, "internal server error"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodPost, "/", nil)
+
+			HandleServiceError(c, tt.err)
+
+			assert.Equal(t, tt.expectedCode, w.Code)
+			assert.Contains(t, w.Body.String(), tt.expectedBody)
+			assert.NotContains(t, w.Body.String(), "10.0.0.5")
+		})
+	}
+}
+
 func TestHandleError_DifferentStatusCodes(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
@@ -312,15 +338,12 @@ func TestHandleError_WithDomainValidation(t *testing.T) {
 
 		// Create validation error using actual domain struct
 		validate := validator.New()
-		validate.RegisterValidation("ddmmyyyy", util.ValidateDDMMYYYY)
 
 		testData := domain.PayTransactionRequest{
 			// Missing required fields to ensure validation errors
 			// PatientID is required but not set (zero value)
-			// DateOfBirth is required but invalid
 			// RecordType is required but not set
-			DateOfBirth: "invalid-date-format",
-			RecordType:  "", // Empty required field
+			RecordType: "", // Empty required field
 		}
 		err := validate.Struct(testData)
 
@@ -0,0 +1,175 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambdacontext"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/buildinfo"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/metrics"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	healthStatusOK          = "ok"
+	healthStatusError       = "error"
+	healthStatusUnavailable = "unavailable"
+)
+
+// HealthCheck reports whether a dependency is usable
+type HealthCheck struct {
+	Name  string
+	Check func(ctx context.Context) error
+	// CacheTTL reuses the last result for this long, for dependencies that are slow
+	// or that must not be hit on every probe such as the external provider
+	CacheTTL time.Duration
+}
+
+type checkResult struct {
+	Status    string    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	LatencyMS int64     `json:"latency_ms"`
+	CheckedAt time.Time `json:"checked_at"`
+	Cached    bool      `json:"cached,omitempty"`
+}
+
+// HealthResponse is the answer of the liveness and readiness probes
+type HealthResponse struct {
+	Status string                 `json:"status"`
+	Checks map[string]checkResult `json:"checks,omitempty"`
+}
+
+// checkRunner runs a HealthCheck and remembers its last result
+type checkRunner struct {
+	check HealthCheck
+	now   func() time.Time
+
+	mu   sync.Mutex
+	last *checkResult
+}
+
+func (c *checkRunner) run(ctx context.Context, timeout time.Duration) checkResult {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.last != nil && c.now().Sub(c.last.CheckedAt) < c.check.CacheTTL {
+		cached := *c.last
+		cached.Cached = true
+		return cached
+	}
+
+	checkCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := c.now()
+	err := c.check.Check(checkCtx)
+	result := checkResult{
+		Status:    healthStatusOK,
+		LatencyMS: c.now().Sub(start).Milliseconds(),
+		CheckedAt: start,
+	}
+	if err != nil {
+		result.Status = healthStatusError
+		result.Error = err.Error()
+	}
+	c.last = &result
+	return result
+}
+
+// Diagnostics describes the running instance for the admin diagnostics endpoint
+type Diagnostics struct {
+	ConfigSource string
+	DBStats      func() sql.DBStats
+}
+
+type HealthHandler struct {
+	checks      []*checkRunner
+	timeout     time.Duration
+	diagnostics Diagnostics
+}
+
+func NewHealthHandler(checks []HealthCheck, timeout time.Duration, diagnostics Diagnostics) *HealthHandler {
+	runners := make([]*checkRunner, 0, len(checks))
+	for _, check := range checks {
+		runners = append(runners, &checkRunner{check: check, now: time.Now})
+	}
+	return &HealthHandler{
+		checks:      runners,
+		timeout:     timeout,
+		diagnostics: diagnostics,
+	}
+}
+
+// Liveness only tells that the process is able to serve requests
+func (h *HealthHandler) Liveness(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, HealthResponse{Status: healthStatusOK})
+}
+
+// Readiness runs every dependency check and answers 503 if any of them fails. The probe is
+// public, so errors are left out: they may name hosts or users, see Diagnostics.
+func (h *HealthHandler) Readiness(ctx *gin.Context) {
+	results := h.runChecks(ctx.Request.Context())
+
+	status, code := healthStatusOK, http.StatusOK
+	for name, result := range results {
+		if result.Status != healthStatusOK {
+			status, code = healthStatusUnavailable, http.StatusServiceUnavailable
+		}
+		result.Error = ""
+		results[name] = result
+	}
+
+	ctx.JSON(code, HealthResponse{Status: status, Checks: results})
+}
+
+// Diagnostics reports build, runtime and connection pool details
+func (h *HealthHandler) Diagnostics(ctx *gin.Context) {
+	body := gin.H{
+		"build":         buildinfo.Get(),
+		"function":      lambdacontext.FunctionName,
+		"cold_start_at": metrics.StartedAt(),
+		"uptime_ms":     time.Since(metrics.StartedAt()).Milliseconds(),
+		"config_source": h.diagnostics.ConfigSource,
+		"checks":        h.runChecks(ctx.Request.Context()),
+	}
+	if h.diagnostics.DBStats != nil {
+		stats := h.diagnostics.DBStats()
+		body["db_pool"] = gin.H{
+			"max_open_connections": stats.MaxOpenConnections,
+			"open_connections":     stats.OpenConnections,
+			"in_use":               stats.InUse,
+			"idle":                 stats.Idle,
+			"wait_count":           stats.WaitCount,
+			"wait_duration_ms":     stats.WaitDuration.Milliseconds(),
+			"max_idle_closed":      stats.MaxIdleClosed,
+			"max_lifetime_closed":  stats.MaxLifetimeClosed,
+		}
+	}
+
+	ctx.JSON(http.StatusOK, body)
+}
+
+// runChecks runs the checks concurrently so readiness is as slow as the slowest dependency
+func (h *HealthHandler) runChecks(ctx context.Context) map[string]checkResult {
+	results := make(map[string]checkResult, len(h.checks))
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	for _, runner := range h.checks {
+		wg.Add(1)
+		go func(runner *checkRunner) {
+			defer wg.Done()
+			result := runner.run(ctx, h.timeout)
+
+			mu.Lock()
+			results[runner.check.Name] = result
+			mu.Unlock()
+		}(runner)
+	}
+	wg.Wait()
+	return results
+}
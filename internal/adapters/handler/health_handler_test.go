@@ -0,0 +1,161 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupHealthRouter(checks []HealthCheck) *gin.Engine {
+	healthHandler := NewHealthHandler(checks, time.Second, Diagnostics{
+		ConfigSource: "test",
+		DBStats: func() sql.DBStats {
+			return sql.DBStats{MaxOpenConnections: 2, OpenConnections: 1, Idle: 1}
+		},
+	})
+
+	router := setupTestRouter()
+	router.GET("/healthz", healthHandler.Liveness)
+	router.GET("/readyz", healthHandler.Readiness)
+	admin := router.Group("/admin", RequireAdminKey("admin-secret"))
+	admin.GET("/diagnostics", healthHandler.Diagnostics)
+	return router
+}
+
+func performGet(router *gin.Engine, path string, headers map[string]string) (*httptest.ResponseRecorder, map[string]interface{}) {
+	req, _ := http.NewRequest("GET", path, nil)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var body map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &body)
+	return w, body
+}
+
+func TestHealthHandler_Liveness(t *testing.T) {
+	router := setupHealthRouter([]HealthCheck{
+		{Name: "database", Check: func(ctx context.Context) error { return errors.New("down") }},
+	})
+
+	// liveness does not depend on any dependency
+	w, body := performGet(router, "/healthz", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "ok", body["status"])
+}
+
+func TestHealthHandler_Readiness(t *testing.T) {
+	testCases := []struct {
+		name           string
+		databaseErr    error
+		expectedCode   int
+		expectedStatus string
+	}{
+		{
+			name:           "All dependencies healthy",
+			expectedCode:   http.StatusOK,
+			expectedStatus: "ok",
+		},
+		{
+			name:           "Database unreachable",
+			databaseErr:    errors.New("connection refused"),
+			expectedCode:   http.StatusServiceUnavailable,
+			expectedStatus: "unavailable",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			router := setupHealthRouter([]HealthCheck{
+				{Name: "database", Check: func(ctx context.Context) error { return tc.databaseErr }},
+				{Name: "config", Check: func(ctx context.Context) error { return nil }},
+			})
+
+			w, body := performGet(router, "/readyz", nil)
+			assert.Equal(t, tc.expectedCode, w.Code)
+			assert.Equal(t, tc.expectedStatus, body["status"])
+
+			checks := body["checks"].(map[string]interface{})
+			assert.Equal(t, "ok", checks["config"].(map[string]interface{})["status"])
+			if tc.databaseErr != nil {
+				database := checks["database"].(map[string]interface{})
+				assert.Equal(t, "error", database["status"])
+				// the probe is public, the error is only reported to admins
+				assert.NotContains(t, database, "error")
+
+				_, body = performGet(router, "/admin/diagnostics", map[string]string{AdminKeyHeader: "admin-secret"})
+				database = body["checks"].(map[string]interface{})["database"].(map[string]interface{})
+				assert.Equal(t, tc.databaseErr.Error(), database["error"])
+			}
+		})
+	}
+}
+
+func TestHealthHandler_Readiness_CachesResults(t *testing.T) {
+	calls := 0
+	router := setupHealthRouter([]HealthCheck{
+		{Name: "provider", Check: func(ctx context.Context) error {
+			calls++
+			return errors.New("timeout")
+		}, CacheTTL: time.Minute},
+	})
+
+	w, _ := performGet(router, "/readyz", nil)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	w, body := performGet(router, "/readyz", nil)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, 1, calls)
+	provider := body["checks"].(map[string]interface{})["provider"].(map[string]interface{})
+	assert.Equal(t, true, provider["cached"])
+	assert.NotContains(t, provider, "error")
+}
+
+func TestHealthHandler_Readiness_CheckTimeout(t *testing.T) {
+	healthHandler := NewHealthHandler([]HealthCheck{
+		{Name: "database", Check: func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		}},
+	}, 10*time.Millisecond, Diagnostics{})
+	router := setupTestRouter()
+	router.GET("/readyz", healthHandler.Readiness)
+
+	w, _ := performGet(router, "/readyz", nil)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+}
+
+func TestHealthHandler_Diagnostics(t *testing.T) {
+	router := setupHealthRouter(nil)
+
+	w, _ := performGet(router, "/admin/diagnostics", nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w, _ = performGet(router, "/admin/diagnostics", map[string]string{AdminKeyHeader: "wrong"})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w, body := performGet(router, "/admin/diagnostics", map[string]string{AdminKeyHeader: "admin-secret"})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "test", body["config_source"])
+	assert.Contains(t, body, "build")
+	assert.Contains(t, body, "cold_start_at")
+	assert.Equal(t, float64(2), body["db_pool"].(map[string]interface{})["max_open_connections"])
+}
+
+func TestRequireAdminKey_EmptyKeyDisablesRoutes(t *testing.T) {
+	router := setupTestRouter()
+	router.GET("/admin", RequireAdminKey(""), func(ctx *gin.Context) { ctx.Status(http.StatusOK) })
+
+	w, _ := performGet(router, "/admin", map[string]string{AdminKeyHeader: ""})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
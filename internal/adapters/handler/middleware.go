@@ -0,0 +1,240 @@
+package handler
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambdacontext"
+	"github.com/awslabs/aws-lambda-go-api-proxy/core"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/logger"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/metrics"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/requestctx"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/tracing"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	RequestIDHeader = "X-Request-ID"
+	AdminKeyHeader  = "X-Admin-Key"
+	// ActorHeader names who the caller says it acts for. It is not trusted, the audit log keeps
+	// it apart from the actor.
+	ActorHeader = "X-Actor-ID"
+
+	adminActor = "admin-key"
+	// anonymousActor is the actor of requests no principal was authenticated for
+	anonymousActor = "anonymous"
+
+	maxRequestIDLength = 128
+	maxActorLength     = 128
+)
+
+// RequestLogger resolves the correlation id of the request, attaches a logger tagged
+// with it to the request context and echoes the id back in the X-Request-ID header.
+// The actor, claimed actor and source IP of the request are kept in the context for
+// the audit log. The actor is anonymous unless API Gateway authorized a principal.
+func RequestLogger() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		start := time.Now()
+		reqCtx := ctx.Request.Context()
+
+		fields := logrus.Fields{
+			"method": ctx.Request.Method,
+			"route":  ctx.FullPath(),
+		}
+		if apiGatewayID := apiGatewayRequestID(reqCtx); apiGatewayID != "" {
+			fields["apigw_request_id"] = apiGatewayID
+		}
+		if lambdaID := lambdaRequestID(reqCtx); lambdaID != "" {
+			fields["aws_request_id"] = lambdaID
+		}
+
+		requestID := resolveRequestID(reqCtx, ctx.GetHeader(RequestIDHeader))
+		fields["request_id"] = requestID
+
+		reqCtx = requestctx.WithRequestID(reqCtx, requestID)
+		actor := authorizedPrincipal(reqCtx)
+		if actor == "" {
+			actor = anonymousActor
+		}
+		reqCtx = requestctx.WithActor(reqCtx, actor)
+		if claimed := ctx.GetHeader(ActorHeader); claimed != "" && len(claimed) <= maxActorLength {
+			reqCtx = requestctx.WithClaimedActor(reqCtx, claimed)
+		}
+		reqCtx = requestctx.WithSourceIP(reqCtx, resolveSourceIP(reqCtx, ctx.RemoteIP()))
+		reqCtx = logger.WithContext(reqCtx, logger.Log.WithFields(fields))
+		ctx.Request = ctx.Request.WithContext(reqCtx)
+		ctx.Header(RequestIDHeader, requestID)
+
+		ctx.Next()
+
+		logger.FromContext(ctx.Request.Context()).WithFields(logrus.Fields{
+			"status":     ctx.Writer.Status(),
+			"latency_ms": time.Since(start).Milliseconds(),
+		}).Info("Request completed")
+	}
+}
+
+// RequestMetrics records the latency of every request by route, method and status code
+func RequestMetrics(recorder metrics.Recorder) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		start := time.Now()
+		ctx.Next()
+
+		route := ctx.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		metrics.ObserveDuration(recorder, metrics.HandlerLatency, time.Since(start), metrics.Dimensions{
+			"route":  route,
+			"method": ctx.Request.Method,
+			"status": strconv.Itoa(ctx.Writer.Status()),
+		})
+	}
+}
+
+// Tracing continues the trace from incoming traceparent or X-Amzn-Trace-Id headers and wraps
+// the request in a server span. It must run after RequestLogger so logs carry the trace id.
+func Tracing() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		reqCtx := tracing.Propagator().Extract(ctx.Request.Context(), propagation.HeaderCarrier(ctx.Request.Header))
+
+		route := ctx.FullPath()
+		spanName := ctx.Request.Method + " " + route
+		if route == "" {
+			spanName = ctx.Request.Method
+		}
+		reqCtx, span := tracing.Tracer().Start(reqCtx, spanName,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", ctx.Request.Method),
+				attribute.String("http.route", route),
+				attribute.String("url.path", ctx.Request.URL.Path),
+				attribute.String("request.id", requestctx.RequestID(reqCtx)),
+			),
+		)
+		defer span.End()
+
+		if sc := span.SpanContext(); sc.IsValid() {
+			reqCtx = logger.WithFields(reqCtx, logrus.Fields{"trace_id": sc.TraceID().String()})
+		}
+		ctx.Request = ctx.Request.WithContext(reqCtx)
+
+		ctx.Next()
+
+		status := ctx.Writer.Status()
+		span.SetAttributes(attribute.Int("http.response.status_code", status))
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+	}
+}
+
+// RequireAdminKey rejects requests that don't present the admin key. An empty key
+// disables the guarded routes entirely rather than leaving them open. Requests without a
+// principal authorized by API Gateway act as admin-key.
+func RequireAdminKey(adminKey string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		provided := ctx.GetHeader(AdminKeyHeader)
+		if adminKey == "" || subtle.ConstantTimeCompare([]byte(provided), []byte(adminKey)) != 1 {
+			logger.FromContext(ctx.Request.Context()).Warn("Rejected admin request")
+			ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error": "forbidden",
+			})
+			return
+		}
+		if actor := requestctx.Actor(ctx.Request.Context()); actor == "" || actor == anonymousActor {
+			ctx.Request = ctx.Request.WithContext(requestctx.WithActor(ctx.Request.Context(), adminActor))
+		}
+		ctx.Next()
+	}
+}
+
+// RequirePrincipal rejects requests that were neither authorized by API Gateway nor present
+// the admin key, so the actor of the request is a principal that was authenticated
+func RequirePrincipal(adminKey string) gin.HandlerFunc {
+	requireAdminKey := RequireAdminKey(adminKey)
+	return func(ctx *gin.Context) {
+		principal := authorizedPrincipal(ctx.Request.Context())
+		if principal == "" {
+			requireAdminKey(ctx)
+			return
+		}
+		ctx.Request = ctx.Request.WithContext(requestctx.WithActor(ctx.Request.Context(), principal))
+		ctx.Next()
+	}
+}
+
+// resolveRequestID prefers an id forwarded by the caller, then the API Gateway
+// request id, then the Lambda invocation id, and generates one as a last resort
+func resolveRequestID(ctx context.Context, header string) string {
+	if header != "" && len(header) <= maxRequestIDLength {
+		return header
+	}
+	if id := apiGatewayRequestID(ctx); id != "" {
+		return id
+	}
+	if id := lambdaRequestID(ctx); id != "" {
+		return id
+	}
+	return uuid.New().String()
+}
+
+// resolveSourceIP prefers the source IP seen by API Gateway over the address of the connection.
+// Forwarding headers are ignored, clients can set them to anything.
+func resolveSourceIP(ctx context.Context, remoteIP string) string {
+	if apiGwCtx, ok := core.GetAPIGatewayV2ContextFromContext(ctx); ok && apiGwCtx.HTTP.SourceIP != "" {
+		return apiGwCtx.HTTP.SourceIP
+	}
+	if apiGwCtx, ok := core.GetAPIGatewayContextFromContext(ctx); ok && apiGwCtx.Identity.SourceIP != "" {
+		return apiGwCtx.Identity.SourceIP
+	}
+	return remoteIP
+}
+
+// authorizedPrincipal is the principal authorized by API Gateway: the JWT sub claim, the IAM
+// user ARN or the principalId of a Lambda authorizer, empty when none authorized the request
+func authorizedPrincipal(ctx context.Context) string {
+	if apiGwCtx, ok := core.GetAPIGatewayV2ContextFromContext(ctx); ok && apiGwCtx.Authorizer != nil {
+		if jwt := apiGwCtx.Authorizer.JWT; jwt != nil && jwt.Claims["sub"] != "" {
+			return jwt.Claims["sub"]
+		}
+		if iam := apiGwCtx.Authorizer.IAM; iam != nil && iam.UserARN != "" {
+			return iam.UserARN
+		}
+	}
+	if apiGwCtx, ok := core.GetAPIGatewayContextFromContext(ctx); ok {
+		if principal, _ := apiGwCtx.Authorizer["principalId"].(string); principal != "" {
+			return principal
+		}
+	}
+	return ""
+}
+
+func apiGatewayRequestID(ctx context.Context) string {
+	if apiGwCtx, ok := core.GetAPIGatewayV2ContextFromContext(ctx); ok {
+		return apiGwCtx.RequestID
+	}
+	if apiGwCtx, ok := core.GetAPIGatewayContextFromContext(ctx); ok {
+		return apiGwCtx.RequestID
+	}
+	return ""
+}
+
+func lambdaRequestID(ctx context.Context) string {
+	if lc, ok := lambdacontext.FromContext(ctx); ok {
+		return lc.AwsRequestID
+	}
+	if lc, ok := core.GetRuntimeContextFromContextV2(ctx); ok && lc != nil {
+		return lc.AwsRequestID
+	}
+	return ""
+}
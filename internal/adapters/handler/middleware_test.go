@@ -0,0 +1,285 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambdacontext"
+	"github.com/awslabs/aws-lambda-go-api-proxy/core"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/logger"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/metrics"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/requestctx"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func setupRequestLoggerRouter(captured *context.Context) *gin.Engine {
+	router := setupTestRouter()
+	router.Use(RequestLogger())
+	router.GET("/ping", func(ctx *gin.Context) {
+		*captured = ctx.Request.Context()
+		ctx.Status(http.StatusOK)
+	})
+	return router
+}
+
+func TestRequestLogger_UsesIncomingHeader(t *testing.T) {
+	var reqCtx context.Context
+	router := setupRequestLoggerRouter(&reqCtx)
+
+	req, _ := http.NewRequest("GET", "/ping", nil)
+	req.Header.Set(RequestIDHeader, "caller-request-id")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "caller-request-id", w.Header().Get(RequestIDHeader))
+	assert.Equal(t, "caller-request-id", requestctx.RequestID(reqCtx))
+
+	entry := logger.FromContext(reqCtx)
+	assert.Equal(t, "caller-request-id", entry.Data["request_id"])
+	assert.Equal(t, "/ping", entry.Data["route"])
+}
+
+func TestRequestLogger_UsesLambdaRequestID(t *testing.T) {
+	var reqCtx context.Context
+	router := setupRequestLoggerRouter(&reqCtx)
+
+	lambdaCtx := lambdacontext.NewContext(context.Background(), &lambdacontext.LambdaContext{AwsRequestID: "lambda-request-id"})
+	req, _ := http.NewRequestWithContext(lambdaCtx, "GET", "/ping", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, "lambda-request-id", w.Header().Get(RequestIDHeader))
+	assert.Equal(t, "lambda-request-id", logger.FromContext(reqCtx).Data["aws_request_id"])
+}
+
+func TestRequestLogger_GeneratesRequestID(t *testing.T) {
+	var reqCtx context.Context
+	router := setupRequestLoggerRouter(&reqCtx)
+
+	req, _ := http.NewRequest("GET", "/ping", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	requestID := w.Header().Get(RequestIDHeader)
+	_, err := uuid.Parse(requestID)
+	assert.NoError(t, err)
+	assert.Equal(t, requestID, requestctx.RequestID(reqCtx))
+}
+
+func TestRequestLogger_ActorAndSourceIP(t *testing.T) {
+	var reqCtx context.Context
+	router := setupRequestLoggerRouter(&reqCtx)
+
+	req, _ := http.NewRequest("GET", "/ping", nil)
+	req.RemoteAddr = "203.0.113.7:52100"
+	req.Header.Set(ActorHeader, "user-42")
+	req.Header.Set("X-Forwarded-For", "198.51.100.1")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// the claimed actor and forwarded address are not trusted
+	assert.Equal(t, "anonymous", requestctx.Actor(reqCtx))
+	assert.Equal(t, "user-42", requestctx.ClaimedActor(reqCtx))
+	assert.Equal(t, "203.0.113.7", requestctx.SourceIP(reqCtx))
+
+	req = apiGatewayRequest(t, "GET", "/ping", events.APIGatewayV2HTTPRequestContext{
+		HTTP: events.APIGatewayV2HTTPRequestContextHTTPDescription{SourceIP: "192.0.2.10"},
+		Authorizer: &events.APIGatewayV2HTTPRequestContextAuthorizerDescription{
+			IAM: &events.APIGatewayV2HTTPRequestContextAuthorizerIAMDescription{UserARN: "arn:aws:iam::123456789012:user/ops"},
+		},
+	})
+	req.Header.Set(ActorHeader, "user-42")
+	req.Header.Set("X-Forwarded-For", "198.51.100.1")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.Equal(t, "arn:aws:iam::123456789012:user/ops", requestctx.Actor(reqCtx))
+	assert.Equal(t, "user-42", requestctx.ClaimedActor(reqCtx))
+	assert.Equal(t, "192.0.2.10", requestctx.SourceIP(reqCtx))
+}
+
+func TestRequireAdminKey_DefaultsActor(t *testing.T) {
+	var reqCtx context.Context
+	router := setupTestRouter()
+	router.Use(RequestLogger())
+	router.GET("/admin", RequireAdminKey("secret"), func(ctx *gin.Context) {
+		reqCtx = ctx.Request.Context()
+		ctx.Status(http.StatusOK)
+	})
+
+	req, _ := http.NewRequest("GET", "/admin", nil)
+	req.Header.Set(AdminKeyHeader, "secret")
+	req.Header.Set(ActorHeader, "user-42")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "admin-key", requestctx.Actor(reqCtx))
+}
+
+func TestLoggerFromContext_AddsFields(t *testing.T) {
+	ctx := requestctx.WithRequestID(context.Background(), "abc")
+	assert.Equal(t, "abc", logger.FromContext(ctx).Data["request_id"])
+
+	ctx = logger.WithFields(ctx, map[string]interface{}{"patient_id": "p-1"})
+	entry := logger.FromContext(ctx)
+	assert.Equal(t, "abc", entry.Data["request_id"])
+	assert.Equal(t, "p-1", entry.Data["patient_id"])
+}
+
+func TestRequestMetrics_RecordsLatency(t *testing.T) {
+	recorder := metrics.NewMemoryRecorder()
+	router := setupTestRouter()
+	router.Use(RequestMetrics(recorder))
+	router.GET("/ping", func(ctx *gin.Context) {
+		ctx.Status(http.StatusTeapot)
+	})
+
+	req, _ := http.NewRequest("GET", "/ping", nil)
+	router.ServeHTTP(httptest.NewRecorder(), req)
+	req, _ = http.NewRequest("GET", "/missing", nil)
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.Len(t, recorder.Observations(metrics.HandlerLatency, metrics.Dimensions{"route": "/ping", "status": "418"}), 1)
+	assert.Len(t, recorder.Observations(metrics.HandlerLatency, metrics.Dimensions{"route": "unmatched", "status": "404"}), 1)
+}
+
+func setupTracingRouter() (*gin.Engine, *tracetest.InMemoryExporter) {
+	exporter := tracetest.NewInMemoryExporter()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+
+	router := setupTestRouter()
+	router.Use(RequestLogger(), Tracing())
+	router.GET("/patients/:id", func(ctx *gin.Context) {
+		ctx.Status(http.StatusInternalServerError)
+	})
+	return router, exporter
+}
+
+func TestTracing_ContinuesTraceparent(t *testing.T) {
+	router, exporter := setupTracingRouter()
+
+	req, _ := http.NewRequest("GET", "/patients/1", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := exporter.GetSpans()
+	assert.Len(t, spans, 1)
+	assert.Equal(t, "GET /patients/:id", spans[0].Name)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", spans[0].SpanContext.TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", spans[0].Parent.SpanID().String())
+	assert.Equal(t, "Error", spans[0].Status.Code.String())
+}
+
+func TestTracing_ContinuesXRayTraceHeader(t *testing.T) {
+	router, exporter := setupTracingRouter()
+
+	req, _ := http.NewRequest("GET", "/patients/1", nil)
+	req.Header.Set("X-Amzn-Trace-Id", "Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8;Sampled=1")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := exporter.GetSpans()
+	assert.Len(t, spans, 1)
+	assert.Equal(t, "5759e988bd862e3fe1be46a994272793", spans[0].SpanContext.TraceID().String())
+}
+
+func TestVersioned(t *testing.T) {
+	deprecatedAt := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	sunsetAt := time.Date(2027, 6, 30, 0, 0, 0, 0, time.UTC)
+	router := setupTestRouter()
+	ok := func(ctx *gin.Context) { ctx.Status(http.StatusOK) }
+	router.GET("/app/v1/transactions/:id", Versioned(APIVersion{Name: "v1", DeprecatedAt: &deprecatedAt, SunsetAt: &sunsetAt, Successor: "v2"}), ok)
+	router.GET("/app/transactions/:id", Versioned(APIVersion{Name: "v1", DeprecatedAt: &deprecatedAt, Successor: "v2"}), ok)
+	router.GET("/app/v2/transactions/:id", Versioned(APIVersion{Name: "v2"}), ok)
+
+	testCases := []struct {
+		path        string
+		version     string
+		deprecation string
+		sunset      string
+		link        string
+	}{
+		{
+			path:        "/app/v1/transactions/42",
+			version:     "v1",
+			deprecation: "@1767225600",
+			sunset:      "Wed, 30 Jun 2027 00:00:00 GMT",
+			link:        `</app/v2/transactions/42>; rel="successor-version"`,
+		},
+		{path: "/app/transactions/42", version: "v1", deprecation: "@1767225600"},
+		{path: "/app/v2/transactions/42", version: "v2"},
+	}
+
+	for _, tc := range testCases {
+		req, _ := http.NewRequest("GET", tc.path, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, tc.version, w.Header().Get(APIVersionHeader), tc.path)
+		assert.Equal(t, tc.deprecation, w.Header().Get("Deprecation"), tc.path)
+		assert.Equal(t, tc.sunset, w.Header().Get("Sunset"), tc.path)
+		assert.Equal(t, tc.link, w.Header().Get("Link"), tc.path)
+	}
+}
+
+func apiGatewayRequest(t *testing.T, method, path string, requestContext events.APIGatewayV2HTTPRequestContext) *http.Request {
+	requestContext.HTTP.Method = method
+	requestContext.HTTP.Path = path
+	var accessor core.RequestAccessorV2
+	req, err := accessor.EventToRequestWithContext(context.Background(), events.APIGatewayV2HTTPRequest{
+		RawPath:        path,
+		RequestContext: requestContext,
+	})
+	assert.NoError(t, err)
+	return req
+}
+
+func TestRequirePrincipal(t *testing.T) {
+	var reqCtx context.Context
+	router := setupTestRouter()
+	router.POST("/consents", RequirePrincipal("secret"), func(ctx *gin.Context) {
+		reqCtx = ctx.Request.Context()
+		ctx.Status(http.StatusCreated)
+	})
+
+	t.Run("Unauthenticated", func(t *testing.T) {
+		req, _ := http.NewRequest("POST", "/consents", nil)
+		req.Header.Set(ActorHeader, "user-42")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("Admin key", func(t *testing.T) {
+		req, _ := http.NewRequest("POST", "/consents", nil)
+		req.Header.Set(AdminKeyHeader, "secret")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Equal(t, "admin-key", requestctx.Actor(reqCtx))
+	})
+
+	t.Run("Authorized by API Gateway", func(t *testing.T) {
+		req := apiGatewayRequest(t, "POST", "/consents", events.APIGatewayV2HTTPRequestContext{
+			Authorizer: &events.APIGatewayV2HTTPRequestContextAuthorizerDescription{
+				JWT: &events.APIGatewayV2HTTPRequestContextAuthorizerJWTDescription{Claims: map[string]string{"sub": "clinician-7"}},
+			},
+		})
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Equal(t, "clinician-7", requestctx.Actor(reqCtx))
+	})
+}
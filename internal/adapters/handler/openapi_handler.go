@@ -0,0 +1,71 @@
+package handler
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	swaggerFiles "github.com/swaggo/files/v2"
+)
+
+//go:embed static/swagger-ui.html
+var swaggerUIPage []byte
+
+// swaggerUIAssets are the Swagger UI files the page loads, served from the embedded dist so
+// the page depends on no third party at runtime
+var swaggerUIAssets = map[string]string{
+	"swagger-ui.css":       "text/css; charset=utf-8",
+	"swagger-ui-bundle.js": "application/javascript; charset=utf-8",
+}
+
+// OpenAPIHandler serves the OpenAPI document of the API and a Swagger UI reading it
+type OpenAPIHandler struct {
+	document []byte
+}
+
+func NewOpenAPIHandler() *OpenAPIHandler {
+	return &OpenAPIHandler{}
+}
+
+// SetDocument sets the document served, it is built once every route is mounted
+func (h *OpenAPIHandler) SetDocument(document interface{}) error {
+	rs, err := json.Marshal(document)
+	if err != nil {
+		return err
+	}
+	h.document = rs
+	return nil
+}
+
+// Spec answers the OpenAPI document
+func (h *OpenAPIHandler) Spec(ctx *gin.Context) {
+	if h.document == nil {
+		HandleError(ctx, http.StatusServiceUnavailable, errors.New("openapi document not built"))
+		return
+	}
+	ctx.Data(http.StatusOK, "application/json; charset=utf-8", h.document)
+}
+
+// SwaggerUI answers a page rendering the document served next to it at openapi.json
+func (h *OpenAPIHandler) SwaggerUI(ctx *gin.Context) {
+	ctx.Data(http.StatusOK, "text/html; charset=utf-8", swaggerUIPage)
+}
+
+// SwaggerUIAsset answers a Swagger UI file the page loads, from docs/assets/ next to it
+func (h *OpenAPIHandler) SwaggerUIAsset(ctx *gin.Context) {
+	name := ctx.Param("file")
+	contentType, ok := swaggerUIAssets[name]
+	if !ok {
+		HandleError(ctx, http.StatusNotFound, errors.New("asset not found"))
+		return
+	}
+	asset, err := fs.ReadFile(swaggerFiles.FS, name)
+	if err != nil {
+		HandleError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+	ctx.Data(http.StatusOK, contentType, asset)
+}
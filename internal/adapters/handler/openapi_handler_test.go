@@ -0,0 +1,41 @@
+package handler
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpenAPIHandler(t *testing.T) {
+	openAPIHandler := NewOpenAPIHandler()
+	router := setupTestRouter()
+	router.GET("/openapi.json", openAPIHandler.Spec)
+	router.GET("/docs", openAPIHandler.SwaggerUI)
+	router.GET("/docs/assets/:file", openAPIHandler.SwaggerUIAsset)
+
+	w, _ := performGet(router, "/openapi.json", nil)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	assert.NoError(t, openAPIHandler.SetDocument(map[string]string{"openapi": "3.0.3"}))
+	w, body := performGet(router, "/openapi.json", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, "3.0.3", body["openapi"])
+
+	w, _ = performGet(router, "/docs", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `url: "openapi.json"`)
+	assert.NotContains(t, w.Body.String(), "https://")
+
+	// the assets the page loads are embedded
+	page := w.Body.String()
+	for _, asset := range []string{"swagger-ui.css", "swagger-ui-bundle.js"} {
+		assert.Contains(t, page, `"docs/assets/`+asset+`"`)
+		w, _ = performGet(router, "/docs/assets/"+asset, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.NotEmpty(t, w.Body.Bytes())
+	}
+	w, _ = performGet(router, "/docs/assets/index.html", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
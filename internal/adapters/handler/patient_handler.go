@@ -1,35 +1,187 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
+	"path"
 
 	"github.com/datphamcode295/go-lambda-pulumi/internal/core/domain"
-	"github.com/datphamcode295/go-lambda-pulumi/internal/core/services"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/core/ports"
+	util "github.com/datphamcode295/go-lambda-pulumi/internal/utils"
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 )
 
+// ClientIDHeader names the client whose date formats a request uses
+const ClientIDHeader = "X-Client-Id"
+
 type PatientHandler struct {
-	svc services.PatientService
+	svc   ports.PatientService
+	dates *util.DateParser
+	// providerFields are the provider response fields answered to clients
+	providerFields []string
+}
+
+// PatientHandlerOption customises a PatientHandler
+type PatientHandlerOption func(*PatientHandler)
+
+// WithDateParser sets the date formats accepted per client, util.DefaultDateFormats by default
+func WithDateParser(dates *util.DateParser) PatientHandlerOption {
+	return func(h *PatientHandler) {
+		h.dates = dates
+	}
+}
+
+// WithProviderResponseFields sets the fields of provider responses answered to clients, the
+// message and error by default. Other fields stay stored on the transaction.
+func WithProviderResponseFields(fields []string) PatientHandlerOption {
+	return func(h *PatientHandler) {
+		if len(fields) > 0 {
+			h.providerFields = fields
+		}
+	}
 }
 
-func NewPatientHandler(PatientService services.PatientService) *PatientHandler {
-	return &PatientHandler{
-		svc: PatientService,
+func NewPatientHandler(patientService ports.PatientService, opts ...PatientHandlerOption) *PatientHandler {
+	h := &PatientHandler{
+		svc:            patientService,
+		dates:          util.DefaultDateParser(),
+		providerFields: defaultProviderResponseFields,
 	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+// transactionAPI binds and answers the transaction routes in the shape of one API version
+type transactionAPI struct {
+	bind     func(h *PatientHandler, ctx *gin.Context) (domain.PayTransactionRequest, error)
+	answer   func(h *PatientHandler, t *domain.Transaction) interface{}
+	accepted func(h *PatientHandler, t *domain.Transaction, statusURL string) interface{}
+}
+
+var transactionsV1 = transactionAPI{
+	bind: (*PatientHandler).bindPayTransactionV1,
+	answer: func(h *PatientHandler, t *domain.Transaction) interface{} {
+		return transactionV1(t, h.providerFields)
+	},
+	accepted: func(h *PatientHandler, t *domain.Transaction, statusURL string) interface{} {
+		return AcceptedTransactionV1{TransactionV1: transactionV1(t, h.providerFields), StatusURL: statusURL}
+	},
+}
+
+var transactionsV2 = transactionAPI{
+	bind: (*PatientHandler).bindPayTransactionV2,
+	answer: func(h *PatientHandler, t *domain.Transaction) interface{} {
+		return transactionV2(t, h.providerFields)
+	},
+	accepted: func(h *PatientHandler, t *domain.Transaction, statusURL string) interface{} {
+		return AcceptedTransactionV2{TransactionV2: transactionV2(t, h.providerFields), StatusURL: statusURL}
+	},
 }
 
 func (h *PatientHandler) PayTransaction(ctx *gin.Context) {
-	var data domain.PayTransactionRequest
-	if err := ctx.ShouldBindJSON(&data); err != nil {
+	h.payTransaction(ctx, transactionsV1)
+}
+
+// PayTransactionV2 is PayTransaction with v2 requests and responses
+func (h *PatientHandler) PayTransactionV2(ctx *gin.Context) {
+	h.payTransaction(ctx, transactionsV2)
+}
+
+// SubmitTransaction accepts the transaction for asynchronous processing and answers 202 with
+// the URL to poll for its outcome. Transactions failing validation are answered right away.
+func (h *PatientHandler) SubmitTransaction(ctx *gin.Context) {
+	h.submitTransaction(ctx, transactionsV1)
+}
+
+// SubmitTransactionV2 is SubmitTransaction with v2 requests and responses
+func (h *PatientHandler) SubmitTransactionV2(ctx *gin.Context) {
+	h.submitTransaction(ctx, transactionsV2)
+}
+
+func (h *PatientHandler) GetTransaction(ctx *gin.Context) {
+	h.getTransaction(ctx, transactionsV1)
+}
+
+// GetTransactionV2 is GetTransaction with a v2 response
+func (h *PatientHandler) GetTransactionV2(ctx *gin.Context) {
+	h.getTransaction(ctx, transactionsV2)
+}
+
+func (h *PatientHandler) payTransaction(ctx *gin.Context, api transactionAPI) {
+	data, err := api.bind(h, ctx)
+	if err != nil {
+		HandleError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	rs, err := h.svc.PayTransaction(ctx.Request.Context(), data)
+	if err != nil {
+		HandleServiceError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, api.answer(h, rs))
+}
+
+func (h *PatientHandler) submitTransaction(ctx *gin.Context, api transactionAPI) {
+	data, err := api.bind(h, ctx)
+	if err != nil {
+		HandleError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	rs, err := h.svc.SubmitTransaction(ctx.Request.Context(), data)
+	if err != nil {
+		HandleServiceError(ctx, err)
+		return
+	}
+	if rs.Status != domain.TransactionStatusPending {
+		ctx.JSON(http.StatusOK, api.answer(h, rs))
+		return
+	}
+
+	// the transactions route is a sibling of the patients group, e.g. /app/v2/transactions/:id
+	statusURL := path.Join(path.Dir(path.Dir(ctx.FullPath())), "transactions", rs.ID.String())
+	ctx.Header("Location", statusURL)
+	ctx.JSON(http.StatusAccepted, api.accepted(h, rs, statusURL))
+}
+
+func (h *PatientHandler) getTransaction(ctx *gin.Context, api transactionAPI) {
+	id, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
 		HandleError(ctx, http.StatusBadRequest, err)
 		return
 	}
 
-	rs, err := h.svc.PayTransaction(data)
+	rs, err := h.svc.GetTransaction(ctx.Request.Context(), id)
+	if errors.Is(err, domain.ErrTransactionNotFound) {
+		HandleError(ctx, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		HandleError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, api.answer(h, rs))
+}
+
+// ListTransactionAttempts returns the provider calls made for a transaction, oldest first
+func (h *PatientHandler) ListTransactionAttempts(ctx *gin.Context) {
+	id, err := uuid.Parse(ctx.Param("id"))
 	if err != nil {
 		HandleError(ctx, http.StatusBadRequest, err)
 		return
 	}
 
-	ctx.JSON(http.StatusOK, rs)
+	rs, err := h.svc.ListTransactionAttempts(ctx.Request.Context(), id)
+	if err != nil {
+		HandleError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, transactionAttemptsV1(rs))
 }
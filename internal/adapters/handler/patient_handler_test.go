@@ -2,6 +2,7 @@ package handler
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"net/http"
@@ -18,17 +19,12 @@ import (
 	"github.com/stretchr/testify/mock"
 )
 
-// PayTransactionService interface for mocking
-type PayTransactionService interface {
-	PayTransaction(data domain.PayTransactionRequest) (*domain.Transaction, error)
-}
-
 // MockPatientService is a simple mock implementation
 type MockPatientService struct {
 	mock.Mock
 }
 
-func (m *MockPatientService) PayTransaction(data domain.PayTransactionRequest) (*domain.Transaction, error) {
+func (m *MockPatientService) PayTransaction(ctx context.Context, data domain.PayTransactionRequest) (*domain.Transaction, error) {
 	args := m.Called(data)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
@@ -36,29 +32,89 @@ func (m *MockPatientService) PayTransaction(data domain.PayTransactionRequest) (
 	return args.Get(0).(*domain.Transaction), args.Error(1)
 }
 
-// TestPatientHandler wraps PatientHandler for testing
-type TestPatientHandler struct {
-	svc PayTransactionService
+func (m *MockPatientService) SubmitTransaction(ctx context.Context, data domain.PayTransactionRequest) (*domain.Transaction, error) {
+	args := m.Called(data)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Transaction), args.Error(1)
 }
 
-func NewTestPatientHandler(svc PayTransactionService) *TestPatientHandler {
-	return &TestPatientHandler{svc: svc}
+func (m *MockPatientService) ProcessTransaction(ctx context.Context, id uuid.UUID) (*domain.Transaction, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Transaction), args.Error(1)
 }
 
-func (h *TestPatientHandler) PayTransaction(ctx *gin.Context) {
-	var data domain.PayTransactionRequest
-	if err := ctx.ShouldBindJSON(&data); err != nil {
-		HandleError(ctx, http.StatusBadRequest, err)
-		return
+func (m *MockPatientService) GetTransaction(ctx context.Context, id uuid.UUID) (*domain.Transaction, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
 	}
+	return args.Get(0).(*domain.Transaction), args.Error(1)
+}
 
-	rs, err := h.svc.PayTransaction(data)
-	if err != nil {
-		HandleError(ctx, http.StatusBadRequest, err)
-		return
+func (m *MockPatientService) RetryFailedTransactions(ctx context.Context) (*domain.RetryResult, error) {
+	args := m.Called()
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
 	}
+	return args.Get(0).(*domain.RetryResult), args.Error(1)
+}
 
-	ctx.JSON(http.StatusOK, rs)
+func (m *MockPatientService) ListTransactionAttempts(ctx context.Context, transactionID uuid.UUID) ([]domain.TransactionAttempt, error) {
+	args := m.Called(transactionID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.TransactionAttempt), args.Error(1)
+}
+
+func (m *MockPatientService) PurgeTransactionAttempts(ctx context.Context) (int64, error) {
+	args := m.Called()
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockPatientService) RecordConsent(ctx context.Context, patientID uuid.UUID, data domain.RecordConsentRequest) (*domain.GuardianConsent, error) {
+	args := m.Called(patientID, data)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.GuardianConsent), args.Error(1)
+}
+
+func (m *MockPatientService) RevokeConsent(ctx context.Context, patientID uuid.UUID, consentID uuid.UUID, data domain.RevokeConsentRequest) (*domain.GuardianConsent, error) {
+	args := m.Called(patientID, consentID, data)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.GuardianConsent), args.Error(1)
+}
+
+func (m *MockPatientService) ListConsents(ctx context.Context, patientID uuid.UUID) ([]domain.GuardianConsent, error) {
+	args := m.Called(patientID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.GuardianConsent), args.Error(1)
+}
+
+func (m *MockPatientService) ListAuditEntries(ctx context.Context, filter domain.AuditFilter) ([]domain.AuditEntry, error) {
+	args := m.Called(filter)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.AuditEntry), args.Error(1)
+}
+
+func (m *MockPatientService) VerifyAuditLog(ctx context.Context) (*domain.AuditVerification, error) {
+	args := m.Called()
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.AuditVerification), args.Error(1)
 }
 
 func setupTestRouter() *gin.Engine {
@@ -67,7 +123,8 @@ func setupTestRouter() *gin.Engine {
 
 	// Register custom validator like in main.go
 	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
-		v.RegisterValidation("ddmmyyyy", util.ValidateDDMMYYYY)
+		v.RegisterValidation("recordtype", util.ValidateRecordType)
+		v.RegisterValidation("date", util.ValidateDate)
 	}
 
 	return router
@@ -76,7 +133,7 @@ func setupTestRouter() *gin.Engine {
 func TestPatientHandler_PayTransaction_Success(t *testing.T) {
 	// Setup
 	mockService := &MockPatientService{}
-	handler := NewTestPatientHandler(mockService)
+	handler := NewPatientHandler(mockService)
 	router := setupTestRouter()
 	router.POST("/pay-transaction", handler.PayTransaction)
 
@@ -86,7 +143,7 @@ func TestPatientHandler_PayTransaction_Success(t *testing.T) {
 
 	requestData := domain.PayTransactionRequest{
 		PatientID:   patientID,
-		DateOfBirth: "15-03-1990",
+		DateOfBirth: mustParseDate("15-03-1990"),
 		RecordType:  "NEW",
 	}
 
@@ -94,7 +151,7 @@ func TestPatientHandler_PayTransaction_Success(t *testing.T) {
 		ID:          transactionID,
 		PatientID:   patientID,
 		Status:      domain.TransactionStatusSuccess,
-		DateOfBirth: "15-03-1990",
+		DateOfBirth: mustParseDate("15-03-1990"),
 		RecordType:  "NEW",
 		APIResponse: json.RawMessage(`{"message": "Transaction success"}`),
 	}
@@ -127,7 +184,7 @@ func TestPatientHandler_PayTransaction_Success(t *testing.T) {
 func TestPatientHandler_PayTransaction_InvalidJSON(t *testing.T) {
 	// Setup
 	mockService := &MockPatientService{}
-	handler := NewTestPatientHandler(mockService)
+	handler := NewPatientHandler(mockService)
 	router := setupTestRouter()
 	router.POST("/pay-transaction", handler.PayTransaction)
 
@@ -154,7 +211,7 @@ func TestPatientHandler_PayTransaction_InvalidJSON(t *testing.T) {
 func TestPatientHandler_PayTransaction_MissingRequiredFields(t *testing.T) {
 	// Setup
 	mockService := &MockPatientService{}
-	handler := NewTestPatientHandler(mockService)
+	handler := NewPatientHandler(mockService)
 	router := setupTestRouter()
 	router.POST("/pay-transaction", handler.PayTransaction)
 
@@ -188,7 +245,7 @@ func TestPatientHandler_PayTransaction_MissingRequiredFields(t *testing.T) {
 func TestPatientHandler_PayTransaction_ServiceError(t *testing.T) {
 	// Setup
 	mockService := &MockPatientService{}
-	handler := NewTestPatientHandler(mockService)
+	handler := NewPatientHandler(mockService)
 	router := setupTestRouter()
 	router.POST("/pay-transaction", handler.PayTransaction)
 
@@ -196,12 +253,12 @@ func TestPatientHandler_PayTransaction_ServiceError(t *testing.T) {
 	patientID := uuid.New()
 	requestData := domain.PayTransactionRequest{
 		PatientID:   patientID,
-		DateOfBirth: "15-03-1990",
+		DateOfBirth: mustParseDate("15-03-1990"),
 		RecordType:  "NEW",
 	}
 
 	// Mock expectations - service returns error
-	mockService.On("PayTransaction", requestData).Return(nil, errors.New("patient not found"))
+	mockService.On("PayTransaction", requestData).Return(nil, domain.ErrPatientNotFound)
 
 	// Create request
 	requestBody, _ := json.Marshal(requestData)
@@ -223,10 +280,44 @@ func TestPatientHandler_PayTransaction_ServiceError(t *testing.T) {
 	mockService.AssertExpectations(t)
 }
 
+func TestPatientHandler_PayTransaction_InternalError(t *testing.T) {
+	// Setup
+	mockService := &MockPatientService{}
+	handler := NewPatientHandler(mockService)
+	router := setupTestRouter()
+	router.POST("/pay-transaction", handler.PayTransaction)
+
+	requestData := domain.PayTransactionRequest{
+		PatientID:   uuid.New(),
+		DateOfBirth: mustParseDate("15-03-1990"),
+		RecordType:  "NEW",
+	}
+
+	// Mock expectations - the database is unreachable
+	mockService.On("PayTransaction", requestData).Return(nil, errors.New("dial tcp 10.0.0.5:5432: connection refused"))
+
+	requestBody, _ := json.Marshal(requestData)
+	req, _ := http.NewRequest("POST", "/pay-transaction", bytes.NewBuffer(requestBody))
+	req.Header.Set("Content-Type", "application/json")
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assertions - the detail is logged, not answered
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+
+	var response map[string]interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, "internal server error", response["error"])
+
+	mockService.AssertExpectations(t)
+}
+
 func TestPatientHandler_PayTransaction_FailedTransaction(t *testing.T) {
 	// Setup
 	mockService := &MockPatientService{}
-	handler := NewTestPatientHandler(mockService)
+	handler := NewPatientHandler(mockService)
 	router := setupTestRouter()
 	router.POST("/pay-transaction", handler.PayTransaction)
 
@@ -236,7 +327,7 @@ func TestPatientHandler_PayTransaction_FailedTransaction(t *testing.T) {
 
 	requestData := domain.PayTransactionRequest{
 		PatientID:   patientID,
-		DateOfBirth: "15-03-2010", // Under 18 years old
+		DateOfBirth: mustParseDate("15-03-2010"), // Under 18 years old
 		RecordType:  "NEW",
 	}
 
@@ -244,7 +335,7 @@ func TestPatientHandler_PayTransaction_FailedTransaction(t *testing.T) {
 		ID:          transactionID,
 		PatientID:   patientID,
 		Status:      domain.TransactionStatusFailed,
-		DateOfBirth: "15-03-2010",
+		DateOfBirth: mustParseDate("15-03-2010"),
 		RecordType:  "NEW",
 		APIResponse: json.RawMessage(`{"error": "Patient must be more than 18 years old"}`),
 	}
@@ -276,32 +367,17 @@ func TestPatientHandler_PayTransaction_FailedTransaction(t *testing.T) {
 func TestPatientHandler_PayTransaction_InvalidRecordType(t *testing.T) {
 	// Setup
 	mockService := &MockPatientService{}
-	handler := NewTestPatientHandler(mockService)
+	handler := NewPatientHandler(mockService)
 	router := setupTestRouter()
 	router.POST("/pay-transaction", handler.PayTransaction)
 
 	// Test data
-	patientID := uuid.New()
-	transactionID := uuid.New()
-
 	requestData := domain.PayTransactionRequest{
-		PatientID:   patientID,
-		DateOfBirth: "15-03-1990",
-		RecordType:  "OLD", // Invalid record type
-	}
-
-	expectedTransaction := &domain.Transaction{
-		ID:          transactionID,
-		PatientID:   patientID,
-		Status:      domain.TransactionStatusFailed,
-		DateOfBirth: "15-03-1990",
-		RecordType:  "OLD",
-		APIResponse: json.RawMessage(`{"error": "Record type must be NEW"}`),
+		PatientID:   uuid.New(),
+		DateOfBirth: mustParseDate("15-03-1990"),
+		RecordType:  "OLD", // Not a registered record type
 	}
 
-	// Mock expectations
-	mockService.On("PayTransaction", requestData).Return(expectedTransaction, nil)
-
 	// Create request
 	requestBody, _ := json.Marshal(requestData)
 	req, _ := http.NewRequest("POST", "/pay-transaction", bytes.NewBuffer(requestBody))
@@ -312,15 +388,17 @@ func TestPatientHandler_PayTransaction_InvalidRecordType(t *testing.T) {
 	router.ServeHTTP(w, req)
 
 	// Assertions
-	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
 
-	var responseTransaction domain.Transaction
-	err := json.Unmarshal(w.Body.Bytes(), &responseTransaction)
+	var response map[string][]ValidationError
+	err := json.Unmarshal(w.Body.Bytes(), &response)
 	assert.NoError(t, err)
-	assert.Equal(t, domain.TransactionStatusFailed, responseTransaction.Status)
-	assert.Equal(t, expectedTransaction.PatientID, responseTransaction.PatientID)
+	assert.Equal(t, []ValidationError{{
+		Field:   "record_type",
+		Message: "Record type must be one of NEW, RENEWAL, TRANSFER, CORRECTION",
+	}}, response["errors"])
 
-	mockService.AssertExpectations(t)
+	mockService.AssertNotCalled(t, "PayTransaction", mock.Anything)
 }
 
 func TestNewPatientHandler_Initialization(t *testing.T) {
@@ -328,7 +406,7 @@ func TestNewPatientHandler_Initialization(t *testing.T) {
 	mockService := &MockPatientService{}
 
 	// Execute
-	handler := NewTestPatientHandler(mockService)
+	handler := NewPatientHandler(mockService)
 
 	// Assertions
 	assert.NotNil(t, handler)
@@ -338,15 +416,15 @@ func TestNewPatientHandler_Initialization(t *testing.T) {
 func TestPatientHandler_PayTransaction_InvalidDateFormat(t *testing.T) {
 	// Setup
 	mockService := &MockPatientService{}
-	handler := NewTestPatientHandler(mockService)
+	handler := NewPatientHandler(mockService)
 	router := setupTestRouter()
 	router.POST("/pay-transaction", handler.PayTransaction)
 
 	// Test data with invalid date format
 	patientID := uuid.New()
-	requestData := domain.PayTransactionRequest{
+	requestData := PayTransactionRequestV1{
 		PatientID:   patientID,
-		DateOfBirth: "1990-03-15", // Wrong format (should be DD-MM-YYYY)
+		DateOfBirth: "03/15/1990", // not a default format
 		RecordType:  "NEW",
 	}
 
@@ -371,10 +449,59 @@ func TestPatientHandler_PayTransaction_InvalidDateFormat(t *testing.T) {
 	mockService.AssertNotCalled(t, "PayTransaction")
 }
 
+func TestPatientHandler_PayTransaction_ClientDateFormats(t *testing.T) {
+	dates, err := util.NewDateParser(nil, map[string][]string{"us-partner": {"MM/DD/YYYY"}})
+	assert.NoError(t, err)
+
+	mockService := &MockPatientService{}
+	handler := NewPatientHandler(mockService, WithDateParser(dates))
+	router := setupTestRouter()
+	router.POST("/pay-transaction", handler.PayTransaction)
+
+	testCases := []struct {
+		name        string
+		client      string
+		dateOfBirth string
+		expectCode  int
+	}{
+		{name: "Default client DD-MM-YYYY", dateOfBirth: "15-03-1990", expectCode: http.StatusOK},
+		{name: "Default client ISO 8601", dateOfBirth: "1990-03-15", expectCode: http.StatusOK},
+		{name: "Default client US format", dateOfBirth: "03/15/1990", expectCode: http.StatusBadRequest},
+		{name: "US partner US format", client: "us-partner", dateOfBirth: "03/15/1990", expectCode: http.StatusOK},
+		{name: "US partner DD-MM-YYYY", client: "us-partner", dateOfBirth: "15-03-1990", expectCode: http.StatusBadRequest},
+		{name: "US partner invalid date", client: "us-partner", dateOfBirth: "02/30/1990", expectCode: http.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockService.ExpectedCalls = nil
+			patientID := uuid.New()
+			if tc.expectCode == http.StatusOK {
+				mockService.On("PayTransaction", mock.MatchedBy(func(data domain.PayTransactionRequest) bool {
+					return data.DateOfBirth.Equal(mustParseDate("15-03-1990"))
+				})).Return(&domain.Transaction{ID: uuid.New(), PatientID: patientID}, nil).Once()
+			}
+
+			requestBody, _ := json.Marshal(PayTransactionRequestV1{PatientID: patientID, DateOfBirth: tc.dateOfBirth, RecordType: "NEW"})
+			req, _ := http.NewRequest("POST", "/pay-transaction", bytes.NewBuffer(requestBody))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set(ClientIDHeader, tc.client)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.expectCode, w.Code, w.Body.String())
+			if tc.expectCode == http.StatusBadRequest {
+				assert.Contains(t, w.Body.String(), `"field":"date_of_birth"`)
+			}
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
 func TestPatientHandler_PayTransaction_EmptyBody(t *testing.T) {
 	// Setup
 	mockService := &MockPatientService{}
-	handler := NewTestPatientHandler(mockService)
+	handler := NewPatientHandler(mockService)
 	router := setupTestRouter()
 	router.POST("/pay-transaction", handler.PayTransaction)
 
@@ -401,7 +528,7 @@ func TestPatientHandler_PayTransaction_EmptyBody(t *testing.T) {
 func TestPatientHandler_PayTransaction_ValidationErrors(t *testing.T) {
 	// Setup
 	mockService := &MockPatientService{}
-	handler := NewTestPatientHandler(mockService)
+	handler := NewPatientHandler(mockService)
 	router := setupTestRouter()
 	router.POST("/pay-transaction", handler.PayTransaction)
 
@@ -477,3 +604,201 @@ func TestPatientHandler_PayTransaction_ValidationErrors(t *testing.T) {
 	// Service should never be called for validation errors
 	mockService.AssertNotCalled(t, "PayTransaction")
 }
+
+func TestPatientHandler_SubmitTransaction(t *testing.T) {
+	testCases := []struct {
+		name             string
+		status           domain.TransactionStatus
+		expectedCode     int
+		expectedLocation bool
+	}{
+		{
+			name:             "Accepted for processing",
+			status:           domain.TransactionStatusPending,
+			expectedCode:     http.StatusAccepted,
+			expectedLocation: true,
+		},
+		{
+			name:         "Rejected by validation",
+			status:       domain.TransactionStatusFailed,
+			expectedCode: http.StatusOK,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Setup
+			mockService := &MockPatientService{}
+			handler := NewPatientHandler(mockService)
+			router := setupTestRouter()
+			router.POST("/app/patients/pay-transaction", handler.SubmitTransaction)
+
+			requestData := domain.PayTransactionRequest{
+				PatientID:   uuid.New(),
+				DateOfBirth: mustParseDate("15-03-1990"),
+				RecordType:  "NEW",
+			}
+			transaction := &domain.Transaction{ID: uuid.New(), PatientID: requestData.PatientID, Status: tc.status}
+			mockService.On("SubmitTransaction", requestData).Return(transaction, nil)
+
+			// Execute request
+			requestBody, _ := json.Marshal(requestData)
+			req, _ := http.NewRequest("POST", "/app/patients/pay-transaction", bytes.NewBuffer(requestBody))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			// Assertions
+			assert.Equal(t, tc.expectedCode, w.Code)
+			var response map[string]interface{}
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Equal(t, transaction.ID.String(), response["id"])
+			assert.Equal(t, string(tc.status), response["status"])
+			if tc.expectedLocation {
+				statusURL := "/app/transactions/" + transaction.ID.String()
+				assert.Equal(t, statusURL, w.Header().Get("Location"))
+				assert.Equal(t, statusURL, response["status_url"])
+			} else {
+				assert.Empty(t, w.Header().Get("Location"))
+			}
+		})
+	}
+}
+
+func TestPatientHandler_GetTransaction(t *testing.T) {
+	// Setup
+	mockService := &MockPatientService{}
+	handler := NewPatientHandler(mockService)
+	router := setupTestRouter()
+	router.GET("/transactions/:id", handler.GetTransaction)
+
+	transaction := &domain.Transaction{ID: uuid.New(), Status: domain.TransactionStatusPending}
+	missingID := uuid.New()
+	failingID := uuid.New()
+	mockService.On("GetTransaction", transaction.ID).Return(transaction, nil)
+	mockService.On("GetTransaction", missingID).Return(nil, domain.ErrTransactionNotFound)
+	mockService.On("GetTransaction", failingID).Return(nil, errors.New("connection refused"))
+
+	testCases := []struct {
+		path         string
+		expectedCode int
+	}{
+		{path: "/transactions/" + transaction.ID.String(), expectedCode: http.StatusOK},
+		{path: "/transactions/" + missingID.String(), expectedCode: http.StatusNotFound},
+		{path: "/transactions/" + failingID.String(), expectedCode: http.StatusInternalServerError},
+		{path: "/transactions/not-a-uuid", expectedCode: http.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		req, _ := http.NewRequest("GET", tc.path, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, tc.expectedCode, w.Code, tc.path)
+	}
+}
+
+func TestPatientHandler_ListTransactionAttempts(t *testing.T) {
+	// Setup
+	mockService := &MockPatientService{}
+	handler := NewPatientHandler(mockService)
+	router := setupTestRouter()
+	router.GET("/transactions/:id/attempts", handler.ListTransactionAttempts)
+
+	transactionID := uuid.New()
+	failingID := uuid.New()
+	attempts := []domain.TransactionAttempt{
+		{ID: uuid.New(), TransactionID: transactionID, Attempt: 1, StatusCode: 503, ErrorClass: domain.AttemptErrorTransient},
+		{ID: uuid.New(), TransactionID: transactionID, Attempt: 2, StatusCode: 200},
+	}
+	mockService.On("ListTransactionAttempts", transactionID).Return(attempts, nil)
+	mockService.On("ListTransactionAttempts", failingID).Return(nil, errors.New("database unavailable"))
+
+	req, _ := http.NewRequest("GET", "/transactions/"+transactionID.String()+"/attempts", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response []domain.TransactionAttempt
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Len(t, response, 2)
+	assert.Equal(t, domain.AttemptErrorTransient, response[0].ErrorClass)
+
+	testCases := []struct {
+		path         string
+		expectedCode int
+	}{
+		{path: "/transactions/" + failingID.String() + "/attempts", expectedCode: http.StatusInternalServerError},
+		{path: "/transactions/not-a-uuid/attempts", expectedCode: http.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		req, _ := http.NewRequest("GET", tc.path, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, tc.expectedCode, w.Code, tc.path)
+	}
+}
+
+func mustParseDate(s string) domain.Date {
+	d, err := domain.ParseDate(s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func TestPatientHandler_PayTransactionV2(t *testing.T) {
+	// Setup
+	mockService := &MockPatientService{}
+	handler := NewPatientHandler(mockService)
+	router := setupTestRouter()
+	router.POST("/v2/pay-transaction", handler.PayTransactionV2)
+
+	patientID := uuid.New()
+	previousID := uuid.New()
+	expected := domain.PayTransactionRequest{
+		PatientID:             patientID,
+		DateOfBirth:           mustParseDate("15-03-1990"),
+		RecordType:            "RENEWAL",
+		PreviousTransactionID: &previousID,
+	}
+	mockService.On("PayTransaction", expected).Return(&domain.Transaction{ID: uuid.New(), PatientID: patientID, Status: domain.TransactionStatusSuccess}, nil)
+
+	testCases := []struct {
+		name         string
+		body         string
+		expectedCode int
+	}{
+		{
+			name:         "Valid request",
+			body:         `{"patient_id":"` + patientID.String() + `","date_of_birth":"1990-03-15","record":{"type":"RENEWAL","previous_transaction_id":"` + previousID.String() + `"}}`,
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "Date of birth not ISO 8601",
+			body:         `{"patient_id":"` + patientID.String() + `","date_of_birth":"15-03-1990","record":{"type":"NEW"}}`,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "v1 body",
+			body:         `{"patient_id":"` + patientID.String() + `","date_of_birth":"1990-03-15","record_type":"NEW"}`,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "Unknown record type",
+			body:         `{"patient_id":"` + patientID.String() + `","date_of_birth":"1990-03-15","record":{"type":"OLD"}}`,
+			expectedCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req, _ := http.NewRequest("POST", "/v2/pay-transaction", bytes.NewBufferString(tc.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.expectedCode, w.Code)
+		})
+	}
+	mockService.AssertNumberOfCalls(t, "PayTransaction", 1)
+}
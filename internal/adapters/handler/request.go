@@ -0,0 +1,107 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/datphamcode295/go-lambda-pulumi/internal/core/domain"
+	util "github.com/datphamcode295/go-lambda-pulumi/internal/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/google/uuid"
+)
+
+// isoDateLayout is the only date of birth layout of the v2 API
+const isoDateLayout = "2006-01-02"
+
+// PayTransactionRequestV1 is the pay-transaction body of the v1 API
+type PayTransactionRequestV1 struct {
+	PatientID   uuid.UUID `json:"patient_id" binding:"required"`
+	DateOfBirth string    `json:"date_of_birth" binding:"required,date"` // in one of DateFormats
+	RecordType  string    `json:"record_type" binding:"required,recordtype"`
+	// PreviousTransactionID is required by RENEWAL, TRANSFER and CORRECTION records
+	PreviousTransactionID *uuid.UUID `json:"previous_transaction_id,omitempty"`
+	// TransferTo is the clinic a TRANSFER record moves the patient to
+	TransferTo string `json:"transfer_to,omitempty"`
+	// CorrectionReason explains what a CORRECTION record fixes
+	CorrectionReason string `json:"correction_reason,omitempty"`
+	// DateFormats are the formats accepted from the calling client, the defaults when empty
+	DateFormats []string `json:"-"`
+}
+
+// AcceptedDateFormats tells the "date" validator which formats DateOfBirth may be in
+func (r PayTransactionRequestV1) AcceptedDateFormats() []string {
+	return r.DateFormats
+}
+
+// PayTransactionRequestV2 is the pay-transaction body of the v2 API. Dates are ISO 8601 and
+// the record type comes with its own fields.
+type PayTransactionRequestV2 struct {
+	PatientID   uuid.UUID `json:"patient_id" binding:"required"`
+	DateOfBirth string    `json:"date_of_birth" binding:"required,datetime=2006-01-02"`
+	Record      RecordV2  `json:"record" binding:"required"`
+}
+
+// RecordV2 is the record a v2 transaction is for
+type RecordV2 struct {
+	Type                  string     `json:"type" binding:"required,recordtype"`
+	PreviousTransactionID *uuid.UUID `json:"previous_transaction_id,omitempty"`
+	TransferTo            string     `json:"transfer_to,omitempty"`
+	CorrectionReason      string     `json:"correction_reason,omitempty"`
+}
+
+// decodeJSON decodes the request body into v and validates it
+func decodeJSON(ctx *gin.Context, v interface{}) error {
+	if ctx.Request.Body == nil {
+		return errors.New("invalid request")
+	}
+	if err := json.NewDecoder(ctx.Request.Body).Decode(v); err != nil {
+		return err
+	}
+	return binding.Validator.ValidateStruct(v)
+}
+
+// bindPayTransactionV1 decodes and validates a v1 pay-transaction request with the date formats
+// of the calling client
+func (h *PatientHandler) bindPayTransactionV1(ctx *gin.Context) (domain.PayTransactionRequest, error) {
+	// DateFormats is not read from the body, so the "date" tag checks the formats of the client
+	data := PayTransactionRequestV1{DateFormats: h.dates.Formats(ctx.GetHeader(ClientIDHeader))}
+	if err := decodeJSON(ctx, &data); err != nil {
+		return domain.PayTransactionRequest{}, err
+	}
+
+	dateOfBirth, err := util.ParseDate(data.DateFormats, data.DateOfBirth)
+	if err != nil {
+		return domain.PayTransactionRequest{}, err
+	}
+	return domain.PayTransactionRequest{
+		PatientID:             data.PatientID,
+		DateOfBirth:           dateOfBirth,
+		RecordType:            data.RecordType,
+		PreviousTransactionID: data.PreviousTransactionID,
+		TransferTo:            data.TransferTo,
+		CorrectionReason:      data.CorrectionReason,
+	}, nil
+}
+
+// bindPayTransactionV2 decodes and validates a v2 pay-transaction request
+func (h *PatientHandler) bindPayTransactionV2(ctx *gin.Context) (domain.PayTransactionRequest, error) {
+	var data PayTransactionRequestV2
+	if err := decodeJSON(ctx, &data); err != nil {
+		return domain.PayTransactionRequest{}, err
+	}
+
+	dateOfBirth, err := time.Parse(isoDateLayout, data.DateOfBirth)
+	if err != nil {
+		return domain.PayTransactionRequest{}, err
+	}
+	return domain.PayTransactionRequest{
+		PatientID:             data.PatientID,
+		DateOfBirth:           domain.DateOf(dateOfBirth),
+		RecordType:            data.Record.Type,
+		PreviousTransactionID: data.Record.PreviousTransactionID,
+		TransferTo:            data.Record.TransferTo,
+		CorrectionReason:      data.Record.CorrectionReason,
+	}, nil
+}
@@ -0,0 +1,334 @@
+package handler
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/datphamcode295/go-lambda-pulumi/internal/core/domain"
+	"github.com/google/uuid"
+)
+
+// defaultProviderResponseFields are the provider response fields answered to clients when none
+// are configured
+var defaultProviderResponseFields = []string{"message", "error"}
+
+// Responses are mapped from the domain explicitly, so a new database column is not answered
+// until it is added here. Types are suffixed with the API version they were introduced in, a
+// later version keeps using the types it did not change.
+
+// TransactionV1 is a transaction as answered by the v1 API
+type TransactionV1 struct {
+	ID        uuid.UUID `json:"id"`
+	PatientID uuid.UUID `json:"patient_id"`
+	Status    string    `json:"status"`
+	// APIResponse holds the allowed fields of the provider response
+	APIResponse           json.RawMessage     `json:"api_response"`
+	RecordType            string              `json:"record_type"`
+	DateOfBirth           *string             `json:"date_of_birth"`
+	PreviousTransactionID *uuid.UUID          `json:"previous_transaction_id,omitempty"`
+	TransferTo            string              `json:"transfer_to,omitempty"`
+	CorrectionReason      string              `json:"correction_reason,omitempty"`
+	FailureKind           string              `json:"failure_kind,omitempty"`
+	ConsentID             *uuid.UUID          `json:"consent_id,omitempty"`
+	RejectionReasons      []RejectionReasonV1 `json:"rejection_reasons,omitempty"`
+	MembershipTier        string              `json:"membership_tier,omitempty"`
+	BaseFeeCents          int64               `json:"base_fee_cents,omitempty"`
+	DiscountCents         int64               `json:"discount_cents,omitempty"`
+	FeeCents              int64               `json:"fee_cents,omitempty"`
+	Currency              string              `json:"currency,omitempty"`
+	AppliedBenefit        string              `json:"applied_benefit,omitempty"`
+	Attempts              int                 `json:"attempts"`
+	NextRetryAt           *time.Time          `json:"next_retry_at,omitempty"`
+	CreatedAt             time.Time           `json:"created_at"`
+}
+
+// RejectionReasonV1 is an eligibility rule broken by a transaction
+type RejectionReasonV1 struct {
+	Rule    string `json:"rule"`
+	Kind    string `json:"kind"`
+	Message string `json:"message"`
+}
+
+// AcceptedTransactionV1 is the 202 answer to a transaction accepted for asynchronous processing
+type AcceptedTransactionV1 struct {
+	TransactionV1
+	StatusURL string `json:"status_url"`
+}
+
+// TransactionV2 is a transaction as answered by the v2 API. Dates are ISO 8601, and the record
+// and fee details are grouped.
+type TransactionV2 struct {
+	ID          uuid.UUID `json:"id"`
+	PatientID   uuid.UUID `json:"patient_id"`
+	Status      string    `json:"status"`
+	FailureKind string    `json:"failure_kind,omitempty"`
+	Record      RecordV2  `json:"record"`
+	DateOfBirth *string   `json:"date_of_birth"`
+	// ProviderResponse holds the allowed fields of the provider response
+	ProviderResponse json.RawMessage     `json:"provider_response"`
+	RejectionReasons []RejectionReasonV1 `json:"rejection_reasons,omitempty"`
+	ConsentID        *uuid.UUID          `json:"consent_id,omitempty"`
+	// Fee is set once the transaction is priced
+	Fee         *FeeV2     `json:"fee,omitempty"`
+	Attempts    int        `json:"attempts"`
+	NextRetryAt *time.Time `json:"next_retry_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// FeeV2 is the price of a transaction, TotalCents is BaseCents less DiscountCents
+type FeeV2 struct {
+	MembershipTier string `json:"membership_tier,omitempty"`
+	BaseCents      int64  `json:"base_cents"`
+	DiscountCents  int64  `json:"discount_cents"`
+	TotalCents     int64  `json:"total_cents"`
+	Currency       string `json:"currency"`
+	AppliedBenefit string `json:"applied_benefit,omitempty"`
+}
+
+// AcceptedTransactionV2 is the v2 answer to a transaction accepted for asynchronous processing
+type AcceptedTransactionV2 struct {
+	TransactionV2
+	StatusURL string `json:"status_url"`
+}
+
+// TransactionAttemptV1 is a provider call made for a transaction. It is only answered to
+// admins, so the provider response is kept whole for troubleshooting.
+type TransactionAttemptV1 struct {
+	ID              uuid.UUID       `json:"id"`
+	TransactionID   uuid.UUID       `json:"transaction_id"`
+	Attempt         int             `json:"attempt"`
+	Request         json.RawMessage `json:"request"`
+	StatusCode      int             `json:"status_code"`
+	ResponseHeaders json.RawMessage `json:"response_headers,omitempty"`
+	ResponseBody    json.RawMessage `json:"response_body,omitempty"`
+	LatencyMS       int64           `json:"latency_ms"`
+	ErrorClass      string          `json:"error_class,omitempty"`
+	Error           string          `json:"error,omitempty"`
+	CreatedAt       time.Time       `json:"created_at"`
+}
+
+// ConsentV1 is a guardian consent as answered by the v1 API
+type ConsentV1 struct {
+	ID                uuid.UUID  `json:"id"`
+	PatientID         uuid.UUID  `json:"patient_id"`
+	GuardianUserID    string     `json:"guardian_user_id,omitempty"`
+	GuardianName      string     `json:"guardian_name,omitempty"`
+	GuardianContact   string     `json:"guardian_contact,omitempty"`
+	Relationship      string     `json:"relationship"`
+	EvidenceReference string     `json:"evidence_reference"`
+	GrantedAt         time.Time  `json:"granted_at"`
+	GrantedBy         string     `json:"granted_by"`
+	ExpiresAt         *time.Time `json:"expires_at,omitempty"`
+	RevokedAt         *time.Time `json:"revoked_at,omitempty"`
+	RevokedBy         string     `json:"revoked_by,omitempty"`
+	RevocationReason  string     `json:"revocation_reason,omitempty"`
+}
+
+// AuditEntryV1 is an audit log entry, with the hashes needed to verify the chain
+type AuditEntryV1 struct {
+	ID           uuid.UUID       `json:"id"`
+	Sequence     int64           `json:"sequence"`
+	Actor        string          `json:"actor"`
+	ClaimedActor string          `json:"claimed_actor,omitempty"`
+	Action       string          `json:"action"`
+	EntityType   string          `json:"entity_type"`
+	EntityID     string          `json:"entity_id"`
+	Changes      json.RawMessage `json:"changes,omitempty"`
+	RequestID    string          `json:"request_id,omitempty"`
+	SourceIP     string          `json:"source_ip,omitempty"`
+	CreatedAt    time.Time       `json:"created_at"`
+	PrevHash     string          `json:"prev_hash"`
+	Hash         string          `json:"hash"`
+}
+
+// AuditVerificationV1 is the outcome of checking the audit chain
+type AuditVerificationV1 struct {
+	Valid    bool   `json:"valid"`
+	Verified int64  `json:"verified"`
+	BrokenAt *int64 `json:"broken_at,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// transactionV1 maps a transaction, answering only the providerFields of the provider response
+func transactionV1(t *domain.Transaction, providerFields []string) TransactionV1 {
+	rs := TransactionV1{
+		ID:                    t.ID,
+		PatientID:             t.PatientID,
+		Status:                string(t.Status),
+		APIResponse:           providerResponse(t.APIResponse, providerFields),
+		RecordType:            t.RecordType,
+		DateOfBirth:           dateV1(t.DateOfBirth),
+		PreviousTransactionID: t.PreviousTransactionID,
+		TransferTo:            t.TransferTo,
+		CorrectionReason:      t.CorrectionReason,
+		FailureKind:           string(t.FailureKind),
+		ConsentID:             t.ConsentID,
+		MembershipTier:        string(t.MembershipTier),
+		BaseFeeCents:          t.BaseFeeCents,
+		DiscountCents:         t.DiscountCents,
+		FeeCents:              t.FeeCents,
+		Currency:              t.Currency,
+		AppliedBenefit:        t.AppliedBenefit,
+		Attempts:              t.Attempts,
+		NextRetryAt:           t.NextRetryAt,
+		CreatedAt:             t.CreatedAt,
+	}
+	rs.RejectionReasons = rejectionReasonsV1(t.RejectionReasons)
+	return rs
+}
+
+// transactionV2 maps a transaction, answering only the providerFields of the provider response
+func transactionV2(t *domain.Transaction, providerFields []string) TransactionV2 {
+	rs := TransactionV2{
+		ID:          t.ID,
+		PatientID:   t.PatientID,
+		Status:      string(t.Status),
+		FailureKind: string(t.FailureKind),
+		Record: RecordV2{
+			Type:                  t.RecordType,
+			PreviousTransactionID: t.PreviousTransactionID,
+			TransferTo:            t.TransferTo,
+			CorrectionReason:      t.CorrectionReason,
+		},
+		ProviderResponse: providerResponse(t.APIResponse, providerFields),
+		RejectionReasons: rejectionReasonsV1(t.RejectionReasons),
+		ConsentID:        t.ConsentID,
+		Attempts:         t.Attempts,
+		NextRetryAt:      t.NextRetryAt,
+		CreatedAt:        t.CreatedAt,
+	}
+	if !t.DateOfBirth.IsZero() {
+		dateOfBirth := t.DateOfBirth.Format(isoDateLayout)
+		rs.DateOfBirth = &dateOfBirth
+	}
+	if t.Currency != "" {
+		rs.Fee = &FeeV2{
+			MembershipTier: string(t.MembershipTier),
+			BaseCents:      t.BaseFeeCents,
+			DiscountCents:  t.DiscountCents,
+			TotalCents:     t.FeeCents,
+			Currency:       t.Currency,
+			AppliedBenefit: t.AppliedBenefit,
+		}
+	}
+	return rs
+}
+
+func rejectionReasonsV1(reasons domain.RejectionReasons) []RejectionReasonV1 {
+	var rs []RejectionReasonV1
+	for _, reason := range reasons {
+		rs = append(rs, RejectionReasonV1{
+			Rule:    reason.Rule,
+			Kind:    reason.Kind,
+			Message: reason.Message,
+		})
+	}
+	return rs
+}
+
+// dateV1 answers a date as DD-MM-YYYY, null when it is not known
+func dateV1(d domain.Date) *string {
+	if d.IsZero() {
+		return nil
+	}
+	s := d.String()
+	return &s
+}
+
+// providerResponse keeps the fields of a provider response object, nothing is kept of
+// responses that are not JSON objects
+func providerResponse(body json.RawMessage, fields []string) json.RawMessage {
+	var all map[string]json.RawMessage
+	if len(body) == 0 || json.Unmarshal(body, &all) != nil || all == nil {
+		return nil
+	}
+
+	kept := map[string]json.RawMessage{}
+	for _, field := range fields {
+		if value, ok := all[field]; ok {
+			kept[field] = value
+		}
+	}
+	rs, err := json.Marshal(kept)
+	if err != nil {
+		return nil
+	}
+	return rs
+}
+
+func transactionAttemptsV1(attempts []domain.TransactionAttempt) []TransactionAttemptV1 {
+	rs := make([]TransactionAttemptV1, 0, len(attempts))
+	for _, a := range attempts {
+		rs = append(rs, TransactionAttemptV1{
+			ID:              a.ID,
+			TransactionID:   a.TransactionID,
+			Attempt:         a.Attempt,
+			Request:         a.Request,
+			StatusCode:      a.StatusCode,
+			ResponseHeaders: a.ResponseHeaders,
+			ResponseBody:    a.ResponseBody,
+			LatencyMS:       a.LatencyMS,
+			ErrorClass:      string(a.ErrorClass),
+			Error:           a.Error,
+			CreatedAt:       a.CreatedAt,
+		})
+	}
+	return rs
+}
+
+func consentV1(c *domain.GuardianConsent) ConsentV1 {
+	return ConsentV1{
+		ID:                c.ID,
+		PatientID:         c.PatientID,
+		GuardianUserID:    c.GuardianUserID,
+		GuardianName:      c.GuardianName,
+		GuardianContact:   c.GuardianContact,
+		Relationship:      c.Relationship,
+		EvidenceReference: c.EvidenceReference,
+		GrantedAt:         c.GrantedAt,
+		GrantedBy:         c.GrantedBy,
+		ExpiresAt:         c.ExpiresAt,
+		RevokedAt:         c.RevokedAt,
+		RevokedBy:         c.RevokedBy,
+		RevocationReason:  c.RevocationReason,
+	}
+}
+
+func consentsV1(consents []domain.GuardianConsent) []ConsentV1 {
+	rs := make([]ConsentV1, 0, len(consents))
+	for i := range consents {
+		rs = append(rs, consentV1(&consents[i]))
+	}
+	return rs
+}
+
+func auditEntriesV1(entries []domain.AuditEntry) []AuditEntryV1 {
+	rs := make([]AuditEntryV1, 0, len(entries))
+	for _, e := range entries {
+		rs = append(rs, AuditEntryV1{
+			ID:           e.ID,
+			Sequence:     e.Sequence,
+			Actor:        e.Actor,
+			ClaimedActor: e.ClaimedActor,
+			Action:       string(e.Action),
+			EntityType:   e.EntityType,
+			EntityID:     e.EntityID,
+			Changes:      e.Changes,
+			RequestID:    e.RequestID,
+			SourceIP:     e.SourceIP,
+			CreatedAt:    e.CreatedAt,
+			PrevHash:     e.PrevHash,
+			Hash:         e.Hash,
+		})
+	}
+	return rs
+}
+
+func auditVerificationV1(v *domain.AuditVerification) AuditVerificationV1 {
+	return AuditVerificationV1{
+		Valid:    v.Valid,
+		Verified: v.Verified,
+		BrokenAt: v.BrokenAt,
+		Reason:   v.Reason,
+	}
+}
@@ -0,0 +1,237 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/datphamcode295/go-lambda-pulumi/internal/core/domain"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+// update rewrites the golden files with the current responses: go test ./internal/adapters/handler -update
+var update = flag.Bool("update", false, "update the golden files")
+
+var (
+	goldenPatientID     = uuid.MustParse("9c7006ad-56e0-47cb-a166-f22426586cd2")
+	goldenTransactionID = uuid.MustParse("b48e654b-e4dd-4614-b0b7-fba186f8d9bb")
+	goldenConsentID     = uuid.MustParse("5f0c8a3e-2d7b-4a51-9c1e-7e2b8d4f6a90")
+	goldenTime          = time.Date(2025, 5, 27, 17, 36, 13, 774575000, time.UTC)
+)
+
+// assertGolden compares an indented JSON response with testdata/name
+func assertGolden(t *testing.T, name string, body []byte) {
+	t.Helper()
+	var indented bytes.Buffer
+	if !assert.NoError(t, json.Indent(&indented, body, "", "  ")) {
+		return
+	}
+	indented.WriteByte('\n')
+
+	path := filepath.Join("testdata", name)
+	if *update {
+		assert.NoError(t, os.MkdirAll("testdata", 0o755))
+		assert.NoError(t, os.WriteFile(path, indented.Bytes(), 0o644))
+		return
+	}
+	golden, err := os.ReadFile(path)
+	if assert.NoError(t, err, "run go test with -update to create %s", path) {
+		assert.Equal(t, string(golden), indented.String(), "response differs from %s", path)
+	}
+}
+
+func TestResponses_Golden(t *testing.T) {
+	previousID := uuid.MustParse("0d4b8e2a-6f1c-4e3b-8a9d-2c5f7e1b3a64")
+	retryAt := goldenTime.Add(time.Minute)
+	expiresAt := goldenTime.AddDate(1, 0, 0)
+
+	success := &domain.Transaction{
+		ID:          goldenTransactionID,
+		PatientID:   goldenPatientID,
+		Status:      domain.TransactionStatusSuccess,
+		APIResponse: json.RawMessage(`{"message": "Transaction success", "internal_ref": "prov-123", "risk_score": 0.2}`),
+		RecordType:  "NEW",
+		DateOfBirth: mustParseDate("12-12-2000"),
+		Attempts:    1,
+		CreatedAt:   goldenTime,
+	}
+	rejected := &domain.Transaction{
+		ID:                    goldenTransactionID,
+		PatientID:             goldenPatientID,
+		Status:                domain.TransactionStatusFailed,
+		APIResponse:           json.RawMessage(`{"error": "Patient must be more than 18 years old"}`),
+		RecordType:            "RENEWAL",
+		DateOfBirth:           mustParseDate("12-12-2010"),
+		PreviousTransactionID: &previousID,
+		FailureKind:           domain.FailureKindRejected,
+		RejectionReasons: domain.RejectionReasons{
+			{Rule: "minimum-age", Kind: "age", Message: "Patient must be more than 18 years old"},
+		},
+		CreatedAt: goldenTime,
+	}
+	retrying := &domain.Transaction{
+		ID:             goldenTransactionID,
+		PatientID:      goldenPatientID,
+		Status:         domain.TransactionStatusFailed,
+		APIResponse:    json.RawMessage(`"upstream unavailable"`),
+		RecordType:     "NEW",
+		DateOfBirth:    mustParseDate("12-12-2000"),
+		FailureKind:    domain.FailureKindTransient,
+		MembershipTier: domain.MembershipPlus,
+		BaseFeeCents:   5000,
+		DiscountCents:  1250,
+		FeeCents:       3750,
+		Currency:       "AUD",
+		AppliedBenefit: "plus: 25% discount",
+		Attempts:       2,
+		NextRetryAt:    &retryAt,
+		CreatedAt:      goldenTime,
+	}
+	pending := &domain.Transaction{
+		ID:          goldenTransactionID,
+		PatientID:   goldenPatientID,
+		Status:      domain.TransactionStatusPending,
+		RecordType:  "NEW",
+		DateOfBirth: mustParseDate("12-12-2000"),
+		CreatedAt:   goldenTime,
+	}
+	attempts := []domain.TransactionAttempt{{
+		ID:              uuid.MustParse("7a1e3c5b-9d2f-4b6a-8c0e-1f3a5b7d9e2c"),
+		TransactionID:   goldenTransactionID,
+		Attempt:         1,
+		Request:         json.RawMessage(`{"patient":{"id":"pt_0123"},"age":24,"record_type":"NEW"}`),
+		StatusCode:      503,
+		ResponseHeaders: json.RawMessage(`{"Retry-After":"30"}`),
+		ResponseBody:    json.RawMessage(`{"error":"unavailable","internal_ref":"prov-123"}`),
+		LatencyMS:       412,
+		ErrorClass:      domain.AttemptErrorTransient,
+		CreatedAt:       goldenTime,
+	}}
+	consents := []domain.GuardianConsent{{
+		ID:                goldenConsentID,
+		PatientID:         goldenPatientID,
+		GuardianName:      "Jane Doe",
+		GuardianContact:   "jane@example.com",
+		Relationship:      "parent",
+		EvidenceReference: "form-42",
+		GrantedAt:         goldenTime,
+		GrantedBy:         "user-42",
+		ExpiresAt:         &expiresAt,
+	}}
+	auditEntries := []domain.AuditEntry{{
+		ID:           uuid.MustParse("3c9e1a7b-5d2f-4e8a-b6c0-9f1d3e5a7b2c"),
+		Sequence:     1,
+		Actor:        "user-1",
+		ClaimedActor: "patient-portal",
+		Action:       domain.AuditActionCreate,
+		EntityType:   domain.AuditEntityTransaction,
+		EntityID:     goldenTransactionID.String(),
+		Changes:      json.RawMessage(`{"status":{"after":"success","before":null}}`),
+		RequestID:    "req-1",
+		SourceIP:     "203.0.113.7",
+		CreatedAt:    goldenTime,
+		Hash:         "c0ffee",
+	}}
+
+	mockService := &MockPatientService{}
+	mockService.On("GetTransaction", goldenTransactionID).Return(success, nil).Once()
+	mockService.On("GetTransaction", goldenTransactionID).Return(rejected, nil).Once()
+	mockService.On("GetTransaction", goldenTransactionID).Return(retrying, nil).Once()
+	mockService.On("GetTransaction", goldenTransactionID).Return(retrying, nil).Once()
+	mockService.On("SubmitTransaction", domain.PayTransactionRequest{
+		PatientID:   goldenPatientID,
+		DateOfBirth: mustParseDate("12-12-2000"),
+		RecordType:  "NEW",
+	}).Return(pending, nil)
+	mockService.On("ListTransactionAttempts", goldenTransactionID).Return(attempts, nil)
+	mockService.On("ListConsents", goldenPatientID).Return(consents, nil)
+	mockService.On("ListAuditEntries", domain.AuditFilter{}).Return(auditEntries, nil)
+
+	h := NewPatientHandler(mockService)
+	router := setupTestRouter()
+	router.GET("/app/transactions/:id", h.GetTransaction)
+	router.GET("/app/admin/transactions/:id/attempts", h.ListTransactionAttempts)
+	router.POST("/app/patients/pay-transaction", h.SubmitTransaction)
+	router.GET("/app/patients/:id/consents", h.ListConsents)
+	router.GET("/app/admin/audit", h.ListAuditEntries)
+	router.GET("/app/v2/transactions/:id", h.GetTransactionV2)
+	router.POST("/app/v2/patients/pay-transaction", h.SubmitTransactionV2)
+
+	testCases := []struct {
+		golden       string
+		method       string
+		path         string
+		body         string
+		expectedCode int
+	}{
+		{golden: "transaction_success.json", method: "GET", path: "/app/transactions/" + goldenTransactionID.String(), expectedCode: http.StatusOK},
+		{golden: "transaction_rejected.json", method: "GET", path: "/app/transactions/" + goldenTransactionID.String(), expectedCode: http.StatusOK},
+		{golden: "transaction_retrying.json", method: "GET", path: "/app/transactions/" + goldenTransactionID.String(), expectedCode: http.StatusOK},
+		{
+			golden:       "transaction_accepted.json",
+			method:       "POST",
+			path:         "/app/patients/pay-transaction",
+			body:         `{"patient_id":"` + goldenPatientID.String() + `","date_of_birth":"12-12-2000","record_type":"NEW"}`,
+			expectedCode: http.StatusAccepted,
+		},
+		{golden: "transaction_attempts.json", method: "GET", path: "/app/admin/transactions/" + goldenTransactionID.String() + "/attempts", expectedCode: http.StatusOK},
+		{golden: "consents.json", method: "GET", path: "/app/patients/" + goldenPatientID.String() + "/consents", expectedCode: http.StatusOK},
+		{golden: "audit_entries.json", method: "GET", path: "/app/admin/audit", expectedCode: http.StatusOK},
+		{golden: "v2_transaction_retrying.json", method: "GET", path: "/app/v2/transactions/" + goldenTransactionID.String(), expectedCode: http.StatusOK},
+		{
+			golden:       "v2_transaction_accepted.json",
+			method:       "POST",
+			path:         "/app/v2/patients/pay-transaction",
+			body:         `{"patient_id":"` + goldenPatientID.String() + `","date_of_birth":"2000-12-12","record":{"type":"NEW"}}`,
+			expectedCode: http.StatusAccepted,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.golden, func(t *testing.T) {
+			req, _ := http.NewRequest(tc.method, tc.path, bytes.NewBufferString(tc.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.expectedCode, w.Code)
+			assertGolden(t, tc.golden, w.Body.Bytes())
+		})
+	}
+}
+
+func TestPatientHandler_WithProviderResponseFields(t *testing.T) {
+	mockService := &MockPatientService{}
+	mockService.On("GetTransaction", goldenTransactionID).Return(&domain.Transaction{
+		ID:          goldenTransactionID,
+		Status:      domain.TransactionStatusSuccess,
+		APIResponse: json.RawMessage(`{"message": "Transaction success", "reference": "prov-123", "risk_score": 0.2}`),
+	}, nil)
+
+	h := NewPatientHandler(mockService, WithProviderResponseFields([]string{"reference"}))
+	router := setupTestRouter()
+	router.GET("/transactions/:id", h.GetTransaction)
+
+	req, _ := http.NewRequest("GET", "/transactions/"+goldenTransactionID.String(), nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response map[string]json.RawMessage
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.JSONEq(t, `{"reference": "prov-123"}`, string(response["api_response"]))
+}
+
+func TestUser_PasswordIsNotSerialized(t *testing.T) {
+	body, err := json.Marshal(domain.User{ID: "user-1", Email: "user@example.com", Password: "hunter2"})
+	assert.NoError(t, err)
+	assert.NotContains(t, string(body), "hunter2")
+	assert.NotContains(t, string(body), "password")
+}
@@ -0,0 +1,52 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// APIVersionHeader names the API version a response was answered with
+const APIVersionHeader = "X-API-Version"
+
+// APIVersion is the lifecycle of an API version
+type APIVersion struct {
+	Name string
+	// DeprecatedAt is when the version was deprecated, nil while it is supported
+	DeprecatedAt *time.Time
+	// SunsetAt is when the version stops being served
+	SunsetAt *time.Time
+	// Successor is the version clients of a deprecated version should move to
+	Successor string
+}
+
+// Versioned tells clients which API version answered them. Deprecated versions also answer
+// when they were deprecated (RFC 9745), when they go away (RFC 8594) and where the same
+// route is in the successor version.
+func Versioned(version APIVersion) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.Header(APIVersionHeader, version.Name)
+		if version.DeprecatedAt != nil {
+			ctx.Header("Deprecation", "@"+strconv.FormatInt(version.DeprecatedAt.Unix(), 10))
+			if link := successorPath(ctx.Request.URL.Path, version); link != "" {
+				ctx.Header("Link", "<"+link+`>; rel="successor-version"`)
+			}
+		}
+		if version.SunsetAt != nil {
+			ctx.Header("Sunset", version.SunsetAt.UTC().Format(http.TimeFormat))
+		}
+		ctx.Next()
+	}
+}
+
+// successorPath is path in the successor version, empty when path does not name its version
+func successorPath(path string, version APIVersion) string {
+	segment := "/" + version.Name + "/"
+	if version.Successor == "" || !strings.Contains(path, segment) {
+		return ""
+	}
+	return strings.Replace(path, segment, "/"+version.Successor+"/", 1)
+}
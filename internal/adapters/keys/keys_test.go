@@ -0,0 +1,94 @@
+package keys_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/aws/aws-sdk-go/service/kms/kmsiface"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/adapters/keys"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStaticKeyProvider(t *testing.T) {
+	ctx := context.Background()
+	old := bytes.Repeat([]byte{1}, 32)
+	current := bytes.Repeat([]byte{2}, 32)
+
+	previous, err := keys.NewStaticKeyProvider(map[string][]byte{"2024-01": old}, "2024-01")
+	assert.NoError(t, err)
+	plaintext, wrapped, err := previous.GenerateDataKey(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, plaintext, 32)
+	assert.NotContains(t, string(wrapped), string(plaintext))
+
+	// after rotation, data keys of the previous key are still unwrapped
+	provider, err := keys.NewStaticKeyProvider(map[string][]byte{"2024-01": old, "2024-07": current}, "2024-07")
+	assert.NoError(t, err)
+	assert.Equal(t, "2024-07", provider.KeyID())
+	unwrapped, err := provider.DecryptDataKey(ctx, "2024-01", wrapped)
+	assert.NoError(t, err)
+	assert.Equal(t, plaintext, unwrapped)
+
+	_, err = provider.DecryptDataKey(ctx, "2024-07", wrapped)
+	assert.Error(t, err)
+	_, err = provider.DecryptDataKey(ctx, "2023-01", wrapped)
+	assert.EqualError(t, err, `static key "2023-01" is not configured`)
+}
+
+func TestNewStaticKeyProvider_Invalid(t *testing.T) {
+	_, err := keys.NewStaticKeyProvider(map[string][]byte{"a": bytes.Repeat([]byte{1}, 32)}, "b")
+	assert.EqualError(t, err, `static key "b" is not configured`)
+
+	_, err = keys.NewStaticKeyProvider(map[string][]byte{"a": []byte("short")}, "a")
+	assert.EqualError(t, err, `static key "a" must be 32 bytes`)
+}
+
+func TestParseStaticKeys(t *testing.T) {
+	key := bytes.Repeat([]byte{7}, 32)
+	parsed, err := keys.ParseStaticKeys(map[string]string{"dev": base64.StdEncoding.EncodeToString(key)})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]byte{"dev": key}, parsed)
+
+	_, err = keys.ParseStaticKeys(map[string]string{"dev": "not base64!"})
+	assert.Error(t, err)
+}
+
+type fakeKMS struct {
+	kmsiface.KMSAPI
+	generate *kms.GenerateDataKeyInput
+	decrypt  *kms.DecryptInput
+}
+
+func (f *fakeKMS) GenerateDataKeyWithContext(ctx aws.Context, input *kms.GenerateDataKeyInput, opts ...request.Option) (*kms.GenerateDataKeyOutput, error) {
+	f.generate = input
+	return &kms.GenerateDataKeyOutput{Plaintext: []byte("plaintext-key"), CiphertextBlob: []byte("wrapped-key")}, nil
+}
+
+func (f *fakeKMS) DecryptWithContext(ctx aws.Context, input *kms.DecryptInput, opts ...request.Option) (*kms.DecryptOutput, error) {
+	f.decrypt = input
+	return &kms.DecryptOutput{Plaintext: []byte("plaintext-key")}, nil
+}
+
+func TestKMSKeyProvider(t *testing.T) {
+	client := &fakeKMS{}
+	provider := keys.NewKMSKeyProvider(client, "alias/patient-fields")
+	assert.Equal(t, "alias/patient-fields", provider.KeyID())
+
+	plaintext, wrapped, err := provider.GenerateDataKey(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("plaintext-key"), plaintext)
+	assert.Equal(t, []byte("wrapped-key"), wrapped)
+	assert.Equal(t, "alias/patient-fields", aws.StringValue(client.generate.KeyId))
+	assert.Equal(t, kms.DataKeySpecAes256, aws.StringValue(client.generate.KeySpec))
+
+	unwrapped, err := provider.DecryptDataKey(context.Background(), "arn:aws:kms:ap-southeast-2:123456789012:key/old", wrapped)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("plaintext-key"), unwrapped)
+	assert.Equal(t, "arn:aws:kms:ap-southeast-2:123456789012:key/old", aws.StringValue(client.decrypt.KeyId))
+	assert.Equal(t, wrapped, client.decrypt.CiphertextBlob)
+}
@@ -0,0 +1,50 @@
+package keys
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/aws/aws-sdk-go/service/kms/kmsiface"
+)
+
+// KMSKeyProvider generates data keys under a KMS key, which never leaves KMS. Data keys
+// wrapped under a previous key are unwrapped as long as the function may still use that key.
+type KMSKeyProvider struct {
+	client kmsiface.KMSAPI
+	keyID  string
+}
+
+// NewKMSKeyProvider generates data keys under keyID, a key id, ARN or alias
+func NewKMSKeyProvider(client kmsiface.KMSAPI, keyID string) *KMSKeyProvider {
+	return &KMSKeyProvider{
+		client: client,
+		keyID:  keyID,
+	}
+}
+
+func (p *KMSKeyProvider) KeyID() string {
+	return p.keyID
+}
+
+func (p *KMSKeyProvider) GenerateDataKey(ctx context.Context) ([]byte, []byte, error) {
+	out, err := p.client.GenerateDataKeyWithContext(ctx, &kms.GenerateDataKeyInput{
+		KeyId:   aws.String(p.keyID),
+		KeySpec: aws.String(kms.DataKeySpecAes256),
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return out.Plaintext, out.CiphertextBlob, nil
+}
+
+func (p *KMSKeyProvider) DecryptDataKey(ctx context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	out, err := p.client.DecryptWithContext(ctx, &kms.DecryptInput{
+		KeyId:          aws.String(keyID),
+		CiphertextBlob: wrapped,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out.Plaintext, nil
+}
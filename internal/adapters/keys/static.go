@@ -0,0 +1,94 @@
+package keys
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+// dataKeySize is the size of AES-256 keys, for master and data keys alike
+const dataKeySize = 32
+
+// StaticKeyProvider wraps data keys under master keys held in memory. It is meant for local
+// development and tests, KMSKeyProvider keeps the master keys out of the process.
+type StaticKeyProvider struct {
+	keys  map[string]cipher.AEAD
+	keyID string
+}
+
+// NewStaticKeyProvider wraps new data keys under the key keyID of keys. The other keys only
+// unwrap data keys, until the data encrypted with them is re-encrypted.
+func NewStaticKeyProvider(keys map[string][]byte, keyID string) (*StaticKeyProvider, error) {
+	if _, ok := keys[keyID]; !ok {
+		return nil, fmt.Errorf("static key %q is not configured", keyID)
+	}
+
+	p := &StaticKeyProvider{keys: map[string]cipher.AEAD{}, keyID: keyID}
+	for id, key := range keys {
+		if len(key) != dataKeySize {
+			return nil, fmt.Errorf("static key %q must be %d bytes", id, dataKeySize)
+		}
+		aead, err := newAEAD(key)
+		if err != nil {
+			return nil, err
+		}
+		p.keys[id] = aead
+	}
+	return p, nil
+}
+
+// ParseStaticKeys decodes base64 keys keyed by their id
+func ParseStaticKeys(values map[string]string) (map[string][]byte, error) {
+	keys := map[string][]byte{}
+	for id, value := range values {
+		key, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return nil, fmt.Errorf("static key %q is not base64: %w", id, err)
+		}
+		keys[id] = key
+	}
+	return keys, nil
+}
+
+func (p *StaticKeyProvider) KeyID() string {
+	return p.keyID
+}
+
+func (p *StaticKeyProvider) GenerateDataKey(ctx context.Context) ([]byte, []byte, error) {
+	plaintext := make([]byte, dataKeySize)
+	if _, err := rand.Read(plaintext); err != nil {
+		return nil, nil, err
+	}
+
+	aead := p.keys[p.keyID]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, nil, err
+	}
+	return plaintext, aead.Seal(nonce, nonce, plaintext, []byte(p.keyID)), nil
+}
+
+func (p *StaticKeyProvider) DecryptDataKey(ctx context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	aead, ok := p.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("static key %q is not configured", keyID)
+	}
+	if len(wrapped) < aead.NonceSize() {
+		return nil, errors.New("wrapped data key is truncated")
+	}
+
+	nonce, sealed := wrapped[:aead.NonceSize()], wrapped[aead.NonceSize():]
+	return aead.Open(nil, nonce, sealed, []byte(keyID))
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,124 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/datphamcode295/go-lambda-pulumi/internal/core/domain"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/tracing"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+const (
+	APIKeyHeader = "X-API-Key"
+
+	// maxResponseBody caps how much of a provider response is kept
+	maxResponseBody = 64 << 10
+)
+
+// HTTPProvider submits patients to the provider's REST API
+type HTTPProvider struct {
+	url       string
+	healthURL string
+	apiKey    string
+	client    *http.Client
+}
+
+// NewHTTPProvider creates a client for the provider at url. healthURL is used for
+// reachability checks and defaults to url.
+func NewHTTPProvider(url, healthURL, apiKey string, timeout time.Duration) *HTTPProvider {
+	if healthURL == "" {
+		healthURL = url
+	}
+	return &HTTPProvider{
+		url:       url,
+		healthURL: healthURL,
+		apiKey:    apiKey,
+		client:    &http.Client{Timeout: timeout},
+	}
+}
+
+func (h *HTTPProvider) SubmitPatient(ctx context.Context, req domain.SubmitPatientRequest) (*domain.ProviderResponse, error) {
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, h.url, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set(APIKeyHeader, h.apiKey)
+	tracing.Propagator().Inject(ctx, propagation.HeaderCarrier(httpReq.Header))
+
+	resp, err := h.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("call provider: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBody))
+	if err != nil {
+		return nil, fmt.Errorf("read provider response: %w", err)
+	}
+	if !json.Valid(body) {
+		// keep the raw text but as valid JSON so it can be stored on the transaction
+		body, _ = json.Marshal(map[string]string{"raw": string(body)})
+	}
+
+	return &domain.ProviderResponse{
+		Success:    resp.StatusCode >= 200 && resp.StatusCode < 300,
+		StatusCode: resp.StatusCode,
+		Body:       body,
+		Retryable:  isRetryableStatus(resp.StatusCode),
+		Headers:    headersOfInterest(resp.Header),
+	}, nil
+}
+
+// keptHeaders are the response headers recorded with each attempt
+var keptHeaders = []string{"Content-Type", "Retry-After", "X-Request-Id", "X-Amzn-Requestid", "X-Correlation-Id"}
+
+func headersOfInterest(header http.Header) map[string]string {
+	headers := map[string]string{}
+	for _, name := range keptHeaders {
+		if value := header.Get(name); value != "" {
+			headers[name] = value
+		}
+	}
+	return headers
+}
+
+// isRetryableStatus reports whether a failure is on the provider side rather than a
+// rejection of the patient
+func isRetryableStatus(status int) bool {
+	return status >= http.StatusInternalServerError ||
+		status == http.StatusTooManyRequests ||
+		status == http.StatusRequestTimeout
+}
+
+// Ping succeeds when the provider answers with anything but a server error
+func (h *HTTPProvider) Ping(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, h.healthURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set(APIKeyHeader, h.apiKey)
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("provider unreachable: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("provider unhealthy: status %d", resp.StatusCode)
+	}
+	return nil
+}
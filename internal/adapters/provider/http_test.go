@@ -0,0 +1,121 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/datphamcode295/go-lambda-pulumi/internal/core/domain"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/tracing"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestHTTPProvider_SubmitPatient(t *testing.T) {
+	otel.SetTracerProvider(sdktrace.NewTracerProvider())
+
+	testCases := []struct {
+		name            string
+		status          int
+		body            string
+		expectedSuccess bool
+		expectedBody    string
+		retryable       bool
+	}{
+		{
+			name:            "Accepted",
+			status:          http.StatusOK,
+			body:            `{"message":"Transaction success"}`,
+			expectedSuccess: true,
+			expectedBody:    `{"message":"Transaction success"}`,
+		},
+		{
+			name:            "Rejected",
+			status:          http.StatusUnprocessableEntity,
+			body:            `{"error":"Transaction failed"}`,
+			expectedSuccess: false,
+			expectedBody:    `{"error":"Transaction failed"}`,
+		},
+		{
+			name:            "Non JSON body",
+			status:          http.StatusBadGateway,
+			body:            `upstream error`,
+			expectedSuccess: false,
+			expectedBody:    `{"raw":"upstream error"}`,
+			retryable:       true,
+		},
+		{
+			name:            "Rate limited",
+			status:          http.StatusTooManyRequests,
+			body:            `{"error":"slow down"}`,
+			expectedSuccess: false,
+			expectedBody:    `{"error":"slow down"}`,
+			retryable:       true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var received domain.SubmitPatientRequest
+			var headers http.Header
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				headers = r.Header
+				json.NewDecoder(r.Body).Decode(&received)
+				w.Header().Set("X-Request-Id", "provider-request-1")
+				w.Header().Set("Set-Cookie", "session=secret")
+				w.WriteHeader(tc.status)
+				w.Write([]byte(tc.body))
+			}))
+			defer server.Close()
+
+			client := NewHTTPProvider(server.URL, "", "api-key", time.Second)
+			ctx, span := tracing.Tracer().Start(context.Background(), "test")
+			defer span.End()
+
+			patient := domain.ProviderPatient{ID: uuid.New().String()}
+			resp, err := client.SubmitPatient(ctx, domain.SubmitPatientRequest{Patient: patient, Age: 30, RecordType: "NEW"})
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedSuccess, resp.Success)
+			assert.Equal(t, tc.status, resp.StatusCode)
+			assert.JSONEq(t, tc.expectedBody, string(resp.Body))
+			assert.Equal(t, tc.retryable, resp.Retryable)
+			assert.Equal(t, "provider-request-1", resp.Headers["X-Request-Id"])
+			assert.NotContains(t, resp.Headers, "Set-Cookie")
+			assert.Equal(t, patient.ID, received.Patient.ID)
+			assert.Equal(t, "api-key", headers.Get(APIKeyHeader))
+			assert.NotEmpty(t, headers.Get("traceparent"))
+		})
+	}
+}
+
+func TestHTTPProvider_SubmitPatient_Unreachable(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.Close()
+
+	client := NewHTTPProvider(server.URL, "", "api-key", time.Second)
+	resp, err := client.SubmitPatient(context.Background(), domain.SubmitPatientRequest{})
+
+	assert.Error(t, err)
+	assert.Nil(t, resp)
+}
+
+func TestHTTPProvider_Ping(t *testing.T) {
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/health", r.URL.Path)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	client := NewHTTPProvider(server.URL+"/submit", server.URL+"/health", "api-key", time.Second)
+	assert.NoError(t, client.Ping(context.Background()))
+
+	status = http.StatusServiceUnavailable
+	assert.EqualError(t, client.Ping(context.Background()), "provider unhealthy: status 503")
+}
@@ -0,0 +1,51 @@
+package provider
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"math/big"
+	"net/http"
+
+	"github.com/datphamcode295/go-lambda-pulumi/internal/core/domain"
+)
+
+// SimulatedProvider stands in for the external provider, succeeding or failing at random
+type SimulatedProvider struct{}
+
+func NewSimulatedProvider() *SimulatedProvider {
+	return &SimulatedProvider{}
+}
+
+func (s *SimulatedProvider) SubmitPatient(ctx context.Context, req domain.SubmitPatientRequest) (*domain.ProviderResponse, error) {
+	isSuccess, err := rand.Int(rand.Reader, big.NewInt(2))
+	if err != nil {
+		return nil, err
+	}
+
+	// if isSuccess is 0 means transaction failed
+	if isSuccess.Int64() == 0 {
+		dummyFailedBody := map[string]string{
+			"error": "Transaction failed",
+		}
+		jsonBody, err := json.Marshal(dummyFailedBody)
+		if err != nil {
+			return nil, err
+		}
+		// failures are random, so they are answered as the transient outage a later submission may get past
+		return &domain.ProviderResponse{Success: false, StatusCode: http.StatusServiceUnavailable, Body: jsonBody, Retryable: isRetryableStatus(http.StatusServiceUnavailable)}, nil
+	}
+
+	dummySuccessBody := map[string]string{
+		"message": "Transaction success",
+	}
+	jsonBody, err := json.Marshal(dummySuccessBody)
+	if err != nil {
+		return nil, err
+	}
+	return &domain.ProviderResponse{Success: true, StatusCode: http.StatusOK, Body: jsonBody}, nil
+}
+
+func (s *SimulatedProvider) Ping(ctx context.Context) error {
+	return nil
+}
@@ -0,0 +1,30 @@
+package provider
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/datphamcode295/go-lambda-pulumi/internal/core/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestSimulatedProvider_FailuresAreTransient keeps the simulated failures classified as the
+// HTTP provider classifies the same status
+func TestSimulatedProvider_FailuresAreTransient(t *testing.T) {
+	p := NewSimulatedProvider()
+	seen := map[bool]bool{}
+	for i := 0; i < 200 && len(seen) < 2; i++ {
+		rs, err := p.SubmitPatient(context.Background(), domain.SubmitPatientRequest{})
+		assert.NoError(t, err)
+		seen[rs.Success] = true
+		if rs.Success {
+			assert.Equal(t, http.StatusOK, rs.StatusCode)
+			continue
+		}
+		assert.Equal(t, http.StatusServiceUnavailable, rs.StatusCode)
+		assert.True(t, rs.Retryable)
+		assert.Equal(t, isRetryableStatus(rs.StatusCode), rs.Retryable)
+	}
+	assert.Len(t, seen, 2)
+}
@@ -0,0 +1,141 @@
+package queue
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/datphamcode295/go-lambda-pulumi/internal/core/domain"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/logger"
+	"github.com/sirupsen/logrus"
+)
+
+// MemoryQueue is a local stand-in for SQS. A received job stays invisible for the visibility
+// timeout and is delivered again unless deleted; after maxReceives deliveries it is moved to
+// the dead letters instead.
+type MemoryQueue struct {
+	visibilityTimeout time.Duration
+	maxReceives       int
+	now               func() time.Time
+
+	mu          sync.Mutex
+	nextID      int
+	messages    []*memoryMessage
+	deadLetters []domain.Job
+}
+
+type memoryMessage struct {
+	id        string
+	job       domain.Job
+	receives  int
+	visibleAt time.Time
+}
+
+// Delivery is a job handed to a consumer by Receive
+type Delivery struct {
+	ID       string
+	Job      domain.Job
+	Receives int
+}
+
+func NewMemoryQueue(visibilityTimeout time.Duration, maxReceives int) *MemoryQueue {
+	return &MemoryQueue{
+		visibilityTimeout: visibilityTimeout,
+		maxReceives:       maxReceives,
+		now:               time.Now,
+	}
+}
+
+func (q *MemoryQueue) Enqueue(ctx context.Context, job domain.Job) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.nextID++
+	q.messages = append(q.messages, &memoryMessage{
+		id:  strconv.Itoa(q.nextID),
+		job: withMetadata(ctx, job),
+	})
+	return nil
+}
+
+// Receive returns up to max visible jobs and hides them for the visibility timeout
+func (q *MemoryQueue) Receive(max int) []Delivery {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := q.now()
+	var deliveries []Delivery
+	remaining := q.messages[:0]
+	for _, message := range q.messages {
+		if len(deliveries) == max || now.Before(message.visibleAt) {
+			remaining = append(remaining, message)
+			continue
+		}
+		if q.maxReceives > 0 && message.receives >= q.maxReceives {
+			q.deadLetters = append(q.deadLetters, message.job)
+			continue
+		}
+
+		message.receives++
+		message.visibleAt = now.Add(q.visibilityTimeout)
+		deliveries = append(deliveries, Delivery{ID: message.id, Job: message.job, Receives: message.receives})
+		remaining = append(remaining, message)
+	}
+	q.messages = remaining
+	return deliveries
+}
+
+// Delete removes a processed job
+func (q *MemoryQueue) Delete(id string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for i, message := range q.messages {
+		if message.id == id {
+			q.messages = append(q.messages[:i], q.messages[i+1:]...)
+			return
+		}
+	}
+}
+
+// Len returns the number of jobs waiting or in flight
+func (q *MemoryQueue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.messages)
+}
+
+// DeadLetters returns the jobs that exhausted their deliveries
+func (q *MemoryQueue) DeadLetters() []domain.Job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return append([]domain.Job(nil), q.deadLetters...)
+}
+
+// Run polls the queue and passes every job to handle until ctx is done. Jobs are deleted when
+// handle succeeds and delivered again after the visibility timeout when it fails.
+func (q *MemoryQueue) Run(ctx context.Context, handle func(ctx context.Context, job domain.Job) error, pollInterval time.Duration) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		for _, delivery := range q.Receive(10) {
+			jobCtx := ContextFromJob(ctx, delivery.Job)
+			if err := handle(jobCtx, delivery.Job); err != nil {
+				logger.FromContext(jobCtx).WithError(err).WithFields(logrus.Fields{
+					"job_type": delivery.Job.Type,
+					"receives": delivery.Receives,
+				}).Warn("Job failed, it will be retried after the visibility timeout")
+				continue
+			}
+			q.Delete(delivery.ID)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
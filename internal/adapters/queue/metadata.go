@@ -0,0 +1,35 @@
+package queue
+
+import (
+	"context"
+
+	"github.com/datphamcode295/go-lambda-pulumi/internal/core/domain"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/requestctx"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/tracing"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+const requestIDKey = "request_id"
+
+// withMetadata copies the request ID and trace context of ctx into the job
+func withMetadata(ctx context.Context, job domain.Job) domain.Job {
+	metadata := map[string]string{}
+	for k, v := range job.Metadata {
+		metadata[k] = v
+	}
+	if requestID := requestctx.RequestID(ctx); requestID != "" {
+		metadata[requestIDKey] = requestID
+	}
+	tracing.Propagator().Inject(ctx, propagation.MapCarrier(metadata))
+
+	job.Metadata = metadata
+	return job
+}
+
+// ContextFromJob restores the request ID and trace context of the request that enqueued job
+func ContextFromJob(ctx context.Context, job domain.Job) context.Context {
+	if requestID := job.Metadata[requestIDKey]; requestID != "" {
+		ctx = requestctx.WithRequestID(ctx, requestID)
+	}
+	return tracing.Propagator().Extract(ctx, propagation.MapCarrier(job.Metadata))
+}
@@ -0,0 +1,130 @@
+package queue_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/adapters/queue"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/core/domain"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/requestctx"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/tracing"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func newJob() domain.Job {
+	return domain.Job{Type: domain.JobTypeProcessTransaction, TransactionID: uuid.New()}
+}
+
+func TestMemoryQueue_VisibilityTimeout(t *testing.T) {
+	q := queue.NewMemoryQueue(time.Minute, 3)
+	assert.NoError(t, q.Enqueue(context.Background(), newJob()))
+
+	deliveries := q.Receive(10)
+	assert.Len(t, deliveries, 1)
+	assert.Equal(t, 1, deliveries[0].Receives)
+
+	// in flight until the visibility timeout expires
+	assert.Empty(t, q.Receive(10))
+	assert.Equal(t, 1, q.Len())
+
+	q.Delete(deliveries[0].ID)
+	assert.Equal(t, 0, q.Len())
+}
+
+func TestMemoryQueue_RedeliversThenDeadLetters(t *testing.T) {
+	q := queue.NewMemoryQueue(0, 2)
+	job := newJob()
+	assert.NoError(t, q.Enqueue(context.Background(), job))
+
+	assert.Equal(t, 1, q.Receive(10)[0].Receives)
+	assert.Equal(t, 2, q.Receive(10)[0].Receives)
+
+	// maxReceives reached, the job is moved aside instead of delivered again
+	assert.Empty(t, q.Receive(10))
+	assert.Equal(t, 0, q.Len())
+	deadLetters := q.DeadLetters()
+	assert.Len(t, deadLetters, 1)
+	assert.Equal(t, job.TransactionID, deadLetters[0].TransactionID)
+}
+
+func TestMemoryQueue_Run(t *testing.T) {
+	q := queue.NewMemoryQueue(0, 5)
+	ok, flaky := newJob(), newJob()
+	assert.NoError(t, q.Enqueue(context.Background(), ok))
+	assert.NoError(t, q.Enqueue(context.Background(), flaky))
+
+	attempts := map[uuid.UUID]int{}
+	handled := make(chan struct{})
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		defer close(handled)
+		q.Run(ctx, func(ctx context.Context, job domain.Job) error {
+			attempts[job.TransactionID]++
+			if job.TransactionID == flaky.TransactionID && attempts[job.TransactionID] < 3 {
+				return errors.New("provider unreachable")
+			}
+			if attempts[flaky.TransactionID] == 3 {
+				cancel()
+			}
+			return nil
+		}, time.Millisecond)
+	}()
+	<-handled
+
+	assert.Equal(t, 1, attempts[ok.TransactionID])
+	assert.Equal(t, 3, attempts[flaky.TransactionID])
+	assert.Equal(t, 0, q.Len())
+	assert.Empty(t, q.DeadLetters())
+}
+
+func TestContextFromJob_RestoresRequestAndTrace(t *testing.T) {
+	otel.SetTracerProvider(sdktrace.NewTracerProvider())
+	ctx := requestctx.WithRequestID(context.Background(), "req-1")
+	ctx, span := tracing.Tracer().Start(ctx, "enqueue")
+	defer span.End()
+
+	q := queue.NewMemoryQueue(time.Minute, 3)
+	assert.NoError(t, q.Enqueue(ctx, newJob()))
+	job := q.Receive(1)[0].Job
+
+	jobCtx := queue.ContextFromJob(context.Background(), job)
+	assert.Equal(t, "req-1", requestctx.RequestID(jobCtx))
+	assert.Equal(t, span.SpanContext().TraceID(), trace.SpanContextFromContext(jobCtx).TraceID())
+}
+
+type fakeSQS struct {
+	sqsiface.SQSAPI
+	input *sqs.SendMessageInput
+}
+
+func (f *fakeSQS) SendMessageWithContext(ctx aws.Context, input *sqs.SendMessageInput, opts ...request.Option) (*sqs.SendMessageOutput, error) {
+	f.input = input
+	return &sqs.SendMessageOutput{MessageId: aws.String("message-1")}, nil
+}
+
+func TestSQSQueue_Enqueue(t *testing.T) {
+	client := &fakeSQS{}
+	q := queue.NewSQSQueue(client, "https://sqs.ap-southeast-2.amazonaws.com/123456789012/jobs")
+	job := newJob()
+
+	assert.NoError(t, q.Enqueue(requestctx.WithRequestID(context.Background(), "req-1"), job))
+
+	assert.Equal(t, "https://sqs.ap-southeast-2.amazonaws.com/123456789012/jobs", aws.StringValue(client.input.QueueUrl))
+	assert.Equal(t, "process_transaction", aws.StringValue(client.input.MessageAttributes["job_type"].StringValue))
+
+	var sent domain.Job
+	assert.NoError(t, json.Unmarshal([]byte(aws.StringValue(client.input.MessageBody)), &sent))
+	assert.Equal(t, job.TransactionID, sent.TransactionID)
+	assert.Equal(t, "req-1", sent.Metadata["request_id"])
+}
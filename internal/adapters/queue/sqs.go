@@ -0,0 +1,47 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/core/domain"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/logger"
+)
+
+// SQSQueue sends jobs to an SQS queue consumed by the function's SQS trigger. Retries
+// follow the queue's visibility timeout and the dead-letter queue of its redrive policy.
+type SQSQueue struct {
+	client   sqsiface.SQSAPI
+	queueURL string
+}
+
+func NewSQSQueue(client sqsiface.SQSAPI, queueURL string) *SQSQueue {
+	return &SQSQueue{
+		client:   client,
+		queueURL: queueURL,
+	}
+}
+
+func (q *SQSQueue) Enqueue(ctx context.Context, job domain.Job) error {
+	body, err := json.Marshal(withMetadata(ctx, job))
+	if err != nil {
+		return err
+	}
+
+	out, err := q.client.SendMessageWithContext(ctx, &sqs.SendMessageInput{
+		QueueUrl:    aws.String(q.queueURL),
+		MessageBody: aws.String(string(body)),
+		MessageAttributes: map[string]*sqs.MessageAttributeValue{
+			"job_type": {DataType: aws.String("String"), StringValue: aws.String(string(job.Type))},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	logger.FromContext(ctx).WithField("message_id", aws.StringValue(out.MessageId)).Debug("Job enqueued")
+	return nil
+}
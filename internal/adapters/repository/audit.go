@@ -0,0 +1,123 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/datphamcode295/go-lambda-pulumi/internal/core/domain"
+	"github.com/jinzhu/gorm"
+)
+
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+// auditChainLock is the postgres advisory lock serializing appends to the audit log
+const auditChainLock = 7305093121
+
+// AppendAuditEntry chains the entry to the last one. Called inside InTransaction, the entry is
+// only stored if the transaction commits, along with the change it records.
+func (u *DB) AppendAuditEntry(ctx context.Context, entry domain.AuditEntry) (*domain.AuditEntry, error) {
+	err := u.InTransaction(ctx, func(ctx context.Context) error {
+		tx, err := u.withContext(ctx)
+		if err != nil {
+			return err
+		}
+
+		// concurrent appends would read the same last entry, the unique sequence would reject
+		// one. The lock is held until the transaction ends and does not block readers.
+		if tx.Dialect().GetName() == "postgres" {
+			if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", auditChainLock).Error; err != nil {
+				return err
+			}
+		}
+
+		last := &domain.AuditEntry{}
+		req := tx.Order("sequence desc").First(last)
+		if req.Error != nil && !req.RecordNotFound() {
+			return req.Error
+		}
+
+		entry.Sequence = last.Sequence + 1
+		entry.PrevHash = last.Hash
+		entry.CreatedAt = entry.CreatedAt.UTC().Truncate(time.Microsecond)
+		entry.Hash = entry.ComputeHash()
+		return tx.Create(&entry).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &entry, nil
+}
+
+func (u *DB) ListAuditEntries(ctx context.Context, filter domain.AuditFilter) ([]domain.AuditEntry, error) {
+	db, err := u.withContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultAuditLimit
+	}
+	if limit > maxAuditLimit {
+		limit = maxAuditLimit
+	}
+
+	var entries []domain.AuditEntry
+	req := auditFilter(db, filter).Order("sequence").Limit(limit).Find(&entries)
+	if req.Error != nil {
+		return nil, req.Error
+	}
+
+	for i := range entries {
+		entries[i].CreatedAt = entries[i].CreatedAt.UTC()
+	}
+	return entries, nil
+}
+
+func auditFilter(db *gorm.DB, filter domain.AuditFilter) *gorm.DB {
+	db = db.Where("sequence > ?", filter.AfterSequence)
+	if filter.Actor != "" {
+		db = db.Where("actor = ?", filter.Actor)
+	}
+	if filter.Action != "" {
+		db = db.Where("action = ?", filter.Action)
+	}
+	if filter.EntityType != "" {
+		db = db.Where("entity_type = ?", filter.EntityType)
+	}
+	if filter.EntityID != "" {
+		db = db.Where("entity_id = ?", filter.EntityID)
+	}
+	if filter.From != nil {
+		db = db.Where("created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		db = db.Where("created_at < ?", *filter.To)
+	}
+	return db
+}
+
+// auditImmutabilityTrigger makes postgres refuse to change or delete audit entries
+const auditImmutabilityTrigger = `
+CREATE OR REPLACE FUNCTION audit_entries_immutable() RETURNS trigger AS $$
+BEGIN
+	RAISE EXCEPTION 'audit entries are append-only';
+END;
+$$ LANGUAGE plpgsql;
+DROP TRIGGER IF EXISTS audit_entries_immutable ON audit_entries;
+CREATE TRIGGER audit_entries_immutable BEFORE UPDATE OR DELETE ON audit_entries
+	FOR EACH ROW EXECUTE PROCEDURE audit_entries_immutable();
+`
+
+// ProtectAuditLog stops the database from updating or deleting audit entries. Only postgres is
+// protected, other databases rely on the hash chain alone.
+func ProtectAuditLog(db *gorm.DB) error {
+	if db.Dialect().GetName() != "postgres" {
+		return nil
+	}
+	return db.Exec(auditImmutabilityTrigger).Error
+}
@@ -0,0 +1,69 @@
+package repository_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/datphamcode295/go-lambda-pulumi/internal/adapters/repository"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/core/domain"
+	"github.com/google/uuid"
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuditEntries(t *testing.T) {
+	db, err := gorm.Open("sqlite3", ":memory:")
+	assert.NoError(t, err)
+	db.DB().SetMaxOpenConns(1)
+	db.AutoMigrate(&domain.AuditEntry{})
+	assert.NoError(t, repository.ProtectAuditLog(db))
+	repo := repository.NewDB(db)
+	ctx := context.Background()
+
+	now := time.Date(2024, time.June, 14, 12, 0, 0, 123456789, time.UTC)
+	entries := []domain.AuditEntry{
+		{Actor: "user-1", Action: domain.AuditActionCreate, EntityType: domain.AuditEntityTransaction, EntityID: "t-1", Changes: json.RawMessage(`{"status":{"after":"SUCCESS","before":null}}`), CreatedAt: now.Add(-time.Hour)},
+		{Actor: "admin", Action: domain.AuditActionView, EntityType: domain.AuditEntityTransaction, EntityID: "t-1", CreatedAt: now},
+		{Actor: "user-1", Action: domain.AuditActionView, EntityType: domain.AuditEntityPatient, EntityID: "p-1", CreatedAt: now},
+	}
+	var appended []domain.AuditEntry
+	for _, entry := range entries {
+		entry.ID = uuid.New()
+		rs, err := repo.AppendAuditEntry(ctx, entry)
+		assert.NoError(t, err)
+		appended = append(appended, *rs)
+	}
+
+	// numbered and chained in order
+	assert.Equal(t, int64(1), appended[0].Sequence)
+	assert.Empty(t, appended[0].PrevHash)
+	assert.Equal(t, int64(3), appended[2].Sequence)
+	assert.Equal(t, appended[1].Hash, appended[2].PrevHash)
+
+	listed, err := repo.ListAuditEntries(ctx, domain.AuditFilter{})
+	assert.NoError(t, err)
+	if assert.Len(t, listed, 3) {
+		assert.NoError(t, domain.VerifyAuditChain(nil, listed))
+		assert.Equal(t, appended[0].CreatedAt, listed[0].CreatedAt)
+	}
+
+	filtered, err := repo.ListAuditEntries(ctx, domain.AuditFilter{Actor: "user-1", EntityType: domain.AuditEntityTransaction})
+	assert.NoError(t, err)
+	assert.Len(t, filtered, 1)
+
+	from := now.Add(-time.Minute)
+	filtered, err = repo.ListAuditEntries(ctx, domain.AuditFilter{Action: domain.AuditActionView, From: &from, AfterSequence: 2})
+	assert.NoError(t, err)
+	if assert.Len(t, filtered, 1) {
+		assert.Equal(t, "p-1", filtered[0].EntityID)
+	}
+
+	// a changed entry breaks the chain
+	db.Model(&domain.AuditEntry{}).Where("sequence = ?", 2).Update("actor", "someone-else")
+	listed, err = repo.ListAuditEntries(ctx, domain.AuditFilter{})
+	assert.NoError(t, err)
+	assert.EqualError(t, domain.VerifyAuditChain(nil, listed), "audit entry 2: hash does not match its content")
+}
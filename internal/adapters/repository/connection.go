@@ -0,0 +1,155 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/datphamcode295/go-lambda-pulumi/internal/config"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/logger"
+	"github.com/jinzhu/gorm"
+)
+
+// Connection opens the database on first use instead of at startup, so a database that is
+// briefly unreachable fails the requests that need it rather than crashing the function
+type Connection struct {
+	cfg     config.DatabaseConfig
+	open    func() (*gorm.DB, error)
+	setup   func(*gorm.DB) error
+	migrate func(*gorm.DB) error
+	now     func() time.Time
+
+	mu       sync.Mutex
+	db       *gorm.DB
+	lastUsed time.Time
+	migrated bool
+}
+
+// NewConnection creates a connection that calls open when the database is first needed. setup,
+// e.g. to register callbacks, runs every time a new handle is opened, and migrate only on the
+// first handle, until it succeeds, so reconnecting after an idle period does not lock tables.
+func NewConnection(cfg config.DatabaseConfig, open func() (*gorm.DB, error), setup, migrate func(*gorm.DB) error) *Connection {
+	return &Connection{
+		cfg:     cfg,
+		open:    open,
+		setup:   setup,
+		migrate: migrate,
+		now:     time.Now,
+	}
+}
+
+// Get returns the open handle, connecting or reconnecting as needed
+func (c *Connection) Get(ctx context.Context) (*gorm.DB, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	if c.db != nil && c.open != nil && c.cfg.PingAfterIdle > 0 && now.Sub(c.lastUsed) > c.cfg.PingAfterIdle {
+		// the environment may have been frozen long enough for the server to drop our connections
+		if err := c.db.DB().PingContext(ctx); err != nil {
+			logger.FromContext(ctx).WithError(err).Warn("Database did not answer after idle period, reconnecting")
+			c.db.Close()
+			c.db = nil
+		}
+	}
+
+	if c.db == nil {
+		db, err := c.connect(ctx)
+		if err != nil {
+			return nil, err
+		}
+		c.db = db
+	}
+
+	c.lastUsed = now
+	return c.db, nil
+}
+
+func (c *Connection) connect(ctx context.Context) (*gorm.DB, error) {
+	if c.open == nil {
+		return nil, fmt.Errorf("database connection is closed")
+	}
+
+	attempts := c.cfg.ConnectAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+	backoff := c.cfg.ConnectBackoff
+
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		var db *gorm.DB
+		if db, err = c.open(); err == nil {
+			c.configurePool(db.DB())
+			if err = c.prepare(db); err == nil {
+				return db, nil
+			}
+			db.Close()
+		}
+
+		logger.FromContext(ctx).WithError(err).WithField("attempt", attempt).Warn("Failed to connect to database")
+		if attempt == attempts {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+	return nil, fmt.Errorf("connect to database after %d attempts: %w", attempts, err)
+}
+
+// prepare runs the setup of a new handle, and the migration when none has succeeded yet
+func (c *Connection) prepare(db *gorm.DB) error {
+	if c.setup != nil {
+		if err := c.setup(db); err != nil {
+			return err
+		}
+	}
+	if c.migrate != nil && !c.migrated {
+		if err := c.migrate(db); err != nil {
+			return err
+		}
+		c.migrated = true
+	}
+	return nil
+}
+
+func (c *Connection) configurePool(db *sql.DB) {
+	if c.cfg.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(c.cfg.MaxOpenConns)
+	}
+	if c.cfg.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(c.cfg.MaxIdleConns)
+	}
+	db.SetConnMaxLifetime(c.cfg.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(c.cfg.ConnMaxIdleTime)
+}
+
+// Stats returns the pool statistics, which are all zero until the database is first used
+func (c *Connection) Stats() sql.DBStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.db == nil {
+		return sql.DBStats{}
+	}
+	return c.db.DB().Stats()
+}
+
+// Close closes the open handle, the next Get opens a new one
+func (c *Connection) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.db == nil {
+		return nil
+	}
+	err := c.db.Close()
+	c.db = nil
+	return err
+}
@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/datphamcode295/go-lambda-pulumi/internal/core/domain"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/logger"
+)
+
+func (u *DB) CreateConsent(ctx context.Context, consent domain.GuardianConsent) (*domain.GuardianConsent, error) {
+	db, err := u.withContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	req := db.Create(&consent)
+	if req.Error != nil {
+		return nil, req.Error
+	}
+	if req.RowsAffected == 0 {
+		return nil, errors.New("consent not created")
+	}
+
+	return &consent, nil
+}
+
+func (u *DB) GetConsent(ctx context.Context, id string) (*domain.GuardianConsent, error) {
+	consent := &domain.GuardianConsent{}
+
+	db, err := u.withContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	req := db.First(consent, "id = ?", id)
+	if req.RecordNotFound() {
+		logger.FromContext(ctx).WithField("consent_id", id).Debug("Consent not found")
+		return nil, domain.ErrConsentNotFound
+	}
+	if req.Error != nil {
+		return nil, req.Error
+	}
+	if err := u.decryptFields(ctx, consent); err != nil {
+		return nil, err
+	}
+
+	return consent, nil
+}
+
+func (u *DB) UpdateConsent(ctx context.Context, consent domain.GuardianConsent) (*domain.GuardianConsent, error) {
+	db, err := u.withContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	req := db.Save(&consent)
+	if req.Error != nil {
+		return nil, req.Error
+	}
+
+	return &consent, nil
+}
+
+func (u *DB) ListConsents(ctx context.Context, patientID string) ([]domain.GuardianConsent, error) {
+	db, err := u.withContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var consents []domain.GuardianConsent
+	req := db.Where("patient_id = ?", patientID).Order("granted_at desc").Find(&consents)
+	if req.Error != nil {
+		return nil, req.Error
+	}
+	for i := range consents {
+		if err := u.decryptFields(ctx, &consents[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	return consents, nil
+}
@@ -0,0 +1,97 @@
+package repository_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/datphamcode295/go-lambda-pulumi/internal/adapters/repository"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/core/domain"
+	"github.com/google/uuid"
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConsents(t *testing.T) {
+	db, err := gorm.Open("sqlite3", ":memory:")
+	assert.NoError(t, err)
+	db.AutoMigrate(&domain.GuardianConsent{})
+	repo := repository.NewDB(db)
+	ctx := context.Background()
+
+	patientID := uuid.New()
+	now := time.Now().UTC().Truncate(time.Second)
+	older := domain.GuardianConsent{ID: uuid.New(), PatientID: patientID, GuardianUserID: "user-1", Relationship: "parent", EvidenceReference: "form-1", GrantedAt: now.Add(-time.Hour)}
+	newer := domain.GuardianConsent{ID: uuid.New(), PatientID: patientID, GuardianContact: "guardian@example.com", Relationship: "legal_guardian", EvidenceReference: "form-2", GrantedAt: now}
+	other := domain.GuardianConsent{ID: uuid.New(), PatientID: uuid.New(), GuardianUserID: "user-2", Relationship: "parent", EvidenceReference: "form-3", GrantedAt: now}
+	for _, consent := range []domain.GuardianConsent{older, newer, other} {
+		_, err := repo.CreateConsent(ctx, consent)
+		assert.NoError(t, err)
+	}
+
+	// newest first, other patients excluded
+	listed, err := repo.ListConsents(ctx, patientID.String())
+	assert.NoError(t, err)
+	if assert.Len(t, listed, 2) {
+		assert.Equal(t, newer.ID, listed[0].ID)
+		assert.Equal(t, older.ID, listed[1].ID)
+	}
+
+	older.RevokedAt = &now
+	older.RevocationReason = "guardian withdrew"
+	_, err = repo.UpdateConsent(ctx, older)
+	assert.NoError(t, err)
+
+	found, err := repo.GetConsent(ctx, older.ID.String())
+	assert.NoError(t, err)
+	if assert.NotNil(t, found.RevokedAt) {
+		assert.True(t, now.Equal(*found.RevokedAt))
+	}
+	assert.Equal(t, "guardian withdrew", found.RevocationReason)
+
+	_, err = repo.GetConsent(ctx, uuid.NewString())
+	assert.ErrorIs(t, err, domain.ErrConsentNotFound)
+}
+
+func TestConsentFieldEncryption(t *testing.T) {
+	db, err := gorm.Open("sqlite3", ":memory:")
+	assert.NoError(t, err)
+	db.AutoMigrate(&domain.GuardianConsent{})
+	encryptor := newFieldEncryptor(t, "k1")
+	repository.RegisterFieldEncryption(db, encryptor)
+	repo := repository.NewDB(db, repository.WithFieldEncryption(encryptor))
+	ctx := context.Background()
+
+	consent := domain.GuardianConsent{ID: uuid.New(), PatientID: uuid.New(), GuardianName: "Mary Doe", GuardianContact: "mary@example.com", Relationship: "parent", EvidenceReference: "form-1", GrantedAt: time.Now().UTC()}
+	created, err := repo.CreateConsent(ctx, consent)
+	assert.NoError(t, err)
+	// the caller keeps the plaintext
+	assert.Equal(t, "mary@example.com", created.GuardianContact)
+
+	stored := &domain.GuardianConsent{}
+	assert.NoError(t, db.First(stored, "id = ?", consent.ID.String()).Error)
+	assert.True(t, strings.HasPrefix(stored.GuardianName, "enc.v1."))
+	assert.True(t, strings.HasPrefix(stored.GuardianContact, "enc.v1."))
+	assert.Equal(t, "parent", stored.Relationship)
+
+	found, err := repo.GetConsent(ctx, consent.ID.String())
+	assert.NoError(t, err)
+	assert.Equal(t, "Mary Doe", found.GuardianName)
+	assert.Equal(t, "mary@example.com", found.GuardianContact)
+
+	found.RevocationReason = "guardian withdrew"
+	_, err = repo.UpdateConsent(ctx, *found)
+	assert.NoError(t, err)
+	assert.NoError(t, db.First(stored, "id = ?", consent.ID.String()).Error)
+	assert.True(t, strings.HasPrefix(stored.GuardianContact, "enc.v1."))
+
+	listed, err := repo.ListConsents(ctx, consent.PatientID.String())
+	assert.NoError(t, err)
+	if assert.Len(t, listed, 1) {
+		assert.Equal(t, "Mary Doe", listed[0].GuardianName)
+		assert.Equal(t, "mary@example.com", listed[0].GuardianContact)
+		assert.Equal(t, "guardian withdrew", listed[0].RevocationReason)
+	}
+}
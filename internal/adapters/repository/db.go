@@ -1,16 +1,69 @@
 package repository
 
 import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/datphamcode295/go-lambda-pulumi/internal/fieldcrypt"
 	"github.com/jinzhu/gorm"
 )
 
 type DB struct {
-	db *gorm.DB
+	conn *Connection
+	// fields encrypts patient and guardian consent fields at rest, they are stored in
+	// plaintext when nil
+	fields *fieldcrypt.Encryptor
+}
+
+// Option configures optional behaviour of DB
+type Option func(*DB)
+
+// WithFieldEncryption encrypts the patient and guardian consent fields tagged encrypted and
+// fills their blind indexes
+func WithFieldEncryption(encryptor *fieldcrypt.Encryptor) Option {
+	return func(u *DB) {
+		u.fields = encryptor
+	}
 }
 
 // new database
-func NewDB(db *gorm.DB) *DB {
-	return &DB{
-		db: db,
+func NewDB(db *gorm.DB, opts ...Option) *DB {
+	u := &DB{
+		conn: &Connection{db: db, now: time.Now},
+	}
+	for _, opt := range opts {
+		opt(u)
+	}
+	return u
+}
+
+// NewLazyDB creates a database whose connection is opened on first use
+func NewLazyDB(conn *Connection, opts ...Option) *DB {
+	u := &DB{
+		conn: conn,
 	}
+	for _, opt := range opts {
+		opt(u)
+	}
+	return u
+}
+
+// Ping checks the database connection is usable
+func (u *DB) Ping(ctx context.Context) error {
+	db, err := u.conn.Get(ctx)
+	if err != nil {
+		return err
+	}
+	return db.DB().PingContext(ctx)
+}
+
+// Stats returns the connection pool statistics
+func (u *DB) Stats() sql.DBStats {
+	return u.conn.Stats()
+}
+
+// Close closes the database connection
+func (u *DB) Close() error {
+	return u.conn.Close()
 }
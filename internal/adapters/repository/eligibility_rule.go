@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/datphamcode295/go-lambda-pulumi/internal/core/rules"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/logger"
+	"github.com/jinzhu/gorm"
+)
+
+// errNoEligibilityRules fails transactions while the rules table is empty, which would
+// otherwise accept every patient
+var errNoEligibilityRules = errors.New("eligibility_rules table is empty")
+
+func (u *DB) EligibilityRules(ctx context.Context) ([]rules.Rule, error) {
+	db, err := u.withContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var list []rules.Rule
+	req := db.Order("position").Find(&list)
+	if req.Error != nil {
+		return nil, req.Error
+	}
+	if len(list) == 0 {
+		return nil, errNoEligibilityRules
+	}
+
+	return list, nil
+}
+
+// SeedEligibilityRules stores the default rules while the eligibility_rules table holds none,
+// so a new table starts out with the minimum age rather than accepting every patient
+func SeedEligibilityRules(db *gorm.DB) error {
+	var count int
+	if err := db.Model(&rules.Rule{}).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	defaults := rules.Default()
+	for i := range defaults {
+		if err := db.Create(&defaults[i]).Error; err != nil {
+			return err
+		}
+	}
+	logger.Log.WithField("rules", len(defaults)).Info("Seeded the default eligibility rules")
+	return nil
+}
@@ -0,0 +1,61 @@
+package repository_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/datphamcode295/go-lambda-pulumi/internal/adapters/repository"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/core/rules"
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEligibilityRules(t *testing.T) {
+	db, err := gorm.Open("sqlite3", ":memory:")
+	assert.NoError(t, err)
+	db.AutoMigrate(&rules.Rule{})
+	repo := repository.NewDB(db)
+
+	minAge := 21
+	assert.NoError(t, db.Create(&rules.Rule{Name: "no-texas", Position: 20, Kind: rules.KindState, Values: rules.StringList{"TX", "OK"}, Exclude: true}).Error)
+	assert.NoError(t, db.Create(&rules.Rule{Name: "adults", Position: 10, Kind: rules.KindAge, MinAge: &minAge, Message: "Adults only"}).Error)
+
+	list, err := repo.EligibilityRules(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []rules.Rule{
+		{Name: "adults", Position: 10, Kind: rules.KindAge, MinAge: &minAge, Message: "Adults only"},
+		{Name: "no-texas", Position: 20, Kind: rules.KindState, Values: rules.StringList{"TX", "OK"}, Exclude: true},
+	}, list)
+}
+
+func TestEligibilityRules_EmptyTable(t *testing.T) {
+	db, err := gorm.Open("sqlite3", ":memory:")
+	assert.NoError(t, err)
+	db.AutoMigrate(&rules.Rule{})
+	repo := repository.NewDB(db)
+
+	_, err = repo.EligibilityRules(context.Background())
+	assert.EqualError(t, err, "eligibility_rules table is empty")
+}
+
+func TestSeedEligibilityRules(t *testing.T) {
+	db, err := gorm.Open("sqlite3", ":memory:")
+	assert.NoError(t, err)
+	db.AutoMigrate(&rules.Rule{})
+	repo := repository.NewDB(db)
+
+	assert.NoError(t, repository.SeedEligibilityRules(db))
+	list, err := repo.EligibilityRules(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, rules.Default(), list)
+
+	// rules of the operator are left alone
+	assert.NoError(t, db.Delete(&rules.Rule{}, "name = ?", "minimum-age").Error)
+	assert.NoError(t, db.Create(&rules.Rule{Name: "no-texas", Position: 20, Kind: rules.KindState, Values: rules.StringList{"TX"}, Exclude: true}).Error)
+	assert.NoError(t, repository.SeedEligibilityRules(db))
+	list, err = repo.EligibilityRules(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, list, 1)
+	assert.Equal(t, "no-texas", list[0].Name)
+}
@@ -1,18 +1,231 @@
 package repository
 
 import (
+	"context"
 	"errors"
+	"fmt"
 
 	"github.com/datphamcode295/go-lambda-pulumi/internal/core/domain"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/fieldcrypt"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/logger"
+	"github.com/jinzhu/gorm"
 )
 
-func (u *DB) GetPatient(id string) (*domain.Patient, error) {
+func (u *DB) GetPatient(ctx context.Context, id string) (*domain.Patient, error) {
 	patient := &domain.Patient{}
 
-	req := u.db.First(&patient, "id = ? ", id)
+	db, err := u.withContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	req := db.First(&patient, "id = ? ", id)
 	if req.RowsAffected == 0 {
-		return nil, errors.New("patient not found")
+		logger.FromContext(ctx).WithField("patient_id", id).Debug("Patient not found")
+		return nil, domain.ErrPatientNotFound
+	}
+
+	if err := u.decryptFields(ctx, patient); err != nil {
+		return nil, err
 	}
+	return patient, nil
+}
+
+// FindPatientByEmail looks a patient up by email, through its blind index when patient fields
+// are encrypted. Rows not encrypted yet have no index and are matched on their plaintext email.
+func (u *DB) FindPatientByEmail(ctx context.Context, email string) (*domain.Patient, error) {
+	patient := &domain.Patient{}
 
+	db, err := u.withContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := db.Where("email = ?", email)
+	if u.fields != nil {
+		query = db.Where("email_index = ? OR ((email_index = '' OR email_index IS NULL) AND email = ?)",
+			u.fields.BlindIndex("email", email), email)
+	}
+	req := query.First(patient)
+	if req.RecordNotFound() {
+		return nil, domain.ErrPatientNotFound
+	}
+	if req.Error != nil {
+		return nil, req.Error
+	}
+
+	if err := u.decryptFields(ctx, patient); err != nil {
+		return nil, err
+	}
 	return patient, nil
 }
+
+// ReencryptPatients encrypts the patient fields still in plaintext or under a previous master
+// key with the current one, batchSize patients at a time, and returns how many it re-encrypted
+func (u *DB) ReencryptPatients(ctx context.Context, batchSize int) (int, error) {
+	if u.fields == nil {
+		return 0, errors.New("field encryption is not configured")
+	}
+	if batchSize <= 0 {
+		return 0, fmt.Errorf("batch size must be positive, got %d", batchSize)
+	}
+	db, err := u.withContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	reencrypted := 0
+	lastID := ""
+	for {
+		query := db.Order("id").Limit(batchSize)
+		if lastID != "" {
+			query = query.Where("id > ?", lastID)
+		}
+		var patients []domain.Patient
+		if err := query.Find(&patients).Error; err != nil {
+			return reencrypted, err
+		}
+
+		for _, patient := range patients {
+			lastID = patient.ID.String()
+			if !u.fields.FieldsNeedRotation(&patient) {
+				continue
+			}
+			if err := u.fields.DecryptFields(ctx, &patient); err != nil {
+				return reencrypted, err
+			}
+			if err := u.fields.EncryptFields(ctx, &patient); err != nil {
+				return reencrypted, err
+			}
+			columns, err := fieldcrypt.Columns(&patient)
+			if err != nil {
+				return reencrypted, err
+			}
+			if err := db.Model(&domain.Patient{}).Where("id = ?", patient.ID).UpdateColumns(columns).Error; err != nil {
+				return reencrypted, err
+			}
+			reencrypted++
+		}
+
+		if len(patients) < batchSize {
+			logger.FromContext(ctx).WithField("reencrypted", reencrypted).Info("Re-encrypted patient fields")
+			return reencrypted, nil
+		}
+	}
+}
+
+// RegisterFieldEncryption encrypts the fields of the patients and guardian consents created
+// or saved through db and fills their blind indexes. They are decrypted again once written, so
+// callers keep working with plaintext.
+func RegisterFieldEncryption(db *gorm.DB, encryptor *fieldcrypt.Encryptor) {
+	cb := db.Callback()
+	cb.Create().Before("gorm:create").Register("fieldcrypt:before_create", encryptFields(encryptor))
+	cb.Create().After("gorm:create").Register("fieldcrypt:after_create", decryptFields(encryptor))
+	cb.Update().Before("gorm:update").Register("fieldcrypt:before_update", encryptFields(encryptor))
+	cb.Update().After("gorm:update").Register("fieldcrypt:after_update", decryptFields(encryptor))
+}
+
+// encryptedModels are the models with fields encrypted at rest, by table
+var encryptedModels = map[string]interface{}{
+	"patients":          &domain.Patient{},
+	"guardian_consents": &domain.GuardianConsent{},
+}
+
+// hasEncryptedFields tells whether v is one of the encryptedModels
+func hasEncryptedFields(v interface{}) bool {
+	switch v.(type) {
+	case *domain.Patient, *domain.GuardianConsent:
+		return true
+	}
+	return false
+}
+
+func encryptFields(encryptor *fieldcrypt.Encryptor) func(scope *gorm.Scope) {
+	return func(scope *gorm.Scope) {
+		if hasEncryptedFields(scope.Value) {
+			if err := encryptor.EncryptFields(scopeContext(scope), scope.Value); err != nil {
+				scope.Err(err)
+			}
+		}
+	}
+}
+
+func decryptFields(encryptor *fieldcrypt.Encryptor) func(scope *gorm.Scope) {
+	return func(scope *gorm.Scope) {
+		if hasEncryptedFields(scope.Value) {
+			if err := encryptor.DecryptFields(scopeContext(scope), scope.Value); err != nil {
+				scope.Err(err)
+			}
+		}
+	}
+}
+
+// decryptFields decrypts a patient or guardian consent read from the database
+func (u *DB) decryptFields(ctx context.Context, v interface{}) error {
+	if u.fields == nil {
+		return nil
+	}
+	return u.fields.DecryptFields(ctx, v)
+}
+
+// PrepareFieldEncryption widens the encrypted columns created before they were encrypted,
+// encrypted values do not fit in varchar(255). Columns already text are left alone, so the
+// tables are only locked the first time. Only postgres needs it.
+func PrepareFieldEncryption(db *gorm.DB) error {
+	if db.Dialect().GetName() != "postgres" {
+		return nil
+	}
+
+	for table, model := range encryptedModels {
+		columns, err := fieldcrypt.EncryptedColumns(model)
+		if err != nil {
+			return err
+		}
+		for _, column := range columns {
+			var dataType string
+			row := db.Raw("SELECT data_type FROM information_schema.columns WHERE table_schema = current_schema() AND table_name = ? AND column_name = ?", table, column).Row()
+			if err := row.Scan(&dataType); err != nil {
+				return err
+			}
+			if dataType == "text" {
+				continue
+			}
+			if err := db.Exec(fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s TYPE text", table, column)).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (u *DB) RecordDateOfBirthMismatch(ctx context.Context, id string) (int, error) {
+	db, err := u.withContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	req := db.Model(&domain.Patient{}).Where("id = ?", id).
+		UpdateColumn("date_of_birth_mismatches", gorm.Expr("date_of_birth_mismatches + ?", 1))
+	if req.Error != nil {
+		return 0, req.Error
+	}
+	if req.RowsAffected == 0 {
+		return 0, domain.ErrPatientNotFound
+	}
+
+	patient := &domain.Patient{}
+	if err := db.Select("date_of_birth_mismatches").First(patient, "id = ?", id).Error; err != nil {
+		return 0, err
+	}
+	return patient.DateOfBirthMismatches, nil
+}
+
+func (u *DB) ResetDateOfBirthMismatches(ctx context.Context, id string) error {
+	db, err := u.withContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	return db.Model(&domain.Patient{}).Where("id = ?", id).
+		UpdateColumn("date_of_birth_mismatches", 0).Error
+}
@@ -1,12 +1,18 @@
 package repository_test
 
 import (
+	"bytes"
+	"context"
+	"strings"
 	"testing"
+	"time"
 
 	"errors"
 
+	"github.com/datphamcode295/go-lambda-pulumi/internal/adapters/keys"
 	"github.com/datphamcode295/go-lambda-pulumi/internal/adapters/repository"
 	"github.com/datphamcode295/go-lambda-pulumi/internal/core/domain"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/fieldcrypt"
 	"github.com/google/uuid"
 	"github.com/jinzhu/gorm"
 	_ "github.com/jinzhu/gorm/dialects/sqlite"
@@ -34,15 +40,179 @@ func TestGetPatient(t *testing.T) {
 	existingPatient := &domain.Patient{ID: patientID, Name: "Test Patient"}
 	db.Create(existingPatient)
 
-	foundPatient, err := repo.GetPatient(patientID.String())
+	foundPatient, err := repo.GetPatient(context.Background(), patientID.String())
 	assert.NoError(t, err)
 	assert.NotNil(t, foundPatient)
 	assert.Equal(t, existingPatient.ID, foundPatient.ID)
 	assert.Equal(t, existingPatient.Name, foundPatient.Name)
 
 	// Case 2: Patient does not exist
-	notFoundPatient, err := repo.GetPatient(uuid.New().String())
+	notFoundPatient, err := repo.GetPatient(context.Background(), uuid.New().String())
 	assert.Error(t, err)
 	assert.Nil(t, notFoundPatient)
 	assert.Equal(t, errors.New("patient not found"), err)
 }
+
+func TestPatientDateOfBirth(t *testing.T) {
+	db, err := setupTestDB()
+	assert.NoError(t, err)
+
+	repo := repository.NewDB(db)
+
+	patientID := uuid.New()
+	db.Create(&domain.Patient{ID: patientID, Name: "Test Patient", DateOfBirth: domain.NewDate(1990, time.March, 15)})
+	unknownID := uuid.New()
+	db.Create(&domain.Patient{ID: unknownID, Name: "Patient Without DOB"})
+
+	foundPatient, err := repo.GetPatient(context.Background(), patientID.String())
+	assert.NoError(t, err)
+	assert.Equal(t, domain.NewDate(1990, time.March, 15), foundPatient.DateOfBirth)
+
+	foundPatient, err = repo.GetPatient(context.Background(), unknownID.String())
+	assert.NoError(t, err)
+	assert.True(t, foundPatient.DateOfBirth.IsZero())
+
+	// mismatches accumulate per patient
+	for expected := 1; expected <= 2; expected++ {
+		mismatches, err := repo.RecordDateOfBirthMismatch(context.Background(), patientID.String())
+		assert.NoError(t, err)
+		assert.Equal(t, expected, mismatches)
+	}
+	foundPatient, err = repo.GetPatient(context.Background(), patientID.String())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, foundPatient.DateOfBirthMismatches)
+
+	assert.NoError(t, repo.ResetDateOfBirthMismatches(context.Background(), patientID.String()))
+	foundPatient, err = repo.GetPatient(context.Background(), patientID.String())
+	assert.NoError(t, err)
+	assert.Equal(t, 0, foundPatient.DateOfBirthMismatches)
+
+	_, err = repo.RecordDateOfBirthMismatch(context.Background(), uuid.New().String())
+	assert.EqualError(t, err, "patient not found")
+}
+
+func newFieldEncryptor(t *testing.T, keyID string) *fieldcrypt.Encryptor {
+	provider, err := keys.NewStaticKeyProvider(map[string][]byte{
+		"k1": bytes.Repeat([]byte{1}, 32),
+		"k2": bytes.Repeat([]byte{2}, 32),
+	}, keyID)
+	assert.NoError(t, err)
+	encryptor, err := fieldcrypt.New(provider, bytes.Repeat([]byte{9}, 32))
+	assert.NoError(t, err)
+	return encryptor
+}
+
+func TestPatientFieldEncryption(t *testing.T) {
+	db, err := setupTestDB()
+	assert.NoError(t, err)
+	ctx := context.Background()
+
+	// written before encryption was enabled
+	patients := []*domain.Patient{
+		{ID: uuid.New(), Name: "John Doe", Email: "john@example.com", Phone: "0400000000", Address: "1 George St", City: "Sydney"},
+		{ID: uuid.New(), Name: "Jane Roe", Email: "jane@example.com", City: "Perth"},
+	}
+	for _, patient := range patients {
+		assert.NoError(t, db.Create(patient).Error)
+	}
+
+	repo := repository.NewDB(db, repository.WithFieldEncryption(newFieldEncryptor(t, "k1")))
+	found, err := repo.GetPatient(ctx, patients[0].ID.String())
+	assert.NoError(t, err)
+	assert.Equal(t, "john@example.com", found.Email)
+
+	reencrypted, err := repo.ReencryptPatients(ctx, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, reencrypted)
+
+	stored := &domain.Patient{}
+	assert.NoError(t, db.First(stored, "id = ?", patients[0].ID.String()).Error)
+	assert.True(t, strings.HasPrefix(stored.Email, "enc.v1."))
+	assert.True(t, strings.HasPrefix(stored.Address, "enc.v1."))
+	assert.NotEmpty(t, stored.EmailIndex)
+	assert.Equal(t, "Sydney", stored.City)
+
+	found, err = repo.GetPatient(ctx, patients[0].ID.String())
+	assert.NoError(t, err)
+	assert.Equal(t, "John Doe", found.Name)
+	assert.Equal(t, "1 George St", found.Address)
+
+	found, err = repo.FindPatientByEmail(ctx, "Jane@Example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, patients[1].ID, found.ID)
+	assert.Equal(t, "Jane Roe", found.Name)
+
+	_, err = repo.FindPatientByEmail(ctx, "nobody@example.com")
+	assert.EqualError(t, err, "patient not found")
+
+	// nothing left to do until the master key changes
+	reencrypted, err = repo.ReencryptPatients(ctx, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, reencrypted)
+
+	rotated := repository.NewDB(db, repository.WithFieldEncryption(newFieldEncryptor(t, "k2")))
+	reencrypted, err = rotated.ReencryptPatients(ctx, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, reencrypted)
+
+	found, err = rotated.FindPatientByEmail(ctx, "john@example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, "0400000000", found.Phone)
+}
+
+func TestReencryptPatients_NotConfigured(t *testing.T) {
+	db, err := setupTestDB()
+	assert.NoError(t, err)
+
+	_, err = repository.NewDB(db).ReencryptPatients(context.Background(), 10)
+	assert.EqualError(t, err, "field encryption is not configured")
+}
+
+func TestPatientFieldEncryption_OnWrite(t *testing.T) {
+	db, err := setupTestDB()
+	assert.NoError(t, err)
+	ctx := context.Background()
+
+	// written before encryption was enabled
+	legacy := &domain.Patient{ID: uuid.New(), Name: "Jane Roe", Email: "jane@example.com"}
+	assert.NoError(t, db.Create(legacy).Error)
+
+	encryptor := newFieldEncryptor(t, "k1")
+	repository.RegisterFieldEncryption(db, encryptor)
+	repo := repository.NewDB(db, repository.WithFieldEncryption(encryptor))
+
+	patient := &domain.Patient{ID: uuid.New(), Name: "John Doe", Email: "john@example.com", City: "Sydney"}
+	assert.NoError(t, db.Create(patient).Error)
+	// the caller keeps the plaintext
+	assert.Equal(t, "john@example.com", patient.Email)
+
+	stored := &domain.Patient{}
+	assert.NoError(t, db.First(stored, "id = ?", patient.ID.String()).Error)
+	assert.True(t, strings.HasPrefix(stored.Name, "enc.v1."))
+	assert.True(t, strings.HasPrefix(stored.Email, "enc.v1."))
+	assert.NotEmpty(t, stored.EmailIndex)
+	assert.Equal(t, "Sydney", stored.City)
+
+	patient.Phone = "0400000000"
+	assert.NoError(t, db.Save(patient).Error)
+	assert.NoError(t, db.First(stored, "id = ?", patient.ID.String()).Error)
+	assert.True(t, strings.HasPrefix(stored.Phone, "enc.v1."))
+
+	found, err := repo.FindPatientByEmail(ctx, "john@example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, "0400000000", found.Phone)
+
+	// not re-encrypted yet, found by its plaintext email
+	found, err = repo.FindPatientByEmail(ctx, "jane@example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, legacy.ID, found.ID)
+}
+
+func TestReencryptPatients_InvalidBatchSize(t *testing.T) {
+	db, err := setupTestDB()
+	assert.NoError(t, err)
+
+	repo := repository.NewDB(db, repository.WithFieldEncryption(newFieldEncryptor(t, "k1")))
+	_, err = repo.ReencryptPatients(context.Background(), 0)
+	assert.EqualError(t, err, "batch size must be positive, got 0")
+}
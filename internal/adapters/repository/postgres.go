@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"net/url"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/rds/rdsutils"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/config"
+	"github.com/jinzhu/gorm"
+	"github.com/lib/pq"
+)
+
+// TokenBuilder returns an IAM auth token for user on the database at endpoint (host:port)
+type TokenBuilder func(endpoint, region, user string) (string, error)
+
+// PostgresOpener returns the open function of a Connection to the Postgres database at databaseURL
+func PostgresOpener(cfg config.DatabaseConfig, databaseURL string) func() (*gorm.DB, error) {
+	if !cfg.IAMAuth {
+		return func() (*gorm.DB, error) {
+			return gorm.Open("postgres", databaseURL)
+		}
+	}
+
+	connector := &iamConnector{
+		databaseURL: databaseURL,
+		region:      cfg.Region,
+		token:       buildAWSAuthToken,
+	}
+	return func() (*gorm.DB, error) {
+		sqlDB := sql.OpenDB(connector)
+		db, err := gorm.Open("postgres", sqlDB)
+		if err != nil {
+			// gorm only closes the pool it created itself
+			sqlDB.Close()
+			return nil, err
+		}
+		return db, nil
+	}
+}
+
+func buildAWSAuthToken(endpoint, region, user string) (string, error) {
+	sess, err := session.NewSession(&aws.Config{Region: aws.String(region)})
+	if err != nil {
+		return "", err
+	}
+	return rdsutils.BuildAuthToken(endpoint, region, user, sess.Config.Credentials)
+}
+
+// iamConnector builds a fresh token for every new connection since tokens expire after
+// 15 minutes while the pool outlives them
+type iamConnector struct {
+	databaseURL string
+	region      string
+	token       TokenBuilder
+}
+
+func (c *iamConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	dsn, err := c.dsn()
+	if err != nil {
+		return nil, err
+	}
+	connector, err := pq.NewConnector(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return connector.Connect(ctx)
+}
+
+func (c *iamConnector) Driver() driver.Driver {
+	return &pq.Driver{}
+}
+
+// dsn returns databaseURL with its password replaced by an IAM auth token
+func (c *iamConnector) dsn() (string, error) {
+	u, err := url.Parse(c.databaseURL)
+	if err != nil || u.User == nil || u.Host == "" {
+		return "", fmt.Errorf("IAM auth requires a postgres://user@host:port/db database URL")
+	}
+
+	endpoint := u.Host
+	if u.Port() == "" {
+		endpoint += ":5432"
+	}
+	token, err := c.token(endpoint, c.region, u.User.Username())
+	if err != nil {
+		return "", fmt.Errorf("build IAM auth token: %w", err)
+	}
+
+	u.User = url.UserPassword(u.User.Username(), token)
+	return u.String(), nil
+}
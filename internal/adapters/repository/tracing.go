@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/datphamcode295/go-lambda-pulumi/internal/tracing"
+	"github.com/jinzhu/gorm"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// gorm v1 has no context support, so the caller's context travels as a scope setting
+const (
+	gormContextKey = "otel:context"
+	gormSpanKey    = "otel:span"
+)
+
+// RegisterTracing creates a client span around every query executed through db
+func RegisterTracing(db *gorm.DB) {
+	cb := db.Callback()
+	cb.Create().Before("gorm:create").Register("otel:before_create", startSpan("create"))
+	cb.Create().After("gorm:create").Register("otel:after_create", endSpan)
+	cb.Query().Before("gorm:query").Register("otel:before_query", startSpan("query"))
+	cb.Query().After("gorm:query").Register("otel:after_query", endSpan)
+	cb.Update().Before("gorm:update").Register("otel:before_update", startSpan("update"))
+	cb.Update().After("gorm:update").Register("otel:after_update", endSpan)
+	cb.Delete().Before("gorm:delete").Register("otel:before_delete", startSpan("delete"))
+	cb.Delete().After("gorm:delete").Register("otel:after_delete", endSpan)
+	cb.RowQuery().Before("gorm:row_query").Register("otel:before_row_query", startSpan("row_query"))
+	cb.RowQuery().After("gorm:row_query").Register("otel:after_row_query", endSpan)
+}
+
+// withContext returns a handle whose queries are traced as children of the span in ctx. Inside
+// InTransaction the handle is the transaction's.
+func (u *DB) withContext(ctx context.Context) (*gorm.DB, error) {
+	if tx, ok := ctx.Value(txKey{}).(*gorm.DB); ok {
+		return tx.Set(gormContextKey, ctx), nil
+	}
+	db, err := u.conn.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return db.Set(gormContextKey, ctx), nil
+}
+
+// scopeContext returns the caller's context set by withContext
+func scopeContext(scope *gorm.Scope) context.Context {
+	if value, ok := scope.Get(gormContextKey); ok {
+		if ctx, ok := value.(context.Context); ok {
+			return ctx
+		}
+	}
+	return context.Background()
+}
+
+func startSpan(operation string) func(scope *gorm.Scope) {
+	return func(scope *gorm.Scope) {
+		_, span := tracing.Tracer().Start(scopeContext(scope), "gorm."+operation+" "+scope.TableName(),
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(
+				attribute.String("db.system", scope.Dialect().GetName()),
+				attribute.String("db.operation", operation),
+				attribute.String("db.sql.table", scope.TableName()),
+			),
+		)
+		scope.Set(gormSpanKey, span)
+	}
+}
+
+func endSpan(scope *gorm.Scope) {
+	value, ok := scope.Get(gormSpanKey)
+	if !ok {
+		return
+	}
+	span, ok := value.(trace.Span)
+	if !ok {
+		return
+	}
+	defer span.End()
+
+	// statement only, bound values may contain PII
+	span.SetAttributes(
+		attribute.String("db.statement", scope.SQL),
+		attribute.Int64("db.rows_affected", scope.DB().RowsAffected),
+	)
+	if err := scope.DB().Error; err != nil && !gorm.IsRecordNotFoundError(err) {
+		tracing.RecordError(span, err)
+	}
+}
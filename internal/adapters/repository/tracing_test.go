@@ -0,0 +1,45 @@
+package repository_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/datphamcode295/go-lambda-pulumi/internal/adapters/repository"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/core/domain"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/tracing"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestRegisterTracing_SpansAreChildrenOfCaller(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+
+	db, err := setupTestDBForTransaction()
+	assert.NoError(t, err)
+	repository.RegisterTracing(db)
+	repo := repository.NewDB(db)
+
+	ctx, parent := tracing.Tracer().Start(context.Background(), "parent")
+	_, err = repo.CreateTransaction(ctx, domain.Transaction{ID: uuid.New(), PatientID: uuid.New(), Status: domain.TransactionStatusSuccess})
+	assert.NoError(t, err)
+	_, err = repo.GetPatient(ctx, uuid.New().String())
+	assert.Error(t, err)
+	parent.End()
+
+	spans := exporter.GetSpans()
+	assert.Len(t, spans, 3)
+
+	create, query := spans[0], spans[1]
+	assert.Equal(t, "gorm.create transactions", create.Name)
+	assert.Equal(t, "gorm.query patients", query.Name)
+	for _, span := range []tracetest.SpanStub{create, query} {
+		assert.Equal(t, parent.SpanContext().TraceID(), span.SpanContext.TraceID())
+		assert.Equal(t, parent.SpanContext().SpanID(), span.Parent.SpanID())
+		// record not found is not an error worth flagging on the span
+		assert.Equal(t, "Unset", span.Status.Code.String())
+	}
+}
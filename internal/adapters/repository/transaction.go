@@ -1,18 +1,162 @@
 package repository
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/datphamcode295/go-lambda-pulumi/internal/core/domain"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/logger"
+	"github.com/jinzhu/gorm"
 )
 
-func (u *DB) CreateTransaction(transaction domain.Transaction) (*domain.Transaction, error) {
-	fmt.Println("Creating transaction", transaction)
-	req := u.db.Create(&transaction)
+// dateOfBirthTables hold a date_of_birth column that was created as varchar
+var dateOfBirthTables = []string{"patients", "transactions"}
+
+// MigrateDateOfBirthColumns converts the date_of_birth columns created as varchar, holding
+// DD-MM-YYYY text, to dates. The text is rewritten as ISO dates first, which postgres then
+// casts in place. Columns already dates are left alone, so the tables are only locked the
+// first time.
+func MigrateDateOfBirthColumns(db *gorm.DB) error {
+	postgres := db.Dialect().GetName() == "postgres"
+	for _, table := range dateOfBirthTables {
+		if !db.Dialect().HasColumn(table, "date_of_birth") {
+			continue
+		}
+		if postgres {
+			var dataType string
+			row := db.Raw("SELECT data_type FROM information_schema.columns WHERE table_schema = current_schema() AND table_name = ? AND column_name = ?", table, "date_of_birth").Row()
+			if err := row.Scan(&dataType); err != nil {
+				return err
+			}
+			if dataType == "date" {
+				continue
+			}
+		}
+
+		req := db.Exec(fmt.Sprintf("UPDATE %s SET date_of_birth = substr(date_of_birth, 7, 4) || '-' || substr(date_of_birth, 4, 2) || '-' || substr(date_of_birth, 1, 2) WHERE date_of_birth LIKE '__-__-____'", table))
+		if req.Error != nil {
+			return req.Error
+		}
+		if req.RowsAffected > 0 {
+			logger.Log.WithField("table", table).WithField("rows", req.RowsAffected).Info("Rewrote dates of birth as ISO dates")
+		}
+		if postgres {
+			if err := db.Exec(fmt.Sprintf("ALTER TABLE %s ALTER COLUMN date_of_birth TYPE date USING NULLIF(date_of_birth, '')::date", table)).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (u *DB) CreateTransaction(ctx context.Context, transaction domain.Transaction) (*domain.Transaction, error) {
+	logger.FromContext(ctx).WithField("transaction", logger.Redact(transaction)).Debug("Creating transaction")
+	db, err := u.withContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	req := db.Create(&transaction)
 	if req.RowsAffected == 0 {
 		return nil, errors.New("transaction not created")
 	}
 
 	return &transaction, nil
 }
+
+func (u *DB) GetTransaction(ctx context.Context, id string) (*domain.Transaction, error) {
+	transaction := &domain.Transaction{}
+
+	db, err := u.withContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	req := db.First(transaction, "id = ?", id)
+	if req.RecordNotFound() {
+		logger.FromContext(ctx).WithField("transaction_id", id).Debug("Transaction not found")
+		return nil, domain.ErrTransactionNotFound
+	}
+	if req.Error != nil {
+		return nil, req.Error
+	}
+
+	return transaction, nil
+}
+
+func (u *DB) UpdateTransaction(ctx context.Context, transaction domain.Transaction) (*domain.Transaction, error) {
+	logger.FromContext(ctx).WithField("transaction", logger.Redact(transaction)).Debug("Updating transaction")
+	db, err := u.withContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	req := db.Save(&transaction)
+	if req.Error != nil {
+		return nil, req.Error
+	}
+
+	return &transaction, nil
+}
+
+func (u *DB) ListRetryableTransactions(ctx context.Context, now time.Time, unclaimedBefore time.Time, limit int) ([]domain.Transaction, error) {
+	db, err := u.withContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var transactions []domain.Transaction
+	// pending transactions only have a next_retry_at once claimed, it is due when the job
+	// processing them failed without an outcome. Those never claimed, e.g. because their job
+	// was lost, are picked up once created before unclaimedBefore.
+	req := db.Where("(((status = ? AND failure_kind = ?) OR status = ?) AND next_retry_at <= ?) OR (status = ? AND next_retry_at IS NULL AND created_at <= ?)",
+		domain.TransactionStatusFailed, domain.FailureKindTransient, domain.TransactionStatusPending, now,
+		domain.TransactionStatusPending, unclaimedBefore).
+		Order("next_retry_at").
+		Order("created_at").
+		Limit(limit).
+		Find(&transactions)
+	if req.Error != nil {
+		return nil, req.Error
+	}
+
+	return transactions, nil
+}
+
+func (u *DB) ClaimRetry(ctx context.Context, id string, attempts int, now time.Time, leaseUntil time.Time) (bool, error) {
+	db, err := u.withContext(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	// attempts acts as a version, it changes whenever the transaction is submitted
+	req := db.Model(&domain.Transaction{}).
+		Where("id = ? AND attempts = ? AND ((status IN (?) AND next_retry_at <= ?) OR (status = ? AND next_retry_at IS NULL))", id, attempts,
+			[]domain.TransactionStatus{domain.TransactionStatusFailed, domain.TransactionStatusPending}, now,
+			domain.TransactionStatusPending).
+		Update("next_retry_at", leaseUntil)
+	if req.Error != nil {
+		return false, req.Error
+	}
+
+	return req.RowsAffected == 1, nil
+}
+
+func (u *DB) ClaimPending(ctx context.Context, id string, now time.Time, leaseUntil time.Time) (bool, error) {
+	db, err := u.withContext(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	// next_retry_at holds the lease of a pending transaction, it is empty until the first claim
+	req := db.Model(&domain.Transaction{}).
+		Where("id = ? AND status = ? AND (next_retry_at IS NULL OR next_retry_at <= ?)", id, domain.TransactionStatusPending, now).
+		Update("next_retry_at", leaseUntil)
+	if req.Error != nil {
+		return false, req.Error
+	}
+
+	return req.RowsAffected == 1, nil
+}
@@ -0,0 +1,46 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/datphamcode295/go-lambda-pulumi/internal/core/domain"
+)
+
+func (u *DB) CreateTransactionAttempt(ctx context.Context, attempt domain.TransactionAttempt) error {
+	db, err := u.withContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	return db.Create(&attempt).Error
+}
+
+func (u *DB) ListTransactionAttempts(ctx context.Context, transactionID string) ([]domain.TransactionAttempt, error) {
+	db, err := u.withContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var attempts []domain.TransactionAttempt
+	req := db.Where("transaction_id = ?", transactionID).Order("attempt").Find(&attempts)
+	if req.Error != nil {
+		return nil, req.Error
+	}
+
+	return attempts, nil
+}
+
+func (u *DB) DeleteTransactionAttemptsBefore(ctx context.Context, before time.Time) (int64, error) {
+	db, err := u.withContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	req := db.Where("created_at < ?", before).Delete(&domain.TransactionAttempt{})
+	if req.Error != nil {
+		return 0, req.Error
+	}
+
+	return req.RowsAffected, nil
+}
@@ -0,0 +1,52 @@
+package repository_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/datphamcode295/go-lambda-pulumi/internal/adapters/repository"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/core/domain"
+	"github.com/google/uuid"
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransactionAttempts(t *testing.T) {
+	db, err := gorm.Open("sqlite3", ":memory:")
+	assert.NoError(t, err)
+	db.AutoMigrate(&domain.TransactionAttempt{})
+	repo := repository.NewDB(db)
+	ctx := context.Background()
+
+	transactionID := uuid.New()
+	now := time.Now()
+	attempts := []domain.TransactionAttempt{
+		{ID: uuid.New(), TransactionID: transactionID, Attempt: 2, StatusCode: 200, Request: json.RawMessage(`{"age":30}`), CreatedAt: now},
+		{ID: uuid.New(), TransactionID: transactionID, Attempt: 1, StatusCode: 503, ErrorClass: domain.AttemptErrorTransient, ResponseHeaders: json.RawMessage(`{"Retry-After":"60"}`), CreatedAt: now.Add(-48 * time.Hour)},
+		{ID: uuid.New(), TransactionID: uuid.New(), Attempt: 1, CreatedAt: now},
+	}
+	for _, attempt := range attempts {
+		assert.NoError(t, repo.CreateTransactionAttempt(ctx, attempt))
+	}
+
+	// listed oldest attempt first, other transactions excluded
+	listed, err := repo.ListTransactionAttempts(ctx, transactionID.String())
+	assert.NoError(t, err)
+	if assert.Len(t, listed, 2) {
+		assert.Equal(t, 1, listed[0].Attempt)
+		assert.Equal(t, domain.AttemptErrorTransient, listed[0].ErrorClass)
+		assert.JSONEq(t, `{"Retry-After":"60"}`, string(listed[0].ResponseHeaders))
+		assert.JSONEq(t, `{"age":30}`, string(listed[1].Request))
+	}
+
+	deleted, err := repo.DeleteTransactionAttemptsBefore(ctx, now.Add(-24*time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+
+	listed, err = repo.ListTransactionAttempts(ctx, transactionID.String())
+	assert.NoError(t, err)
+	assert.Len(t, listed, 1)
+}
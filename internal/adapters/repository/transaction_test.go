@@ -1,6 +1,7 @@
 package repository_test
 
 import (
+	"context"
 	"testing"
 	"time"
 
@@ -44,7 +45,7 @@ func TestCreateTransaction(t *testing.T) {
 		CreatedAt: time.Now(),
 	}
 
-	createdTransaction, err := repo.CreateTransaction(transactionToCreate)
+	createdTransaction, err := repo.CreateTransaction(context.Background(), transactionToCreate)
 	assert.NoError(t, err)
 	assert.NotNil(t, createdTransaction)
 	assert.Equal(t, transactionToCreate.ID, createdTransaction.ID)
@@ -56,3 +57,229 @@ func TestCreateTransaction(t *testing.T) {
 	db.First(&fetchedTransaction, "id = ?", transactionID)
 	assert.Equal(t, transactionToCreate.ID, fetchedTransaction.ID)
 }
+
+func TestGetAndUpdateTransaction(t *testing.T) {
+	db, err := setupTestDBForTransaction()
+	assert.NoError(t, err)
+
+	repo := repository.NewDB(db)
+
+	transaction := domain.Transaction{
+		ID:          uuid.New(),
+		PatientID:   uuid.New(),
+		Status:      domain.TransactionStatusPending,
+		RecordType:  "NEW",
+		DateOfBirth: mustParseDate("15-03-1990"),
+	}
+	_, err = repo.CreateTransaction(context.Background(), transaction)
+	assert.NoError(t, err)
+
+	// Case 1: Transaction exists
+	found, err := repo.GetTransaction(context.Background(), transaction.ID.String())
+	assert.NoError(t, err)
+	assert.Equal(t, domain.TransactionStatusPending, found.Status)
+
+	// Case 2: Transaction is updated
+	found.Status = domain.TransactionStatusSuccess
+	found.APIResponse = []byte(`{"message":"Transaction success"}`)
+	_, err = repo.UpdateTransaction(context.Background(), *found)
+	assert.NoError(t, err)
+
+	updated, err := repo.GetTransaction(context.Background(), transaction.ID.String())
+	assert.NoError(t, err)
+	assert.Equal(t, domain.TransactionStatusSuccess, updated.Status)
+	assert.JSONEq(t, `{"message":"Transaction success"}`, string(updated.APIResponse))
+
+	// Case 3: Transaction does not exist
+	missing, err := repo.GetTransaction(context.Background(), uuid.New().String())
+	assert.EqualError(t, err, "transaction not found")
+	assert.Nil(t, missing)
+}
+
+func TestListRetryableAndClaimRetry(t *testing.T) {
+	db, err := setupTestDBForTransaction()
+	assert.NoError(t, err)
+
+	repo := repository.NewDB(db)
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	past, future := now.Add(-time.Minute), now.Add(time.Minute)
+
+	due := domain.Transaction{ID: uuid.New(), Status: domain.TransactionStatusFailed, FailureKind: domain.FailureKindTransient, Attempts: 1, NextRetryAt: &past}
+	notYet := domain.Transaction{ID: uuid.New(), Status: domain.TransactionStatusFailed, FailureKind: domain.FailureKindTransient, Attempts: 1, NextRetryAt: &future}
+	rejected := domain.Transaction{ID: uuid.New(), Status: domain.TransactionStatusFailed, FailureKind: domain.FailureKindRejected}
+	exhausted := domain.Transaction{ID: uuid.New(), Status: domain.TransactionStatusFailed, FailureKind: domain.FailureKindTransient, Attempts: 5}
+	for _, transaction := range []domain.Transaction{due, notYet, rejected, exhausted} {
+		_, err := repo.CreateTransaction(context.Background(), transaction)
+		assert.NoError(t, err)
+	}
+
+	// Case 1: only the due transient failure is listed
+	transactions, err := repo.ListRetryableTransactions(context.Background(), now, now.Add(-5*time.Minute), 10)
+	assert.NoError(t, err)
+	assert.Len(t, transactions, 1)
+	assert.Equal(t, due.ID, transactions[0].ID)
+
+	// Case 2: a stale attempts count does not claim
+	claimed, err := repo.ClaimRetry(context.Background(), due.ID.String(), 0, now, now.Add(5*time.Minute))
+	assert.NoError(t, err)
+	assert.False(t, claimed)
+
+	// Case 3: the first claim wins, the lease hides it from the next listing
+	claimed, err = repo.ClaimRetry(context.Background(), due.ID.String(), 1, now, now.Add(5*time.Minute))
+	assert.NoError(t, err)
+	assert.True(t, claimed)
+
+	claimed, err = repo.ClaimRetry(context.Background(), due.ID.String(), 1, now, now.Add(5*time.Minute))
+	assert.NoError(t, err)
+	assert.False(t, claimed)
+
+	transactions, err = repo.ListRetryableTransactions(context.Background(), now, now.Add(-5*time.Minute), 10)
+	assert.NoError(t, err)
+	assert.Empty(t, transactions)
+}
+
+func TestTransactionRejectionReasons(t *testing.T) {
+	db, err := setupTestDBForTransaction()
+	assert.NoError(t, err)
+	repo := repository.NewDB(db)
+
+	rejected := domain.Transaction{
+		ID:          uuid.New(),
+		PatientID:   uuid.New(),
+		Status:      domain.TransactionStatusFailed,
+		FailureKind: domain.FailureKindRejected,
+		RejectionReasons: domain.RejectionReasons{
+			{Rule: "minimum-age", Kind: "age", Message: "Patient must be more than 18 years old"},
+			{Rule: "record-type", Kind: "record_type", Message: "Record type must be NEW"},
+		},
+	}
+	accepted := domain.Transaction{ID: uuid.New(), PatientID: uuid.New(), Status: domain.TransactionStatusSuccess}
+	_, err = repo.CreateTransaction(context.Background(), rejected)
+	assert.NoError(t, err)
+	_, err = repo.CreateTransaction(context.Background(), accepted)
+	assert.NoError(t, err)
+
+	got, err := repo.GetTransaction(context.Background(), rejected.ID.String())
+	assert.NoError(t, err)
+	assert.Equal(t, rejected.RejectionReasons, got.RejectionReasons)
+
+	got, err = repo.GetTransaction(context.Background(), accepted.ID.String())
+	assert.NoError(t, err)
+	assert.Nil(t, got.RejectionReasons)
+}
+
+func mustParseDate(s string) domain.Date {
+	d, err := domain.ParseDate(s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func TestClaimPending(t *testing.T) {
+	db, err := setupTestDBForTransaction()
+	assert.NoError(t, err)
+
+	repo := repository.NewDB(db)
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	pending := domain.Transaction{ID: uuid.New(), Status: domain.TransactionStatusPending}
+	done := domain.Transaction{ID: uuid.New(), Status: domain.TransactionStatusSuccess}
+	for _, transaction := range []domain.Transaction{pending, done} {
+		_, err := repo.CreateTransaction(context.Background(), transaction)
+		assert.NoError(t, err)
+	}
+
+	// Case 1: the first delivery claims, a redelivery within the lease does not
+	claimed, err := repo.ClaimPending(context.Background(), pending.ID.String(), now, now.Add(5*time.Minute))
+	assert.NoError(t, err)
+	assert.True(t, claimed)
+
+	claimed, err = repo.ClaimPending(context.Background(), pending.ID.String(), now.Add(time.Minute), now.Add(6*time.Minute))
+	assert.NoError(t, err)
+	assert.False(t, claimed)
+
+	// Case 2: an expired lease is claimed again, e.g. after the first delivery crashed
+	claimed, err = repo.ClaimPending(context.Background(), pending.ID.String(), now.Add(10*time.Minute), now.Add(15*time.Minute))
+	assert.NoError(t, err)
+	assert.True(t, claimed)
+
+	// Case 3: processed transactions are not claimed
+	claimed, err = repo.ClaimPending(context.Background(), done.ID.String(), now, now.Add(5*time.Minute))
+	assert.NoError(t, err)
+	assert.False(t, claimed)
+
+	// Case 4: a pending transaction is left to the retries once its lease expired
+	transactions, err := repo.ListRetryableTransactions(context.Background(), now.Add(10*time.Minute), now.Add(5*time.Minute), 10)
+	assert.NoError(t, err)
+	assert.Empty(t, transactions)
+
+	transactions, err = repo.ListRetryableTransactions(context.Background(), now.Add(20*time.Minute), now.Add(15*time.Minute), 10)
+	assert.NoError(t, err)
+	assert.Len(t, transactions, 1)
+	assert.Equal(t, pending.ID, transactions[0].ID)
+
+	claimed, err = repo.ClaimRetry(context.Background(), pending.ID.String(), 0, now.Add(20*time.Minute), now.Add(25*time.Minute))
+	assert.NoError(t, err)
+	assert.True(t, claimed)
+}
+
+func TestListRetryable_UnclaimedPending(t *testing.T) {
+	db, err := setupTestDBForTransaction()
+	assert.NoError(t, err)
+
+	repo := repository.NewDB(db)
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	// the job of lost was never delivered, the one of recent may still be
+	lost := domain.Transaction{ID: uuid.New(), Status: domain.TransactionStatusPending, CreatedAt: now.Add(-time.Hour)}
+	recent := domain.Transaction{ID: uuid.New(), Status: domain.TransactionStatusPending, CreatedAt: now.Add(-time.Minute)}
+	for _, transaction := range []domain.Transaction{lost, recent} {
+		_, err := repo.CreateTransaction(context.Background(), transaction)
+		assert.NoError(t, err)
+	}
+
+	transactions, err := repo.ListRetryableTransactions(context.Background(), now, now.Add(-5*time.Minute), 10)
+	assert.NoError(t, err)
+	assert.Len(t, transactions, 1)
+	assert.Equal(t, lost.ID, transactions[0].ID)
+
+	claimed, err := repo.ClaimRetry(context.Background(), lost.ID.String(), 0, now, now.Add(5*time.Minute))
+	assert.NoError(t, err)
+	assert.True(t, claimed)
+
+	// the lease hides it now, and a late delivery of its job does not claim it
+	transactions, err = repo.ListRetryableTransactions(context.Background(), now, now.Add(-5*time.Minute), 10)
+	assert.NoError(t, err)
+	assert.Empty(t, transactions)
+
+	claimed, err = repo.ClaimPending(context.Background(), lost.ID.String(), now, now.Add(5*time.Minute))
+	assert.NoError(t, err)
+	assert.False(t, claimed)
+}
+
+func TestMigrateDateOfBirthColumns(t *testing.T) {
+	db, err := gorm.Open("sqlite3", ":memory:")
+	assert.NoError(t, err)
+	assert.NoError(t, db.Exec("CREATE TABLE transactions (id varchar(255) PRIMARY KEY, date_of_birth varchar(255))").Error)
+	stored := uuid.New()
+	missing := uuid.New()
+	assert.NoError(t, db.Exec("INSERT INTO transactions (id, date_of_birth) VALUES (?, '15-03-1990'), (?, '')", stored.String(), missing.String()).Error)
+	db.AutoMigrate(&domain.Transaction{})
+
+	assert.NoError(t, repository.MigrateDateOfBirthColumns(db))
+	// migrated dates are not rewritten again
+	assert.NoError(t, repository.MigrateDateOfBirthColumns(db))
+
+	var raw string
+	assert.NoError(t, db.Raw("SELECT date_of_birth FROM transactions WHERE id = ?", stored.String()).Row().Scan(&raw))
+	assert.Equal(t, "1990-03-15", raw)
+
+	repo := repository.NewDB(db)
+	transaction, err := repo.GetTransaction(context.Background(), stored.String())
+	assert.NoError(t, err)
+	assert.Equal(t, domain.NewDate(1990, time.March, 15), transaction.DateOfBirth)
+	transaction, err = repo.GetTransaction(context.Background(), missing.String())
+	assert.NoError(t, err)
+	assert.True(t, transaction.DateOfBirth.IsZero())
+}
@@ -0,0 +1,34 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/jinzhu/gorm"
+)
+
+// txKey carries the database transaction of InTransaction in the context
+type txKey struct{}
+
+// InTransaction runs fn in a database transaction, committed when fn returns nil and rolled back
+// otherwise. The methods of u called with the context given to fn take part in it, and so does
+// a nested InTransaction.
+func (u *DB) InTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{}).(*gorm.DB); ok {
+		return fn(ctx)
+	}
+
+	db, err := u.conn.Get(ctx)
+	if err != nil {
+		return err
+	}
+	tx := db.Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+	defer tx.Rollback()
+
+	if err := fn(context.WithValue(ctx, txKey{}, tx)); err != nil {
+		return err
+	}
+	return tx.Commit().Error
+}
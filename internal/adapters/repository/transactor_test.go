@@ -0,0 +1,59 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/datphamcode295/go-lambda-pulumi/internal/adapters/repository"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/core/domain"
+	"github.com/google/uuid"
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInTransaction(t *testing.T) {
+	db, err := gorm.Open("sqlite3", ":memory:")
+	assert.NoError(t, err)
+	// every connection to :memory: is a separate database
+	db.DB().SetMaxOpenConns(1)
+	db.AutoMigrate(&domain.Transaction{}, &domain.AuditEntry{})
+	repo := repository.NewDB(db)
+	ctx := context.Background()
+
+	write := func(ctx context.Context, id uuid.UUID) error {
+		if _, err := repo.CreateTransaction(ctx, domain.Transaction{ID: id, Status: domain.TransactionStatusSuccess, RecordType: "NEW"}); err != nil {
+			return err
+		}
+		_, err := repo.AppendAuditEntry(ctx, domain.AuditEntry{ID: uuid.New(), Action: domain.AuditActionCreate, EntityType: domain.AuditEntityTransaction, EntityID: id.String(), CreatedAt: time.Now()})
+		return err
+	}
+
+	// the change and its audit entry are committed together
+	committed := uuid.New()
+	assert.NoError(t, repo.InTransaction(ctx, func(ctx context.Context) error {
+		return write(ctx, committed)
+	}))
+	_, err = repo.GetTransaction(ctx, committed.String())
+	assert.NoError(t, err)
+
+	// and rolled back together
+	rolledBack := uuid.New()
+	err = repo.InTransaction(ctx, func(ctx context.Context) error {
+		if err := write(ctx, rolledBack); err != nil {
+			return err
+		}
+		return errors.New("audit failed")
+	})
+	assert.EqualError(t, err, "audit failed")
+	_, err = repo.GetTransaction(ctx, rolledBack.String())
+	assert.ErrorIs(t, err, domain.ErrTransactionNotFound)
+
+	entries, err := repo.ListAuditEntries(ctx, domain.AuditFilter{})
+	assert.NoError(t, err)
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, committed.String(), entries[0].EntityID)
+	}
+}
@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/datphamcode295/go-lambda-pulumi/internal/core/domain"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/logger"
+	"github.com/jinzhu/gorm"
+)
+
+func (u *DB) GetUser(ctx context.Context, id string) (*domain.User, error) {
+	user := &domain.User{}
+
+	db, err := u.withContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	req := db.First(user, "id = ?", id)
+	if req.RecordNotFound() {
+		logger.FromContext(ctx).WithField("user_id", id).Debug("User not found")
+		return nil, domain.ErrUserNotFound
+	}
+	if req.Error != nil {
+		return nil, req.Error
+	}
+
+	return user, nil
+}
+
+// MigrateMembershipTiers carries the users of the former membership flag over to the basic
+// tier. The flag is cleared as they are, so users losing their tier later stay without one
+// and the column can be dropped once migrated. Users holding a tier already keep it.
+func MigrateMembershipTiers(db *gorm.DB) error {
+	if !db.Dialect().HasColumn("users", "membership") {
+		return nil
+	}
+	req := db.Exec("UPDATE users SET membership_tier = CASE WHEN membership_tier IS NULL OR membership_tier = '' THEN ? ELSE membership_tier END, membership = ? WHERE membership = ?",
+		domain.MembershipBasic, false, true)
+	if req.Error != nil {
+		return req.Error
+	}
+	if req.RowsAffected > 0 {
+		logger.Log.WithField("users", req.RowsAffected).Info("Migrated members to the basic membership tier")
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package repository_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/datphamcode295/go-lambda-pulumi/internal/adapters/repository"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/core/domain"
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetUser(t *testing.T) {
+	db, err := gorm.Open("sqlite3", ":memory:")
+	assert.NoError(t, err)
+	db.AutoMigrate(&domain.User{})
+	repo := repository.NewDB(db)
+
+	endsAt := time.Date(2030, time.January, 1, 0, 0, 0, 0, time.UTC)
+	db.Create(&domain.User{ID: "user-1", Email: "member@example.com", MembershipTier: domain.MembershipPlus, MembershipEndsAt: &endsAt})
+
+	user, err := repo.GetUser(context.Background(), "user-1")
+	assert.NoError(t, err)
+	assert.Equal(t, domain.MembershipPlus, user.MembershipTier)
+	if assert.NotNil(t, user.MembershipEndsAt) {
+		assert.True(t, endsAt.Equal(*user.MembershipEndsAt))
+	}
+
+	_, err = repo.GetUser(context.Background(), "user-2")
+	assert.ErrorIs(t, err, domain.ErrUserNotFound)
+}
+
+func TestMigrateMembershipTiers(t *testing.T) {
+	db, err := gorm.Open("sqlite3", ":memory:")
+	assert.NoError(t, err)
+	assert.NoError(t, db.Exec("CREATE TABLE users (id varchar(255) PRIMARY KEY, email varchar(255), membership bool)").Error)
+	assert.NoError(t, db.Exec("INSERT INTO users (id, membership) VALUES ('member', ?), ('guest', ?)", true, false).Error)
+	db.AutoMigrate(&domain.User{})
+	assert.NoError(t, db.Exec("INSERT INTO users (id, membership, membership_tier) VALUES ('premium', ?, 'premium')", true).Error)
+
+	assert.NoError(t, repository.MigrateMembershipTiers(db))
+
+	repo := repository.NewDB(db)
+	for id, tier := range map[string]domain.MembershipTier{"member": domain.MembershipBasic, "guest": domain.MembershipNone, "premium": domain.MembershipPremium} {
+		user, err := repo.GetUser(context.Background(), id)
+		if assert.NoError(t, err, id) {
+			assert.Equal(t, tier, user.MembershipTier, id)
+		}
+	}
+
+	// migrated users are not carried over again
+	assert.NoError(t, db.Model(&domain.User{}).Where("id = ?", "member").UpdateColumn("membership_tier", domain.MembershipNone).Error)
+	assert.NoError(t, repository.MigrateMembershipTiers(db))
+	user, err := repo.GetUser(context.Background(), "member")
+	assert.NoError(t, err)
+	assert.Equal(t, domain.MembershipNone, user.MembershipTier)
+}
@@ -0,0 +1,58 @@
+// Package worker processes the background jobs delivered by a JobQueue.
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/adapters/queue"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/core/domain"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/core/ports"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/logger"
+	"github.com/sirupsen/logrus"
+)
+
+type Worker struct {
+	svc ports.PatientService
+}
+
+func New(svc ports.PatientService) *Worker {
+	return &Worker{
+		svc: svc,
+	}
+}
+
+// Handle runs job, an error leaves it on the queue to be retried
+func (w *Worker) Handle(ctx context.Context, job domain.Job) error {
+	ctx = logger.WithFields(ctx, logrus.Fields{"job_type": job.Type})
+
+	switch job.Type {
+	case domain.JobTypeProcessTransaction:
+		transaction, err := w.svc.ProcessTransaction(ctx, job.TransactionID)
+		if err != nil {
+			return err
+		}
+		logger.FromContext(ctx).WithFields(logrus.Fields{
+			"transaction_id": transaction.ID.String(),
+			"status":         transaction.Status,
+		}).Info("Transaction processed")
+		return nil
+	default:
+		return fmt.Errorf("unknown job type %q", job.Type)
+	}
+}
+
+// HandleSQSMessage decodes a job sent by queue.SQSQueue and runs it
+func (w *Worker) HandleSQSMessage(ctx context.Context, message events.SQSMessage) error {
+	var job domain.Job
+	if err := json.Unmarshal([]byte(message.Body), &job); err != nil {
+		return fmt.Errorf("decode job: %w", err)
+	}
+
+	ctx = logger.WithFields(queue.ContextFromJob(ctx, job), logrus.Fields{
+		"receive_count": message.Attributes["ApproximateReceiveCount"],
+	})
+	return w.Handle(ctx, job)
+}
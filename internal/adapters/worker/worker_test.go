@@ -0,0 +1,60 @@
+package worker_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/adapters/worker"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/core/domain"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/core/ports"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/requestctx"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakePatientService struct {
+	ports.PatientService
+	processed []uuid.UUID
+	requestID string
+	err       error
+}
+
+func (f *fakePatientService) ProcessTransaction(ctx context.Context, id uuid.UUID) (*domain.Transaction, error) {
+	f.processed = append(f.processed, id)
+	f.requestID = requestctx.RequestID(ctx)
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &domain.Transaction{ID: id, Status: domain.TransactionStatusSuccess}, nil
+}
+
+func TestWorker_HandleSQSMessage(t *testing.T) {
+	svc := &fakePatientService{}
+	w := worker.New(svc)
+	id := uuid.New()
+
+	err := w.HandleSQSMessage(context.Background(), events.SQSMessage{
+		MessageId: "message-1",
+		Body:      `{"type":"process_transaction","transaction_id":"` + id.String() + `","metadata":{"request_id":"req-1"}}`,
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []uuid.UUID{id}, svc.processed)
+	assert.Equal(t, "req-1", svc.requestID)
+}
+
+func TestWorker_HandleErrors(t *testing.T) {
+	svc := &fakePatientService{err: errors.New("provider unreachable")}
+	w := worker.New(svc)
+
+	err := w.Handle(context.Background(), domain.Job{Type: domain.JobTypeProcessTransaction, TransactionID: uuid.New()})
+	assert.EqualError(t, err, "provider unreachable")
+
+	err = w.Handle(context.Background(), domain.Job{Type: "unknown"})
+	assert.EqualError(t, err, `unknown job type "unknown"`)
+
+	err = w.HandleSQSMessage(context.Background(), events.SQSMessage{Body: "not json"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,522 @@
+// Package app builds the application from its configuration. Every port can be replaced
+// through an Option, so tests and alternate entry points reuse the same wiring.
+package app
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/adapters/handler"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/adapters/keys"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/adapters/provider"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/adapters/queue"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/adapters/repository"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/adapters/worker"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/config"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/core/domain"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/core/ports"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/core/pricing"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/core/rules"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/core/services"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/fieldcrypt"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/logger"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/metrics"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/tracing"
+	util "github.com/datphamcode295/go-lambda-pulumi/internal/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+// JobRetryFailedTransactions is the job, and EventBridge rule, retrying transient provider failures
+const JobRetryFailedTransactions = "retry-failed-transactions"
+
+// JobPurgeTransactionAttempts is the job, and EventBridge rule, deleting attempts past their retention
+const JobPurgeTransactionAttempts = "purge-transaction-attempts"
+
+// JobReencryptPatients is the job re-encrypting patient fields after the master key changed or
+// encryption was enabled. It is run on demand, not on a schedule.
+const JobReencryptPatients = "reencrypt-patients"
+
+// Database is the storage as seen by the health and diagnostics endpoints
+type Database interface {
+	Ping(ctx context.Context) error
+	Stats() sql.DBStats
+}
+
+// Handlers are the HTTP handlers mounted on the router
+type Handlers struct {
+	Patient *handler.PatientHandler
+	Health  *handler.HealthHandler
+	OpenAPI *handler.OpenAPIHandler
+}
+
+// App holds the wired dependencies of one running instance
+type App struct {
+	Config   *config.Config
+	Router   *gin.Engine
+	Handlers Handlers
+	Metrics  metrics.Recorder
+	// Worker processes the jobs of the queue, it is the SQS message handler of the function
+	Worker *worker.Worker
+	// Jobs are run by schedules, direct invocations or the -job flag, keyed by name
+	Jobs map[string]func(ctx context.Context) error
+
+	closers []func(ctx context.Context) error
+}
+
+type dependencies struct {
+	database        Database
+	patientRepo     ports.PatientRepository
+	transactionRepo ports.TransactionRepository
+	attemptRepo     ports.TransactionAttemptRepository
+	consentRepo     ports.ConsentRepository
+	userRepo        ports.UserRepository
+	auditRepo       ports.AuditRepository
+	keyProvider     ports.KeyProvider
+	eligibility     ports.EligibilityRuleSource
+	provider        ports.PatientProvider
+	queue           ports.JobQueue
+	patientService  ports.PatientService
+	metrics         metrics.Recorder
+}
+
+// Option replaces a dependency that New would otherwise build from the configuration
+type Option func(*dependencies)
+
+// WithDatabase sets the database reported by the health endpoints
+func WithDatabase(database Database) Option {
+	return func(d *dependencies) {
+		d.database = database
+	}
+}
+
+func WithPatientRepository(repo ports.PatientRepository) Option {
+	return func(d *dependencies) {
+		d.patientRepo = repo
+	}
+}
+
+func WithTransactionRepository(repo ports.TransactionRepository) Option {
+	return func(d *dependencies) {
+		d.transactionRepo = repo
+	}
+}
+
+func WithAttemptRepository(repo ports.TransactionAttemptRepository) Option {
+	return func(d *dependencies) {
+		d.attemptRepo = repo
+	}
+}
+
+func WithConsentRepository(repo ports.ConsentRepository) Option {
+	return func(d *dependencies) {
+		d.consentRepo = repo
+	}
+}
+
+func WithUserRepository(repo ports.UserRepository) Option {
+	return func(d *dependencies) {
+		d.userRepo = repo
+	}
+}
+
+func WithAuditRepository(repo ports.AuditRepository) Option {
+	return func(d *dependencies) {
+		d.auditRepo = repo
+	}
+}
+
+// WithKeyProvider replaces the key provider selected by FIELD_ENCRYPTION_KEY_PROVIDER
+func WithKeyProvider(keyProvider ports.KeyProvider) Option {
+	return func(d *dependencies) {
+		d.keyProvider = keyProvider
+	}
+}
+
+// WithEligibilityRules replaces the rules selected by ELIGIBILITY_RULES_SOURCE
+func WithEligibilityRules(source ports.EligibilityRuleSource) Option {
+	return func(d *dependencies) {
+		d.eligibility = source
+	}
+}
+
+func WithPatientProvider(patientProvider ports.PatientProvider) Option {
+	return func(d *dependencies) {
+		d.provider = patientProvider
+	}
+}
+
+func WithJobQueue(queue ports.JobQueue) Option {
+	return func(d *dependencies) {
+		d.queue = queue
+	}
+}
+
+// WithPatientService replaces the service, the repositories and provider are then unused by it
+func WithPatientService(svc ports.PatientService) Option {
+	return func(d *dependencies) {
+		d.patientService = svc
+	}
+}
+
+func WithMetrics(recorder metrics.Recorder) Option {
+	return func(d *dependencies) {
+		d.metrics = recorder
+	}
+}
+
+// New sets up logging and tracing, builds every dependency not given as an Option and
+// mounts the routes. Nothing is connected yet, the database is opened on first use.
+func New(ctx context.Context, cfg *config.Config, opts ...Option) (*App, error) {
+	deps := &dependencies{}
+	for _, opt := range opts {
+		opt(deps)
+	}
+
+	a := &App{Config: cfg}
+	logger.SetupLogger(cfg.Log)
+
+	// a broken exporter must not take the API down, requests are served untraced
+	if shutdownTracing, err := tracing.Setup(ctx, cfg.Tracing); err != nil {
+		logger.Log.WithError(err).Error("Failed to set up tracing")
+	} else {
+		a.closers = append(a.closers, shutdownTracing)
+	}
+
+	fields, err := newFieldEncryptor(cfg.Encryption, deps.keyProvider)
+	if err != nil {
+		return nil, fmt.Errorf("field encryption: %w", err)
+	}
+
+	if deps.database == nil && (deps.patientRepo == nil || deps.transactionRepo == nil) {
+		var storeOpts []repository.Option
+		if fields != nil {
+			storeOpts = append(storeOpts, repository.WithFieldEncryption(fields))
+		}
+		store := repository.NewLazyDB(repository.NewConnection(cfg.Database, repository.PostgresOpener(cfg.Database, cfg.DatabaseURL), registerCallbacks(fields), migrateDatabase(fields)), storeOpts...)
+		a.closers = append(a.closers, func(context.Context) error { return store.Close() })
+		deps.database = store
+		if deps.patientRepo == nil {
+			deps.patientRepo = store
+		}
+		if deps.transactionRepo == nil {
+			deps.transactionRepo = store
+		}
+		if deps.attemptRepo == nil {
+			deps.attemptRepo = store
+		}
+		if deps.consentRepo == nil {
+			deps.consentRepo = store
+		}
+		if deps.userRepo == nil {
+			deps.userRepo = store
+		}
+		if deps.auditRepo == nil {
+			deps.auditRepo = store
+		}
+	}
+
+	if deps.metrics == nil {
+		deps.metrics = metrics.NoopRecorder{}
+		if cfg.Metrics.Enabled {
+			deps.metrics = metrics.NewEMFRecorder(os.Stdout, cfg.Metrics.Namespace, nil)
+		}
+	}
+	a.Metrics = deps.metrics
+
+	if deps.provider == nil {
+		if cfg.Provider.URL != "" {
+			deps.provider = provider.NewHTTPProvider(cfg.Provider.URL, cfg.Provider.HealthURL, cfg.APIKey, cfg.Provider.Timeout)
+		} else {
+			deps.provider = provider.NewSimulatedProvider()
+		}
+	}
+
+	if deps.queue == nil && cfg.Queue.Async {
+		queue, err := a.newJobQueue()
+		if err != nil {
+			return nil, err
+		}
+		deps.queue = queue
+	}
+
+	if deps.eligibility == nil {
+		eligibility, err := newEligibilityRules(cfg.Eligibility, deps.database)
+		if err != nil {
+			return nil, err
+		}
+		deps.eligibility = eligibility
+	}
+
+	dates, err := util.NewDateParser(cfg.Dates.Formats, cfg.Dates.ClientFormats)
+	if err != nil {
+		return nil, err
+	}
+
+	if deps.patientService == nil {
+		location, err := time.LoadLocation(cfg.Eligibility.TimeZone)
+		if err != nil {
+			return nil, fmt.Errorf("eligibility time zone: %w", err)
+		}
+		opts := []services.Option{
+			services.WithProvider(deps.provider), services.WithJobQueue(deps.queue), services.WithMetrics(deps.metrics),
+			services.WithAttemptRepository(deps.attemptRepo), services.WithConsentRepository(deps.consentRepo),
+			services.WithUserRepository(deps.userRepo), services.WithEligibilityRules(deps.eligibility),
+			services.WithAuditLog(deps.auditRepo), services.WithTimeZone(location),
+		}
+		disclosure, err := newProviderPatientPolicy(cfg.Provider)
+		if err != nil {
+			return nil, fmt.Errorf("provider patient: %w", err)
+		}
+		opts = append(opts, services.WithProviderPatientPolicy(disclosure))
+		if cfg.Pricing.BaseFeeCents > 0 {
+			pricer, err := newPricer(cfg.Pricing)
+			if err != nil {
+				return nil, err
+			}
+			opts = append(opts, services.WithPricing(pricer))
+		}
+		deps.patientService = services.NewPatientService(cfg, deps.patientRepo, deps.transactionRepo, opts...)
+	}
+	a.Worker = worker.New(deps.patientService)
+	a.Jobs = map[string]func(ctx context.Context) error{
+		JobRetryFailedTransactions: func(ctx context.Context) error {
+			_, err := deps.patientService.RetryFailedTransactions(ctx)
+			return err
+		},
+		JobPurgeTransactionAttempts: func(ctx context.Context) error {
+			_, err := deps.patientService.PurgeTransactionAttempts(ctx)
+			return err
+		},
+	}
+	if reencrypter, ok := deps.patientRepo.(patientReencrypter); ok && fields != nil {
+		a.Jobs[JobReencryptPatients] = func(ctx context.Context) error {
+			_, err := reencrypter.ReencryptPatients(ctx, cfg.Encryption.RotationBatchSize)
+			return err
+		}
+	}
+	if memoryQueue, ok := deps.queue.(*queue.MemoryQueue); ok {
+		a.startLocalWorker(memoryQueue)
+	}
+
+	versions, defaultVersion, err := newAPIVersions(cfg.API)
+	if err != nil {
+		return nil, fmt.Errorf("api versions: %w", err)
+	}
+
+	a.Handlers = Handlers{
+		Patient: handler.NewPatientHandler(deps.patientService,
+			handler.WithDateParser(dates),
+			handler.WithProviderResponseFields(cfg.Provider.ResponseFields),
+		),
+		Health:  a.newHealthHandler(deps),
+		OpenAPI: handler.NewOpenAPIHandler(),
+	}
+	a.Router = a.newRouter(versions, defaultVersion)
+	if err := a.Handlers.OpenAPI.SetDocument(a.newOpenAPIDocument(versions, defaultVersion)); err != nil {
+		return nil, fmt.Errorf("openapi: %w", err)
+	}
+	return a, nil
+}
+
+// newPricer returns the pricing of the configuration, invalid benefits fail startup
+func newPricer(cfg config.PricingConfig) (*pricing.Pricer, error) {
+	benefits, err := pricing.ParseBenefits(cfg.Benefits)
+	if err != nil {
+		return nil, fmt.Errorf("membership benefits: %w", err)
+	}
+	return pricing.New(int64(cfg.BaseFeeCents), cfg.Currency, benefits)
+}
+
+// newEligibilityRules returns the rules of the configuration, or of the database when it is
+// the configured source. Invalid rules in the configuration fail startup.
+func newEligibilityRules(cfg config.EligibilityConfig, database Database) (ports.EligibilityRuleSource, error) {
+	switch cfg.Source {
+	case "", "config":
+		if cfg.Rules == "" {
+			return rules.Static(rules.Default()), nil
+		}
+		list, err := rules.Parse([]byte(cfg.Rules))
+		if err != nil {
+			return nil, err
+		}
+		return rules.Static(list), nil
+	case "database":
+		store, ok := database.(ports.EligibilityRuleSource)
+		if !ok {
+			return nil, errors.New("eligibility rules source is database but the database does not store rules")
+		}
+		return rules.NewCachedSource(store.EligibilityRules, cfg.CacheTTL), nil
+	default:
+		return nil, fmt.Errorf("unknown eligibility rules source %q", cfg.Source)
+	}
+}
+
+// newProviderPatientPolicy returns what the provider is sent of patients
+func newProviderPatientPolicy(cfg config.ProviderConfig) (domain.ProviderPatientPolicy, error) {
+	var tokenKey []byte
+	if cfg.TokenizePatientID {
+		key, err := base64.StdEncoding.DecodeString(cfg.PatientTokenKey)
+		if err != nil {
+			return domain.ProviderPatientPolicy{}, fmt.Errorf("patient token key is not base64: %w", err)
+		}
+		if len(key) == 0 {
+			return domain.ProviderPatientPolicy{}, errors.New("patient token key is not configured")
+		}
+		tokenKey = key
+	}
+	return domain.NewProviderPatientPolicy(cfg.Name, cfg.PatientFields, tokenKey)
+}
+
+// patientReencrypter is implemented by patient stores encrypting fields at rest
+type patientReencrypter interface {
+	ReencryptPatients(ctx context.Context, batchSize int) (int, error)
+}
+
+// newFieldEncryptor returns the encryptor of patient fields, nil when they are stored in
+// plaintext. keyProvider replaces the one of the configuration when set.
+func newFieldEncryptor(cfg config.EncryptionConfig, keyProvider ports.KeyProvider) (*fieldcrypt.Encryptor, error) {
+	if keyProvider == nil {
+		switch cfg.KeyProvider {
+		case "", "none":
+			return nil, nil
+		case "static":
+			masterKeys, err := keys.ParseStaticKeys(cfg.StaticKeys)
+			if err != nil {
+				return nil, err
+			}
+			if keyProvider, err = keys.NewStaticKeyProvider(masterKeys, cfg.StaticKeyID); err != nil {
+				return nil, err
+			}
+		case "kms":
+			if cfg.KMSKeyID == "" {
+				return nil, errors.New("kms key id is not configured")
+			}
+			sess, err := session.NewSession(&aws.Config{Region: aws.String(cfg.Region)})
+			if err != nil {
+				return nil, err
+			}
+			keyProvider = keys.NewKMSKeyProvider(kms.New(sess), cfg.KMSKeyID)
+		default:
+			return nil, fmt.Errorf("unknown key provider %q", cfg.KeyProvider)
+		}
+	}
+
+	indexKey, err := base64.StdEncoding.DecodeString(cfg.BlindIndexKey)
+	if err != nil {
+		return nil, fmt.Errorf("blind index key is not base64: %w", err)
+	}
+	return fieldcrypt.New(keyProvider, indexKey)
+}
+
+// newJobQueue returns the SQS queue when one is configured and an in-memory queue otherwise.
+// The in-memory queue is refused in Lambda, where jobs would be lost with the environment.
+func (a *App) newJobQueue() (ports.JobQueue, error) {
+	if a.Config.Queue.URL == "" {
+		if a.Config.Queue.Lambda {
+			return nil, errors.New("asynchronous pay-transaction needs JOB_QUEUE_URL in Lambda")
+		}
+		return queue.NewMemoryQueue(a.Config.Queue.VisibilityTimeout, a.Config.Queue.MaxReceives), nil
+	}
+
+	sess, err := session.NewSession(&aws.Config{Region: aws.String(a.Config.Queue.Region)})
+	if err != nil {
+		return nil, err
+	}
+	return queue.NewSQSQueue(sqs.New(sess), a.Config.Queue.URL), nil
+}
+
+// startLocalWorker consumes the in-memory queue in the background until the app is closed.
+// This is meant for local runs, a frozen Lambda environment would not make progress.
+func (a *App) startLocalWorker(memoryQueue *queue.MemoryQueue) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		memoryQueue.Run(ctx, a.Worker.Handle, a.Config.Queue.PollInterval)
+	}()
+
+	a.closers = append(a.closers, func(context.Context) error {
+		cancel()
+		<-done
+		return nil
+	})
+}
+
+func (a *App) newHealthHandler(deps *dependencies) *handler.HealthHandler {
+	checks := []handler.HealthCheck{
+		{Name: "config", Check: a.checkConfigLoaded},
+		{Name: "provider", Check: deps.provider.Ping, CacheTTL: a.Config.Health.ProviderCacheTTL},
+	}
+	diagnostics := handler.Diagnostics{ConfigSource: a.Config.Source}
+	if deps.database != nil {
+		checks = append([]handler.HealthCheck{{Name: "database", Check: deps.database.Ping, CacheTTL: a.Config.Health.DatabaseCacheTTL}}, checks...)
+		diagnostics.DBStats = deps.database.Stats
+	}
+	return handler.NewHealthHandler(checks, a.Config.Health.CheckTimeout, diagnostics)
+}
+
+func (a *App) checkConfigLoaded(ctx context.Context) error {
+	if a.Config.DatabaseURL == "" || a.Config.APIKey == "" {
+		return errors.New("configuration is incomplete")
+	}
+	return nil
+}
+
+// Close releases the database and flushes pending spans
+func (a *App) Close(ctx context.Context) error {
+	var errs []error
+	for i := len(a.closers) - 1; i >= 0; i-- {
+		if err := a.closers[i](ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// registerCallbacks returns the setup of every new database handle, encrypting patient and
+// consent fields with fields unless it is nil
+func registerCallbacks(fields *fieldcrypt.Encryptor) func(*gorm.DB) error {
+	return func(db *gorm.DB) error {
+		repository.RegisterTracing(db)
+		if fields != nil {
+			repository.RegisterFieldEncryption(db, fields)
+		}
+		return nil
+	}
+}
+
+// migrateDatabase returns the schema setup, run once per process on the first handle
+func migrateDatabase(fields *fieldcrypt.Encryptor) func(*gorm.DB) error {
+	return func(db *gorm.DB) error {
+		// Create or modify the database tables based on the model structs found in the imported package
+		err := db.AutoMigrate(&domain.User{}, &domain.Patient{}, &domain.Transaction{}, &domain.TransactionAttempt{}, &domain.GuardianConsent{}, &domain.AuditEntry{}, &rules.Rule{}).Error
+		if err != nil {
+			return err
+		}
+		if err := repository.MigrateMembershipTiers(db); err != nil {
+			return err
+		}
+		if err := repository.SeedEligibilityRules(db); err != nil {
+			return err
+		}
+		if err := repository.MigrateDateOfBirthColumns(db); err != nil {
+			return err
+		}
+		if fields != nil {
+			if err := repository.PrepareFieldEncryption(db); err != nil {
+				return err
+			}
+		}
+		return repository.ProtectAuditLog(db)
+	}
+}
@@ -0,0 +1,454 @@
+package app_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/datphamcode295/go-lambda-pulumi/internal/adapters/handler"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/adapters/repository"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/app"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/config"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/core/domain"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/core/ports"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/metrics"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/openapi"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/requestctx"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeDatabase struct {
+	err error
+}
+
+func (f *fakeDatabase) Ping(ctx context.Context) error { return f.err }
+func (f *fakeDatabase) Stats() sql.DBStats             { return sql.DBStats{MaxOpenConnections: 2} }
+
+type fakePatientService struct {
+	ports.PatientService
+	calls int
+}
+
+func (f *fakePatientService) PayTransaction(ctx context.Context, data domain.PayTransactionRequest) (*domain.Transaction, error) {
+	f.calls++
+	return &domain.Transaction{ID: uuid.New(), PatientID: data.PatientID, Status: domain.TransactionStatusSuccess}, nil
+}
+
+func (f *fakePatientService) RecordConsent(ctx context.Context, patientID uuid.UUID, data domain.RecordConsentRequest) (*domain.GuardianConsent, error) {
+	return &domain.GuardianConsent{ID: uuid.New(), PatientID: patientID, GrantedBy: requestctx.Actor(ctx)}, nil
+}
+
+func (f *fakePatientService) ListConsents(ctx context.Context, patientID uuid.UUID) ([]domain.GuardianConsent, error) {
+	return nil, nil
+}
+
+type acceptingProvider struct{}
+
+func (acceptingProvider) SubmitPatient(ctx context.Context, req domain.SubmitPatientRequest) (*domain.ProviderResponse, error) {
+	return &domain.ProviderResponse{Success: true, StatusCode: http.StatusOK, Body: json.RawMessage(`{"message":"Transaction success"}`)}, nil
+}
+
+func (acceptingProvider) Ping(ctx context.Context) error { return nil }
+
+func testConfig() *config.Config {
+	gin.SetMode(gin.TestMode)
+	return &config.Config{
+		DatabaseURL: "postgres://app@localhost/patients",
+		APIKey:      "api-key",
+		AdminAPIKey: "admin-key",
+		Source:      "test",
+	}
+}
+
+func serve(a *app.App, method, path string, body []byte, headers map[string]string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(method, path, bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	a.Router.ServeHTTP(w, req)
+	return w
+}
+
+func TestNew_UsesOverrides(t *testing.T) {
+	svc := &fakePatientService{}
+	recorder := metrics.NewMemoryRecorder()
+	a, err := app.New(context.Background(), testConfig(),
+		app.WithDatabase(&fakeDatabase{}),
+		app.WithPatientService(svc),
+		app.WithMetrics(recorder),
+	)
+	assert.NoError(t, err)
+	defer a.Close(context.Background())
+
+	body, _ := json.Marshal(domain.PayTransactionRequest{
+		PatientID:   uuid.New(),
+		DateOfBirth: domain.NewDate(1990, time.January, 1),
+		RecordType:  "NEW",
+	})
+	w := serve(a, http.MethodPost, "/app/patients/pay-transaction", body, nil)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 1, svc.calls)
+	assert.Same(t, recorder, a.Metrics)
+	assert.Len(t, recorder.Observations(metrics.HandlerLatency, metrics.Dimensions{"route": "/app/patients/pay-transaction", "status": "200"}), 1)
+}
+
+func TestNew_HealthRoutes(t *testing.T) {
+	database := &fakeDatabase{}
+	a, err := app.New(context.Background(), testConfig(), app.WithDatabase(database), app.WithPatientService(&fakePatientService{}))
+	assert.NoError(t, err)
+	defer a.Close(context.Background())
+
+	for _, path := range []string{"/healthz", "/readyz", "/app/healthz", "/app/readyz"} {
+		assert.Equal(t, http.StatusOK, serve(a, http.MethodGet, path, nil, nil).Code, path)
+	}
+
+	database.err = errors.New("connection refused")
+	assert.Equal(t, http.StatusServiceUnavailable, serve(a, http.MethodGet, "/app/readyz", nil, nil).Code)
+
+	assert.Equal(t, http.StatusForbidden, serve(a, http.MethodGet, "/app/admin/diagnostics", nil, nil).Code)
+	w := serve(a, http.MethodGet, "/app/admin/diagnostics", nil, map[string]string{handler.AdminKeyHeader: "admin-key"})
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestNew_ConsentsNeedPrincipal(t *testing.T) {
+	a, err := app.New(context.Background(), testConfig(), app.WithDatabase(&fakeDatabase{}), app.WithPatientService(&fakePatientService{}))
+	assert.NoError(t, err)
+	defer a.Close(context.Background())
+
+	path := "/app/patients/" + uuid.New().String() + "/consents"
+	body := []byte(`{"guardian_contact": "guardian@example.com", "relationship": "parent", "evidence_reference": "form-1"}`)
+	assert.Equal(t, http.StatusForbidden, serve(a, http.MethodPost, path, body, map[string]string{handler.ActorHeader: "user-42"}).Code)
+
+	w := serve(a, http.MethodPost, path, body, map[string]string{handler.AdminKeyHeader: "admin-key"})
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var consent handler.ConsentV1
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &consent))
+	assert.Equal(t, "admin-key", consent.GrantedBy)
+
+	// consents hold guardian details, reading them needs a principal too
+	assert.Equal(t, http.StatusForbidden, serve(a, http.MethodGet, path, nil, nil).Code)
+	assert.Equal(t, http.StatusOK, serve(a, http.MethodGet, path, nil, map[string]string{handler.AdminKeyHeader: "admin-key"}).Code)
+}
+
+func TestNew_DefaultsDoNotConnect(t *testing.T) {
+	// the database is unreachable, building the app must still succeed
+	cfg := testConfig()
+	cfg.DatabaseURL = "postgres://app@127.0.0.1:1/patients?connect_timeout=1"
+	cfg.Database.ConnectAttempts = 1
+
+	a, err := app.New(context.Background(), cfg)
+	assert.NoError(t, err)
+	assert.NotNil(t, a.Handlers.Patient)
+	assert.NotNil(t, a.Handlers.Health)
+
+	w := serve(a, http.MethodGet, "/readyz", nil, nil)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.NoError(t, a.Close(context.Background()))
+}
+
+func TestNew_AsyncPayTransaction(t *testing.T) {
+	db, err := gorm.Open("sqlite3", ":memory:")
+	assert.NoError(t, err)
+	// every connection to :memory: is a separate database
+	db.DB().SetMaxOpenConns(1)
+	db.AutoMigrate(&domain.Patient{}, &domain.Transaction{}, &domain.TransactionAttempt{})
+	patient := &domain.Patient{ID: uuid.New(), Name: "Test Patient", Email: "test@example.com"}
+	db.Create(patient)
+	store := repository.NewDB(db)
+
+	cfg := testConfig()
+	cfg.Queue = config.QueueConfig{Async: true, VisibilityTimeout: time.Second, MaxReceives: 3, PollInterval: 5 * time.Millisecond}
+	cfg.Provider.PatientFields = []string{"email"}
+	a, err := app.New(context.Background(), cfg,
+		app.WithDatabase(store),
+		app.WithPatientRepository(store),
+		app.WithTransactionRepository(store),
+		app.WithAttemptRepository(store),
+		app.WithPatientProvider(acceptingProvider{}),
+	)
+	assert.NoError(t, err)
+	defer a.Close(context.Background())
+
+	body, _ := json.Marshal(domain.PayTransactionRequest{PatientID: patient.ID, DateOfBirth: domain.NewDate(1990, time.January, 1), RecordType: "NEW"})
+	w := serve(a, http.MethodPost, "/app/patients/pay-transaction", body, nil)
+	assert.Equal(t, http.StatusAccepted, w.Code)
+
+	statusURL := w.Header().Get("Location")
+	assert.Regexp(t, "^/app/transactions/", statusURL)
+
+	assert.Eventually(t, func() bool {
+		var transaction domain.Transaction
+		w := serve(a, http.MethodGet, statusURL, nil, nil)
+		return w.Code == http.StatusOK &&
+			json.Unmarshal(w.Body.Bytes(), &transaction) == nil &&
+			transaction.Status == domain.TransactionStatusSuccess
+	}, time.Second, 10*time.Millisecond)
+
+	attemptsURL := strings.Replace(statusURL, "/app/", "/app/admin/", 1) + "/attempts"
+	w = serve(a, http.MethodGet, attemptsURL, nil, map[string]string{"X-Admin-Key": "admin-key"})
+	assert.Equal(t, http.StatusOK, w.Code)
+	var attempts []domain.TransactionAttempt
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &attempts))
+	if assert.Len(t, attempts, 1) {
+		assert.Equal(t, 1, attempts[0].Attempt)
+		assert.Equal(t, domain.AttemptErrorNone, attempts[0].ErrorClass)
+		assert.Contains(t, string(attempts[0].Request), `"email":"[REDACTED]"`)
+	}
+
+	w = serve(a, http.MethodGet, attemptsURL, nil, nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+type retryingPatientService struct {
+	ports.PatientService
+	runs   int
+	purges int
+}
+
+func (r *retryingPatientService) RetryFailedTransactions(ctx context.Context) (*domain.RetryResult, error) {
+	r.runs++
+	return &domain.RetryResult{}, nil
+}
+
+func (r *retryingPatientService) PurgeTransactionAttempts(ctx context.Context) (int64, error) {
+	r.purges++
+	return 0, nil
+}
+
+func TestNew_Jobs(t *testing.T) {
+	svc := &retryingPatientService{}
+	a, err := app.New(context.Background(), testConfig(), app.WithDatabase(&fakeDatabase{}), app.WithPatientService(svc))
+	assert.NoError(t, err)
+	defer a.Close(context.Background())
+
+	assert.NoError(t, a.Jobs[app.JobRetryFailedTransactions](context.Background()))
+	assert.Equal(t, 1, svc.runs)
+
+	assert.NoError(t, a.Jobs[app.JobPurgeTransactionAttempts](context.Background()))
+	assert.Equal(t, 1, svc.purges)
+}
+
+func TestNew_EligibilityRules(t *testing.T) {
+	cfg := testConfig()
+	cfg.Eligibility.Rules = `[{"name": "adults", "kind": "age", "min_age": 21}]`
+	a, err := app.New(context.Background(), cfg, app.WithDatabase(&fakeDatabase{}), app.WithPatientService(&fakePatientService{}))
+	assert.NoError(t, err)
+	assert.NoError(t, a.Close(context.Background()))
+
+	cfg.Eligibility.Rules = `[{"name": "adults", "kind": "height"}]`
+	_, err = app.New(context.Background(), cfg, app.WithDatabase(&fakeDatabase{}), app.WithPatientService(&fakePatientService{}))
+	assert.EqualError(t, err, `rule "adults": unknown kind "height"`)
+
+	cfg.Eligibility = config.EligibilityConfig{Source: "database"}
+	_, err = app.New(context.Background(), cfg, app.WithDatabase(&fakeDatabase{}), app.WithPatientService(&fakePatientService{}))
+	assert.EqualError(t, err, "eligibility rules source is database but the database does not store rules")
+}
+
+func TestNew_AsyncWithoutQueueInLambda(t *testing.T) {
+	cfg := testConfig()
+	cfg.Queue = config.QueueConfig{Async: true, Lambda: true}
+	_, err := app.New(context.Background(), cfg, app.WithDatabase(&fakeDatabase{}))
+	assert.EqualError(t, err, "asynchronous pay-transaction needs JOB_QUEUE_URL in Lambda")
+}
+
+func TestNew_InvalidTimeZone(t *testing.T) {
+	cfg := testConfig()
+	cfg.Eligibility.TimeZone = "Mars/Olympus_Mons"
+	_, err := app.New(context.Background(), cfg, app.WithDatabase(&fakeDatabase{}))
+	assert.ErrorContains(t, err, "eligibility time zone")
+}
+
+func TestNew_InvalidDateFormats(t *testing.T) {
+	cfg := testConfig()
+	cfg.Dates.ClientFormats = map[string][]string{"partner": {"MM/DD/YYYY", "DD/MM/YYYY"}}
+	_, err := app.New(context.Background(), cfg, app.WithDatabase(&fakeDatabase{}), app.WithPatientService(&fakePatientService{}))
+	assert.EqualError(t, err, `date formats of client "partner": MM/DD/YYYY and DD/MM/YYYY are ambiguous`)
+}
+
+func TestNew_InvalidMembershipBenefits(t *testing.T) {
+	cfg := testConfig()
+	cfg.Pricing = config.PricingConfig{BaseFeeCents: 5000, Currency: "AUD", Benefits: map[string]string{"gold": "10%"}}
+	_, err := app.New(context.Background(), cfg, app.WithDatabase(&fakeDatabase{}))
+	assert.EqualError(t, err, `membership benefits: unknown membership tier "gold"`)
+}
+
+func TestNew_FieldEncryption(t *testing.T) {
+	key := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 32))
+	indexKey := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{9}, 32))
+
+	cfg := testConfig()
+	cfg.DatabaseURL = "postgres://app@127.0.0.1:1/patients?connect_timeout=1"
+	cfg.Encryption = config.EncryptionConfig{KeyProvider: "static", StaticKeys: map[string]string{"dev": key}, StaticKeyID: "dev", BlindIndexKey: indexKey}
+	a, err := app.New(context.Background(), cfg)
+	assert.NoError(t, err)
+	assert.Contains(t, a.Jobs, app.JobReencryptPatients)
+	assert.NoError(t, a.Close(context.Background()))
+
+	testCases := []struct {
+		name     string
+		cfg      config.EncryptionConfig
+		expected string
+	}{
+		{name: "Unknown provider", cfg: config.EncryptionConfig{KeyProvider: "vault"}, expected: `field encryption: unknown key provider "vault"`},
+		{name: "Missing KMS key", cfg: config.EncryptionConfig{KeyProvider: "kms"}, expected: "field encryption: kms key id is not configured"},
+		{name: "Missing static key", cfg: config.EncryptionConfig{KeyProvider: "static", StaticKeys: map[string]string{"dev": key}, StaticKeyID: "prod", BlindIndexKey: indexKey}, expected: `field encryption: static key "prod" is not configured`},
+		{name: "Missing blind index key", cfg: config.EncryptionConfig{KeyProvider: "static", StaticKeys: map[string]string{"dev": key}, StaticKeyID: "dev"}, expected: "field encryption: blind index key must be at least 32 bytes"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := testConfig()
+			cfg.Encryption = tc.cfg
+			_, err := app.New(context.Background(), cfg, app.WithDatabase(&fakeDatabase{}))
+			assert.EqualError(t, err, tc.expected)
+		})
+	}
+}
+
+func TestNew_APIVersions(t *testing.T) {
+	svc := &fakePatientService{}
+	cfg := testConfig()
+	cfg.API = config.APIConfig{
+		DefaultVersion: "v1",
+		Deprecations:   map[string]string{"v1": "2026-01-01"},
+		Sunsets:        map[string]string{"v1": "2027-06-30"},
+	}
+	a, err := app.New(context.Background(), cfg, app.WithDatabase(&fakeDatabase{}), app.WithPatientService(svc))
+	assert.NoError(t, err)
+	defer a.Close(context.Background())
+
+	patientID := uuid.New()
+	v1Body := []byte(`{"patient_id":"` + patientID.String() + `","date_of_birth":"01-01-1990","record_type":"NEW"}`)
+	v2Body := []byte(`{"patient_id":"` + patientID.String() + `","date_of_birth":"1990-01-01","record":{"type":"NEW"}}`)
+
+	// v1 is deprecated, unversioned routes answer as v1
+	for _, path := range []string{"/app/v1/patients/pay-transaction", "/app/patients/pay-transaction"} {
+		w := serve(a, http.MethodPost, path, v1Body, nil)
+		assert.Equal(t, http.StatusOK, w.Code, path)
+		assert.Equal(t, "v1", w.Header().Get(handler.APIVersionHeader), path)
+		assert.Equal(t, "@1767225600", w.Header().Get("Deprecation"), path)
+		assert.Equal(t, "Wed, 30 Jun 2027 00:00:00 GMT", w.Header().Get("Sunset"), path)
+	}
+	w := serve(a, http.MethodPost, "/app/v1/patients/pay-transaction", v1Body, nil)
+	assert.Equal(t, `</app/v2/patients/pay-transaction>; rel="successor-version"`, w.Header().Get("Link"))
+
+	// v2 takes its own body and is not deprecated
+	w = serve(a, http.MethodPost, "/app/v2/patients/pay-transaction", v2Body, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "v2", w.Header().Get(handler.APIVersionHeader))
+	assert.Empty(t, w.Header().Get("Deprecation"))
+	assert.Equal(t, http.StatusBadRequest, serve(a, http.MethodPost, "/app/v2/patients/pay-transaction", v1Body, nil).Code)
+	assert.Equal(t, 4, svc.calls)
+
+	// admin routes are served once, outside of versions
+	w = serve(a, http.MethodGet, "/app/admin/diagnostics", nil, map[string]string{handler.AdminKeyHeader: "admin-key"})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get(handler.APIVersionHeader))
+	assert.Empty(t, w.Header().Get("Deprecation"))
+	for _, path := range []string{"/app/v1/admin/diagnostics", "/app/v2/admin/diagnostics"} {
+		assert.Equal(t, http.StatusNotFound, serve(a, http.MethodGet, path, nil, map[string]string{handler.AdminKeyHeader: "admin-key"}).Code, path)
+	}
+}
+
+func TestNew_APIDefaultVersion(t *testing.T) {
+	svc := &fakePatientService{}
+	cfg := testConfig()
+	cfg.API.DefaultVersion = "v2"
+	a, err := app.New(context.Background(), cfg, app.WithDatabase(&fakeDatabase{}), app.WithPatientService(svc))
+	assert.NoError(t, err)
+	defer a.Close(context.Background())
+
+	body := []byte(`{"patient_id":"` + uuid.New().String() + `","date_of_birth":"1990-01-01","record":{"type":"NEW"}}`)
+	w := serve(a, http.MethodPost, "/app/patients/pay-transaction", body, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "v2", w.Header().Get(handler.APIVersionHeader))
+
+	for _, api := range []config.APIConfig{
+		{DefaultVersion: "v3"},
+		{Deprecations: map[string]string{"v0": "2026-01-01"}},
+		{Sunsets: map[string]string{"v1": "30-06-2027"}},
+	} {
+		cfg := testConfig()
+		cfg.API = api
+		_, err := app.New(context.Background(), cfg, app.WithDatabase(&fakeDatabase{}), app.WithPatientService(svc))
+		assert.ErrorContains(t, err, "api versions: ")
+	}
+}
+
+// TestNew_OpenAPI fails when a route is mounted without an entry in the OpenAPI document, add
+// one to newDocs in openapi.go
+func TestNew_OpenAPI(t *testing.T) {
+	for _, async := range []bool{false, true} {
+		cfg := testConfig()
+		cfg.Queue = config.QueueConfig{Async: async, VisibilityTimeout: time.Second, MaxReceives: 3, PollInterval: time.Second}
+		cfg.API.SwaggerUI = true
+		cfg.API.Deprecations = map[string]string{"v1": "2026-01-01"}
+		a, err := app.New(context.Background(), cfg, app.WithDatabase(&fakeDatabase{}), app.WithPatientService(&fakePatientService{}))
+		assert.NoError(t, err)
+		defer a.Close(context.Background())
+
+		w := serve(a, http.MethodGet, "/app/openapi.json", nil, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		var doc openapi.Document
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &doc))
+		assert.Equal(t, openapi.Version, doc.OpenAPI)
+
+		for _, route := range a.Router.Routes() {
+			if strings.HasPrefix(route.Path, "/debug/pprof") {
+				continue
+			}
+			path := regexp.MustCompile(`:([^/]+)`).ReplaceAllString(route.Path, "{$1}")
+			item, ok := doc.Paths[path]
+			if assert.True(t, ok, "%s %s is not documented", route.Method, route.Path) {
+				assert.Contains(t, *item, strings.ToLower(route.Method), "%s %s is not documented", route.Method, route.Path)
+			}
+		}
+
+		pay := (*doc.Paths["/app/v1/patients/pay-transaction"])["post"]
+		assert.Equal(t, []string{"v1"}, pay.Tags)
+		assert.True(t, pay.Deprecated)
+		request := doc.Components.Schemas["PayTransactionRequestV1"]
+		assert.ElementsMatch(t, []string{"patient_id", "date_of_birth", "record_type"}, request.Required)
+		assert.Len(t, request.Properties["record_type"].Enum, len(domain.RecordTypeNames()))
+		assert.Equal(t, "v1", (*doc.Paths["/app/patients/pay-transaction"])["post"].Tags[0])
+
+		payV2 := (*doc.Paths["/app/v2/patients/pay-transaction"])["post"]
+		assert.False(t, payV2.Deprecated)
+		assert.Equal(t, "date", doc.Components.Schemas["PayTransactionRequestV2"].Properties["date_of_birth"].Format)
+
+		audit := (*doc.Paths["/app/admin/audit"])["get"]
+		assert.Equal(t, []map[string][]string{{"adminKey": {}}}, audit.Security)
+		assert.Empty(t, audit.Tags)
+		assert.False(t, audit.Deprecated)
+		assert.NotContains(t, doc.Paths, "/app/v2/admin/audit")
+		assert.Contains(t, doc.Components.SecuritySchemes, "adminKey")
+
+		w = serve(a, http.MethodGet, "/app/docs", nil, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "openapi.json")
+		w = serve(a, http.MethodGet, "/app/docs/assets/swagger-ui-bundle.js", nil, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Header().Get("Deprecation"))
+	}
+
+	a, err := app.New(context.Background(), testConfig(), app.WithDatabase(&fakeDatabase{}), app.WithPatientService(&fakePatientService{}))
+	assert.NoError(t, err)
+	defer a.Close(context.Background())
+	w := serve(a, http.MethodGet, "/app/docs", nil, nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -0,0 +1,275 @@
+package app
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/datphamcode295/go-lambda-pulumi/internal/adapters/handler"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/buildinfo"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/core/domain"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/logger"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/openapi"
+	util "github.com/datphamcode295/go-lambda-pulumi/internal/utils"
+	"github.com/google/uuid"
+)
+
+const adminKeyScheme = "adminKey"
+
+// idPath types the :id path parameter of transaction and patient routes
+type idPath struct {
+	ID uuid.UUID `uri:"id"`
+}
+
+type consentPath struct {
+	ID        uuid.UUID `uri:"id"`
+	ConsentID uuid.UUID `uri:"consentId"`
+}
+
+// swaggerAssetPath types the :file path parameter of the Swagger UI assets
+type swaggerAssetPath struct {
+	File string `uri:"file"`
+}
+
+// newOpenAPIDocument documents the routes of the router. Every handler mounted in routes.go
+// needs an entry in newDocs, routes without one are left out of the document.
+func (a *App) newOpenAPIDocument(versions map[string]handler.APIVersion, defaultVersion string) *openapi.Document {
+	docs := a.newDocs()
+	routes := a.Router.Routes()
+	if undocumented := docs.Undocumented(routes); len(undocumented) > 0 {
+		logger.Log.WithField("routes", undocumented).Warn("Routes missing from the OpenAPI document")
+	}
+
+	doc := docs.Build(openapi.Info{
+		Title:       "Patient transactions API",
+		Description: "Pays and tracks patient transactions with the provider",
+		Version:     buildinfo.Get().Version,
+	}, routes)
+	for path, item := range doc.Paths {
+		name := pathVersion(path, defaultVersion)
+		if name == "" {
+			continue
+		}
+		for _, operation := range *item {
+			operation.Tags = []string{name}
+			operation.Deprecated = versions[name].DeprecatedAt != nil
+		}
+	}
+	return doc
+}
+
+// pathVersion is the API version answering path, empty for the routes outside of versions
+func pathVersion(path, defaultVersion string) string {
+	rest := strings.TrimPrefix(path, "/app/")
+	for _, version := range apiVersions {
+		if strings.HasPrefix(rest, version.name+"/") {
+			return version.name
+		}
+	}
+	if rest == path || strings.HasPrefix(rest, "admin/") || strings.HasPrefix(rest, "docs/") {
+		return ""
+	}
+	switch rest {
+	case "healthz", "readyz", "openapi.json", "docs":
+		return ""
+	}
+	return defaultVersion
+}
+
+func (a *App) newDocs() *openapi.Docs {
+	docs := openapi.NewDocs()
+	docs.Ignore("/debug/pprof")
+
+	dateFormats := a.Config.Dates.Formats
+	if len(dateFormats) == 0 {
+		dateFormats = util.DefaultDateFormats
+	}
+	docs.Validator("date", func(schema *openapi.Schema, _ string) {
+		schema.Description = "Date in one of " + strings.Join(dateFormats, ", ") + ", or in the formats configured for the X-Client-Id client"
+		schema.Example = "15-03-1990"
+	})
+	docs.Validator("recordtype", func(schema *openapi.Schema, _ string) {
+		schema.Enum = nil
+		for _, name := range domain.RecordTypeNames() {
+			schema.Enum = append(schema.Enum, name)
+		}
+	})
+	docs.SecurityScheme(adminKeyScheme, openapi.SecurityScheme{
+		Type:        "apiKey",
+		In:          "header",
+		Name:        handler.AdminKeyHeader,
+		Description: "The ADMIN_API_KEY of the deployment",
+	})
+
+	invalid := openapi.OneOf{handler.ErrorResponse{}, handler.ValidationErrorResponse{}}
+	failed := handler.ErrorResponse{}
+
+	health, patient, spec := a.Handlers.Health, a.Handlers.Patient, a.Handlers.OpenAPI
+	docs.Add(health.Liveness, openapi.Operation{
+		Summary:   "Liveness probe",
+		Responses: map[int]interface{}{http.StatusOK: handler.HealthResponse{}},
+	})
+	docs.Add(health.Readiness, openapi.Operation{
+		Summary:     "Readiness probe",
+		Description: "Checks every dependency, 503 when one of them fails. Errors are only reported by the diagnostics endpoint",
+		Responses: map[int]interface{}{
+			http.StatusOK:                 handler.HealthResponse{},
+			http.StatusServiceUnavailable: handler.HealthResponse{},
+		},
+	})
+	docs.Add(spec.Spec, openapi.Operation{
+		Summary:   "This OpenAPI document",
+		Responses: map[int]interface{}{http.StatusOK: map[string]interface{}{}},
+	})
+	docs.Add(spec.SwaggerUI, openapi.Operation{
+		Summary:   "Swagger UI of this document",
+		Responses: map[int]interface{}{http.StatusOK: nil},
+	})
+	docs.Add(spec.SwaggerUIAsset, openapi.Operation{
+		Summary:   "Stylesheet and script of the Swagger UI",
+		Params:    swaggerAssetPath{},
+		Responses: map[int]interface{}{http.StatusOK: nil, http.StatusNotFound: failed},
+	})
+
+	clientID := map[string]string{handler.ClientIDHeader: "Client whose date formats date_of_birth is read in"}
+	docs.Add(patient.PayTransaction, openapi.Operation{
+		Summary: "Pay a transaction",
+		Headers: clientID,
+		Request: handler.PayTransactionRequestV1{},
+		Responses: map[int]interface{}{
+			http.StatusOK:                  handler.TransactionV1{},
+			http.StatusBadRequest:          invalid,
+			http.StatusInternalServerError: failed,
+		},
+	})
+	docs.Add(patient.SubmitTransaction, openapi.Operation{
+		Summary:     "Submit a transaction",
+		Description: "Accepts the transaction for asynchronous processing, poll its status URL for the outcome",
+		Headers:     clientID,
+		Request:     handler.PayTransactionRequestV1{},
+		Responses: map[int]interface{}{
+			http.StatusOK:                  handler.TransactionV1{},
+			http.StatusAccepted:            handler.AcceptedTransactionV1{},
+			http.StatusBadRequest:          invalid,
+			http.StatusInternalServerError: failed,
+		},
+	})
+	docs.Add(patient.PayTransactionV2, openapi.Operation{
+		Summary: "Pay a transaction",
+		Request: handler.PayTransactionRequestV2{},
+		Responses: map[int]interface{}{
+			http.StatusOK:                  handler.TransactionV2{},
+			http.StatusBadRequest:          invalid,
+			http.StatusInternalServerError: failed,
+		},
+	})
+	docs.Add(patient.SubmitTransactionV2, openapi.Operation{
+		Summary:     "Submit a transaction",
+		Description: "Accepts the transaction for asynchronous processing, poll its status URL for the outcome",
+		Request:     handler.PayTransactionRequestV2{},
+		Responses: map[int]interface{}{
+			http.StatusOK:                  handler.TransactionV2{},
+			http.StatusAccepted:            handler.AcceptedTransactionV2{},
+			http.StatusBadRequest:          invalid,
+			http.StatusInternalServerError: failed,
+		},
+	})
+	docs.Add(patient.GetTransaction, openapi.Operation{
+		Summary: "Get a transaction",
+		Params:  idPath{},
+		Responses: map[int]interface{}{
+			http.StatusOK:                  handler.TransactionV1{},
+			http.StatusBadRequest:          failed,
+			http.StatusNotFound:            failed,
+			http.StatusInternalServerError: failed,
+		},
+	})
+	docs.Add(patient.GetTransactionV2, openapi.Operation{
+		Summary: "Get a transaction",
+		Params:  idPath{},
+		Responses: map[int]interface{}{
+			http.StatusOK:                  handler.TransactionV2{},
+			http.StatusBadRequest:          failed,
+			http.StatusNotFound:            failed,
+			http.StatusInternalServerError: failed,
+		},
+	})
+
+	admin := []string{adminKeyScheme}
+	principal := "Needs a principal authorized by API Gateway or the admin key, recorded as who made the change"
+	docs.Add(patient.ListConsents, openapi.Operation{
+		Summary:     "List the guardian consents of a patient",
+		Description: "Needs a principal authorized by API Gateway or the admin key, the consents hold guardian details",
+		Params:      idPath{},
+		Security:    admin,
+		Responses: map[int]interface{}{
+			http.StatusOK:                  []handler.ConsentV1{},
+			http.StatusBadRequest:          failed,
+			http.StatusForbidden:           failed,
+			http.StatusInternalServerError: failed,
+		},
+	})
+	docs.Add(patient.RecordConsent, openapi.Operation{
+		Summary:     "Record a guardian consent",
+		Description: principal,
+		Params:      idPath{},
+		Request:     domain.RecordConsentRequest{},
+		Security:    admin,
+		Responses: map[int]interface{}{
+			http.StatusCreated:    handler.ConsentV1{},
+			http.StatusBadRequest: invalid,
+			http.StatusForbidden:  failed,
+		},
+	})
+	docs.Add(patient.RevokeConsent, openapi.Operation{
+		Summary:     "Revoke a guardian consent",
+		Description: principal,
+		Params:      consentPath{},
+		Request:     domain.RevokeConsentRequest{},
+		Security:    admin,
+		Responses: map[int]interface{}{
+			http.StatusOK:         handler.ConsentV1{},
+			http.StatusBadRequest: invalid,
+			http.StatusForbidden:  failed,
+			http.StatusNotFound:   failed,
+		},
+	})
+
+	docs.Add(health.Diagnostics, openapi.Operation{
+		Summary:   "Build, runtime and connection pool details",
+		Security:  admin,
+		Responses: map[int]interface{}{http.StatusOK: map[string]interface{}{}, http.StatusForbidden: failed},
+	})
+	docs.Add(patient.ListTransactionAttempts, openapi.Operation{
+		Summary:  "List the provider calls made for a transaction",
+		Params:   idPath{},
+		Security: admin,
+		Responses: map[int]interface{}{
+			http.StatusOK:                  []handler.TransactionAttemptV1{},
+			http.StatusBadRequest:          failed,
+			http.StatusForbidden:           failed,
+			http.StatusInternalServerError: failed,
+		},
+	})
+	docs.Add(patient.ListAuditEntries, openapi.Operation{
+		Summary:  "List the audit log",
+		Params:   domain.AuditFilter{},
+		Security: admin,
+		Responses: map[int]interface{}{
+			http.StatusOK:                  []handler.AuditEntryV1{},
+			http.StatusBadRequest:          invalid,
+			http.StatusForbidden:           failed,
+			http.StatusInternalServerError: failed,
+		},
+	})
+	docs.Add(patient.VerifyAuditLog, openapi.Operation{
+		Summary:     "Verify the hash chain of the audit log",
+		Description: "A broken chain still answers 200",
+		Security:    admin,
+		Responses: map[int]interface{}{
+			http.StatusOK:                  handler.AuditVerificationV1{},
+			http.StatusForbidden:           failed,
+			http.StatusInternalServerError: failed,
+		},
+	})
+	return docs
+}
@@ -0,0 +1,145 @@
+package app
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/datphamcode295/go-lambda-pulumi/internal/adapters/handler"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/config"
+	util "github.com/datphamcode295/go-lambda-pulumi/internal/utils"
+	"github.com/gin-contrib/pprof"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// apiVersions mounts the routes of each API version, oldest first. Every version is served
+// under /app/<version>, the default one also under /app. Admin routes are not versioned.
+var apiVersions = []struct {
+	name  string
+	mount func(a *App, group *gin.RouterGroup)
+}{
+	{name: "v1", mount: (*App).mountV1},
+	{name: "v2", mount: (*App).mountV2},
+}
+
+func (a *App) newRouter(versions map[string]handler.APIVersion, defaultVersion string) *gin.Engine {
+	router := gin.Default()
+	router.Use(handler.RequestLogger(), handler.Tracing(), handler.RequestMetrics(a.Metrics))
+	// Register custom validator
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterValidation("recordtype", util.ValidateRecordType)
+		v.RegisterValidation("date", util.ValidateDate)
+	}
+
+	pprof.Register(router)
+
+	health := a.Handlers.Health
+	router.GET("/healthz", health.Liveness)
+	router.GET("/readyz", health.Readiness)
+
+	api := router.Group("/app")
+
+	// API Gateway prefixes paths with the stage name, so probes also answer under /app
+	api.GET("/healthz", health.Liveness)
+	api.GET("/readyz", health.Readiness)
+
+	api.GET("/openapi.json", a.Handlers.OpenAPI.Spec)
+	if a.Config.API.SwaggerUI {
+		api.GET("/docs", a.Handlers.OpenAPI.SwaggerUI)
+		api.GET("/docs/assets/:file", a.Handlers.OpenAPI.SwaggerUIAsset)
+	}
+
+	admin := api.Group("/admin", handler.RequireAdminKey(a.Config.AdminAPIKey))
+	admin.GET("/diagnostics", a.Handlers.Health.Diagnostics)
+	admin.GET("/transactions/:id/attempts", a.Handlers.Patient.ListTransactionAttempts)
+	admin.GET("/audit", a.Handlers.Patient.ListAuditEntries)
+	admin.GET("/audit/verify", a.Handlers.Patient.VerifyAuditLog)
+
+	for _, version := range apiVersions {
+		version.mount(a, api.Group("/"+version.name, handler.Versioned(versions[version.name])))
+		if version.name == defaultVersion {
+			version.mount(a, api.Group("", handler.Versioned(versions[version.name])))
+		}
+	}
+
+	return router
+}
+
+func (a *App) mountV1(group *gin.RouterGroup) {
+	payTransaction := a.Handlers.Patient.PayTransaction
+	if a.Config.Queue.Async {
+		payTransaction = a.Handlers.Patient.SubmitTransaction
+	}
+	group.POST("/patients/pay-transaction", payTransaction)
+	group.GET("/transactions/:id", a.Handlers.Patient.GetTransaction)
+	a.mountShared(group)
+}
+
+// mountV2 changes the transaction routes, see handler.PayTransactionRequestV2
+func (a *App) mountV2(group *gin.RouterGroup) {
+	payTransaction := a.Handlers.Patient.PayTransactionV2
+	if a.Config.Queue.Async {
+		payTransaction = a.Handlers.Patient.SubmitTransactionV2
+	}
+	group.POST("/patients/pay-transaction", payTransaction)
+	group.GET("/transactions/:id", a.Handlers.Patient.GetTransactionV2)
+	a.mountShared(group)
+}
+
+// mountShared mounts the routes no version changed yet
+func (a *App) mountShared(group *gin.RouterGroup) {
+	principal := handler.RequirePrincipal(a.Config.AdminAPIKey)
+	group.GET("/patients/:id/consents", principal, a.Handlers.Patient.ListConsents)
+	group.POST("/patients/:id/consents", principal, a.Handlers.Patient.RecordConsent)
+	group.POST("/patients/:id/consents/:consentId/revoke", principal, a.Handlers.Patient.RevokeConsent)
+}
+
+// newAPIVersions returns the lifecycle of every API version and the version answering the
+// unversioned routes, the oldest when none is configured. Deprecated versions are succeeded by
+// the latest one.
+func newAPIVersions(cfg config.APIConfig) (map[string]handler.APIVersion, string, error) {
+	known := map[string]bool{}
+	for _, version := range apiVersions {
+		known[version.name] = true
+	}
+	defaultVersion := cfg.DefaultVersion
+	if defaultVersion == "" {
+		defaultVersion = apiVersions[0].name
+	}
+	if !known[defaultVersion] {
+		return nil, "", fmt.Errorf("unknown default version %q", defaultVersion)
+	}
+	for _, dates := range []map[string]string{cfg.Deprecations, cfg.Sunsets} {
+		for name := range dates {
+			if !known[name] {
+				return nil, "", fmt.Errorf("unknown version %q", name)
+			}
+		}
+	}
+
+	latest := apiVersions[len(apiVersions)-1].name
+	versions := map[string]handler.APIVersion{}
+	for _, version := range apiVersions {
+		rs := handler.APIVersion{Name: version.name}
+		if date, ok := cfg.Deprecations[version.name]; ok {
+			deprecatedAt, err := time.Parse(time.DateOnly, date)
+			if err != nil {
+				return nil, "", fmt.Errorf("deprecation date of %s: %w", version.name, err)
+			}
+			rs.DeprecatedAt = &deprecatedAt
+			if version.name != latest {
+				rs.Successor = latest
+			}
+		}
+		if date, ok := cfg.Sunsets[version.name]; ok {
+			sunsetAt, err := time.Parse(time.DateOnly, date)
+			if err != nil {
+				return nil, "", fmt.Errorf("sunset date of %s: %w", version.name, err)
+			}
+			rs.SunsetAt = &sunsetAt
+		}
+		versions[version.name] = rs
+	}
+	return versions, defaultVersion, nil
+}
@@ -1,9 +1,9 @@
 package config
 
 import (
-	"fmt"
 	"log"
 	"os"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
@@ -13,12 +13,193 @@ import (
 type Config struct {
 	DatabaseURL string
 	APIKey      string
+	// AdminAPIKey guards the /app/admin endpoints, which are disabled when it is empty
+	AdminAPIKey string
+	// Source describes where the configuration was loaded from, for diagnostics
+	Source      string
+	Database    DatabaseConfig
+	Queue       QueueConfig
+	Retry       RetryConfig
+	Eligibility EligibilityConfig
+	Dates       DatesConfig
+	Pricing     PricingConfig
+	Encryption  EncryptionConfig
+	Provider    ProviderConfig
+	API         APIConfig
+	Health      HealthConfig
+	Log         LogConfig
+	Metrics     MetricsConfig
+	Tracing     TracingConfig
+}
+
+type DatabaseConfig struct {
+	// MaxOpenConns and MaxIdleConns are per Lambda instance, which serves one request at a time
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+	// ConnectAttempts bounds how many times opening the database is tried per request,
+	// waiting ConnectBackoff after the first failure and doubling it after each one
+	ConnectAttempts int
+	ConnectBackoff  time.Duration
+	// PingAfterIdle pings the database before use when it was idle for longer, so connections
+	// broken while the environment was frozen are replaced. Zero disables the check
+	PingAfterIdle time.Duration
+	// IAMAuth replaces the password of DatabaseURL with an RDS IAM auth token, e.g. for RDS Proxy
+	IAMAuth bool
+	Region  string
+}
+
+type QueueConfig struct {
+	// Async makes pay-transaction answer 202 and leaves the provider call to the worker
+	Async bool
+	// URL of the SQS queue jobs are sent to, an in-memory queue is used when empty
+	URL    string
+	Region string
+	// Lambda is set when running in AWS Lambda, whose frozen environment does not process
+	// the in-memory queue, so asynchronous mode needs URL there
+	Lambda bool
+	// VisibilityTimeout, MaxReceives and PollInterval apply to the in-memory queue,
+	// SQS uses the settings and redrive policy of the queue
+	VisibilityTimeout time.Duration
+	MaxReceives       int
+	PollInterval      time.Duration
+}
+
+type RetryConfig struct {
+	// MaxAttempts bounds the provider submissions of a transaction, including the first one
+	MaxAttempts int
+	// BaseDelay is the wait after the first failure, doubled after each further one up to MaxDelay
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// BatchSize is how many due transactions one scheduler run retries
+	BatchSize int
+	// AttemptRetention is how long provider attempt records are kept, zero keeps them forever
+	AttemptRetention time.Duration
+	// Lease keeps a claimed transaction from other scheduler runs, it is retried again after
+	// the lease if the run claiming it died
+	Lease time.Duration
+}
+
+type EligibilityConfig struct {
+	// Source is "config" to use Rules or "database" to read the eligibility_rules table
+	Source string
+	// Rules is a JSON array of rules, the built-in rules are used when empty
+	Rules string
+	// CacheTTL is how long rules read from the database are reused
+	CacheTTL time.Duration
+	// TimeZone is the IANA zone whose calendar date counts as today for patient ages
+	TimeZone string
+	// RequireDateOfBirth rejects patients without a date of birth on file instead of skipping
+	// verification and the age rules
+	RequireDateOfBirth bool
+	// MaxDateOfBirthMismatches locks a patient out of pay-transaction after that many wrong dates of birth in a row, 0 never does
+	MaxDateOfBirthMismatches int
+}
+
+type DatesConfig struct {
+	// Formats are the date formats accepted from every client, e.g. DD-MM-YYYY. Empty means the defaults
+	Formats []string
+	// ClientFormats replaces Formats for the clients it is keyed by, matched with the X-Client-Id header
+	ClientFormats map[string][]string
+}
+
+type PricingConfig struct {
+	// BaseFeeCents is the fee of a pay-transaction before membership benefits, 0 leaves transactions unpriced
+	BaseFeeCents int
+	Currency     string
+	// Benefits maps membership tiers to a discount such as "10%" or "waive", the defaults are used when empty
+	Benefits map[string]string
+}
+
+type EncryptionConfig struct {
+	// KeyProvider is one of none, static or kms. Patient fields are stored in plaintext with none
+	KeyProvider string
+	// KMSKeyID is the key id, ARN or alias of the KMS key new data keys are generated under
+	KMSKeyID string
+	Region   string
+	// StaticKeys maps key ids to base64 AES-256 master keys, for development and tests
+	StaticKeys map[string]string
+	// StaticKeyID names the static key new data keys are wrapped with
+	StaticKeyID string
+	// BlindIndexKey is the base64 key of the blind indexes, it must not change once patients are indexed
+	BlindIndexKey string
+	// RotationBatchSize is how many patients the re-encryption job reads at a time
+	RotationBatchSize int
+}
+
+type ProviderConfig struct {
+	// URL of the provider API, the simulated provider is used when empty
+	URL       string
+	HealthURL string
+	Timeout   time.Duration
+	// Name scopes patient tokens, each provider knows a patient by a different token
+	Name string
+	// PatientFields are the patient fields sent besides the id, none when empty
+	PatientFields []string
+	// TokenizePatientID sends a token instead of the patient's UUID
+	TokenizePatientID bool
+	// PatientTokenKey is the base64 key patient tokens are derived with
+	PatientTokenKey string
+	// ResponseFields are the provider response fields answered to clients, the message and error when empty
+	ResponseFields []string
+}
+
+type APIConfig struct {
+	// DefaultVersion answers the unversioned /app routes
+	DefaultVersion string
+	// Deprecations maps deprecated versions to the YYYY-MM-DD date they were deprecated
+	Deprecations map[string]string
+	// Sunsets maps versions to the YYYY-MM-DD date they stop being served
+	Sunsets map[string]string
+	// SwaggerUI serves a Swagger UI of the OpenAPI document at /app/docs
+	SwaggerUI bool
+}
+
+type HealthConfig struct {
+	// CheckTimeout bounds each readiness check
+	CheckTimeout time.Duration
+	// ProviderCacheTTL is how long a provider reachability result is reused
+	ProviderCacheTTL time.Duration
+	// DatabaseCacheTTL is how long a database ping result is reused
+	DatabaseCacheTTL time.Duration
+}
+
+type LogConfig struct {
+	// Level is the default log level (trace, debug, info, warn, error). Empty means derived from HEX_ARCH_ENV
+	Level string
+	// Format is one of text, json or logfmt. Empty means derived from HEX_ARCH_ENV
+	Format string
+	// ReportCaller adds file and line of the log call to every entry
+	ReportCaller bool
+	// PackageLevels overrides Level for specific packages, keyed by package name e.g. "repository"
+	PackageLevels map[string]string
+	// SampleBurst is how many identical debug lines are logged per SampleInterval, 0 disables sampling
+	SampleBurst    int
+	SampleInterval time.Duration
+	// RedactFields lists extra field names masked in log output on top of the logger defaults
+	RedactFields []string
+}
+
+type MetricsConfig struct {
+	// Enabled turns on CloudWatch Embedded Metric Format output on stdout
+	Enabled   bool
+	Namespace string
+}
+
+type TracingConfig struct {
+	// Exporter is one of none, stdout, file or otlp
+	Exporter    string
+	FilePath    string
+	ServiceName string
+	// SampleRatio is the fraction of new traces recorded, traces started upstream follow the caller's decision
+	SampleRatio float64
 }
 
 func NewConfig() *Config {
 	// Get AWS region from environment variable or use default
 	region := os.Getenv("AWS_REGION")
-	fmt.Println("AWS_REGION", region)
+	log.Println("AWS_REGION", region)
 	if region == "" {
 		region = "ap-southeast-2"
 	}
@@ -45,9 +226,132 @@ func NewConfig() *Config {
 		log.Fatalf("Failed to get API_KEY parameter: %v", err)
 	}
 
+	// optional, admin endpoints stay disabled without it
+	adminAPIKey, err := getParameter(ssmClient, "/app/adminApiKey")
+	if err != nil {
+		log.Printf("Admin API key not available, admin endpoints are disabled: %v", err)
+	}
+
+	// only needed with field encryption
+	blindIndexKey, err := getParameter(ssmClient, "/app/fieldBlindIndexKey")
+	if err != nil {
+		log.Printf("Blind index key not available: %v", err)
+	}
+
+	// only needed with patient tokens
+	patientTokenKey, err := getParameter(ssmClient, "/app/providerPatientTokenKey")
+	if err != nil {
+		log.Printf("Provider patient token key not available: %v", err)
+	}
+
 	return &Config{
 		DatabaseURL: databaseURL,
 		APIKey:      apiKey,
+		AdminAPIKey: adminAPIKey,
+		Source:      "ssm:/app,env",
+		Database: DatabaseConfig{
+			MaxOpenConns:    getIntEnv("DB_MAX_OPEN_CONNS", 2),
+			MaxIdleConns:    getIntEnv("DB_MAX_IDLE_CONNS", 2),
+			ConnMaxLifetime: getDurationEnv("DB_CONN_MAX_LIFETIME", 5*time.Minute),
+			ConnMaxIdleTime: getDurationEnv("DB_CONN_MAX_IDLE_TIME", time.Minute),
+			ConnectAttempts: getIntEnv("DB_CONNECT_ATTEMPTS", 3),
+			ConnectBackoff:  getDurationEnv("DB_CONNECT_BACKOFF", 200*time.Millisecond),
+			PingAfterIdle:   getDurationEnv("DB_PING_AFTER_IDLE", 30*time.Second),
+			IAMAuth:         getBoolEnv("DB_IAM_AUTH", false),
+			Region:          region,
+		},
+		Queue: QueueConfig{
+			Async:             getBoolEnv("PAY_TRANSACTION_ASYNC", false),
+			URL:               os.Getenv("JOB_QUEUE_URL"),
+			Region:            region,
+			Lambda:            os.Getenv("AWS_LAMBDA_FUNCTION_NAME") != "",
+			VisibilityTimeout: getDurationEnv("JOB_VISIBILITY_TIMEOUT", 30*time.Second),
+			MaxReceives:       getIntEnv("JOB_MAX_RECEIVES", 5),
+			PollInterval:      getDurationEnv("JOB_POLL_INTERVAL", time.Second),
+		},
+		Retry: RetryConfig{
+			MaxAttempts: getIntEnv("RETRY_MAX_ATTEMPTS", 5),
+			BaseDelay:   getDurationEnv("RETRY_BASE_DELAY", time.Minute),
+			MaxDelay:    getDurationEnv("RETRY_MAX_DELAY", 30*time.Minute),
+			BatchSize:   getIntEnv("RETRY_BATCH_SIZE", 50),
+			Lease:       getDurationEnv("RETRY_LEASE", 5*time.Minute),
+
+			AttemptRetention: getDurationEnv("TRANSACTION_ATTEMPT_RETENTION", 30*24*time.Hour),
+		},
+		Eligibility: EligibilityConfig{
+			Source:   getEnv("ELIGIBILITY_RULES_SOURCE", "config"),
+			Rules:    os.Getenv("ELIGIBILITY_RULES"),
+			CacheTTL: getDurationEnv("ELIGIBILITY_RULES_CACHE_TTL", time.Minute),
+			TimeZone: getEnv("ELIGIBILITY_TIME_ZONE", "UTC"),
+
+			RequireDateOfBirth:       getBoolEnv("ELIGIBILITY_REQUIRE_DOB", false),
+			MaxDateOfBirthMismatches: getIntEnv("ELIGIBILITY_MAX_DOB_MISMATCHES", 5),
+		},
+		Dates: DatesConfig{
+			Formats:       getListEnv("DATE_FORMATS"),
+			ClientFormats: getFormatsEnv("CLIENT_DATE_FORMATS"),
+		},
+		Pricing: PricingConfig{
+			BaseFeeCents: getIntEnv("TRANSACTION_FEE_CENTS", 0),
+			Currency:     getEnv("TRANSACTION_CURRENCY", "AUD"),
+			Benefits:     getMapEnv("MEMBERSHIP_BENEFITS"),
+		},
+		Encryption: EncryptionConfig{
+			KeyProvider:       getEnv("FIELD_ENCRYPTION_KEY_PROVIDER", "none"),
+			KMSKeyID:          os.Getenv("FIELD_ENCRYPTION_KMS_KEY_ID"),
+			Region:            region,
+			StaticKeys:        getMapEnv("FIELD_ENCRYPTION_STATIC_KEYS"),
+			StaticKeyID:       os.Getenv("FIELD_ENCRYPTION_STATIC_KEY_ID"),
+			BlindIndexKey:     blindIndexKey,
+			RotationBatchSize: getIntEnv("FIELD_ENCRYPTION_ROTATION_BATCH_SIZE", 100),
+		},
+		Provider: ProviderConfig{
+			URL:       os.Getenv("PROVIDER_URL"),
+			HealthURL: os.Getenv("PROVIDER_HEALTH_URL"),
+			Timeout:   getDurationEnv("PROVIDER_TIMEOUT", 10*time.Second),
+
+			Name:              getEnv("PROVIDER_NAME", "provider"),
+			PatientFields:     getListEnv("PROVIDER_PATIENT_FIELDS"),
+			TokenizePatientID: getBoolEnv("PROVIDER_TOKENIZE_PATIENT_ID", false),
+			PatientTokenKey:   patientTokenKey,
+			ResponseFields:    getListEnv("PROVIDER_RESPONSE_FIELDS"),
+		},
+		API: APIConfig{
+			DefaultVersion: getEnv("API_DEFAULT_VERSION", "v1"),
+			Deprecations:   getMapEnv("API_DEPRECATED_VERSIONS"),
+			Sunsets:        getMapEnv("API_SUNSET_VERSIONS"),
+			SwaggerUI:      getBoolEnv("API_SWAGGER_UI", false),
+		},
+		Health: HealthConfig{
+			CheckTimeout:     getDurationEnv("HEALTH_CHECK_TIMEOUT", 2*time.Second),
+			ProviderCacheTTL: getDurationEnv("HEALTH_PROVIDER_CACHE_TTL", 30*time.Second),
+			DatabaseCacheTTL: getDurationEnv("HEALTH_DATABASE_CACHE_TTL", 5*time.Second),
+		},
+		Log: NewLogConfig(),
+		Metrics: MetricsConfig{
+			Enabled:   getBoolEnv("METRICS_ENABLED", true),
+			Namespace: getEnv("METRICS_NAMESPACE", "GoLambdaPulumi"),
+		},
+		Tracing: TracingConfig{
+			Exporter:    getEnv("TRACING_EXPORTER", "none"),
+			FilePath:    getEnv("TRACING_FILE", "traces.json"),
+			ServiceName: getEnv("OTEL_SERVICE_NAME", "go-lambda-pulumi"),
+			SampleRatio: getFloatEnv("TRACING_SAMPLE_RATIO", 1),
+		},
+	}
+}
+
+// NewLogConfig reads the log configuration from the environment. It is loaded on its own so
+// the logger can be set up before the rest of the configuration, whose warnings it formats.
+func NewLogConfig() LogConfig {
+	return LogConfig{
+		Level:          os.Getenv("LOG_LEVEL"),
+		Format:         os.Getenv("LOG_FORMAT"),
+		ReportCaller:   getBoolEnv("LOG_REPORT_CALLER", true),
+		PackageLevels:  getMapEnv("LOG_PACKAGE_LEVELS"),
+		SampleBurst:    getIntEnv("LOG_SAMPLE_BURST", 0),
+		SampleInterval: getDurationEnv("LOG_SAMPLE_INTERVAL", time.Second),
+		RedactFields:   getListEnv("LOG_REDACT_FIELDS"),
 	}
 }
 
@@ -0,0 +1,107 @@
+package config
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// getEnv reads an environment variable, returning fallback when it is unset or empty
+func getEnv(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// getListEnv reads a comma separated environment variable, ignoring empty items
+func getListEnv(key string) []string {
+	var items []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// getMapEnv reads a comma separated list of key=value pairs
+func getMapEnv(key string) map[string]string {
+	items := map[string]string{}
+	for _, item := range getListEnv(key) {
+		k, v, ok := strings.Cut(item, "=")
+		if !ok {
+			log.Printf("Ignoring malformed item %q in %s", item, key)
+			continue
+		}
+		items[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+	return items
+}
+
+// getFormatsEnv reads a comma separated list of key=value pairs whose values are lists separated by |
+func getFormatsEnv(key string) map[string][]string {
+	items := map[string][]string{}
+	for k, v := range getMapEnv(key) {
+		for _, format := range strings.Split(v, "|") {
+			if format = strings.TrimSpace(format); format != "" {
+				items[k] = append(items[k], format)
+			}
+		}
+	}
+	return items
+}
+
+func getBoolEnv(key string, fallback bool) bool {
+	value, ok := os.LookupEnv(key)
+	if !ok || value == "" {
+		return fallback
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Printf("Invalid boolean %q for %s, using %v", value, key, fallback)
+		return fallback
+	}
+	return parsed
+}
+
+func getIntEnv(key string, fallback int) int {
+	value, ok := os.LookupEnv(key)
+	if !ok || value == "" {
+		return fallback
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("Invalid integer %q for %s, using %d", value, key, fallback)
+		return fallback
+	}
+	return parsed
+}
+
+func getFloatEnv(key string, fallback float64) float64 {
+	value, ok := os.LookupEnv(key)
+	if !ok || value == "" {
+		return fallback
+	}
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		log.Printf("Invalid number %q for %s, using %v", value, key, fallback)
+		return fallback
+	}
+	return parsed
+}
+
+func getDurationEnv(key string, fallback time.Duration) time.Duration {
+	value, ok := os.LookupEnv(key)
+	if !ok || value == "" {
+		return fallback
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("Invalid duration %q for %s, using %s", value, key, fallback)
+		return fallback
+	}
+	return parsed
+}
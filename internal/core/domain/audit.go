@@ -0,0 +1,129 @@
+package domain
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type AuditAction string
+
+const (
+	AuditActionView   AuditAction = "view"
+	AuditActionCreate AuditAction = "create"
+	AuditActionUpdate AuditAction = "update"
+)
+
+// Audited entity types
+const (
+	AuditEntityTransaction = "transaction"
+	AuditEntityPatient     = "patient"
+	AuditEntityConsent     = "consent"
+)
+
+// AuditEntry records who did what to which entity. Entries are only ever appended, each one
+// carries the hash of the one before it so a changed or deleted entry breaks the chain.
+type AuditEntry struct {
+	ID uuid.UUID `json:"id" db:"id"`
+	// Sequence numbers the entries without gaps, starting at 1
+	Sequence int64 `json:"sequence" db:"sequence" gorm:"unique_index"`
+	// Actor is the authenticated principal of the request, anonymous for unauthenticated requests
+	// and system for background jobs
+	Actor string `json:"actor" db:"actor"`
+	// ClaimedActor is who the caller said it acted for, not verified
+	ClaimedActor string      `json:"claimed_actor,omitempty" db:"claimed_actor"`
+	Action       AuditAction `json:"action" db:"action"`
+	EntityType   string      `json:"entity_type" db:"entity_type"`
+	EntityID     string      `json:"entity_id" db:"entity_id"`
+	// Changes maps every changed field to its before and after values, with personal data masked
+	Changes   json.RawMessage `json:"changes,omitempty" db:"changes"`
+	RequestID string          `json:"request_id,omitempty" db:"request_id"`
+	SourceIP  string          `json:"source_ip,omitempty" db:"source_ip"`
+	CreatedAt time.Time       `json:"created_at" db:"created_at"`
+	PrevHash  string          `json:"prev_hash" db:"prev_hash"`
+	Hash      string          `json:"hash" db:"hash"`
+}
+
+// ComputeHash returns the hash of the entry chained to PrevHash. CreatedAt is hashed in UTC at
+// microsecond precision, as databases store it.
+func (e AuditEntry) ComputeHash() string {
+	fields := []interface{}{
+		e.PrevHash,
+		e.Sequence,
+		e.ID.String(),
+		e.Actor,
+		e.ClaimedActor,
+		e.Action,
+		e.EntityType,
+		e.EntityID,
+		string(e.Changes),
+		e.RequestID,
+		e.SourceIP,
+		e.CreatedAt.UTC().Truncate(time.Microsecond).Format(time.RFC3339Nano),
+	}
+	content, _ := json.Marshal(fields)
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// AuditChainError tells where the audit chain was found broken
+type AuditChainError struct {
+	Sequence int64
+	Reason   string
+}
+
+func (e *AuditChainError) Error() string {
+	return fmt.Sprintf("audit entry %d: %s", e.Sequence, e.Reason)
+}
+
+// VerifyAuditChain checks that entries, in sequence order, follow previous and each other
+// without gaps or changes. previous is nil when entries start the chain. It returns an
+// *AuditChainError for the first entry that does not.
+func VerifyAuditChain(previous *AuditEntry, entries []AuditEntry) error {
+	var sequence int64
+	var hash string
+	if previous != nil {
+		sequence = previous.Sequence
+		hash = previous.Hash
+	}
+
+	for _, entry := range entries {
+		switch {
+		case entry.Sequence != sequence+1:
+			return &AuditChainError{Sequence: entry.Sequence, Reason: fmt.Sprintf("expected sequence %d", sequence+1)}
+		case entry.PrevHash != hash:
+			return &AuditChainError{Sequence: entry.Sequence, Reason: "previous hash does not match"}
+		case entry.ComputeHash() != entry.Hash:
+			return &AuditChainError{Sequence: entry.Sequence, Reason: "hash does not match its content"}
+		}
+		sequence = entry.Sequence
+		hash = entry.Hash
+	}
+	return nil
+}
+
+// AuditFilter selects audit entries, empty fields match everything. Entries are listed by
+// sequence, after AfterSequence.
+type AuditFilter struct {
+	Actor         string      `form:"actor"`
+	Action        AuditAction `form:"action" binding:"omitempty,oneof=view create update"`
+	EntityType    string      `form:"entity_type"`
+	EntityID      string      `form:"entity_id"`
+	From          *time.Time  `form:"from" time_format:"2006-01-02T15:04:05Z07:00"`
+	To            *time.Time  `form:"to" time_format:"2006-01-02T15:04:05Z07:00"`
+	AfterSequence int64       `form:"after_sequence" binding:"min=0"`
+	Limit         int         `form:"limit" binding:"min=0,max=1000"`
+}
+
+// AuditVerification is the outcome of checking the whole audit chain
+type AuditVerification struct {
+	Valid    bool  `json:"valid"`
+	Verified int64 `json:"verified"`
+	// BrokenAt is the sequence of the first entry failing verification
+	BrokenAt *int64 `json:"broken_at,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+}
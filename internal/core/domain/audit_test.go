@@ -0,0 +1,65 @@
+package domain_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/datphamcode295/go-lambda-pulumi/internal/core/domain"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func auditChain(n int) []domain.AuditEntry {
+	entries := make([]domain.AuditEntry, n)
+	var prevHash string
+	for i := range entries {
+		entries[i] = domain.AuditEntry{
+			ID:         uuid.New(),
+			Sequence:   int64(i + 1),
+			Actor:      "user-1",
+			Action:     domain.AuditActionView,
+			EntityType: domain.AuditEntityTransaction,
+			EntityID:   "t-1",
+			CreatedAt:  time.Date(2024, time.June, 14, 12, i, 0, 0, time.UTC),
+			PrevHash:   prevHash,
+		}
+		entries[i].Hash = entries[i].ComputeHash()
+		prevHash = entries[i].Hash
+	}
+	return entries
+}
+
+func TestVerifyAuditChain(t *testing.T) {
+	assert.NoError(t, domain.VerifyAuditChain(nil, auditChain(3)))
+	assert.NoError(t, domain.VerifyAuditChain(nil, nil))
+
+	entries := auditChain(3)
+	assert.NoError(t, domain.VerifyAuditChain(&entries[0], entries[1:]))
+
+	entries = auditChain(3)
+	entries[1].EntityID = "t-2"
+	assert.EqualError(t, domain.VerifyAuditChain(nil, entries), "audit entry 2: hash does not match its content")
+
+	entries = auditChain(3)
+	assert.EqualError(t, domain.VerifyAuditChain(nil, []domain.AuditEntry{entries[0], entries[2]}), "audit entry 3: expected sequence 2")
+
+	// rehashing a changed entry still breaks the link to the next one
+	entries = auditChain(3)
+	entries[1].EntityID = "t-2"
+	entries[1].Hash = entries[1].ComputeHash()
+	assert.EqualError(t, domain.VerifyAuditChain(nil, entries), "audit entry 3: previous hash does not match")
+}
+
+func TestAuditEntry_ComputeHash_TimeZone(t *testing.T) {
+	entry := auditChain(1)[0]
+	local := entry
+	local.CreatedAt = entry.CreatedAt.In(time.FixedZone("AEST", 10*60*60))
+	assert.Equal(t, entry.ComputeHash(), local.ComputeHash())
+}
+
+func TestAuditEntry_ComputeHash_ClaimedActor(t *testing.T) {
+	entry := auditChain(1)[0]
+	claimed := entry
+	claimed.ClaimedActor = "user-42"
+	assert.NotEqual(t, entry.ComputeHash(), claimed.ComputeHash())
+}
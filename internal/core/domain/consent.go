@@ -0,0 +1,55 @@
+package domain
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrConsentNotFound is returned when a consent does not exist for the patient
+var ErrConsentNotFound = errors.New("consent not found")
+
+// GuardianConsent is a guardian's consent to transactions of an underage patient. The
+// guardian is a registered User, or a contact when they have no account. The guardian's name
+// and contact are encrypted at rest like the patient fields.
+type GuardianConsent struct {
+	ID        uuid.UUID `json:"id" db:"id"`
+	PatientID uuid.UUID `json:"patient_id" db:"patient_id"`
+	// GuardianUserID is the ID of the guardian's User, empty for a contact
+	GuardianUserID  string `json:"guardian_user_id,omitempty" db:"guardian_user_id"`
+	GuardianName    string `json:"guardian_name,omitempty" db:"guardian_name" gorm:"type:text" encrypted:"true"`
+	GuardianContact string `json:"guardian_contact,omitempty" db:"guardian_contact" gorm:"type:text" encrypted:"true"`
+	Relationship    string `json:"relationship" db:"relationship"`
+	// EvidenceReference points to the signed form or record the consent was given in
+	EvidenceReference string    `json:"evidence_reference" db:"evidence_reference"`
+	GrantedAt         time.Time `json:"granted_at" db:"granted_at"`
+	// GrantedBy is the principal that recorded the consent
+	GrantedBy        string     `json:"granted_by" db:"granted_by"`
+	ExpiresAt        *time.Time `json:"expires_at,omitempty" db:"expires_at"`
+	RevokedAt        *time.Time `json:"revoked_at,omitempty" db:"revoked_at"`
+	RevokedBy        string     `json:"revoked_by,omitempty" db:"revoked_by"`
+	RevocationReason string     `json:"revocation_reason,omitempty" db:"revocation_reason"`
+}
+
+// ValidAt tells whether the consent was granted, and neither revoked nor expired, at t
+func (c GuardianConsent) ValidAt(t time.Time) bool {
+	return !c.GrantedAt.After(t) &&
+		(c.RevokedAt == nil || c.RevokedAt.After(t)) &&
+		(c.ExpiresAt == nil || c.ExpiresAt.After(t))
+}
+
+// RecordConsentRequest records the consent of a guardian given by user ID or by contact
+type RecordConsentRequest struct {
+	GuardianUserID    string     `json:"guardian_user_id" binding:"required_without=GuardianContact"`
+	GuardianName      string     `json:"guardian_name"`
+	GuardianContact   string     `json:"guardian_contact" binding:"required_without=GuardianUserID"`
+	Relationship      string     `json:"relationship" binding:"required"`
+	EvidenceReference string     `json:"evidence_reference" binding:"required"`
+	ExpiresAt         *time.Time `json:"expires_at"`
+}
+
+// RevokeConsentRequest revokes a consent, the reason is kept with it
+type RevokeConsentRequest struct {
+	Reason string `json:"reason" binding:"required"`
+}
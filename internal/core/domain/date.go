@@ -0,0 +1,117 @@
+package domain
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// DateLayout is how dates are written in the API, DD-MM-YYYY
+const DateLayout = "02-01-2006"
+
+// Date is a calendar date without time of day or time zone, held as midnight UTC
+type Date struct {
+	time.Time
+}
+
+func NewDate(year int, month time.Month, day int) Date {
+	return Date{time.Date(year, month, day, 0, 0, 0, 0, time.UTC)}
+}
+
+// ParseDate reads a date written in DateLayout
+func ParseDate(s string) (Date, error) {
+	t, err := time.Parse(DateLayout, s)
+	if err != nil {
+		return Date{}, err
+	}
+	return Date{t}, nil
+}
+
+// DateOf is the calendar date of t in its own location
+func DateOf(t time.Time) Date {
+	return NewDate(t.Date())
+}
+
+// Equal tells whether both are the same calendar date
+func (d Date) Equal(other Date) bool {
+	return d.Time.Equal(other.Time)
+}
+
+func (d Date) String() string {
+	if d.IsZero() {
+		return ""
+	}
+	return d.Format(DateLayout)
+}
+
+func (d Date) MarshalJSON() ([]byte, error) {
+	if d.IsZero() {
+		return []byte(`null`), nil
+	}
+	return json.Marshal(d.String())
+}
+
+func (d *Date) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*d = Date{}
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	if s == "" {
+		*d = Date{}
+		return nil
+	}
+	parsed, err := ParseDate(s)
+	if err != nil {
+		return fmt.Errorf("date must be in DD-MM-YYYY format: %w", err)
+	}
+	*d = parsed
+	return nil
+}
+
+// Value stores the date in a date column, the zero date as NULL
+func (d Date) Value() (driver.Value, error) {
+	if d.IsZero() {
+		return nil, nil
+	}
+	return d.Time, nil
+}
+
+// storedLayouts are the text forms a date column can be read back in. DD-MM-YYYY is the
+// layout transactions were stored with before dates had their own type.
+var storedLayouts = []string{"2006-01-02", time.RFC3339, "2006-01-02 15:04:05Z07:00", "2006-01-02 15:04:05", DateLayout}
+
+func (d *Date) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*d = Date{}
+		return nil
+	case time.Time:
+		*d = DateOf(v)
+		return nil
+	case []byte:
+		return d.scanText(string(v))
+	case string:
+		return d.scanText(v)
+	default:
+		return fmt.Errorf("cannot scan %T into Date", src)
+	}
+}
+
+func (d *Date) scanText(s string) error {
+	if s == "" {
+		*d = Date{}
+		return nil
+	}
+	for _, layout := range storedLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			*d = DateOf(t)
+			return nil
+		}
+	}
+	return fmt.Errorf("cannot read %q as a date", s)
+}
@@ -0,0 +1,64 @@
+package domain_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/datphamcode295/go-lambda-pulumi/internal/core/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDate_JSON(t *testing.T) {
+	data, err := json.Marshal(struct {
+		DateOfBirth domain.Date `json:"date_of_birth"`
+		Missing     domain.Date `json:"missing"`
+	}{DateOfBirth: domain.NewDate(1990, time.March, 15)})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"date_of_birth": "15-03-1990", "missing": null}`, string(data))
+
+	var date domain.Date
+	assert.NoError(t, json.Unmarshal([]byte(`"29-02-2020"`), &date))
+	assert.Equal(t, domain.NewDate(2020, time.February, 29), date)
+
+	assert.NoError(t, json.Unmarshal([]byte(`null`), &date))
+	assert.True(t, date.IsZero())
+
+	assert.Error(t, json.Unmarshal([]byte(`"1990-03-15"`), &date))
+	assert.Error(t, json.Unmarshal([]byte(`"29-02-2021"`), &date))
+}
+
+func TestDate_ValueAndScan(t *testing.T) {
+	value, err := domain.NewDate(1990, time.March, 15).Value()
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(1990, time.March, 15, 0, 0, 0, 0, time.UTC), value)
+
+	value, err = domain.Date{}.Value()
+	assert.NoError(t, err)
+	assert.Nil(t, value)
+
+	testCases := []struct {
+		name string
+		src  interface{}
+	}{
+		{name: "Date column", src: time.Date(1990, time.March, 15, 0, 0, 0, 0, time.UTC)},
+		{name: "Date column in another zone", src: time.Date(1990, time.March, 15, 0, 0, 0, 0, time.FixedZone("AEST", 10*60*60))},
+		{name: "ISO text", src: "1990-03-15"},
+		{name: "Timestamp text", src: []byte("1990-03-15T00:00:00Z")},
+		{name: "Legacy DD-MM-YYYY text", src: "15-03-1990"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var date domain.Date
+			assert.NoError(t, date.Scan(tc.src))
+			assert.Equal(t, domain.NewDate(1990, time.March, 15), date)
+		})
+	}
+
+	var date domain.Date
+	assert.NoError(t, date.Scan(nil))
+	assert.True(t, date.IsZero())
+	assert.Error(t, date.Scan("yesterday"))
+	assert.Error(t, date.Scan(42))
+}
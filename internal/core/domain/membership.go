@@ -0,0 +1,47 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// MembershipTier is the level of a user's membership, MembershipNone without one
+type MembershipTier string
+
+const (
+	MembershipNone    MembershipTier = ""
+	MembershipBasic   MembershipTier = "basic"
+	MembershipPlus    MembershipTier = "plus"
+	MembershipPremium MembershipTier = "premium"
+)
+
+// ErrUserNotFound is returned when no user has the requested id
+var ErrUserNotFound = errors.New("user not found")
+
+// MembershipTiers are the tiers a user can hold, lowest first
+func MembershipTiers() []MembershipTier {
+	return []MembershipTier{MembershipBasic, MembershipPlus, MembershipPremium}
+}
+
+// ParseMembershipTier returns the tier named s
+func ParseMembershipTier(s string) (MembershipTier, error) {
+	for _, tier := range MembershipTiers() {
+		if string(tier) == s {
+			return tier, nil
+		}
+	}
+	return MembershipNone, fmt.Errorf("unknown membership tier %q", s)
+}
+
+// MembershipAt returns the tier of the user at t, MembershipNone outside the validity of the
+// membership. Either bound may be left open.
+func (u User) MembershipAt(t time.Time) MembershipTier {
+	if u.MembershipStartsAt != nil && t.Before(*u.MembershipStartsAt) {
+		return MembershipNone
+	}
+	if u.MembershipEndsAt != nil && !t.Before(*u.MembershipEndsAt) {
+		return MembershipNone
+	}
+	return u.MembershipTier
+}
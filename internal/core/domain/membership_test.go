@@ -0,0 +1,33 @@
+package domain_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/datphamcode295/go-lambda-pulumi/internal/core/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUser_MembershipAt(t *testing.T) {
+	startsAt := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	endsAt := time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)
+	user := domain.User{MembershipTier: domain.MembershipPlus, MembershipStartsAt: &startsAt, MembershipEndsAt: &endsAt}
+
+	assert.Equal(t, domain.MembershipNone, user.MembershipAt(startsAt.Add(-time.Second)))
+	assert.Equal(t, domain.MembershipPlus, user.MembershipAt(startsAt))
+	assert.Equal(t, domain.MembershipPlus, user.MembershipAt(endsAt.Add(-time.Second)))
+	assert.Equal(t, domain.MembershipNone, user.MembershipAt(endsAt))
+
+	// open ended memberships
+	assert.Equal(t, domain.MembershipPlus, domain.User{MembershipTier: domain.MembershipPlus}.MembershipAt(endsAt))
+	assert.Equal(t, domain.MembershipNone, domain.User{}.MembershipAt(endsAt))
+}
+
+func TestParseMembershipTier(t *testing.T) {
+	tier, err := domain.ParseMembershipTier("premium")
+	assert.NoError(t, err)
+	assert.Equal(t, domain.MembershipPremium, tier)
+
+	_, err = domain.ParseMembershipTier("gold")
+	assert.EqualError(t, err, `unknown membership tier "gold"`)
+}
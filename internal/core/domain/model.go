@@ -1,28 +1,48 @@
 package domain
 
 import (
+	"database/sql/driver"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"time"
 
 	"github.com/google/uuid"
 )
 
 type User struct {
-	ID         string `json:"id" db:"id"`
-	Email      string `json:"email" db:"email"`
-	Password   string `json:"password" db:"password"`
-	Membership bool   `json:"membership" db:"membership"`
+	ID    string `json:"id" db:"id"`
+	Email string `json:"email" db:"email"`
+	// Password is never serialized, responses are mapped by the handlers
+	Password string `json:"-" db:"password"`
+	// MembershipTier applies from MembershipStartsAt until MembershipEndsAt, see MembershipAt
+	MembershipTier     MembershipTier `json:"membership_tier,omitempty" db:"membership_tier"`
+	MembershipStartsAt *time.Time     `json:"membership_starts_at,omitempty" db:"membership_starts_at"`
+	MembershipEndsAt   *time.Time     `json:"membership_ends_at,omitempty" db:"membership_ends_at"`
 }
 
+// ErrPatientNotFound is returned when no patient has the requested id
+var ErrPatientNotFound = errors.New("patient not found")
+
+// Patient fields tagged encrypted are encrypted at rest when field encryption is configured,
+// see the fieldcrypt package
 type Patient struct {
 	ID      uuid.UUID `json:"id" db:"id"`
-	Name    string    `json:"name" db:"name"`
-	Email   string    `json:"email" db:"email"`
-	Phone   string    `json:"phone" db:"phone"`
-	Address string    `json:"address" db:"address"`
-	City    string    `json:"city" db:"city"`
-	State   string    `json:"state" db:"state"`
-	Zip     string    `json:"zip" db:"zip"`
+	Name    string    `json:"name" db:"name" gorm:"type:text" encrypted:"true"`
+	Email   string    `json:"email" db:"email" gorm:"type:text" encrypted:"true"`
+	Phone   string    `json:"phone" db:"phone" gorm:"type:text" encrypted:"true"`
+	Address string    `json:"address" db:"address" gorm:"type:text" encrypted:"true"`
+	// EmailIndex is the blind index patients are looked up by email with
+	EmailIndex string `json:"-" db:"email_index" gorm:"index" blind_index:"Email"`
+	City       string `json:"city" db:"city"`
+	State      string `json:"state" db:"state"`
+	Zip        string `json:"zip" db:"zip"`
+	// UserID is the account holding the patient, whose membership prices their transactions
+	UserID string `json:"user_id,omitempty" db:"user_id"`
+	// DateOfBirth is what pay-transaction requests are verified against, zero when not on file
+	DateOfBirth Date `json:"date_of_birth" db:"date_of_birth" gorm:"type:date"`
+	// DateOfBirthMismatches counts pay-transactions claiming another date of birth
+	DateOfBirthMismatches int `json:"-" db:"date_of_birth_mismatches"`
 }
 
 type TransactionStatus string
@@ -30,20 +50,173 @@ type TransactionStatus string
 const (
 	TransactionStatusSuccess TransactionStatus = "success"
 	TransactionStatusFailed  TransactionStatus = "failed"
+	// TransactionStatusPending is a transaction accepted for asynchronous processing
+	TransactionStatusPending TransactionStatus = "pending"
+)
+
+// FailureKind tells whether a failed transaction may succeed when submitted again
+type FailureKind string
+
+const (
+	// FailureKindRejected is a business rejection, by our validation or by the provider
+	FailureKindRejected FailureKind = "rejected"
+	// FailureKindTransient is a provider failure worth retrying
+	FailureKindTransient FailureKind = "transient"
 )
 
+// ErrTransactionNotFound is returned when no transaction has the requested id
+var ErrTransactionNotFound = errors.New("transaction not found")
+
 type Transaction struct {
 	ID          uuid.UUID         `json:"id" db:"id"`
 	PatientID   uuid.UUID         `json:"patient_id" db:"patient_id"`
 	Status      TransactionStatus `json:"status" db:"status"`
 	APIResponse json.RawMessage   `json:"api_response" db:"api_response"`
 	RecordType  string            `json:"record_type" db:"record_type"`
-	DateOfBirth string            `json:"date_of_birth" db:"date_of_birth"`
-	CreatedAt   time.Time         `json:"created_at" db:"created_at"`
+	DateOfBirth Date              `json:"date_of_birth" db:"date_of_birth" gorm:"type:date"`
+	// PreviousTransactionID is the transaction a RENEWAL, TRANSFER or CORRECTION continues
+	PreviousTransactionID *uuid.UUID  `json:"previous_transaction_id,omitempty" db:"previous_transaction_id"`
+	TransferTo            string      `json:"transfer_to,omitempty" db:"transfer_to"`
+	CorrectionReason      string      `json:"correction_reason,omitempty" db:"correction_reason"`
+	FailureKind           FailureKind `json:"failure_kind,omitempty" db:"failure_kind"`
+	// ConsentID is the guardian consent found for a patient too young without one
+	ConsentID *uuid.UUID `json:"consent_id,omitempty" db:"consent_id"`
+	// RejectionReasons lists the eligibility rules a rejected transaction broke
+	RejectionReasons RejectionReasons `json:"rejection_reasons,omitempty" db:"rejection_reasons" gorm:"type:text"`
+	// MembershipTier is the tier of the patient's user when the transaction was priced
+	MembershipTier MembershipTier `json:"membership_tier,omitempty" db:"membership_tier"`
+	// BaseFeeCents is the fee before membership benefits, less DiscountCents it gives FeeCents
+	BaseFeeCents  int64  `json:"base_fee_cents,omitempty" db:"base_fee_cents"`
+	DiscountCents int64  `json:"discount_cents,omitempty" db:"discount_cents"`
+	FeeCents      int64  `json:"fee_cents,omitempty" db:"fee_cents"`
+	Currency      string `json:"currency,omitempty" db:"currency"`
+	// AppliedBenefit describes the membership benefit behind DiscountCents, e.g. "plus: 25% discount"
+	AppliedBenefit string `json:"applied_benefit,omitempty" db:"applied_benefit"`
+	// Attempts counts submissions to the provider
+	Attempts int `json:"attempts" db:"attempts"`
+	// NextRetryAt is set while a transient failure is waiting to be retried
+	NextRetryAt *time.Time `json:"next_retry_at,omitempty" db:"next_retry_at"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+}
+
+// RejectionReason is an eligibility rule broken by a transaction
+type RejectionReason struct {
+	Rule    string `json:"rule"`
+	Kind    string `json:"kind"`
+	Message string `json:"message"`
 }
 
+// RejectionReasons is stored as a JSON column
+type RejectionReasons []RejectionReason
+
+func (r RejectionReasons) Value() (driver.Value, error) {
+	if len(r) == 0 {
+		return nil, nil
+	}
+	data, err := json.Marshal(r)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func (r *RejectionReasons) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*r = nil
+		return nil
+	case string:
+		return json.Unmarshal([]byte(v), r)
+	case []byte:
+		return json.Unmarshal(v, r)
+	default:
+		return fmt.Errorf("cannot scan %T into RejectionReasons", src)
+	}
+}
+
+// PayTransactionRequest asks for a transaction of the patient, as bound by the API version
+// it came in through
 type PayTransactionRequest struct {
-	PatientID   uuid.UUID `json:"patient_id" binding:"required"`
-	DateOfBirth string    `json:"date_of_birth" binding:"required,ddmmyyyy"` // with format DD-MM-YYYY
-	RecordType  string    `json:"record_type" binding:"required"`
+	PatientID   uuid.UUID `json:"patient_id"`
+	DateOfBirth Date      `json:"date_of_birth"`
+	RecordType  string    `json:"record_type"`
+	// PreviousTransactionID is required by RENEWAL, TRANSFER and CORRECTION records
+	PreviousTransactionID *uuid.UUID `json:"previous_transaction_id,omitempty"`
+	// TransferTo is the clinic a TRANSFER record moves the patient to
+	TransferTo string `json:"transfer_to,omitempty"`
+	// CorrectionReason explains what a CORRECTION record fixes
+	CorrectionReason string `json:"correction_reason,omitempty"`
+}
+
+// SubmitPatientRequest is the payload sent to the external provider
+type SubmitPatientRequest struct {
+	Patient    ProviderPatient `json:"patient"`
+	Age        int             `json:"age"`
+	RecordType string          `json:"record_type"`
+
+	PreviousTransactionID *uuid.UUID `json:"previous_transaction_id,omitempty"`
+	TransferTo            string     `json:"transfer_to,omitempty"`
+	CorrectionReason      string     `json:"correction_reason,omitempty"`
+}
+
+// ProviderResponse is the outcome of a call to the external provider
+type ProviderResponse struct {
+	Success    bool            `json:"success"`
+	StatusCode int             `json:"status_code"`
+	Body       json.RawMessage `json:"body"`
+	// Retryable marks a failure the provider may accept on a later submission
+	Retryable bool `json:"retryable"`
+	// Headers holds the response headers worth keeping for troubleshooting
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// AttemptErrorClass classifies the outcome of a provider call
+type AttemptErrorClass string
+
+const (
+	AttemptErrorNone      AttemptErrorClass = ""
+	AttemptErrorRejected  AttemptErrorClass = "rejected"
+	AttemptErrorTransient AttemptErrorClass = "transient"
+	AttemptErrorTimeout   AttemptErrorClass = "timeout"
+	AttemptErrorNetwork   AttemptErrorClass = "network"
+)
+
+// TransactionAttempt records one call to the provider for a transaction
+type TransactionAttempt struct {
+	ID            uuid.UUID `json:"id" db:"id"`
+	TransactionID uuid.UUID `json:"transaction_id" db:"transaction_id"`
+	Attempt       int       `json:"attempt" db:"attempt"`
+	// Request is the payload sent, with personal data redacted
+	Request         json.RawMessage   `json:"request" db:"request"`
+	StatusCode      int               `json:"status_code" db:"status_code"`
+	ResponseHeaders json.RawMessage   `json:"response_headers,omitempty" db:"response_headers"`
+	ResponseBody    json.RawMessage   `json:"response_body,omitempty" db:"response_body"`
+	LatencyMS       int64             `json:"latency_ms" db:"latency_ms"`
+	ErrorClass      AttemptErrorClass `json:"error_class,omitempty" db:"error_class"`
+	Error           string            `json:"error,omitempty" db:"error"`
+	CreatedAt       time.Time         `json:"created_at" db:"created_at"`
+}
+
+// RetryResult summarises a run of the retry scheduler
+type RetryResult struct {
+	Retried   int `json:"retried"`
+	Succeeded int `json:"succeeded"`
+	Failed    int `json:"failed"`
+	// Exhausted counts transactions that used their last attempt
+	Exhausted int `json:"exhausted"`
+}
+
+type JobType string
+
+const (
+	// JobTypeProcessTransaction submits a pending transaction to the provider
+	JobTypeProcessTransaction JobType = "process_transaction"
+)
+
+// Job is a unit of background work delivered through a JobQueue
+type Job struct {
+	Type          JobType   `json:"type"`
+	TransactionID uuid.UUID `json:"transaction_id"`
+	// Metadata carries the request ID and trace context of the request that enqueued the job
+	Metadata map[string]string `json:"metadata,omitempty"`
 }
@@ -0,0 +1,142 @@
+package domain
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+
+	"github.com/google/uuid"
+)
+
+// minPatientTokenKeySize is the smallest key accepted for patient tokens
+const minPatientTokenKeySize = 32
+
+// ProviderPatient is the patient as sent to the external provider. Only the fields allowed by
+// the ProviderPatientPolicy are set.
+type ProviderPatient struct {
+	// ID is the patient's UUID, or a token standing for it
+	ID          string `json:"id"`
+	Name        string `json:"name,omitempty"`
+	Email       string `json:"email,omitempty"`
+	Phone       string `json:"phone,omitempty"`
+	Address     string `json:"address,omitempty"`
+	City        string `json:"city,omitempty"`
+	State       string `json:"state,omitempty"`
+	Zip         string `json:"zip,omitempty"`
+	DateOfBirth string `json:"date_of_birth,omitempty"`
+}
+
+// patientDisclosure lists every Patient field with the ProviderPatient field it may be sent as,
+// empty for fields that never leave the service. A Patient field missing here fails the
+// contract test, so new fields are not sent until someone decides they may be.
+var patientDisclosure = map[string]string{
+	"ID":                    "id",
+	"Name":                  "name",
+	"Email":                 "email",
+	"Phone":                 "phone",
+	"Address":               "address",
+	"City":                  "city",
+	"State":                 "state",
+	"Zip":                   "zip",
+	"DateOfBirth":           "date_of_birth",
+	"EmailIndex":            "",
+	"UserID":                "",
+	"DateOfBirthMismatches": "",
+}
+
+// PatientDisclosure returns every Patient field with the ProviderPatient field it may be sent
+// as, empty for fields that are never sent
+func PatientDisclosure() map[string]string {
+	disclosure := make(map[string]string, len(patientDisclosure))
+	for field, sentAs := range patientDisclosure {
+		disclosure[field] = sentAs
+	}
+	return disclosure
+}
+
+// ProviderPatientFields lists the fields that may be allowed for a provider, the ID is always sent
+func ProviderPatientFields() []string {
+	var fields []string
+	for _, sentAs := range patientDisclosure {
+		if sentAs != "" && sentAs != "id" {
+			fields = append(fields, sentAs)
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// ProviderPatientPolicy decides what a provider learns about a patient
+type ProviderPatientPolicy struct {
+	// Provider names the provider, tokens differ between providers
+	Provider string
+	// Fields are the ProviderPatient fields sent besides the ID
+	Fields []string
+	// TokenKey replaces the patient's UUID with a token keyed by it, the UUID is sent when empty
+	TokenKey []byte
+}
+
+// DefaultProviderPatientPolicy sends the patient's UUID and nothing else
+func DefaultProviderPatientPolicy() ProviderPatientPolicy {
+	return ProviderPatientPolicy{}
+}
+
+// NewProviderPatientPolicy returns the policy of provider, sending fields and, when tokenKey is
+// set, a token instead of the patient's UUID. No fields sends the ID only, every other field
+// is opted into per provider.
+func NewProviderPatientPolicy(provider string, fields []string, tokenKey []byte) (ProviderPatientPolicy, error) {
+	allowed := map[string]bool{}
+	for _, field := range ProviderPatientFields() {
+		allowed[field] = true
+	}
+	for _, field := range fields {
+		if !allowed[field] {
+			return ProviderPatientPolicy{}, fmt.Errorf("patient field %q cannot be sent to the provider, allowed are %v", field, ProviderPatientFields())
+		}
+	}
+	if tokenKey != nil && len(tokenKey) < minPatientTokenKeySize {
+		return ProviderPatientPolicy{}, fmt.Errorf("patient token key must be at least %d bytes", minPatientTokenKeySize)
+	}
+	return ProviderPatientPolicy{Provider: provider, Fields: fields, TokenKey: tokenKey}, nil
+}
+
+// Patient returns what the provider is sent of patient
+func (p ProviderPatientPolicy) Patient(patient *Patient) ProviderPatient {
+	rs := ProviderPatient{ID: p.PatientID(patient.ID)}
+	for _, field := range p.Fields {
+		switch field {
+		case "name":
+			rs.Name = patient.Name
+		case "email":
+			rs.Email = patient.Email
+		case "phone":
+			rs.Phone = patient.Phone
+		case "address":
+			rs.Address = patient.Address
+		case "city":
+			rs.City = patient.City
+		case "state":
+			rs.State = patient.State
+		case "zip":
+			rs.Zip = patient.Zip
+		case "date_of_birth":
+			rs.DateOfBirth = patient.DateOfBirth.String()
+		}
+	}
+	return rs
+}
+
+// PatientID returns the id the provider knows the patient by. Tokens are stable for a patient
+// and provider, and cannot be traced back to the UUID without the key.
+func (p ProviderPatientPolicy) PatientID(id uuid.UUID) string {
+	if len(p.TokenKey) == 0 {
+		return id.String()
+	}
+	mac := hmac.New(sha256.New, p.TokenKey)
+	mac.Write([]byte(p.Provider))
+	mac.Write([]byte{0})
+	mac.Write(id[:])
+	return "pt_" + hex.EncodeToString(mac.Sum(nil)[:16])
+}
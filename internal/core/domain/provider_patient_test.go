@@ -0,0 +1,97 @@
+package domain_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/datphamcode295/go-lambda-pulumi/internal/core/domain"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestProviderPatient_Contract fails when Patient gains a field nobody decided whether the
+// provider may be sent, or when a field that may be sent is not mapped
+func TestProviderPatient_Contract(t *testing.T) {
+	disclosure := domain.PatientDisclosure()
+
+	providerFields := map[string]bool{}
+	providerType := reflect.TypeOf(domain.ProviderPatient{})
+	for i := 0; i < providerType.NumField(); i++ {
+		providerFields[strings.Split(providerType.Field(i).Tag.Get("json"), ",")[0]] = true
+	}
+
+	patientType := reflect.TypeOf(domain.Patient{})
+	for i := 0; i < patientType.NumField(); i++ {
+		name := patientType.Field(i).Name
+		sentAs, ok := disclosure[name]
+		if !assert.True(t, ok, "Patient.%s is not listed in patientDisclosure, decide whether the provider may be sent it", name) {
+			continue
+		}
+		if sentAs != "" {
+			assert.True(t, providerFields[sentAs], "Patient.%s is sent as %q, which ProviderPatient does not have", name, sentAs)
+		}
+		delete(disclosure, name)
+	}
+	assert.Empty(t, disclosure, "patientDisclosure lists fields Patient does not have")
+
+	// every field that may be sent is mapped
+	patient := &domain.Patient{
+		ID:          uuid.New(),
+		Name:        "John Doe",
+		Email:       "john@example.com",
+		Phone:       "0400000000",
+		Address:     "1 George St",
+		City:        "Sydney",
+		State:       "NSW",
+		Zip:         "2000",
+		DateOfBirth: domain.NewDate(1990, 3, 15),
+	}
+	sent, err := json.Marshal(domain.ProviderPatientPolicy{Fields: domain.ProviderPatientFields()}.Patient(patient))
+	assert.NoError(t, err)
+	var fields map[string]interface{}
+	assert.NoError(t, json.Unmarshal(sent, &fields))
+	for field := range providerFields {
+		assert.NotEmpty(t, fields[field], "ProviderPatient.%s is never set", field)
+	}
+}
+
+func TestProviderPatientPolicy(t *testing.T) {
+	patient := &domain.Patient{ID: uuid.New(), Name: "John Doe", Email: "john@example.com", State: "NSW", Zip: "2000", UserID: "user-1"}
+
+	// fields are sent only when opted into
+	assert.Equal(t, domain.ProviderPatient{ID: patient.ID.String()}, domain.DefaultProviderPatientPolicy().Patient(patient))
+	policy, err := domain.NewProviderPatientPolicy("acme", nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, domain.ProviderPatient{ID: patient.ID.String()}, policy.Patient(patient))
+
+	policy, err = domain.NewProviderPatientPolicy("acme", []string{"state", "zip"}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, domain.ProviderPatient{ID: patient.ID.String(), State: "NSW", Zip: "2000"}, policy.Patient(patient))
+
+	_, err = domain.NewProviderPatientPolicy("acme", []string{"user_id"}, nil)
+	assert.EqualError(t, err, `patient field "user_id" cannot be sent to the provider, allowed are [address city date_of_birth email name phone state zip]`)
+
+	_, err = domain.NewProviderPatientPolicy("acme", nil, []byte("short"))
+	assert.EqualError(t, err, "patient token key must be at least 32 bytes")
+}
+
+func TestProviderPatientPolicy_Tokens(t *testing.T) {
+	key := bytes.Repeat([]byte{3}, 32)
+	id := uuid.New()
+
+	acme, err := domain.NewProviderPatientPolicy("acme", nil, key)
+	assert.NoError(t, err)
+	other, err := domain.NewProviderPatientPolicy("other", nil, key)
+	assert.NoError(t, err)
+
+	token := acme.PatientID(id)
+	assert.True(t, strings.HasPrefix(token, "pt_"))
+	assert.NotContains(t, token, id.String())
+	assert.Equal(t, token, acme.PatientID(id), "tokens are stable")
+	assert.NotEqual(t, token, acme.PatientID(uuid.New()))
+	assert.NotEqual(t, token, other.PatientID(id), "providers cannot correlate patients")
+	assert.Equal(t, token, acme.Patient(&domain.Patient{ID: id}).ID)
+}
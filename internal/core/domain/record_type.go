@@ -0,0 +1,134 @@
+package domain
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+const (
+	RecordTypeNew        = "NEW"
+	RecordTypeRenewal    = "RENEWAL"
+	RecordTypeTransfer   = "TRANSFER"
+	RecordTypeCorrection = "CORRECTION"
+)
+
+// RecordTypeSpec declares how transactions of a record type are validated, what is sent to
+// the provider for them and which record types they may follow
+type RecordTypeSpec struct {
+	Name string
+	// Validate checks the fields the record type requires on the transaction
+	Validate func(transaction Transaction) error
+	// Payload maps the transaction to the provider request
+	Payload func(transaction Transaction, patient ProviderPatient, age int) SubmitPatientRequest
+	// Follows lists the record types of the previous transaction this one may continue,
+	// empty for record types that start a new history
+	Follows []string
+}
+
+// CanFollow tells whether a transaction of this type may continue one of the given record type
+func (s RecordTypeSpec) CanFollow(recordType string) bool {
+	for _, follows := range s.Follows {
+		if follows == recordType {
+			return true
+		}
+	}
+	return false
+}
+
+// recordTypes is the registry of supported record types, in the order they are listed
+var recordTypes = []RecordTypeSpec{
+	{
+		Name: RecordTypeNew,
+		Validate: func(transaction Transaction) error {
+			if transaction.PreviousTransactionID != nil {
+				return errors.New("NEW records cannot have a previous transaction")
+			}
+			return nil
+		},
+		Payload: basePayload,
+	},
+	{
+		Name:     RecordTypeRenewal,
+		Validate: requirePrevious,
+		Payload: func(transaction Transaction, patient ProviderPatient, age int) SubmitPatientRequest {
+			req := basePayload(transaction, patient, age)
+			req.PreviousTransactionID = transaction.PreviousTransactionID
+			return req
+		},
+		Follows: []string{RecordTypeNew, RecordTypeRenewal, RecordTypeTransfer},
+	},
+	{
+		Name: RecordTypeTransfer,
+		Validate: func(transaction Transaction) error {
+			if err := requirePrevious(transaction); err != nil {
+				return err
+			}
+			if strings.TrimSpace(transaction.TransferTo) == "" {
+				return errors.New("TRANSFER records require transfer_to")
+			}
+			return nil
+		},
+		Payload: func(transaction Transaction, patient ProviderPatient, age int) SubmitPatientRequest {
+			req := basePayload(transaction, patient, age)
+			req.PreviousTransactionID = transaction.PreviousTransactionID
+			req.TransferTo = transaction.TransferTo
+			return req
+		},
+		Follows: []string{RecordTypeNew, RecordTypeRenewal, RecordTypeTransfer},
+	},
+	{
+		Name: RecordTypeCorrection,
+		Validate: func(transaction Transaction) error {
+			if err := requirePrevious(transaction); err != nil {
+				return err
+			}
+			if strings.TrimSpace(transaction.CorrectionReason) == "" {
+				return errors.New("CORRECTION records require correction_reason")
+			}
+			return nil
+		},
+		Payload: func(transaction Transaction, patient ProviderPatient, age int) SubmitPatientRequest {
+			req := basePayload(transaction, patient, age)
+			req.PreviousTransactionID = transaction.PreviousTransactionID
+			req.CorrectionReason = transaction.CorrectionReason
+			return req
+		},
+		Follows: []string{RecordTypeNew, RecordTypeRenewal, RecordTypeTransfer, RecordTypeCorrection},
+	},
+}
+
+// LookupRecordType returns the spec of a registered record type, names are case sensitive
+func LookupRecordType(name string) (RecordTypeSpec, bool) {
+	for _, spec := range recordTypes {
+		if spec.Name == name {
+			return spec, true
+		}
+	}
+	return RecordTypeSpec{}, false
+}
+
+// RecordTypeNames lists the registered record types
+func RecordTypeNames() []string {
+	names := make([]string, 0, len(recordTypes))
+	for _, spec := range recordTypes {
+		names = append(names, spec.Name)
+	}
+	return names
+}
+
+func requirePrevious(transaction Transaction) error {
+	if transaction.PreviousTransactionID == nil || *transaction.PreviousTransactionID == uuid.Nil {
+		return errors.New(transaction.RecordType + " records require previous_transaction_id")
+	}
+	return nil
+}
+
+func basePayload(transaction Transaction, patient ProviderPatient, age int) SubmitPatientRequest {
+	return SubmitPatientRequest{
+		Patient:    patient,
+		Age:        age,
+		RecordType: transaction.RecordType,
+	}
+}
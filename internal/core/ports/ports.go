@@ -1,17 +1,122 @@
 package ports
 
 import (
+	"context"
+	"time"
+
 	"github.com/datphamcode295/go-lambda-pulumi/internal/core/domain"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/core/rules"
+	"github.com/google/uuid"
 )
 
 type PatientService interface {
-	PayTransaction(data domain.PayTransactionRequest) (*domain.Transaction, error)
+	PayTransaction(ctx context.Context, data domain.PayTransactionRequest) (*domain.Transaction, error)
+	// SubmitTransaction validates and persists a pending transaction, then enqueues it for ProcessTransaction
+	SubmitTransaction(ctx context.Context, data domain.PayTransactionRequest) (*domain.Transaction, error)
+	ProcessTransaction(ctx context.Context, id uuid.UUID) (*domain.Transaction, error)
+	GetTransaction(ctx context.Context, id uuid.UUID) (*domain.Transaction, error)
+	// RetryFailedTransactions submits again the transient failures whose retry is due
+	RetryFailedTransactions(ctx context.Context) (*domain.RetryResult, error)
+	ListTransactionAttempts(ctx context.Context, transactionID uuid.UUID) ([]domain.TransactionAttempt, error)
+	// PurgeTransactionAttempts deletes attempts older than the retention and returns how many
+	PurgeTransactionAttempts(ctx context.Context) (int64, error)
+	RecordConsent(ctx context.Context, patientID uuid.UUID, data domain.RecordConsentRequest) (*domain.GuardianConsent, error)
+	RevokeConsent(ctx context.Context, patientID uuid.UUID, consentID uuid.UUID, data domain.RevokeConsentRequest) (*domain.GuardianConsent, error)
+	ListConsents(ctx context.Context, patientID uuid.UUID) ([]domain.GuardianConsent, error)
+	ListAuditEntries(ctx context.Context, filter domain.AuditFilter) ([]domain.AuditEntry, error)
+	// VerifyAuditLog checks the hash chain of the whole audit log
+	VerifyAuditLog(ctx context.Context) (*domain.AuditVerification, error)
 }
 
 type PatientRepository interface {
-	GetPatient(id string) (*domain.Patient, error)
+	GetPatient(ctx context.Context, id string) (*domain.Patient, error)
+	// RecordDateOfBirthMismatch counts a wrong date of birth claimed for the patient and returns the new count
+	RecordDateOfBirthMismatch(ctx context.Context, id string) (int, error)
+	// ResetDateOfBirthMismatches clears the wrong dates of birth counted for the patient
+	ResetDateOfBirthMismatches(ctx context.Context, id string) error
+}
+
+type UserRepository interface {
+	// GetUser returns domain.ErrUserNotFound when the user does not exist
+	GetUser(ctx context.Context, id string) (*domain.User, error)
 }
 
 type TransactionRepository interface {
-	CreateTransaction(transaction domain.Transaction) (*domain.Transaction, error)
+	CreateTransaction(ctx context.Context, transaction domain.Transaction) (*domain.Transaction, error)
+	GetTransaction(ctx context.Context, id string) (*domain.Transaction, error)
+	UpdateTransaction(ctx context.Context, transaction domain.Transaction) (*domain.Transaction, error)
+	// ListRetryableTransactions returns failed transactions with a transient failure due for retry at now,
+	// pending transactions whose ClaimPending lease expired without an outcome, and pending transactions
+	// never claimed that were created before unclaimedBefore
+	ListRetryableTransactions(ctx context.Context, now time.Time, unclaimedBefore time.Time, limit int) ([]domain.Transaction, error)
+	// ClaimRetry moves the retry of a due transaction to leaseUntil and reports whether it did. It fails
+	// when another run claimed or retried the transaction since it was listed at attempts.
+	ClaimRetry(ctx context.Context, id string, attempts int, now time.Time, leaseUntil time.Time) (bool, error)
+	// ClaimPending leases a pending transaction until leaseUntil and reports whether it did. It fails
+	// while another delivery of its job holds an unexpired lease.
+	ClaimPending(ctx context.Context, id string, now time.Time, leaseUntil time.Time) (bool, error)
+}
+
+// PatientProvider submits patients to the external provider
+type PatientProvider interface {
+	SubmitPatient(ctx context.Context, req domain.SubmitPatientRequest) (*domain.ProviderResponse, error)
+	// Ping reports whether the provider is reachable
+	Ping(ctx context.Context) error
+}
+
+// TransactionAttemptRepository stores the history of provider calls
+type TransactionAttemptRepository interface {
+	CreateTransactionAttempt(ctx context.Context, attempt domain.TransactionAttempt) error
+	ListTransactionAttempts(ctx context.Context, transactionID string) ([]domain.TransactionAttempt, error)
+	DeleteTransactionAttemptsBefore(ctx context.Context, before time.Time) (int64, error)
+}
+
+// ConsentRepository stores guardian consents
+type ConsentRepository interface {
+	CreateConsent(ctx context.Context, consent domain.GuardianConsent) (*domain.GuardianConsent, error)
+	// GetConsent returns domain.ErrConsentNotFound when the consent does not exist
+	GetConsent(ctx context.Context, id string) (*domain.GuardianConsent, error)
+	UpdateConsent(ctx context.Context, consent domain.GuardianConsent) (*domain.GuardianConsent, error)
+	// ListConsents returns the consents of a patient, newest first
+	ListConsents(ctx context.Context, patientID string) ([]domain.GuardianConsent, error)
+}
+
+// AuditRepository stores the append-only audit log
+type AuditRepository interface {
+	// AppendAuditEntry numbers the entry after the last one, chains it to its hash and stores it
+	AppendAuditEntry(ctx context.Context, entry domain.AuditEntry) (*domain.AuditEntry, error)
+	// ListAuditEntries returns the entries matching filter in sequence order
+	ListAuditEntries(ctx context.Context, filter domain.AuditFilter) ([]domain.AuditEntry, error)
+}
+
+// Transactor runs changes across repositories atomically
+type Transactor interface {
+	// InTransaction runs fn in a transaction committed when fn returns nil. The repositories of
+	// the same database called with the context given to fn take part in it.
+	InTransaction(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// KeyProvider wraps the data keys fields are encrypted with under a master key
+type KeyProvider interface {
+	// KeyID names the master key new data keys are wrapped with
+	KeyID() string
+	// GenerateDataKey returns a new data key in plaintext and wrapped under the master key KeyID
+	GenerateDataKey(ctx context.Context) (plaintext []byte, wrapped []byte, err error)
+	// DecryptDataKey unwraps a data key wrapped under the master key keyID
+	DecryptDataKey(ctx context.Context, keyID string, wrapped []byte) ([]byte, error)
+}
+
+// EligibilityRuleSource provides the rules pay-transactions are checked against
+type EligibilityRuleSource interface {
+	EligibilityRules(ctx context.Context) ([]rules.Rule, error)
+}
+
+// Clock tells the current time, so time dependent behaviour can be tested
+type Clock interface {
+	Now() time.Time
+}
+
+// JobQueue delivers background jobs to the worker
+type JobQueue interface {
+	Enqueue(ctx context.Context, job domain.Job) error
 }
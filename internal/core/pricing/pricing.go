@@ -0,0 +1,111 @@
+// Package pricing sets the fee of a pay-transaction from the membership tier of the patient's
+// user.
+package pricing
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/datphamcode295/go-lambda-pulumi/internal/core/domain"
+)
+
+// Benefit is what a membership tier takes off the fee
+type Benefit struct {
+	// DiscountPercent is taken off the base fee, rounded down to the cent
+	DiscountPercent int
+	// WaiveFee takes the whole fee off
+	WaiveFee bool
+}
+
+func (b Benefit) String() string {
+	if b.WaiveFee {
+		return "fee waived"
+	}
+	return fmt.Sprintf("%d%% discount", b.DiscountPercent)
+}
+
+// Quote is the price of a transaction
+type Quote struct {
+	BaseFeeCents  int64
+	DiscountCents int64
+	FeeCents      int64
+	Currency      string
+	// AppliedBenefit describes the benefit applied, empty when none was
+	AppliedBenefit string
+}
+
+// Pricer prices transactions at a base fee less the benefit of the membership tier
+type Pricer struct {
+	baseFeeCents int64
+	currency     string
+	benefits     map[domain.MembershipTier]Benefit
+}
+
+// DefaultBenefits are the benefits of each tier when none are configured
+func DefaultBenefits() map[domain.MembershipTier]Benefit {
+	return map[domain.MembershipTier]Benefit{
+		domain.MembershipBasic:   {DiscountPercent: 10},
+		domain.MembershipPlus:    {DiscountPercent: 25},
+		domain.MembershipPremium: {WaiveFee: true},
+	}
+}
+
+// New returns a Pricer charging baseFeeCents in currency, DefaultBenefits are used when
+// benefits is nil
+func New(baseFeeCents int64, currency string, benefits map[domain.MembershipTier]Benefit) (*Pricer, error) {
+	if baseFeeCents < 0 {
+		return nil, fmt.Errorf("base fee %d is negative", baseFeeCents)
+	}
+	if benefits == nil {
+		benefits = DefaultBenefits()
+	}
+	for tier, benefit := range benefits {
+		if benefit.DiscountPercent < 0 || benefit.DiscountPercent > 100 {
+			return nil, fmt.Errorf("discount of membership tier %q must be between 0 and 100%%", tier)
+		}
+	}
+	return &Pricer{baseFeeCents: baseFeeCents, currency: currency, benefits: benefits}, nil
+}
+
+// ParseBenefits reads benefits keyed by tier name, each either a discount such as "10%" or
+// "waive" for a fee waiver
+func ParseBenefits(values map[string]string) (map[domain.MembershipTier]Benefit, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+	benefits := map[domain.MembershipTier]Benefit{}
+	for name, value := range values {
+		tier, err := domain.ParseMembershipTier(name)
+		if err != nil {
+			return nil, err
+		}
+		if value == "waive" {
+			benefits[tier] = Benefit{WaiveFee: true}
+			continue
+		}
+		percent, err := strconv.Atoi(strings.TrimSuffix(value, "%"))
+		if err != nil || !strings.HasSuffix(value, "%") {
+			return nil, fmt.Errorf("benefit %q of membership tier %q must be a percentage or waive", value, name)
+		}
+		benefits[tier] = Benefit{DiscountPercent: percent}
+	}
+	return benefits, nil
+}
+
+// Price returns the fee for a patient whose user holds tier
+func (p *Pricer) Price(tier domain.MembershipTier) Quote {
+	quote := Quote{BaseFeeCents: p.baseFeeCents, FeeCents: p.baseFeeCents, Currency: p.currency}
+	benefit, ok := p.benefits[tier]
+	if !ok || tier == domain.MembershipNone {
+		return quote
+	}
+
+	quote.DiscountCents = p.baseFeeCents * int64(benefit.DiscountPercent) / 100
+	if benefit.WaiveFee {
+		quote.DiscountCents = p.baseFeeCents
+	}
+	quote.FeeCents = p.baseFeeCents - quote.DiscountCents
+	quote.AppliedBenefit = fmt.Sprintf("%s: %s", tier, benefit)
+	return quote
+}
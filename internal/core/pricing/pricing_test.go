@@ -0,0 +1,81 @@
+package pricing_test
+
+import (
+	"testing"
+
+	"github.com/datphamcode295/go-lambda-pulumi/internal/core/domain"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/core/pricing"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPricer_Price(t *testing.T) {
+	pricer, err := pricing.New(4999, "AUD", nil)
+	assert.NoError(t, err)
+
+	testCases := []struct {
+		name     string
+		tier     domain.MembershipTier
+		expected pricing.Quote
+	}{
+		{
+			name:     "No membership",
+			tier:     domain.MembershipNone,
+			expected: pricing.Quote{BaseFeeCents: 4999, FeeCents: 4999, Currency: "AUD"},
+		},
+		{
+			name:     "Discount rounded down",
+			tier:     domain.MembershipBasic,
+			expected: pricing.Quote{BaseFeeCents: 4999, DiscountCents: 499, FeeCents: 4500, Currency: "AUD", AppliedBenefit: "basic: 10% discount"},
+		},
+		{
+			name:     "Larger discount",
+			tier:     domain.MembershipPlus,
+			expected: pricing.Quote{BaseFeeCents: 4999, DiscountCents: 1249, FeeCents: 3750, Currency: "AUD", AppliedBenefit: "plus: 25% discount"},
+		},
+		{
+			name:     "Fee waived",
+			tier:     domain.MembershipPremium,
+			expected: pricing.Quote{BaseFeeCents: 4999, DiscountCents: 4999, FeeCents: 0, Currency: "AUD", AppliedBenefit: "premium: fee waived"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, pricer.Price(tc.tier))
+		})
+	}
+}
+
+func TestPricer_TierWithoutBenefit(t *testing.T) {
+	pricer, err := pricing.New(1000, "AUD", map[domain.MembershipTier]pricing.Benefit{domain.MembershipPremium: {WaiveFee: true}})
+	assert.NoError(t, err)
+
+	assert.Equal(t, pricing.Quote{BaseFeeCents: 1000, FeeCents: 1000, Currency: "AUD"}, pricer.Price(domain.MembershipBasic))
+}
+
+func TestNew_Invalid(t *testing.T) {
+	_, err := pricing.New(-1, "AUD", nil)
+	assert.EqualError(t, err, "base fee -1 is negative")
+
+	_, err = pricing.New(1000, "AUD", map[domain.MembershipTier]pricing.Benefit{domain.MembershipBasic: {DiscountPercent: 120}})
+	assert.EqualError(t, err, `discount of membership tier "basic" must be between 0 and 100%`)
+}
+
+func TestParseBenefits(t *testing.T) {
+	benefits, err := pricing.ParseBenefits(map[string]string{"basic": "5%", "premium": "waive"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[domain.MembershipTier]pricing.Benefit{
+		domain.MembershipBasic:   {DiscountPercent: 5},
+		domain.MembershipPremium: {WaiveFee: true},
+	}, benefits)
+
+	benefits, err = pricing.ParseBenefits(nil)
+	assert.NoError(t, err)
+	assert.Nil(t, benefits)
+
+	_, err = pricing.ParseBenefits(map[string]string{"gold": "5%"})
+	assert.EqualError(t, err, `unknown membership tier "gold"`)
+
+	_, err = pricing.ParseBenefits(map[string]string{"basic": "5"})
+	assert.EqualError(t, err, `benefit "5" of membership tier "basic" must be a percentage or waive`)
+}
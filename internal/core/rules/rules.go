@@ -0,0 +1,234 @@
+// Package rules decides whether a pay-transaction is eligible for submission to the provider.
+// Rules are declarative so they can be changed from configuration or the database without a
+// deploy.
+package rules
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/datphamcode295/go-lambda-pulumi/internal/core/domain"
+)
+
+// Kind selects the fact a rule checks
+type Kind string
+
+const (
+	// KindAge rejects patients outside MinAge..MaxAge, both bounds inclusive and optional
+	KindAge Kind = "age"
+	// KindRecordType rejects record types not in Values
+	KindRecordType Kind = "record_type"
+	// KindState rejects patient states not in Values, ignoring case
+	KindState Kind = "state"
+	// KindZip rejects patient zip codes not starting with one of Values
+	KindZip Kind = "zip"
+	// KindMembership rejects patients whose membership tier is not in Values, ignoring case
+	KindMembership Kind = "membership"
+)
+
+// Rule is one eligibility check. Rules are evaluated by ascending Position.
+type Rule struct {
+	Name     string `json:"name" db:"name" gorm:"primary_key"`
+	Position int    `json:"position" db:"position"`
+	Kind     Kind   `json:"kind" db:"kind"`
+	MinAge   *int   `json:"min_age,omitempty" db:"min_age"`
+	MaxAge   *int   `json:"max_age,omitempty" db:"max_age"`
+	// Values are the accepted values of the set kinds, or the refused ones with Exclude
+	Values  StringList `json:"values,omitempty" db:"values" gorm:"type:text"`
+	Exclude bool       `json:"exclude,omitempty" db:"exclude"`
+	// Message is returned to the caller on rejection, a generic one is used when empty
+	Message string `json:"message,omitempty" db:"message"`
+	// WaivedByConsent skips the rule for patients with a valid guardian consent, e.g. a minimum age
+	WaivedByConsent bool `json:"waived_by_consent,omitempty" db:"waived_by_consent"`
+}
+
+func (Rule) TableName() string {
+	return "eligibility_rules"
+}
+
+// StringList is stored as a comma separated column
+type StringList []string
+
+func (l StringList) Value() (driver.Value, error) {
+	return strings.Join(l, ","), nil
+}
+
+func (l *StringList) Scan(src interface{}) error {
+	var s string
+	switch v := src.(type) {
+	case nil:
+	case string:
+		s = v
+	case []byte:
+		s = string(v)
+	default:
+		return fmt.Errorf("cannot scan %T into StringList", src)
+	}
+
+	*l = nil
+	for _, value := range strings.Split(s, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			*l = append(*l, value)
+		}
+	}
+	return nil
+}
+
+// Facts are what the rules are evaluated against
+type Facts struct {
+	Age int
+	// AgeUnknown skips the age rules, for patients without a date of birth on file
+	AgeUnknown bool
+	RecordType string
+	State      string
+	Zip        string
+	// Membership is the patient's membership tier, empty when the patient has none
+	Membership string
+	// GuardianConsent is set when a guardian's consent to the transaction is on file
+	GuardianConsent bool
+}
+
+// Default are the rules applied when none are configured, minors are eligible with the consent
+// of a guardian. Record types are checked against the domain registry whatever the rules, a
+// record_type rule only narrows them further.
+func Default() []Rule {
+	minAge := 18
+	return []Rule{
+		{Name: "minimum-age", Position: 10, Kind: KindAge, MinAge: &minAge, Message: "Patient must be more than 18 years old", WaivedByConsent: true},
+	}
+}
+
+// Engine evaluates an ordered list of rules
+type Engine struct {
+	rules []Rule
+}
+
+// New checks the rules and orders them by Position, keeping the given order on ties
+func New(rules []Rule) (*Engine, error) {
+	ordered := make([]Rule, len(rules))
+	copy(ordered, rules)
+	sort.SliceStable(ordered, func(i, j int) bool { return ordered[i].Position < ordered[j].Position })
+
+	names := map[string]bool{}
+	for _, rule := range ordered {
+		if rule.Name == "" {
+			return nil, fmt.Errorf("rule at position %d has no name", rule.Position)
+		}
+		if names[rule.Name] {
+			return nil, fmt.Errorf("rule %q is defined twice", rule.Name)
+		}
+		names[rule.Name] = true
+		if err := rule.check(); err != nil {
+			return nil, fmt.Errorf("rule %q: %w", rule.Name, err)
+		}
+	}
+	return &Engine{rules: ordered}, nil
+}
+
+// Parse reads a JSON array of rules and checks it
+func Parse(data []byte) ([]Rule, error) {
+	var rules []Rule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("parse eligibility rules: %w", err)
+	}
+	if _, err := New(rules); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+func (r Rule) check() error {
+	switch r.Kind {
+	case KindAge:
+		if r.MinAge == nil && r.MaxAge == nil {
+			return fmt.Errorf("age rule needs min_age or max_age")
+		}
+		if r.MinAge != nil && r.MaxAge != nil && *r.MinAge > *r.MaxAge {
+			return fmt.Errorf("min_age %d is above max_age %d", *r.MinAge, *r.MaxAge)
+		}
+	case KindRecordType, KindState, KindZip, KindMembership:
+		if len(r.Values) == 0 {
+			return fmt.Errorf("%s rule needs values", r.Kind)
+		}
+	default:
+		return fmt.Errorf("unknown kind %q", r.Kind)
+	}
+	return nil
+}
+
+// Evaluate returns a reason for every rule the facts break, in rule order. No reasons means
+// the transaction is eligible.
+func (e *Engine) Evaluate(facts Facts) []domain.RejectionReason {
+	var reasons []domain.RejectionReason
+	for _, rule := range e.rules {
+		if rule.allows(facts) || (rule.WaivedByConsent && facts.GuardianConsent) {
+			continue
+		}
+		reasons = append(reasons, domain.RejectionReason{
+			Rule:    rule.Name,
+			Kind:    string(rule.Kind),
+			Message: rule.message(facts),
+		})
+	}
+	return reasons
+}
+
+// NeedsConsent reports whether one of reasons comes from a rule waived by guardian consent
+func (e *Engine) NeedsConsent(reasons []domain.RejectionReason) bool {
+	for _, reason := range reasons {
+		for _, rule := range e.rules {
+			if rule.Name == reason.Rule && rule.WaivedByConsent {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (r Rule) allows(facts Facts) bool {
+	switch r.Kind {
+	case KindAge:
+		return facts.AgeUnknown || ((r.MinAge == nil || facts.Age >= *r.MinAge) && (r.MaxAge == nil || facts.Age <= *r.MaxAge))
+	case KindRecordType:
+		return r.matches(facts.RecordType, func(value, candidate string) bool { return value == candidate })
+	case KindState:
+		return r.matches(facts.State, strings.EqualFold)
+	case KindZip:
+		return r.matches(facts.Zip, func(value, prefix string) bool { return value != "" && strings.HasPrefix(value, prefix) })
+	case KindMembership:
+		return r.matches(facts.Membership, strings.EqualFold)
+	}
+	return false
+}
+
+// matches tells whether value is accepted by the set of Values, or refused by it with Exclude
+func (r Rule) matches(value string, equal func(value, candidate string) bool) bool {
+	for _, candidate := range r.Values {
+		if equal(value, candidate) {
+			return !r.Exclude
+		}
+	}
+	return r.Exclude
+}
+
+func (r Rule) message(facts Facts) string {
+	if r.Message != "" {
+		return r.Message
+	}
+
+	switch r.Kind {
+	case KindAge:
+		return fmt.Sprintf("Patient age %d is not eligible", facts.Age)
+	case KindRecordType:
+		return fmt.Sprintf("Record type %s is not eligible", facts.RecordType)
+	case KindState:
+		return fmt.Sprintf("Patients in state %s are not eligible", facts.State)
+	case KindZip:
+		return fmt.Sprintf("Patients in zip code %s are not eligible", facts.Zip)
+	default:
+		return "Patient membership is not eligible"
+	}
+}
@@ -0,0 +1,187 @@
+package rules_test
+
+import (
+	"testing"
+
+	"github.com/datphamcode295/go-lambda-pulumi/internal/core/domain"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/core/rules"
+	"github.com/stretchr/testify/assert"
+)
+
+func intPtr(v int) *int {
+	return &v
+}
+
+func TestEngine_Default(t *testing.T) {
+	engine, err := rules.New(rules.Default())
+	assert.NoError(t, err)
+
+	testCases := []struct {
+		name     string
+		facts    rules.Facts
+		expected []domain.RejectionReason
+	}{
+		{
+			name:  "Eligible adult",
+			facts: rules.Facts{Age: 18, RecordType: "NEW"},
+		},
+		{
+			name:  "Minor",
+			facts: rules.Facts{Age: 17, RecordType: "NEW"},
+			expected: []domain.RejectionReason{
+				{Rule: "minimum-age", Kind: "age", Message: "Patient must be more than 18 years old"},
+			},
+		},
+		{
+			name:  "Minor with guardian consent",
+			facts: rules.Facts{Age: 12, RecordType: "NEW", GuardianConsent: true},
+		},
+		{
+			name:  "Record types are left to the registry",
+			facts: rules.Facts{Age: 30, RecordType: "OLD"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, engine.Evaluate(tc.facts))
+		})
+	}
+}
+
+func TestEngine_NeedsConsent(t *testing.T) {
+	engine, err := rules.New([]rules.Rule{
+		{Name: "minimum-age", Kind: rules.KindAge, MinAge: intPtr(18), WaivedByConsent: true},
+		{Name: "states", Kind: rules.KindState, Values: rules.StringList{"NSW"}},
+	})
+	assert.NoError(t, err)
+
+	minor := engine.Evaluate(rules.Facts{Age: 16, State: "NSW"})
+	assert.True(t, engine.NeedsConsent(minor))
+	assert.Empty(t, engine.Evaluate(rules.Facts{Age: 16, State: "NSW", GuardianConsent: true}))
+
+	outOfState := engine.Evaluate(rules.Facts{Age: 30, State: "VIC"})
+	assert.False(t, engine.NeedsConsent(outOfState))
+	// consent only waives the rules marked for it
+	assert.Len(t, engine.Evaluate(rules.Facts{Age: 16, State: "VIC", GuardianConsent: true}), 1)
+}
+
+func TestEngine_Kinds(t *testing.T) {
+	testCases := []struct {
+		name     string
+		rule     rules.Rule
+		facts    rules.Facts
+		eligible bool
+		message  string
+	}{
+		{name: "Age below minimum", rule: rules.Rule{Kind: rules.KindAge, MinAge: intPtr(21)}, facts: rules.Facts{Age: 20}, message: "Patient age 20 is not eligible"},
+		{name: "Age at minimum", rule: rules.Rule{Kind: rules.KindAge, MinAge: intPtr(21)}, facts: rules.Facts{Age: 21}, eligible: true},
+		{name: "Age at maximum", rule: rules.Rule{Kind: rules.KindAge, MaxAge: intPtr(65)}, facts: rules.Facts{Age: 65}, eligible: true},
+		{name: "Age above maximum", rule: rules.Rule{Kind: rules.KindAge, MaxAge: intPtr(65)}, facts: rules.Facts{Age: 66}, message: "Patient age 66 is not eligible"},
+		{name: "Age inside range", rule: rules.Rule{Kind: rules.KindAge, MinAge: intPtr(18), MaxAge: intPtr(65)}, facts: rules.Facts{Age: 40}, eligible: true},
+		{name: "Age unknown skips the rule", rule: rules.Rule{Kind: rules.KindAge, MinAge: intPtr(21)}, facts: rules.Facts{Age: 10, AgeUnknown: true}, eligible: true},
+		{name: "Allowed record type", rule: rules.Rule{Kind: rules.KindRecordType, Values: rules.StringList{"NEW", "RENEWAL"}}, facts: rules.Facts{RecordType: "RENEWAL"}, eligible: true},
+		{name: "Record type is case sensitive", rule: rules.Rule{Kind: rules.KindRecordType, Values: rules.StringList{"NEW"}}, facts: rules.Facts{RecordType: "new"}, message: "Record type new is not eligible"},
+		{name: "Refused record type", rule: rules.Rule{Kind: rules.KindRecordType, Values: rules.StringList{"NEW"}}, facts: rules.Facts{RecordType: "TRANSFER"}, message: "Record type TRANSFER is not eligible"},
+		{name: "Allowed state ignoring case", rule: rules.Rule{Kind: rules.KindState, Values: rules.StringList{"CA", "NY"}}, facts: rules.Facts{State: "ny"}, eligible: true},
+		{name: "State not allowed", rule: rules.Rule{Kind: rules.KindState, Values: rules.StringList{"CA"}}, facts: rules.Facts{State: "TX"}, message: "Patients in state TX are not eligible"},
+		{name: "Excluded state", rule: rules.Rule{Kind: rules.KindState, Values: rules.StringList{"TX"}, Exclude: true}, facts: rules.Facts{State: "TX"}, message: "Patients in state TX are not eligible"},
+		{name: "State outside exclusion", rule: rules.Rule{Kind: rules.KindState, Values: rules.StringList{"TX"}, Exclude: true}, facts: rules.Facts{State: "CA"}, eligible: true},
+		{name: "Zip prefix allowed", rule: rules.Rule{Kind: rules.KindZip, Values: rules.StringList{"90", "91"}}, facts: rules.Facts{Zip: "91011"}, eligible: true},
+		{name: "Zip prefix not allowed", rule: rules.Rule{Kind: rules.KindZip, Values: rules.StringList{"90"}}, facts: rules.Facts{Zip: "10001"}, message: "Patients in zip code 10001 are not eligible"},
+		{name: "Missing zip not allowed", rule: rules.Rule{Kind: rules.KindZip, Values: rules.StringList{"90"}}, facts: rules.Facts{}, message: "Patients in zip code  are not eligible"},
+		{name: "Excluded zip prefix", rule: rules.Rule{Kind: rules.KindZip, Values: rules.StringList{"100"}, Exclude: true}, facts: rules.Facts{Zip: "10001"}, message: "Patients in zip code 10001 are not eligible"},
+		{name: "Membership tier allowed", rule: rules.Rule{Kind: rules.KindMembership, Values: rules.StringList{"gold"}}, facts: rules.Facts{Membership: "Gold"}, eligible: true},
+		{name: "No membership", rule: rules.Rule{Kind: rules.KindMembership, Values: rules.StringList{"gold"}}, facts: rules.Facts{}, message: "Patient membership is not eligible"},
+		{name: "Custom message", rule: rules.Rule{Kind: rules.KindState, Values: rules.StringList{"CA"}, Message: "Only Californian patients"}, facts: rules.Facts{State: "TX"}, message: "Only Californian patients"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.rule.Name = "rule"
+			engine, err := rules.New([]rules.Rule{tc.rule})
+			assert.NoError(t, err)
+
+			reasons := engine.Evaluate(tc.facts)
+			if tc.eligible {
+				assert.Empty(t, reasons)
+				return
+			}
+			if assert.Len(t, reasons, 1) {
+				assert.Equal(t, "rule", reasons[0].Rule)
+				assert.Equal(t, string(tc.rule.Kind), reasons[0].Kind)
+				assert.Equal(t, tc.message, reasons[0].Message)
+			}
+		})
+	}
+}
+
+func TestEngine_OrdersByPosition(t *testing.T) {
+	engine, err := rules.New([]rules.Rule{
+		{Name: "third", Position: 30, Kind: rules.KindState, Values: rules.StringList{"CA"}},
+		{Name: "first", Position: 10, Kind: rules.KindAge, MinAge: intPtr(18)},
+		{Name: "second", Position: 10, Kind: rules.KindRecordType, Values: rules.StringList{"NEW"}},
+	})
+	assert.NoError(t, err)
+
+	var names []string
+	for _, reason := range engine.Evaluate(rules.Facts{Age: 1, RecordType: "OLD", State: "TX"}) {
+		names = append(names, reason.Rule)
+	}
+	assert.Equal(t, []string{"first", "second", "third"}, names)
+}
+
+func TestNew_InvalidRules(t *testing.T) {
+	testCases := []struct {
+		name  string
+		rules []rules.Rule
+		err   string
+	}{
+		{name: "Missing name", rules: []rules.Rule{{Kind: rules.KindAge, MinAge: intPtr(18)}}, err: "rule at position 0 has no name"},
+		{name: "Duplicate name", rules: []rules.Rule{{Name: "a", Kind: rules.KindAge, MinAge: intPtr(18)}, {Name: "a", Kind: rules.KindAge, MaxAge: intPtr(90)}}, err: `rule "a" is defined twice`},
+		{name: "Unknown kind", rules: []rules.Rule{{Name: "a", Kind: "height"}}, err: `rule "a": unknown kind "height"`},
+		{name: "Age without bounds", rules: []rules.Rule{{Name: "a", Kind: rules.KindAge}}, err: `rule "a": age rule needs min_age or max_age`},
+		{name: "Inverted age range", rules: []rules.Rule{{Name: "a", Kind: rules.KindAge, MinAge: intPtr(65), MaxAge: intPtr(18)}}, err: `rule "a": min_age 65 is above max_age 18`},
+		{name: "Set without values", rules: []rules.Rule{{Name: "a", Kind: rules.KindState}}, err: `rule "a": state rule needs values`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := rules.New(tc.rules)
+			assert.EqualError(t, err, tc.err)
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	list, err := rules.Parse([]byte(`[
+		{"name": "adults", "position": 1, "kind": "age", "min_age": 21},
+		{"name": "no-texas", "position": 2, "kind": "state", "values": ["TX"], "exclude": true, "message": "Not available in Texas"}
+	]`))
+	assert.NoError(t, err)
+	assert.Equal(t, []rules.Rule{
+		{Name: "adults", Position: 1, Kind: rules.KindAge, MinAge: intPtr(21)},
+		{Name: "no-texas", Position: 2, Kind: rules.KindState, Values: rules.StringList{"TX"}, Exclude: true, Message: "Not available in Texas"},
+	}, list)
+
+	_, err = rules.Parse([]byte(`{"name": "not-a-list"}`))
+	assert.Error(t, err)
+
+	_, err = rules.Parse([]byte(`[{"name": "a", "kind": "state"}]`))
+	assert.EqualError(t, err, `rule "a": state rule needs values`)
+}
+
+func TestStringList_ValueAndScan(t *testing.T) {
+	value, err := rules.StringList{"CA", "NY"}.Value()
+	assert.NoError(t, err)
+	assert.Equal(t, "CA,NY", value)
+
+	var list rules.StringList
+	assert.NoError(t, list.Scan([]byte(" CA , NY,")))
+	assert.Equal(t, rules.StringList{"CA", "NY"}, list)
+
+	assert.NoError(t, list.Scan(nil))
+	assert.Nil(t, list)
+
+	assert.Error(t, list.Scan(42))
+}
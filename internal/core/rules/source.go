@@ -0,0 +1,51 @@
+package rules
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Static serves a fixed list of rules, e.g. the ones from the configuration
+type Static []Rule
+
+func (s Static) EligibilityRules(ctx context.Context) ([]Rule, error) {
+	return s, nil
+}
+
+// CachedSource reuses the rules returned by load for a while, so rules kept in the database
+// are not read for every transaction
+type CachedSource struct {
+	load func(ctx context.Context) ([]Rule, error)
+	ttl  time.Duration
+	now  func() time.Time
+
+	mu       sync.Mutex
+	rules    []Rule
+	loadedAt time.Time
+}
+
+func NewCachedSource(load func(ctx context.Context) ([]Rule, error), ttl time.Duration) *CachedSource {
+	return &CachedSource{load: load, ttl: ttl, now: time.Now}
+}
+
+// EligibilityRules returns the cached rules, loading them again once they are older than the TTL.
+// A failed load is returned rather than serving stale rules.
+func (c *CachedSource) EligibilityRules(ctx context.Context) ([]Rule, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.rules != nil && c.now().Sub(c.loadedAt) < c.ttl {
+		return c.rules, nil
+	}
+
+	rules, err := c.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if rules == nil {
+		rules = []Rule{}
+	}
+	c.rules, c.loadedAt = rules, c.now()
+	return rules, nil
+}
@@ -0,0 +1,42 @@
+package rules
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCachedSource(t *testing.T) {
+	loads := 0
+	var loadErr error
+	source := NewCachedSource(func(ctx context.Context) ([]Rule, error) {
+		loads++
+		if loadErr != nil {
+			return nil, loadErr
+		}
+		return Default(), nil
+	}, time.Minute)
+	now := time.Now()
+	source.now = func() time.Time { return now }
+
+	list, err := source.EligibilityRules(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, Default(), list)
+
+	_, err = source.EligibilityRules(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, loads, "served from cache within the TTL")
+
+	now = now.Add(time.Minute)
+	loadErr = errors.New("database unavailable")
+	_, err = source.EligibilityRules(context.Background())
+	assert.EqualError(t, err, "database unavailable")
+
+	loadErr = nil
+	_, err = source.EligibilityRules(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 3, loads)
+}
@@ -0,0 +1,174 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+
+	"github.com/datphamcode295/go-lambda-pulumi/internal/core/domain"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/core/ports"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/logger"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/requestctx"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// systemActor is the actor of work not done on behalf of a request, such as the worker, the
+// retry job and the dispatcher. HTTP requests always carry an actor, anonymous at least.
+const systemActor = "system"
+
+// auditVerifyBatch is how many entries VerifyAuditLog reads at a time
+const auditVerifyBatch = 500
+
+var errAuditNotStored = errors.New("audit log is not stored")
+
+// WithAuditLog sets where the audit log is appended to, nothing is audited without it. When
+// its store implements ports.Transactor, changes are written in one transaction with their
+// audit entries.
+func WithAuditLog(repo ports.AuditRepository) Option {
+	return func(p *PatientService) {
+		p.auditRepo = repo
+		p.transactor, _ = repo.(ports.Transactor)
+	}
+}
+
+// inTransaction runs fn, which writes a change and audits it with auditChange, in one
+// transaction of the audit log's store. Without one fn runs on its own.
+func (p *PatientService) inTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if p.transactor == nil {
+		return fn(ctx)
+	}
+	return p.transactor.InTransaction(ctx, fn)
+}
+
+// auditChange audits a change written in inTransaction. A failed append fails the transaction,
+// so no change is stored without its entry; without a transaction it is only logged.
+func (p *PatientService) auditChange(ctx context.Context, action domain.AuditAction, entityType, entityID string, before, after interface{}) error {
+	if p.transactor == nil {
+		p.audit(ctx, action, entityType, entityID, before, after)
+		return nil
+	}
+	return p.appendAudit(ctx, action, entityType, entityID, before, after)
+}
+
+// audit appends who did action to the entity, with the fields changed from before to after.
+// Either may be nil. Failing to append is only logged, auditing must not fail the request.
+func (p *PatientService) audit(ctx context.Context, action domain.AuditAction, entityType, entityID string, before, after interface{}) {
+	if err := p.appendAudit(ctx, action, entityType, entityID, before, after); err != nil {
+		logger.FromContext(ctx).WithError(err).WithFields(logrus.Fields{
+			"entity_type": entityType,
+			"entity_id":   entityID,
+		}).Error("Failed to append audit entry")
+	}
+}
+
+func (p *PatientService) appendAudit(ctx context.Context, action domain.AuditAction, entityType, entityID string, before, after interface{}) error {
+	if p.auditRepo == nil {
+		return nil
+	}
+
+	actor := requestctx.Actor(ctx)
+	if actor == "" {
+		actor = systemActor
+	}
+	entry := domain.AuditEntry{
+		ID:           uuid.New(),
+		Actor:        actor,
+		ClaimedActor: requestctx.ClaimedActor(ctx),
+		Action:       action,
+		EntityType:   entityType,
+		EntityID:     entityID,
+		RequestID:    requestctx.RequestID(ctx),
+		SourceIP:     requestctx.SourceIP(ctx),
+		CreatedAt:    p.clock.Now(),
+	}
+	if changes := auditChanges(before, after); len(changes) > 0 {
+		if payload, err := json.Marshal(logger.Redact(changes)); err == nil {
+			entry.Changes = payload
+		}
+	}
+
+	_, err := p.auditRepo.AppendAuditEntry(ctx, entry)
+	return err
+}
+
+// auditChanges maps the JSON fields that differ between before and after to both values
+func auditChanges(before, after interface{}) map[string]map[string]interface{} {
+	beforeFields, afterFields := jsonFields(before), jsonFields(after)
+	changes := map[string]map[string]interface{}{}
+	for field, value := range afterFields {
+		if previous, ok := beforeFields[field]; !ok || !reflect.DeepEqual(previous, value) {
+			changes[field] = map[string]interface{}{"before": previous, "after": value}
+		}
+	}
+	for field, previous := range beforeFields {
+		if _, ok := afterFields[field]; !ok {
+			changes[field] = map[string]interface{}{"before": previous, "after": nil}
+		}
+	}
+	return changes
+}
+
+func jsonFields(v interface{}) map[string]interface{} {
+	if v == nil || reflect.ValueOf(v).Kind() == reflect.Ptr && reflect.ValueOf(v).IsNil() {
+		return nil
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil
+	}
+	return fields
+}
+
+func (p *PatientService) ListAuditEntries(ctx context.Context, filter domain.AuditFilter) ([]domain.AuditEntry, error) {
+	if p.auditRepo == nil {
+		return nil, errAuditNotStored
+	}
+	return p.auditRepo.ListAuditEntries(ctx, filter)
+}
+
+// VerifyAuditLog walks the audit log in sequence order and checks every entry is chained to
+// the one before it
+func (p *PatientService) VerifyAuditLog(ctx context.Context) (*domain.AuditVerification, error) {
+	if p.auditRepo == nil {
+		return nil, errAuditNotStored
+	}
+
+	rs := &domain.AuditVerification{Valid: true}
+	var previous *domain.AuditEntry
+	for {
+		filter := domain.AuditFilter{Limit: auditVerifyBatch}
+		if previous != nil {
+			filter.AfterSequence = previous.Sequence
+		}
+		entries, err := p.auditRepo.ListAuditEntries(ctx, filter)
+		if err != nil {
+			return nil, err
+		}
+
+		var chainErr *domain.AuditChainError
+		if err := domain.VerifyAuditChain(previous, entries); errors.As(err, &chainErr) {
+			rs.Valid = false
+			rs.BrokenAt = &chainErr.Sequence
+			rs.Reason = chainErr.Reason
+			for _, entry := range entries {
+				if entry.Sequence == chainErr.Sequence {
+					break
+				}
+				rs.Verified++
+			}
+			logger.FromContext(ctx).WithError(err).Error("Audit log chain is broken")
+			return rs, nil
+		}
+		rs.Verified += int64(len(entries))
+		if len(entries) < auditVerifyBatch {
+			return rs, nil
+		}
+		previous = &entries[len(entries)-1]
+	}
+}
@@ -0,0 +1,228 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/datphamcode295/go-lambda-pulumi/internal/core/domain"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/requestctx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockAuditRepository mocks the AuditRepository interface
+type MockAuditRepository struct {
+	mock.Mock
+}
+
+func (m *MockAuditRepository) AppendAuditEntry(ctx context.Context, entry domain.AuditEntry) (*domain.AuditEntry, error) {
+	args := m.Called(entry)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.AuditEntry), args.Error(1)
+}
+
+func (m *MockAuditRepository) ListAuditEntries(ctx context.Context, filter domain.AuditFilter) ([]domain.AuditEntry, error) {
+	args := m.Called(filter)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.AuditEntry), args.Error(1)
+}
+
+func TestPatientService_PayTransaction_Audited(t *testing.T) {
+	now := time.Date(2024, time.June, 14, 12, 0, 0, 0, time.UTC)
+
+	// Setup
+	mockPatientRepo := &MockPatientRepository{}
+	mockTransactionRepo := &MockTransactionRepository{}
+	mockAuditRepo := &MockAuditRepository{}
+	mockProvider := &MockPatientProvider{}
+	service := NewPatientService(createTestConfig(), mockPatientRepo, mockTransactionRepo,
+		WithProvider(mockProvider), WithAuditLog(mockAuditRepo), WithClock(fixedClock(now)))
+
+	patient := createTestPatient()
+	mockPatientRepo.On("GetPatient", patient.ID.String()).Return(patient, nil)
+	mockProvider.On("SubmitPatient", mock.Anything).Return(&domain.ProviderResponse{Success: true, StatusCode: 200, Body: json.RawMessage(`{}`)}, nil)
+	mockTransactionRepo.On("CreateTransaction", mock.Anything).Return(&domain.Transaction{Status: domain.TransactionStatusSuccess, DateOfBirth: patient.DateOfBirth}, nil)
+	mockAuditRepo.On("AppendAuditEntry", mock.Anything).Return(nil, errors.New("connection refused"))
+
+	ctx := requestctx.WithRequestID(context.Background(), "req-1")
+	ctx = requestctx.WithActor(ctx, "user-42")
+	ctx = requestctx.WithClaimedActor(ctx, "patient-portal")
+	ctx = requestctx.WithSourceIP(ctx, "203.0.113.7")
+
+	// Execute
+	_, err := service.PayTransaction(ctx, domain.PayTransactionRequest{
+		PatientID:   patient.ID,
+		DateOfBirth: mustParseDate("01-01-1990"),
+		RecordType:  "NEW",
+	})
+
+	// Assertions, failing to audit does not fail the transaction
+	assert.NoError(t, err)
+	read := mockAuditRepo.Calls[0].Arguments.Get(0).(domain.AuditEntry)
+	assert.Equal(t, domain.AuditActionView, read.Action)
+	assert.Equal(t, domain.AuditEntityPatient, read.EntityType)
+	assert.Equal(t, patient.ID.String(), read.EntityID)
+	assert.Equal(t, "user-42", read.Actor)
+
+	entry := mockAuditRepo.Calls[1].Arguments.Get(0).(domain.AuditEntry)
+	assert.Equal(t, "user-42", entry.Actor)
+	assert.Equal(t, "patient-portal", entry.ClaimedActor)
+	assert.Equal(t, domain.AuditActionCreate, entry.Action)
+	assert.Equal(t, domain.AuditEntityTransaction, entry.EntityType)
+	assert.Equal(t, "req-1", entry.RequestID)
+	assert.Equal(t, "203.0.113.7", entry.SourceIP)
+	assert.Equal(t, now, entry.CreatedAt)
+
+	var changes map[string]interface{}
+	assert.NoError(t, json.Unmarshal(entry.Changes, &changes))
+	assert.Equal(t, "[REDACTED]", changes["date_of_birth"])
+	assert.Equal(t, map[string]interface{}{"before": nil, "after": "success"}, changes["status"])
+}
+
+func TestPatientService_ProcessTransaction_AuditsChanges(t *testing.T) {
+	// Setup
+	mockPatientRepo := &MockPatientRepository{}
+	mockTransactionRepo := &MockTransactionRepository{}
+	mockAuditRepo := &MockAuditRepository{}
+	mockProvider := &MockPatientProvider{}
+	service := NewPatientService(createTestConfig(), mockPatientRepo, mockTransactionRepo,
+		WithProvider(mockProvider), WithAuditLog(mockAuditRepo))
+
+	patient := createTestPatient()
+	pending := &domain.Transaction{ID: patient.ID, PatientID: patient.ID, DateOfBirth: patient.DateOfBirth, RecordType: "NEW", Status: domain.TransactionStatusPending}
+	mockTransactionRepo.On("GetTransaction", pending.ID.String()).Return(pending, nil)
+	mockTransactionRepo.On("ClaimPending", pending.ID.String()).Return(true, nil)
+	mockPatientRepo.On("GetPatient", patient.ID.String()).Return(patient, nil)
+	mockProvider.On("SubmitPatient", mock.Anything).Return(&domain.ProviderResponse{Success: true, StatusCode: 200, Body: json.RawMessage(`{}`)}, nil)
+	mockTransactionRepo.On("UpdateTransaction", mock.Anything).Return(&domain.Transaction{ID: pending.ID, DateOfBirth: patient.DateOfBirth, RecordType: "NEW", Status: domain.TransactionStatusSuccess, Attempts: 1, APIResponse: json.RawMessage(`{}`)}, nil)
+	mockAuditRepo.On("AppendAuditEntry", mock.Anything).Return(&domain.AuditEntry{}, nil)
+
+	// Execute
+	_, err := service.ProcessTransaction(context.Background(), pending.ID)
+
+	// Assertions, only the changed fields are kept and workers act as the system
+	assert.NoError(t, err)
+	entry := mockAuditRepo.Calls[0].Arguments.Get(0).(domain.AuditEntry)
+	assert.Equal(t, "system", entry.Actor)
+	assert.Equal(t, domain.AuditActionUpdate, entry.Action)
+	assert.Equal(t, pending.ID.String(), entry.EntityID)
+
+	var changes map[string]map[string]interface{}
+	assert.NoError(t, json.Unmarshal(entry.Changes, &changes))
+	assert.Equal(t, map[string]interface{}{"before": "pending", "after": "success"}, changes["status"])
+	assert.NotContains(t, changes, "date_of_birth")
+	assert.NotContains(t, changes, "record_type")
+}
+
+// transactionalAuditRepository is an audit log whose store has transactions
+type transactionalAuditRepository struct {
+	MockAuditRepository
+	transactions int
+}
+
+func (r *transactionalAuditRepository) InTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	r.transactions++
+	return fn(ctx)
+}
+
+func TestPatientService_PayTransaction_AuditFailsTransaction(t *testing.T) {
+	// Setup
+	mockPatientRepo := &MockPatientRepository{}
+	mockTransactionRepo := &MockTransactionRepository{}
+	auditRepo := &transactionalAuditRepository{}
+	mockProvider := &MockPatientProvider{}
+	service := NewPatientService(createTestConfig(), mockPatientRepo, mockTransactionRepo,
+		WithProvider(mockProvider), WithAuditLog(auditRepo))
+
+	patient := createTestPatient()
+	mockPatientRepo.On("GetPatient", patient.ID.String()).Return(patient, nil)
+	mockProvider.On("SubmitPatient", mock.Anything).Return(&domain.ProviderResponse{Success: true, StatusCode: 200, Body: json.RawMessage(`{}`)}, nil)
+	mockTransactionRepo.On("CreateTransaction", mock.Anything).Return(&domain.Transaction{Status: domain.TransactionStatusSuccess}, nil)
+	auditRepo.On("AppendAuditEntry", mock.Anything).Return(nil, errors.New("connection refused"))
+
+	// Execute
+	result, err := service.PayTransaction(context.Background(), domain.PayTransactionRequest{
+		PatientID:   patient.ID,
+		DateOfBirth: mustParseDate("01-01-1990"),
+		RecordType:  "NEW",
+	})
+
+	// Assertions, the transaction is not stored without its audit entry
+	assert.EqualError(t, err, "connection refused")
+	assert.Nil(t, result)
+	assert.Equal(t, 1, auditRepo.transactions)
+	mockTransactionRepo.AssertCalled(t, "CreateTransaction", mock.Anything)
+}
+
+func TestPatientService_DateOfBirthMismatch_Audited(t *testing.T) {
+	// Setup
+	mockPatientRepo := &MockPatientRepository{}
+	mockTransactionRepo := &MockTransactionRepository{}
+	auditRepo := &transactionalAuditRepository{}
+	service := NewPatientService(createTestConfig(), mockPatientRepo, mockTransactionRepo, WithAuditLog(auditRepo))
+
+	patient := createTestPatient()
+	patient.DateOfBirth = mustParseDate("15-03-1990")
+	patient.DateOfBirthMismatches = 1
+	mockPatientRepo.On("GetPatient", patient.ID.String()).Return(patient, nil)
+	mockPatientRepo.On("RecordDateOfBirthMismatch", patient.ID.String()).Return(2, nil)
+	mockTransactionRepo.On("CreateTransaction", mock.Anything).Return(&domain.Transaction{Status: domain.TransactionStatusFailed}, nil)
+	auditRepo.On("AppendAuditEntry", mock.Anything).Return(&domain.AuditEntry{}, nil)
+
+	// Execute
+	_, err := service.PayTransaction(context.Background(), domain.PayTransactionRequest{
+		PatientID:   patient.ID,
+		DateOfBirth: mustParseDate("15-03-1980"),
+		RecordType:  "NEW",
+	})
+
+	// Assertions, the counter update is audited in its own transaction
+	assert.NoError(t, err)
+	assert.Equal(t, 2, auditRepo.transactions)
+	entry := auditRepo.Calls[1].Arguments.Get(0).(domain.AuditEntry)
+	assert.Equal(t, domain.AuditActionUpdate, entry.Action)
+	assert.Equal(t, domain.AuditEntityPatient, entry.EntityType)
+	assert.Equal(t, patient.ID.String(), entry.EntityID)
+	assert.JSONEq(t, `{"date_of_birth_mismatches": {"before": 1, "after": 2}}`, string(entry.Changes))
+}
+
+func TestPatientService_VerifyAuditLog(t *testing.T) {
+	chain := make([]domain.AuditEntry, 3)
+	var prevHash string
+	for i := range chain {
+		chain[i] = domain.AuditEntry{Sequence: int64(i + 1), Actor: "user-1", Action: domain.AuditActionView, PrevHash: prevHash}
+		chain[i].Hash = chain[i].ComputeHash()
+		prevHash = chain[i].Hash
+	}
+
+	// Setup
+	mockAuditRepo := &MockAuditRepository{}
+	service := NewPatientService(createTestConfig(), &MockPatientRepository{}, &MockTransactionRepository{}, WithAuditLog(mockAuditRepo))
+	mockAuditRepo.On("ListAuditEntries", domain.AuditFilter{Limit: auditVerifyBatch}).Return(chain, nil).Once()
+
+	// Execute
+	rs, err := service.VerifyAuditLog(context.Background())
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, &domain.AuditVerification{Valid: true, Verified: 3}, rs)
+
+	// a tampered entry is reported
+	chain[1].Actor = "someone-else"
+	mockAuditRepo.On("ListAuditEntries", domain.AuditFilter{Limit: auditVerifyBatch}).Return(chain, nil).Once()
+	rs, err = service.VerifyAuditLog(context.Background())
+	assert.NoError(t, err)
+	assert.False(t, rs.Valid)
+	assert.Equal(t, int64(1), rs.Verified)
+	if assert.NotNil(t, rs.BrokenAt) {
+		assert.Equal(t, int64(2), *rs.BrokenAt)
+	}
+	assert.Equal(t, "hash does not match its content", rs.Reason)
+}
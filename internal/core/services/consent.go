@@ -0,0 +1,147 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"github.com/datphamcode295/go-lambda-pulumi/internal/core/domain"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/logger"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/requestctx"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+var errConsentsNotStored = errors.New("guardian consents are not stored")
+
+// RecordConsent records a guardian's consent to the transactions of the patient, granted now
+func (p *PatientService) RecordConsent(ctx context.Context, patientID uuid.UUID, data domain.RecordConsentRequest) (*domain.GuardianConsent, error) {
+	if p.consentRepo == nil {
+		return nil, errConsentsNotStored
+	}
+
+	ctx = logger.WithFields(ctx, logrus.Fields{"patient_id": patientID.String()})
+	if _, err := p.patientRepo.GetPatient(ctx, patientID.String()); err != nil {
+		return nil, err
+	}
+
+	if err := p.checkGuardianUser(ctx, data.GuardianUserID); err != nil {
+		return nil, err
+	}
+
+	now := p.clock.Now()
+	if data.ExpiresAt != nil && !data.ExpiresAt.After(now) {
+		return nil, errors.New("consent expires_at must be in the future")
+	}
+
+	var consent *domain.GuardianConsent
+	err := p.inTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		consent, err = p.consentRepo.CreateConsent(ctx, domain.GuardianConsent{
+			ID:                uuid.New(),
+			PatientID:         patientID,
+			GuardianUserID:    data.GuardianUserID,
+			GuardianName:      data.GuardianName,
+			GuardianContact:   data.GuardianContact,
+			Relationship:      data.Relationship,
+			EvidenceReference: data.EvidenceReference,
+			GrantedAt:         now,
+			GrantedBy:         requestctx.Actor(ctx),
+			ExpiresAt:         data.ExpiresAt,
+		})
+		if err != nil {
+			return err
+		}
+		return p.auditChange(ctx, domain.AuditActionCreate, domain.AuditEntityConsent, consent.ID.String(), nil, consent)
+	})
+	if err != nil {
+		return nil, err
+	}
+	logger.FromContext(ctx).WithField("consent_id", consent.ID.String()).Info("Guardian consent recorded")
+	return consent, nil
+}
+
+// checkGuardianUser rejects a guardian user ID that is not the ID of a user. Without a user
+// repository it cannot be checked, so only contacts are accepted.
+func (p *PatientService) checkGuardianUser(ctx context.Context, userID string) error {
+	if userID == "" {
+		return nil
+	}
+	if p.userRepo == nil {
+		return errors.New("guardian users are not stored, record the guardian contact instead")
+	}
+
+	_, err := p.userRepo.GetUser(ctx, userID)
+	if errors.Is(err, domain.ErrUserNotFound) {
+		return errors.New("guardian user not found")
+	}
+	return err
+}
+
+// RevokeConsent revokes a consent of the patient now. Revoking it again keeps the first
+// revocation.
+func (p *PatientService) RevokeConsent(ctx context.Context, patientID uuid.UUID, consentID uuid.UUID, data domain.RevokeConsentRequest) (*domain.GuardianConsent, error) {
+	if p.consentRepo == nil {
+		return nil, errConsentsNotStored
+	}
+
+	ctx = logger.WithFields(ctx, logrus.Fields{"patient_id": patientID.String(), "consent_id": consentID.String()})
+	consent, err := p.consentRepo.GetConsent(ctx, consentID.String())
+	if err != nil {
+		return nil, err
+	}
+	if consent.PatientID != patientID {
+		return nil, domain.ErrConsentNotFound
+	}
+	if consent.RevokedAt != nil {
+		return consent, nil
+	}
+
+	before := *consent
+	now := p.clock.Now()
+	consent.RevokedAt = &now
+	consent.RevokedBy = requestctx.Actor(ctx)
+	consent.RevocationReason = data.Reason
+	err = p.inTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		if consent, err = p.consentRepo.UpdateConsent(ctx, *consent); err != nil {
+			return err
+		}
+		return p.auditChange(ctx, domain.AuditActionUpdate, domain.AuditEntityConsent, consentID.String(), before, consent)
+	})
+	if err != nil {
+		return nil, err
+	}
+	logger.FromContext(ctx).Info("Guardian consent revoked")
+	return consent, nil
+}
+
+func (p *PatientService) ListConsents(ctx context.Context, patientID uuid.UUID) ([]domain.GuardianConsent, error) {
+	if p.consentRepo == nil {
+		return nil, errConsentsNotStored
+	}
+	consents, err := p.consentRepo.ListConsents(ctx, patientID.String())
+	if err != nil {
+		return nil, err
+	}
+	p.audit(ctx, domain.AuditActionView, domain.AuditEntityPatient, patientID.String(), nil, nil)
+	return consents, nil
+}
+
+// validConsent returns a consent of the patient valid now, nil when there is none
+func (p *PatientService) validConsent(ctx context.Context, patientID uuid.UUID) (*domain.GuardianConsent, error) {
+	if p.consentRepo == nil {
+		return nil, nil
+	}
+
+	consents, err := p.consentRepo.ListConsents(ctx, patientID.String())
+	if err != nil {
+		return nil, err
+	}
+	now := p.clock.Now()
+	for _, consent := range consents {
+		if consent.ValidAt(now) {
+			return &consent, nil
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,226 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/datphamcode295/go-lambda-pulumi/internal/core/domain"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/requestctx"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockConsentRepository mocks the ConsentRepository interface
+type MockConsentRepository struct {
+	mock.Mock
+}
+
+func (m *MockConsentRepository) CreateConsent(ctx context.Context, consent domain.GuardianConsent) (*domain.GuardianConsent, error) {
+	args := m.Called(consent)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.GuardianConsent), args.Error(1)
+}
+
+func (m *MockConsentRepository) GetConsent(ctx context.Context, id string) (*domain.GuardianConsent, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.GuardianConsent), args.Error(1)
+}
+
+func (m *MockConsentRepository) UpdateConsent(ctx context.Context, consent domain.GuardianConsent) (*domain.GuardianConsent, error) {
+	args := m.Called(consent)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.GuardianConsent), args.Error(1)
+}
+
+func (m *MockConsentRepository) ListConsents(ctx context.Context, patientID string) ([]domain.GuardianConsent, error) {
+	args := m.Called(patientID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.GuardianConsent), args.Error(1)
+}
+
+func TestPatientService_PayTransaction_GuardianConsent(t *testing.T) {
+	now := time.Date(2024, time.June, 14, 12, 0, 0, 0, time.UTC)
+	expired := now.Add(-time.Hour)
+	revoked := now.Add(-time.Minute)
+	valid := domain.GuardianConsent{ID: uuid.New(), GrantedAt: now.Add(-24 * time.Hour)}
+
+	testCases := []struct {
+		name      string
+		consents  []domain.GuardianConsent
+		listErr   error
+		status    domain.TransactionStatus
+		consentID *uuid.UUID
+		wantErr   bool
+	}{
+		{name: "No consent", status: domain.TransactionStatusFailed},
+		{name: "Valid consent", consents: []domain.GuardianConsent{valid}, status: domain.TransactionStatusSuccess, consentID: &valid.ID},
+		{name: "Expired consent", consents: []domain.GuardianConsent{{ID: uuid.New(), GrantedAt: now.Add(-48 * time.Hour), ExpiresAt: &expired}}, status: domain.TransactionStatusFailed},
+		{name: "Revoked consent", consents: []domain.GuardianConsent{{ID: uuid.New(), GrantedAt: now.Add(-48 * time.Hour), RevokedAt: &revoked}}, status: domain.TransactionStatusFailed},
+		{name: "Lookup error", listErr: errors.New("connection refused"), wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Setup
+			mockPatientRepo := &MockPatientRepository{}
+			mockTransactionRepo := &MockTransactionRepository{}
+			mockConsentRepo := &MockConsentRepository{}
+			mockProvider := &MockPatientProvider{}
+			service := NewPatientService(createTestConfig(), mockPatientRepo, mockTransactionRepo,
+				WithProvider(mockProvider), WithConsentRepository(mockConsentRepo), WithClock(fixedClock(now)))
+
+			patient := createTestPatient()
+			patient.DateOfBirth = mustParseDate("01-01-2012")
+			mockPatientRepo.On("GetPatient", patient.ID.String()).Return(patient, nil)
+			mockConsentRepo.On("ListConsents", patient.ID.String()).Return(tc.consents, tc.listErr)
+			mockProvider.On("SubmitPatient", mock.Anything).Return(&domain.ProviderResponse{Success: true, StatusCode: 200, Body: json.RawMessage(`{}`)}, nil)
+			mockTransactionRepo.On("CreateTransaction", mock.Anything).Return(&domain.Transaction{}, nil)
+
+			// Execute, the patient is 12
+			_, err := service.PayTransaction(context.Background(), domain.PayTransactionRequest{
+				PatientID:   patient.ID,
+				DateOfBirth: mustParseDate("01-01-2012"),
+				RecordType:  "NEW",
+			})
+
+			// Assertions
+			if tc.wantErr {
+				assert.Error(t, err)
+				mockTransactionRepo.AssertNotCalled(t, "CreateTransaction", mock.Anything)
+				return
+			}
+			assert.NoError(t, err)
+			saved := mockTransactionRepo.Calls[0].Arguments.Get(0).(domain.Transaction)
+			assert.Equal(t, tc.status, saved.Status)
+			assert.Equal(t, tc.consentID, saved.ConsentID)
+		})
+	}
+}
+
+func TestPatientService_PayTransaction_AdultNeedsNoConsent(t *testing.T) {
+	// Setup
+	mockPatientRepo := &MockPatientRepository{}
+	mockTransactionRepo := &MockTransactionRepository{}
+	mockConsentRepo := &MockConsentRepository{}
+	mockProvider := &MockPatientProvider{}
+	service := NewPatientService(createTestConfig(), mockPatientRepo, mockTransactionRepo,
+		WithProvider(mockProvider), WithConsentRepository(mockConsentRepo))
+
+	patient := createTestPatient()
+	mockPatientRepo.On("GetPatient", patient.ID.String()).Return(patient, nil)
+	mockProvider.On("SubmitPatient", mock.Anything).Return(&domain.ProviderResponse{Success: true, StatusCode: 200, Body: json.RawMessage(`{}`)}, nil)
+	mockTransactionRepo.On("CreateTransaction", mock.Anything).Return(&domain.Transaction{}, nil)
+
+	// Execute
+	_, err := service.PayTransaction(context.Background(), domain.PayTransactionRequest{
+		PatientID:   patient.ID,
+		DateOfBirth: mustParseDate("01-01-1990"),
+		RecordType:  "NEW",
+	})
+
+	// Assertions
+	assert.NoError(t, err)
+	mockConsentRepo.AssertNotCalled(t, "ListConsents", mock.Anything)
+}
+
+func TestPatientService_RecordConsent(t *testing.T) {
+	now := time.Date(2024, time.June, 14, 12, 0, 0, 0, time.UTC)
+	past := now.Add(-time.Hour)
+
+	// Setup
+	mockPatientRepo := &MockPatientRepository{}
+	mockConsentRepo := &MockConsentRepository{}
+	mockUserRepo := &MockUserRepository{}
+	service := NewPatientService(createTestConfig(), mockPatientRepo, &MockTransactionRepository{},
+		WithConsentRepository(mockConsentRepo), WithUserRepository(mockUserRepo), WithClock(fixedClock(now)))
+
+	patient := createTestPatient()
+	mockPatientRepo.On("GetPatient", patient.ID.String()).Return(patient, nil)
+	mockUserRepo.On("GetUser", "user-1").Return(&domain.User{ID: "user-1"}, nil)
+	mockUserRepo.On("GetUser", "user-2").Return(nil, domain.ErrUserNotFound)
+	mockConsentRepo.On("CreateConsent", mock.Anything).Return(&domain.GuardianConsent{}, nil)
+
+	// Execute
+	_, err := service.RecordConsent(requestctx.WithActor(context.Background(), "clinician-7"), patient.ID, domain.RecordConsentRequest{
+		GuardianUserID:    "user-1",
+		Relationship:      "parent",
+		EvidenceReference: "s3://consents/form-1.pdf",
+	})
+
+	// Assertions
+	assert.NoError(t, err)
+	consent := mockConsentRepo.Calls[0].Arguments.Get(0).(domain.GuardianConsent)
+	assert.Equal(t, patient.ID, consent.PatientID)
+	assert.Equal(t, now, consent.GrantedAt)
+	assert.Equal(t, "clinician-7", consent.GrantedBy)
+	assert.Equal(t, "s3://consents/form-1.pdf", consent.EvidenceReference)
+	assert.True(t, consent.ValidAt(now))
+
+	_, err = service.RecordConsent(context.Background(), patient.ID, domain.RecordConsentRequest{
+		GuardianUserID:    "user-1",
+		Relationship:      "parent",
+		EvidenceReference: "s3://consents/form-1.pdf",
+		ExpiresAt:         &past,
+	})
+	assert.EqualError(t, err, "consent expires_at must be in the future")
+
+	_, err = service.RecordConsent(context.Background(), patient.ID, domain.RecordConsentRequest{
+		GuardianUserID:    "user-2",
+		Relationship:      "parent",
+		EvidenceReference: "s3://consents/form-1.pdf",
+	})
+	assert.EqualError(t, err, "guardian user not found")
+	mockConsentRepo.AssertNumberOfCalls(t, "CreateConsent", 1)
+}
+
+func TestPatientService_RevokeConsent(t *testing.T) {
+	now := time.Date(2024, time.June, 14, 12, 0, 0, 0, time.UTC)
+	patientID := uuid.New()
+	consent := &domain.GuardianConsent{ID: uuid.New(), PatientID: patientID, GrantedAt: now.Add(-time.Hour)}
+	earlier := now.Add(-time.Minute)
+	revoked := &domain.GuardianConsent{ID: uuid.New(), PatientID: patientID, GrantedAt: now.Add(-time.Hour), RevokedAt: &earlier}
+
+	// Setup
+	mockConsentRepo := &MockConsentRepository{}
+	service := NewPatientService(createTestConfig(), &MockPatientRepository{}, &MockTransactionRepository{},
+		WithConsentRepository(mockConsentRepo), WithClock(fixedClock(now)))
+	mockConsentRepo.On("GetConsent", consent.ID.String()).Return(consent, nil)
+	mockConsentRepo.On("GetConsent", revoked.ID.String()).Return(revoked, nil)
+	mockConsentRepo.On("UpdateConsent", mock.Anything).Return(&domain.GuardianConsent{}, nil)
+
+	// Execute
+	_, err := service.RevokeConsent(requestctx.WithActor(context.Background(), "clinician-7"), patientID, consent.ID, domain.RevokeConsentRequest{Reason: "guardian withdrew"})
+
+	// Assertions
+	assert.NoError(t, err)
+	rs := mockConsentRepo.Calls[1].Arguments.Get(0).(domain.GuardianConsent)
+	if assert.NotNil(t, rs.RevokedAt) {
+		assert.Equal(t, now, *rs.RevokedAt)
+	}
+	assert.Equal(t, "guardian withdrew", rs.RevocationReason)
+	assert.Equal(t, "clinician-7", rs.RevokedBy)
+	assert.False(t, rs.ValidAt(now))
+
+	// revoking again keeps the first revocation
+	again, err := service.RevokeConsent(context.Background(), patientID, revoked.ID, domain.RevokeConsentRequest{Reason: "again"})
+	assert.NoError(t, err)
+	assert.Equal(t, earlier, *again.RevokedAt)
+
+	// consents of other patients are not found
+	_, err = service.RevokeConsent(context.Background(), uuid.New(), consent.ID, domain.RevokeConsentRequest{Reason: "wrong patient"})
+	assert.ErrorIs(t, err, domain.ErrConsentNotFound)
+	mockConsentRepo.AssertNumberOfCalls(t, "UpdateConsent", 1)
+}
@@ -1,131 +1,827 @@
 package services
 
 import (
-	"crypto/rand"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"math"
-	"math/big"
+	"net"
+	"strings"
 	"time"
 
 	"github.com/datphamcode295/go-lambda-pulumi/internal/config"
 	"github.com/datphamcode295/go-lambda-pulumi/internal/core/domain"
 	"github.com/datphamcode295/go-lambda-pulumi/internal/core/ports"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/core/pricing"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/core/rules"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/logger"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/metrics"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/tracing"
 	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type PatientService struct {
 	cfg             *config.Config
 	patientRepo     ports.PatientRepository
 	transactionRepo ports.TransactionRepository
+	provider        ports.PatientProvider
+	queue           ports.JobQueue
+	attemptRepo     ports.TransactionAttemptRepository
+	eligibility     ports.EligibilityRuleSource
+	consentRepo     ports.ConsentRepository
+	userRepo        ports.UserRepository
+	auditRepo       ports.AuditRepository
+	// transactor writes changes together with their audit entries, nil when the audit log's
+	// store has no transactions
+	transactor ports.Transactor
+	pricer     *pricing.Pricer
+	disclosure domain.ProviderPatientPolicy
+	metrics    metrics.Recorder
+	clock      ports.Clock
+	// location is the time zone in which a patient's age is counted
+	location *time.Location
 }
 
-func NewPatientService(cfg *config.Config, patientRepo ports.PatientRepository, transactionRepo ports.TransactionRepository) *PatientService {
-	return &PatientService{
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// Option configures optional dependencies of PatientService
+type Option func(*PatientService)
+
+// WithProvider sets the client used to submit patients to the external provider
+func WithProvider(provider ports.PatientProvider) Option {
+	return func(p *PatientService) {
+		p.provider = provider
+	}
+}
+
+// WithJobQueue sets the queue SubmitTransaction hands transactions to
+func WithJobQueue(queue ports.JobQueue) Option {
+	return func(p *PatientService) {
+		p.queue = queue
+	}
+}
+
+// WithAttemptRepository sets where every provider call is recorded, attempts are not kept without it
+func WithAttemptRepository(repo ports.TransactionAttemptRepository) Option {
+	return func(p *PatientService) {
+		p.attemptRepo = repo
+	}
+}
+
+// WithEligibilityRules sets where the eligibility rules come from, rules.Default is used without it
+func WithEligibilityRules(source ports.EligibilityRuleSource) Option {
+	return func(p *PatientService) {
+		p.eligibility = source
+	}
+}
+
+// WithConsentRepository sets where guardian consents are kept, minors are never eligible without it
+func WithConsentRepository(repo ports.ConsentRepository) Option {
+	return func(p *PatientService) {
+		p.consentRepo = repo
+	}
+}
+
+// WithUserRepository sets where the users holding patients are looked up, patients have no
+// membership without it
+func WithUserRepository(repo ports.UserRepository) Option {
+	return func(p *PatientService) {
+		p.userRepo = repo
+	}
+}
+
+// WithPricing sets the fees of transactions, they are not priced without it
+func WithPricing(pricer *pricing.Pricer) Option {
+	return func(p *PatientService) {
+		p.pricer = pricer
+	}
+}
+
+// WithProviderPatientPolicy sets what the provider is sent of patients, only the patient's UUID
+// is sent without it, see domain.DefaultProviderPatientPolicy
+func WithProviderPatientPolicy(policy domain.ProviderPatientPolicy) Option {
+	return func(p *PatientService) {
+		p.disclosure = policy
+	}
+}
+
+// WithClock sets the clock used for ages and timestamps, the system clock is used without it
+func WithClock(clock ports.Clock) Option {
+	return func(p *PatientService) {
+		p.clock = clock
+	}
+}
+
+// WithTimeZone sets the time zone of "today" when counting ages, UTC is used without it
+func WithTimeZone(location *time.Location) Option {
+	return func(p *PatientService) {
+		p.location = location
+	}
+}
+
+// WithMetrics sets the recorder used for business and latency metrics
+func WithMetrics(recorder metrics.Recorder) Option {
+	return func(p *PatientService) {
+		p.metrics = recorder
+	}
+}
+
+func NewPatientService(cfg *config.Config, patientRepo ports.PatientRepository, transactionRepo ports.TransactionRepository, opts ...Option) *PatientService {
+	p := &PatientService{
 		cfg:             cfg,
 		patientRepo:     patientRepo,
 		transactionRepo: transactionRepo,
+		eligibility:     rules.Static(rules.Default()),
+		disclosure:      domain.DefaultProviderPatientPolicy(),
+		metrics:         metrics.NoopRecorder{},
+		clock:           systemClock{},
+		location:        time.UTC,
+	}
+	for _, opt := range opts {
+		opt(p)
 	}
+	return p
 }
 
-func (p *PatientService) PayTransaction(data domain.PayTransactionRequest) (*domain.Transaction, error) {
-	patient, err := p.patientRepo.GetPatient(data.PatientID.String())
+func (p *PatientService) PayTransaction(ctx context.Context, data domain.PayTransactionRequest) (rs *domain.Transaction, err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "PatientService.PayTransaction", trace.WithAttributes(
+		attribute.String("patient.id", data.PatientID.String()),
+		attribute.String("transaction.record_type", data.RecordType),
+	))
+	defer func() {
+		if rs != nil {
+			span.SetAttributes(attribute.String("transaction.status", string(rs.Status)))
+		}
+		tracing.RecordError(span, err)
+		span.End()
+	}()
+
+	ctx, patient, transaction, patientAge, err := p.newTransaction(ctx, data)
 	if err != nil {
 		return nil, err
 	}
+	span.SetAttributes(attribute.String("transaction.id", transaction.ID.String()))
 
-	transaction := domain.Transaction{
-		ID:          uuid.New(),
-		PatientID:   data.PatientID,
-		DateOfBirth: data.DateOfBirth,
-		RecordType:  data.RecordType,
+	if rejected, err := p.validate(ctx, &transaction, patient, patientAge); err != nil {
+		return nil, err
+	} else if rejected {
+		return p.saveTransaction(ctx, transaction)
+	}
+	p.price(&transaction)
+
+	if err := p.submit(ctx, &transaction, patient, patientAge); err != nil {
+		// the attempt is already recorded against the transaction, which must be saved with it
+		p.failTransient(ctx, &transaction, err)
+	}
+	return p.saveTransaction(ctx, transaction)
+}
+
+// SubmitTransaction records the transaction as pending and leaves the provider call to the
+// worker. Transactions failing validation are rejected right away as in PayTransaction.
+func (p *PatientService) SubmitTransaction(ctx context.Context, data domain.PayTransactionRequest) (rs *domain.Transaction, err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "PatientService.SubmitTransaction", trace.WithAttributes(
+		attribute.String("patient.id", data.PatientID.String()),
+		attribute.String("transaction.record_type", data.RecordType),
+	))
+	defer func() {
+		tracing.RecordError(span, err)
+		span.End()
+	}()
+
+	if p.queue == nil {
+		return nil, errors.New("job queue is not configured")
 	}
 
-	// validate data
-	// patient more than 18 years old
-	patientDateOfBirth, err := time.Parse("02-01-2006", data.DateOfBirth)
+	ctx, patient, transaction, patientAge, err := p.newTransaction(ctx, data)
 	if err != nil {
-		return nil, errors.New("date of birth format must be DD-MM-YYYY")
+		return nil, err
 	}
+	span.SetAttributes(attribute.String("transaction.id", transaction.ID.String()))
 
-	patientAge := math.Floor(time.Since(patientDateOfBirth).Hours() / 24 / 365)
-	if patientAge < 18 {
-		transaction.Status = domain.TransactionStatusFailed
-		transaction.APIResponse = json.RawMessage(`{"error": "Patient must be more than 18 years old"}`)
-		rs, err := p.transactionRepo.CreateTransaction(transaction)
-		if err != nil {
+	if rejected, err := p.validate(ctx, &transaction, patient, patientAge); err != nil {
+		return nil, err
+	} else if rejected {
+		return p.saveTransaction(ctx, transaction)
+	}
+	p.price(&transaction)
+
+	transaction.Status = domain.TransactionStatusPending
+	rs, err = p.createTransaction(ctx, transaction)
+	if err != nil {
+		return nil, err
+	}
+
+	err = p.queue.Enqueue(ctx, domain.Job{Type: domain.JobTypeProcessTransaction, TransactionID: transaction.ID})
+	if err != nil {
+		// no job will process it, RetryFailedTransactions submits it instead
+		before := *rs
+		p.failTransient(ctx, rs, fmt.Errorf("enqueue: %w", err))
+		if rs, err = p.updateTransaction(ctx, before, *rs); err != nil {
 			return nil, err
 		}
-		return rs, nil
 	}
+	return rs, nil
+}
 
-	// only accept record with type NEW
-	if data.RecordType != "NEW" {
-		transaction.Status = domain.TransactionStatusFailed
-		transaction.APIResponse = json.RawMessage(`{"error": "Record type must be NEW"}`)
-		rs, err := p.transactionRepo.CreateTransaction(transaction)
-		if err != nil {
-			return nil, err
+// ProcessTransaction submits a pending transaction to the provider. Transactions that were
+// already processed, or are leased by another delivery of the job, are returned unchanged, so a
+// redelivered job is harmless. A provider error fails the transaction as transient rather than
+// the job, RetryFailedTransactions submits it again.
+func (p *PatientService) ProcessTransaction(ctx context.Context, id uuid.UUID) (rs *domain.Transaction, err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "PatientService.ProcessTransaction", trace.WithAttributes(
+		attribute.String("transaction.id", id.String()),
+	))
+	defer func() {
+		if rs != nil {
+			span.SetAttributes(attribute.String("transaction.status", string(rs.Status)))
 		}
+		tracing.RecordError(span, err)
+		span.End()
+	}()
 
-		return rs, nil
+	ctx = logger.WithFields(ctx, logrus.Fields{"transaction_id": id.String()})
+	transaction, err := p.transactionRepo.GetTransaction(ctx, id.String())
+	if err != nil {
+		return nil, err
+	}
+	if transaction.Status != domain.TransactionStatusPending {
+		logger.FromContext(ctx).WithField("status", transaction.Status).Info("Transaction already processed")
+		return transaction, nil
 	}
 
-	// remap
-	type SubmitPatientRequest struct {
-		Patient    *domain.Patient `json:"patient"`
-		Age        int             `json:"age"`
-		RecordType string          `json:"record_type"`
+	now := p.clock.Now()
+	claimed, err := p.transactionRepo.ClaimPending(ctx, id.String(), now, now.Add(p.cfg.Retry.Lease))
+	if err != nil {
+		return nil, err
+	}
+	if !claimed {
+		logger.FromContext(ctx).Info("Transaction claimed by another delivery")
+		return transaction, nil
 	}
 
-	submitPatientRequest := SubmitPatientRequest{
-		Patient:    patient,
-		Age:        int(patientAge),
-		RecordType: data.RecordType,
+	ctx = logger.WithFields(ctx, logrus.Fields{"patient_id": transaction.PatientID.String()})
+	patient, err := p.patientRepo.GetPatient(ctx, transaction.PatientID.String())
+	if err != nil {
+		return nil, err
+	}
+	before := *transaction
+	if err := p.submit(ctx, transaction, patient, p.ageOf(patient, transaction.DateOfBirth)); err != nil {
+		p.failTransient(ctx, transaction, err)
 	}
 
-	// call external api
-	fmt.Printf("Calling external api with request: %+v\n and api key: %s\n", submitPatientRequest, p.cfg.APIKey)
-	isSuccess, err := rand.Int(rand.Reader, big.NewInt(2))
+	rs, err = p.updateTransaction(ctx, before, *transaction)
 	if err != nil {
 		return nil, err
 	}
+	p.countOutcome(*rs)
+	return rs, nil
+}
 
-	// if isSuccess is 0 means transaction failed
-	if isSuccess.Int64() == 0 {
-		dummyFailedBody := map[string]string{
-			"error": "Transaction failed",
-		}
+func (p *PatientService) GetTransaction(ctx context.Context, id uuid.UUID) (*domain.Transaction, error) {
+	transaction, err := p.transactionRepo.GetTransaction(ctx, id.String())
+	if err != nil {
+		return nil, err
+	}
+	p.audit(ctx, domain.AuditActionView, domain.AuditEntityTransaction, id.String(), nil, nil)
+	return transaction, nil
+}
+
+func (p *PatientService) ListTransactionAttempts(ctx context.Context, transactionID uuid.UUID) ([]domain.TransactionAttempt, error) {
+	if p.attemptRepo == nil {
+		return nil, errors.New("transaction attempts are not recorded")
+	}
+	attempts, err := p.attemptRepo.ListTransactionAttempts(ctx, transactionID.String())
+	if err != nil {
+		return nil, err
+	}
+	p.audit(ctx, domain.AuditActionView, domain.AuditEntityTransaction, transactionID.String(), nil, nil)
+	return attempts, nil
+}
+
+// PurgeTransactionAttempts deletes the attempts older than the configured retention. A zero
+// retention keeps them forever.
+func (p *PatientService) PurgeTransactionAttempts(ctx context.Context) (int64, error) {
+	retention := p.cfg.Retry.AttemptRetention
+	if p.attemptRepo == nil || retention <= 0 {
+		return 0, nil
+	}
+
+	deleted, err := p.attemptRepo.DeleteTransactionAttemptsBefore(ctx, p.clock.Now().Add(-retention))
+	if err != nil {
+		return 0, err
+	}
+	logger.FromContext(ctx).WithField("deleted", deleted).Info("Purged transaction attempts")
+	return deleted, nil
+}
+
+// newTransaction looks up the patient and builds the transaction for data
+func (p *PatientService) newTransaction(ctx context.Context, data domain.PayTransactionRequest) (context.Context, *domain.Patient, domain.Transaction, int, error) {
+	ctx = logger.WithFields(ctx, logrus.Fields{"patient_id": data.PatientID.String()})
+	patient, err := p.patientRepo.GetPatient(ctx, data.PatientID.String())
+	if err != nil {
+		return ctx, nil, domain.Transaction{}, 0, err
+	}
+	p.audit(ctx, domain.AuditActionView, domain.AuditEntityPatient, patient.ID.String(), nil, nil)
 
-		jsonBody, err := json.Marshal(dummyFailedBody)
+	transaction := domain.Transaction{
+		ID:                    uuid.New(),
+		PatientID:             data.PatientID,
+		DateOfBirth:           data.DateOfBirth,
+		RecordType:            data.RecordType,
+		PreviousTransactionID: data.PreviousTransactionID,
+		TransferTo:            data.TransferTo,
+		CorrectionReason:      data.CorrectionReason,
+	}
+	ctx = logger.WithFields(ctx, logrus.Fields{"transaction_id": transaction.ID.String()})
+
+	transaction.MembershipTier, err = p.membershipTier(ctx, patient)
+	if err != nil {
+		return ctx, nil, domain.Transaction{}, 0, err
+	}
+
+	return ctx, patient, transaction, p.ageOf(patient, data.DateOfBirth), nil
+}
+
+// membershipTier returns the current tier of the user holding the patient. A user that no
+// longer exists has no membership.
+func (p *PatientService) membershipTier(ctx context.Context, patient *domain.Patient) (domain.MembershipTier, error) {
+	if p.userRepo == nil || patient.UserID == "" {
+		return domain.MembershipNone, nil
+	}
+
+	user, err := p.userRepo.GetUser(ctx, patient.UserID)
+	if errors.Is(err, domain.ErrUserNotFound) {
+		logger.FromContext(ctx).WithField("user_id", patient.UserID).Warn("User of the patient not found, pricing without membership")
+		return domain.MembershipNone, nil
+	}
+	if err != nil {
+		return domain.MembershipNone, err
+	}
+	return user.MembershipAt(p.clock.Now()), nil
+}
+
+// price sets the fee of the transaction from the membership tier it was created with
+func (p *PatientService) price(transaction *domain.Transaction) {
+	if p.pricer == nil {
+		return
+	}
+
+	quote := p.pricer.Price(transaction.MembershipTier)
+	transaction.BaseFeeCents = quote.BaseFeeCents
+	transaction.DiscountCents = quote.DiscountCents
+	transaction.FeeCents = quote.FeeCents
+	transaction.Currency = quote.Currency
+	transaction.AppliedBenefit = quote.AppliedBenefit
+}
+
+// validate checks the transaction against the eligibility rules and its record type, and
+// reports whether it was rejected. A rejected transaction is failed with every reason in
+// RejectionReasons and the message of the first one in APIResponse.
+func (p *PatientService) validate(ctx context.Context, transaction *domain.Transaction, patient *domain.Patient, patientAge int) (bool, error) {
+	// an empty list is kept, it is how a source says no rule applies
+	ruleList, err := p.eligibility.EligibilityRules(ctx)
+	if err != nil {
+		return false, err
+	}
+	engine, err := rules.New(ruleList)
+	if err != nil {
+		return false, err
+	}
+
+	reasons, err := p.verifyDateOfBirth(ctx, *transaction, patient)
+	if err != nil {
+		return false, err
+	}
+	facts := rules.Facts{
+		Age:        patientAge,
+		AgeUnknown: patient.DateOfBirth.IsZero(),
+		RecordType: transaction.RecordType,
+		State:      patient.State,
+		Zip:        patient.Zip,
+		Membership: string(transaction.MembershipTier),
+	}
+	ruleReasons := engine.Evaluate(facts)
+	if engine.NeedsConsent(ruleReasons) {
+		consent, err := p.validConsent(ctx, patient.ID)
 		if err != nil {
-			return nil, err
+			return false, err
+		}
+		if consent != nil {
+			logger.FromContext(ctx).WithField("consent_id", consent.ID.String()).Info("Guardian consent on file")
+			transaction.ConsentID = &consent.ID
+			facts.GuardianConsent = true
+			ruleReasons = engine.Evaluate(facts)
+		}
+	}
+	reasons = append(reasons, ruleReasons...)
+	recordTypeReason, err := p.checkRecordType(ctx, *transaction)
+	if err != nil {
+		return false, err
+	}
+	if recordTypeReason != nil {
+		reasons = append(reasons, *recordTypeReason)
+	}
+	if len(reasons) == 0 {
+		return false, nil
+	}
+
+	logger.FromContext(ctx).WithField("rejection_reasons", reasons).Info("Transaction rejected")
+	transaction.Status = domain.TransactionStatusFailed
+	transaction.FailureKind = domain.FailureKindRejected
+	transaction.RejectionReasons = reasons
+	transaction.APIResponse, _ = json.Marshal(map[string]string{"error": reasons[0].Message})
+	return true, nil
+}
+
+// verifyDateOfBirth compares the claimed date of birth with the patient's. Mismatches are
+// counted on the patient, who is locked out after MaxDateOfBirthMismatches of them in a row;
+// a match before that clears the count. Patients without a date of birth on file are only
+// accepted, without checking their age, until RequireDateOfBirth is set.
+func (p *PatientService) verifyDateOfBirth(ctx context.Context, transaction domain.Transaction, patient *domain.Patient) ([]domain.RejectionReason, error) {
+	reject := func(rule, message string) ([]domain.RejectionReason, error) {
+		return []domain.RejectionReason{{Rule: rule, Kind: "date_of_birth", Message: message}}, nil
+	}
+
+	maxMismatches := p.cfg.Eligibility.MaxDateOfBirthMismatches
+	if maxMismatches > 0 && patient.DateOfBirthMismatches >= maxMismatches {
+		return reject("date-of-birth-locked", "Too many date of birth mismatches for this patient")
+	}
+
+	if patient.DateOfBirth.IsZero() {
+		if p.cfg.Eligibility.RequireDateOfBirth {
+			return reject("date-of-birth-unknown", "Patient date of birth is not on file")
+		}
+		logger.FromContext(ctx).Warn("Patient date of birth is not on file, skipping verification and age rules")
+		return nil, nil
+	}
+
+	if patient.DateOfBirth.Equal(transaction.DateOfBirth) {
+		if patient.DateOfBirthMismatches > 0 {
+			err := p.inTransaction(ctx, func(ctx context.Context) error {
+				if err := p.patientRepo.ResetDateOfBirthMismatches(ctx, patient.ID.String()); err != nil {
+					return err
+				}
+				return p.auditMismatches(ctx, patient, 0)
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+		return nil, nil
+	}
+
+	var mismatches int
+	err := p.inTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		if mismatches, err = p.patientRepo.RecordDateOfBirthMismatch(ctx, patient.ID.String()); err != nil {
+			return err
 		}
+		return p.auditMismatches(ctx, patient, mismatches)
+	})
+	if err != nil {
+		return nil, err
+	}
+	p.metrics.Count(metrics.DateOfBirthMismatch, 1, nil)
+	logger.FromContext(ctx).WithField("mismatches", mismatches).Warn("Date of birth does not match the patient")
+	return reject("date-of-birth-mismatch", "Date of birth does not match our records")
+}
+
+// auditMismatches audits the date of birth mismatches of the patient set to count
+func (p *PatientService) auditMismatches(ctx context.Context, patient *domain.Patient, count int) error {
+	return p.auditChange(ctx, domain.AuditActionUpdate, domain.AuditEntityPatient, patient.ID.String(),
+		map[string]int{"date_of_birth_mismatches": patient.DateOfBirthMismatches},
+		map[string]int{"date_of_birth_mismatches": count})
+}
+
+// checkRecordType validates the transaction against its record type and, for record types
+// continuing a history, that the previous transaction may be followed by this one
+func (p *PatientService) checkRecordType(ctx context.Context, transaction domain.Transaction) (*domain.RejectionReason, error) {
+	reject := func(rule, message string) (*domain.RejectionReason, error) {
+		return &domain.RejectionReason{Rule: rule, Kind: string(rules.KindRecordType), Message: message}, nil
+	}
+
+	spec, ok := domain.LookupRecordType(transaction.RecordType)
+	if !ok {
+		return reject("record-type", "Record type must be one of "+strings.Join(domain.RecordTypeNames(), ", "))
+	}
+	if err := spec.Validate(transaction); err != nil {
+		return reject("record-type-fields", err.Error())
+	}
+	if transaction.PreviousTransactionID == nil {
+		return nil, nil
+	}
 
+	previous, err := p.transactionRepo.GetTransaction(ctx, transaction.PreviousTransactionID.String())
+	if errors.Is(err, domain.ErrTransactionNotFound) || (err == nil && previous.PatientID != transaction.PatientID) {
+		return reject("record-type-transition", "Previous transaction not found for this patient")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if previous.Status != domain.TransactionStatusSuccess {
+		return reject("record-type-transition", "Previous transaction was not successful")
+	}
+	if !spec.CanFollow(previous.RecordType) {
+		return reject("record-type-transition", fmt.Sprintf("%s records cannot follow %s records", spec.Name, previous.RecordType))
+	}
+	return nil, nil
+}
+
+// submit calls the external provider and records its answer on the transaction. Transient
+// failures are scheduled for RetryFailedTransactions until the attempts run out.
+func (p *PatientService) submit(ctx context.Context, transaction *domain.Transaction, patient *domain.Patient, patientAge int) error {
+	transaction.Attempts++
+
+	spec, ok := domain.LookupRecordType(transaction.RecordType)
+	if !ok {
+		return fmt.Errorf("unknown record type %q", transaction.RecordType)
+	}
+	submitPatientRequest := spec.Payload(*transaction, p.disclosure.Patient(patient), patientAge)
+
+	// call external api
+	logger.FromContext(ctx).WithField("request", logger.Redact(submitPatientRequest)).Debug("Calling external api")
+	providerStart := time.Now()
+	providerCtx, providerSpan := tracing.Tracer().Start(ctx, "provider.SubmitPatient", trace.WithSpanKind(trace.SpanKindClient))
+	resp, err := p.provider.SubmitPatient(providerCtx, submitPatientRequest)
+	tracing.RecordError(providerSpan, err)
+	providerSpan.End()
+	latency := time.Since(providerStart)
+	metrics.ObserveDuration(p.metrics, metrics.ProviderLatency, latency, metrics.Dimensions{
+		"record_type": recordTypeDimension(transaction.RecordType),
+	})
+	p.recordAttempt(ctx, *transaction, submitPatientRequest, resp, err, latency)
+	if err != nil {
+		return err
+	}
+
+	transaction.APIResponse = resp.Body
+	transaction.NextRetryAt = nil
+	switch {
+	case resp.Success:
+		transaction.Status = domain.TransactionStatusSuccess
+		transaction.FailureKind = ""
+	case resp.Retryable:
 		transaction.Status = domain.TransactionStatusFailed
-		transaction.APIResponse = jsonBody
-		rs, err := p.transactionRepo.CreateTransaction(transaction)
-		if err != nil {
-			return nil, err
+		p.scheduleRetry(transaction)
+	default:
+		transaction.Status = domain.TransactionStatusFailed
+		transaction.FailureKind = domain.FailureKindRejected
+	}
+	return nil
+}
+
+// recordAttempt stores the call to the provider. Failing to store it is only logged, the
+// history must not fail the transaction.
+func (p *PatientService) recordAttempt(ctx context.Context, transaction domain.Transaction, request domain.SubmitPatientRequest, resp *domain.ProviderResponse, callErr error, latency time.Duration) {
+	if p.attemptRepo == nil {
+		return
+	}
+
+	attempt := domain.TransactionAttempt{
+		ID:            uuid.New(),
+		TransactionID: transaction.ID,
+		Attempt:       transaction.Attempts,
+		LatencyMS:     latency.Milliseconds(),
+		ErrorClass:    attemptErrorClass(resp, callErr),
+		CreatedAt:     p.clock.Now(),
+	}
+	if payload, err := json.Marshal(logger.Redact(request)); err == nil {
+		attempt.Request = payload
+	}
+	if callErr != nil {
+		attempt.Error = callErr.Error()
+	}
+	if resp != nil {
+		attempt.StatusCode = resp.StatusCode
+		attempt.ResponseBody = resp.Body
+		if len(resp.Headers) > 0 {
+			if headers, err := json.Marshal(resp.Headers); err == nil {
+				attempt.ResponseHeaders = headers
+			}
 		}
-		return rs, nil
-	} else {
-		// create transaction with status success
-		dummySuccessBody := map[string]string{
-			"message": "Transaction success",
+	}
+
+	if err := p.attemptRepo.CreateTransactionAttempt(ctx, attempt); err != nil {
+		logger.FromContext(ctx).WithError(err).Warn("Failed to record transaction attempt")
+	}
+}
+
+// attemptErrorClass classifies a provider call for the attempt history
+func attemptErrorClass(resp *domain.ProviderResponse, err error) domain.AttemptErrorClass {
+	var netErr net.Error
+	switch {
+	case err == nil && resp.Success:
+		return domain.AttemptErrorNone
+	case err == nil && resp.Retryable:
+		return domain.AttemptErrorTransient
+	case err == nil:
+		return domain.AttemptErrorRejected
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return domain.AttemptErrorTimeout
+	default:
+		return domain.AttemptErrorNetwork
+	}
+}
+
+// scheduleRetry sets when a transient failure is retried, doubling the delay after every
+// attempt. The transaction is left without a retry once it used its last attempt.
+func (p *PatientService) scheduleRetry(transaction *domain.Transaction) {
+	transaction.FailureKind = domain.FailureKindTransient
+	transaction.NextRetryAt = nil
+
+	retry := p.cfg.Retry
+	if transaction.Attempts >= retry.MaxAttempts {
+		return
+	}
+
+	delay := retry.BaseDelay
+	for i := 1; i < transaction.Attempts && (retry.MaxDelay <= 0 || delay < retry.MaxDelay); i++ {
+		delay *= 2
+	}
+	if retry.MaxDelay > 0 && delay > retry.MaxDelay {
+		delay = retry.MaxDelay
+	}
+	nextRetryAt := p.clock.Now().Add(delay)
+	transaction.NextRetryAt = &nextRetryAt
+}
+
+// failTransient fails the transaction after an error a later submission may get past, such as
+// an unreachable provider, and schedules its retry
+func (p *PatientService) failTransient(ctx context.Context, transaction *domain.Transaction, err error) {
+	logger.FromContext(ctx).WithError(err).Warn("Transaction failed, scheduling a retry")
+	transaction.Status = domain.TransactionStatusFailed
+	p.scheduleRetry(transaction)
+}
+
+// RetryFailedTransactions submits again the transactions whose transient failure is due for
+// retry. A transaction is claimed before it is submitted, so concurrent runs skip it.
+func (p *PatientService) RetryFailedTransactions(ctx context.Context) (rs *domain.RetryResult, err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "PatientService.RetryFailedTransactions")
+	defer func() {
+		if rs != nil {
+			span.SetAttributes(
+				attribute.Int("retry.retried", rs.Retried),
+				attribute.Int("retry.succeeded", rs.Succeeded),
+			)
 		}
-		jsonBody, err := json.Marshal(dummySuccessBody)
+		tracing.RecordError(span, err)
+		span.End()
+	}()
+
+	now := p.clock.Now()
+	// a pending transaction whose job has not claimed it within a lease is taken as lost
+	transactions, err := p.transactionRepo.ListRetryableTransactions(ctx, now, now.Add(-p.cfg.Retry.Lease), p.cfg.Retry.BatchSize)
+	if err != nil {
+		return nil, err
+	}
+
+	rs = &domain.RetryResult{}
+	for _, transaction := range transactions {
+		txCtx := logger.WithFields(ctx, logrus.Fields{
+			"transaction_id": transaction.ID.String(),
+			"patient_id":     transaction.PatientID.String(),
+			"attempts":       transaction.Attempts,
+		})
+
+		claimed, err := p.transactionRepo.ClaimRetry(txCtx, transaction.ID.String(), transaction.Attempts, now, now.Add(p.cfg.Retry.Lease))
 		if err != nil {
-			return nil, err
+			return rs, err
+		}
+		if !claimed {
+			logger.FromContext(txCtx).Debug("Transaction claimed by another run")
+			continue
 		}
 
-		transaction.Status = domain.TransactionStatusSuccess
-		transaction.APIResponse = jsonBody
-		rs, err := p.transactionRepo.CreateTransaction(transaction)
-		if err != nil {
-			return nil, err
+		rs.Retried++
+		if err := p.retry(txCtx, &transaction); err != nil {
+			// the lease makes it due again, a later run retries it
+			logger.FromContext(txCtx).WithError(err).Warn("Failed to retry transaction")
+			rs.Failed++
+			continue
+		}
+
+		switch {
+		case transaction.Status == domain.TransactionStatusSuccess:
+			rs.Succeeded++
+		case transaction.NextRetryAt == nil:
+			rs.Exhausted++
+			logger.FromContext(txCtx).WithField("failure_kind", transaction.FailureKind).Warn("Transaction failed permanently")
+		default:
+			rs.Failed++
+		}
+	}
+
+	logger.FromContext(ctx).WithFields(logrus.Fields{
+		"retried":   rs.Retried,
+		"succeeded": rs.Succeeded,
+		"failed":    rs.Failed,
+		"exhausted": rs.Exhausted,
+	}).Info("Retried failed transactions")
+	return rs, nil
+}
+
+// retry submits a claimed transaction again and stores the outcome. A provider error counts
+// as a transient failure.
+func (p *PatientService) retry(ctx context.Context, transaction *domain.Transaction) error {
+	patient, err := p.patientRepo.GetPatient(ctx, transaction.PatientID.String())
+	if err != nil {
+		return err
+	}
+	before := *transaction
+	if err := p.submit(ctx, transaction, patient, p.ageOf(patient, transaction.DateOfBirth)); err != nil {
+		p.failTransient(ctx, transaction, err)
+	}
+
+	if _, err := p.updateTransaction(ctx, before, *transaction); err != nil {
+		return err
+	}
+	p.countOutcome(*transaction)
+	return nil
+}
+
+// ageOf is the age of the patient by the date of birth on file. The claimed date of birth is
+// only used for patients without one, whose age rules are skipped.
+func (p *PatientService) ageOf(patient *domain.Patient, claimed domain.Date) int {
+	if patient.DateOfBirth.IsZero() {
+		return p.patientAge(claimed)
+	}
+	return p.patientAge(patient.DateOfBirth)
+}
+
+// patientAge is the age in whole years of a patient born on dateOfBirth, as of today in the
+// service's time zone
+func (p *PatientService) patientAge(dateOfBirth domain.Date) int {
+	return ageOn(dateOfBirth.Time, p.clock.Now().In(p.location))
+}
+
+// ageOn counts the birthdays passed by today. Only the calendar dates matter, a patient born on
+// Feb 29 has their birthday on Mar 1 in common years.
+func ageOn(dateOfBirth, today time.Time) int {
+	age := today.Year() - dateOfBirth.Year()
+	if today.Month() < dateOfBirth.Month() || (today.Month() == dateOfBirth.Month() && today.Day() < dateOfBirth.Day()) {
+		age--
+	}
+	return age
+}
+
+// saveTransaction persists the outcome of a pay-transaction and counts it by status and record type
+func (p *PatientService) saveTransaction(ctx context.Context, transaction domain.Transaction) (*domain.Transaction, error) {
+	rs, err := p.createTransaction(ctx, transaction)
+	if err != nil {
+		return nil, err
+	}
+
+	p.countOutcome(transaction)
+	return rs, nil
+}
+
+// createTransaction stores a new transaction along with its audit entry
+func (p *PatientService) createTransaction(ctx context.Context, transaction domain.Transaction) (rs *domain.Transaction, err error) {
+	err = p.inTransaction(ctx, func(ctx context.Context) error {
+		if rs, err = p.transactionRepo.CreateTransaction(ctx, transaction); err != nil {
+			return err
+		}
+		return p.auditChange(ctx, domain.AuditActionCreate, domain.AuditEntityTransaction, transaction.ID.String(), nil, rs)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rs, nil
+}
+
+// updateTransaction stores the changes made to a transaction since before along with their
+// audit entry
+func (p *PatientService) updateTransaction(ctx context.Context, before, transaction domain.Transaction) (rs *domain.Transaction, err error) {
+	err = p.inTransaction(ctx, func(ctx context.Context) error {
+		if rs, err = p.transactionRepo.UpdateTransaction(ctx, transaction); err != nil {
+			return err
 		}
-		return rs, nil
+		return p.auditChange(ctx, domain.AuditActionUpdate, domain.AuditEntityTransaction, transaction.ID.String(), before, rs)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rs, nil
+}
+
+func (p *PatientService) countOutcome(transaction domain.Transaction) {
+	p.metrics.Count(metrics.TransactionOutcome, 1, metrics.Dimensions{
+		"status":      string(transaction.Status),
+		"record_type": recordTypeDimension(transaction.RecordType),
+	})
+}
+
+// recordTypeDimension keeps the metric cardinality bounded to the registered record types
+func recordTypeDimension(recordType string) string {
+	if _, ok := domain.LookupRecordType(recordType); ok {
+		return recordType
 	}
+	return "UNKNOWN"
 }
@@ -1,13 +1,19 @@
 package services
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"testing"
 	"time"
 
+	"github.com/datphamcode295/go-lambda-pulumi/internal/adapters/provider"
 	"github.com/datphamcode295/go-lambda-pulumi/internal/config"
 	"github.com/datphamcode295/go-lambda-pulumi/internal/core/domain"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/core/pricing"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/core/rules"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/metrics"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -18,7 +24,17 @@ type MockPatientRepository struct {
 	mock.Mock
 }
 
-func (m *MockPatientRepository) GetPatient(id string) (*domain.Patient, error) {
+func (m *MockPatientRepository) RecordDateOfBirthMismatch(ctx context.Context, id string) (int, error) {
+	args := m.Called(id)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockPatientRepository) ResetDateOfBirthMismatches(ctx context.Context, id string) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockPatientRepository) GetPatient(ctx context.Context, id string) (*domain.Patient, error) {
 	args := m.Called(id)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
@@ -31,7 +47,23 @@ type MockTransactionRepository struct {
 	mock.Mock
 }
 
-func (m *MockTransactionRepository) CreateTransaction(transaction domain.Transaction) (*domain.Transaction, error) {
+func (m *MockTransactionRepository) CreateTransaction(ctx context.Context, transaction domain.Transaction) (*domain.Transaction, error) {
+	args := m.Called(transaction)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Transaction), args.Error(1)
+}
+
+func (m *MockTransactionRepository) GetTransaction(ctx context.Context, id string) (*domain.Transaction, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Transaction), args.Error(1)
+}
+
+func (m *MockTransactionRepository) UpdateTransaction(ctx context.Context, transaction domain.Transaction) (*domain.Transaction, error) {
 	args := m.Called(transaction)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
@@ -39,6 +71,71 @@ func (m *MockTransactionRepository) CreateTransaction(transaction domain.Transac
 	return args.Get(0).(*domain.Transaction), args.Error(1)
 }
 
+func (m *MockTransactionRepository) ListRetryableTransactions(ctx context.Context, now time.Time, unclaimedBefore time.Time, limit int) ([]domain.Transaction, error) {
+	args := m.Called(limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.Transaction), args.Error(1)
+}
+
+func (m *MockTransactionRepository) ClaimRetry(ctx context.Context, id string, attempts int, now time.Time, leaseUntil time.Time) (bool, error) {
+	args := m.Called(id, attempts)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockTransactionRepository) ClaimPending(ctx context.Context, id string, now time.Time, leaseUntil time.Time) (bool, error) {
+	args := m.Called(id)
+	return args.Bool(0), args.Error(1)
+}
+
+// MockJobQueue mocks the JobQueue interface
+type MockJobQueue struct {
+	mock.Mock
+}
+
+func (m *MockJobQueue) Enqueue(ctx context.Context, job domain.Job) error {
+	return m.Called(job).Error(0)
+}
+
+type MockTransactionAttemptRepository struct {
+	mock.Mock
+}
+
+func (m *MockTransactionAttemptRepository) CreateTransactionAttempt(ctx context.Context, attempt domain.TransactionAttempt) error {
+	return m.Called(attempt).Error(0)
+}
+
+func (m *MockTransactionAttemptRepository) ListTransactionAttempts(ctx context.Context, transactionID string) ([]domain.TransactionAttempt, error) {
+	args := m.Called(transactionID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.TransactionAttempt), args.Error(1)
+}
+
+func (m *MockTransactionAttemptRepository) DeleteTransactionAttemptsBefore(ctx context.Context, before time.Time) (int64, error) {
+	args := m.Called(before)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+// MockPatientProvider mocks the PatientProvider interface
+type MockPatientProvider struct {
+	mock.Mock
+}
+
+func (m *MockPatientProvider) SubmitPatient(ctx context.Context, req domain.SubmitPatientRequest) (*domain.ProviderResponse, error) {
+	args := m.Called(req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.ProviderResponse), args.Error(1)
+}
+
+func (m *MockPatientProvider) Ping(ctx context.Context) error {
+	return m.Called().Error(0)
+}
+
 // Helper function to create a test config
 func createTestConfig() *config.Config {
 	return &config.Config{
@@ -66,7 +163,7 @@ func TestNewPatientService(t *testing.T) {
 	mockPatientRepo := &MockPatientRepository{}
 	mockTransactionRepo := &MockTransactionRepository{}
 
-	service := NewPatientService(cfg, mockPatientRepo, mockTransactionRepo)
+	service := NewPatientService(cfg, mockPatientRepo, mockTransactionRepo, WithProvider(provider.NewSimulatedProvider()))
 
 	assert.NotNil(t, service)
 	assert.Equal(t, cfg, service.cfg)
@@ -79,12 +176,12 @@ func TestPatientService_PayTransaction_PatientNotFound(t *testing.T) {
 	cfg := createTestConfig()
 	mockPatientRepo := &MockPatientRepository{}
 	mockTransactionRepo := &MockTransactionRepository{}
-	service := NewPatientService(cfg, mockPatientRepo, mockTransactionRepo)
+	service := NewPatientService(cfg, mockPatientRepo, mockTransactionRepo, WithProvider(provider.NewSimulatedProvider()))
 
 	patientID := uuid.New()
 	request := domain.PayTransactionRequest{
 		PatientID:   patientID,
-		DateOfBirth: "15-03-1990",
+		DateOfBirth: mustParseDate("15-03-1990"),
 		RecordType:  "NEW",
 	}
 
@@ -92,7 +189,7 @@ func TestPatientService_PayTransaction_PatientNotFound(t *testing.T) {
 	mockPatientRepo.On("GetPatient", patientID.String()).Return(nil, errors.New("patient not found"))
 
 	// Execute
-	result, err := service.PayTransaction(request)
+	result, err := service.PayTransaction(context.Background(), request)
 
 	// Assertions
 	assert.Error(t, err)
@@ -103,48 +200,19 @@ func TestPatientService_PayTransaction_PatientNotFound(t *testing.T) {
 	mockTransactionRepo.AssertNotCalled(t, "CreateTransaction")
 }
 
-func TestPatientService_PayTransaction_InvalidDateFormat(t *testing.T) {
-	// Setup
-	cfg := createTestConfig()
-	mockPatientRepo := &MockPatientRepository{}
-	mockTransactionRepo := &MockTransactionRepository{}
-	service := NewPatientService(cfg, mockPatientRepo, mockTransactionRepo)
-
-	patient := createTestPatient()
-	patientID := patient.ID
-	request := domain.PayTransactionRequest{
-		PatientID:   patientID,
-		DateOfBirth: "1990-03-15", // Wrong format (should be DD-MM-YYYY)
-		RecordType:  "NEW",
-	}
-
-	// Mock expectations
-	mockPatientRepo.On("GetPatient", patientID.String()).Return(patient, nil)
-
-	// Execute
-	result, err := service.PayTransaction(request)
-
-	// Assertions
-	assert.Error(t, err)
-	assert.Nil(t, result)
-	assert.Equal(t, "date of birth format must be DD-MM-YYYY", err.Error())
-
-	mockPatientRepo.AssertExpectations(t)
-	mockTransactionRepo.AssertNotCalled(t, "CreateTransaction")
-}
-
 func TestPatientService_PayTransaction_PatientUnder18(t *testing.T) {
 	// Setup
 	cfg := createTestConfig()
 	mockPatientRepo := &MockPatientRepository{}
 	mockTransactionRepo := &MockTransactionRepository{}
-	service := NewPatientService(cfg, mockPatientRepo, mockTransactionRepo)
+	service := NewPatientService(cfg, mockPatientRepo, mockTransactionRepo, WithProvider(provider.NewSimulatedProvider()))
 
 	patient := createTestPatient()
 	patientID := patient.ID
 
 	// Calculate a date that makes patient under 18 (e.g., 10 years ago)
-	under18Date := time.Now().AddDate(-10, 0, 0).Format("02-01-2006")
+	under18Date := domain.DateOf(time.Now().AddDate(-10, 0, 0))
+	patient.DateOfBirth = under18Date
 
 	request := domain.PayTransactionRequest{
 		PatientID:   patientID,
@@ -166,12 +234,12 @@ func TestPatientService_PayTransaction_PatientUnder18(t *testing.T) {
 	mockTransactionRepo.On("CreateTransaction", mock.MatchedBy(func(t domain.Transaction) bool {
 		return t.PatientID == patientID &&
 			t.Status == domain.TransactionStatusFailed &&
-			t.DateOfBirth == under18Date &&
+			t.DateOfBirth.Equal(under18Date) &&
 			t.RecordType == "NEW"
 	})).Return(expectedTransaction, nil)
 
 	// Execute
-	result, err := service.PayTransaction(request)
+	result, err := service.PayTransaction(context.Background(), request)
 
 	// Assertions
 	assert.NoError(t, err)
@@ -184,26 +252,199 @@ func TestPatientService_PayTransaction_PatientUnder18(t *testing.T) {
 	mockTransactionRepo.AssertExpectations(t)
 }
 
+func TestPatientService_PayTransaction_AgeFromDateOfBirthOnFile(t *testing.T) {
+	testCases := []struct {
+		name    string
+		stored  domain.Date
+		claimed domain.Date
+		reasons []string
+	}{
+		{name: "Minor claiming to be an adult", stored: domain.DateOf(time.Now().AddDate(-10, 0, 0)), claimed: mustParseDate("15-03-1990"), reasons: []string{"date-of-birth-mismatch", "minimum-age"}},
+		{name: "No date of birth on file skips the age rules", claimed: domain.DateOf(time.Now().AddDate(-10, 0, 0))},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Setup
+			mockPatientRepo := &MockPatientRepository{}
+			mockTransactionRepo := &MockTransactionRepository{}
+			mockProvider := &MockPatientProvider{}
+			service := NewPatientService(createTestConfig(), mockPatientRepo, mockTransactionRepo, WithProvider(mockProvider))
+
+			patient := createTestPatient()
+			patient.DateOfBirth = tc.stored
+			mockPatientRepo.On("GetPatient", patient.ID.String()).Return(patient, nil)
+			mockPatientRepo.On("RecordDateOfBirthMismatch", patient.ID.String()).Return(1, nil)
+			mockProvider.On("SubmitPatient", mock.Anything).Return(&domain.ProviderResponse{Success: true, StatusCode: 200, Body: json.RawMessage(`{}`)}, nil)
+			mockTransactionRepo.On("CreateTransaction", mock.Anything).Return(&domain.Transaction{}, nil)
+
+			// Execute
+			_, err := service.PayTransaction(context.Background(), domain.PayTransactionRequest{
+				PatientID:   patient.ID,
+				DateOfBirth: tc.claimed,
+				RecordType:  "NEW",
+			})
+
+			// Assertions
+			assert.NoError(t, err)
+			saved := mockTransactionRepo.Calls[0].Arguments.Get(0).(domain.Transaction)
+			var rules []string
+			for _, reason := range saved.RejectionReasons {
+				rules = append(rules, reason.Rule)
+			}
+			assert.Equal(t, tc.reasons, rules)
+			if tc.reasons == nil {
+				assert.Equal(t, domain.TransactionStatusSuccess, saved.Status)
+			}
+		})
+	}
+}
+
+func TestPatientService_PayTransaction_RecordsMetrics(t *testing.T) {
+	// Setup
+	cfg := createTestConfig()
+	mockPatientRepo := &MockPatientRepository{}
+	mockTransactionRepo := &MockTransactionRepository{}
+	recorder := metrics.NewMemoryRecorder()
+	service := NewPatientService(cfg, mockPatientRepo, mockTransactionRepo, WithProvider(provider.NewSimulatedProvider()), WithMetrics(recorder))
+
+	minor := createTestPatient()
+	minor.DateOfBirth = domain.DateOf(time.Now().AddDate(-10, 0, 0))
+	adult := createTestPatient()
+	adult.DateOfBirth = mustParseDate("15-03-1990")
+
+	// Mock expectations
+	mockPatientRepo.On("GetPatient", minor.ID.String()).Return(minor, nil)
+	mockPatientRepo.On("GetPatient", adult.ID.String()).Return(adult, nil)
+	mockTransactionRepo.On("CreateTransaction", mock.AnythingOfType("domain.Transaction")).Return(&domain.Transaction{}, nil)
+
+	// Execute: one rejected request and one that reaches the provider
+	_, err := service.PayTransaction(context.Background(), domain.PayTransactionRequest{PatientID: minor.ID, DateOfBirth: minor.DateOfBirth, RecordType: "NEW"})
+	assert.NoError(t, err)
+	_, err = service.PayTransaction(context.Background(), domain.PayTransactionRequest{PatientID: adult.ID, DateOfBirth: adult.DateOfBirth, RecordType: "NEW"})
+	assert.NoError(t, err)
+
+	// Assertions
+	assert.Equal(t, float64(2), recorder.Counter(metrics.TransactionOutcome, metrics.Dimensions{"record_type": "NEW"}))
+	assert.GreaterOrEqual(t, recorder.Counter(metrics.TransactionOutcome, metrics.Dimensions{"status": "failed"}), float64(1))
+	assert.Len(t, recorder.Observations(metrics.ProviderLatency, nil), 1)
+}
+
+func TestPatientService_PayTransaction_ProviderOutcome(t *testing.T) {
+	testCases := []struct {
+		name           string
+		response       *domain.ProviderResponse
+		expectedStatus domain.TransactionStatus
+	}{
+		{
+			name:           "Provider accepts",
+			response:       &domain.ProviderResponse{Success: true, StatusCode: 200, Body: json.RawMessage(`{"message": "Transaction success"}`)},
+			expectedStatus: domain.TransactionStatusSuccess,
+		},
+		{
+			name:           "Provider rejects",
+			response:       &domain.ProviderResponse{Success: false, StatusCode: 422, Body: json.RawMessage(`{"error": "Transaction failed"}`)},
+			expectedStatus: domain.TransactionStatusFailed,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Setup
+			cfg := createTestConfig()
+			mockPatientRepo := &MockPatientRepository{}
+			mockTransactionRepo := &MockTransactionRepository{}
+			mockProvider := &MockPatientProvider{}
+			service := NewPatientService(cfg, mockPatientRepo, mockTransactionRepo, WithProvider(mockProvider))
+
+			patient := createTestPatient()
+			request := domain.PayTransactionRequest{
+				PatientID:   patient.ID,
+				DateOfBirth: mustParseDate("15-03-1990"),
+				RecordType:  "NEW",
+			}
+
+			// Mock expectations
+			mockPatientRepo.On("GetPatient", patient.ID.String()).Return(patient, nil)
+			mockProvider.On("SubmitPatient", mock.MatchedBy(func(req domain.SubmitPatientRequest) bool {
+				return req.Patient.ID == patient.ID.String() && req.RecordType == "NEW" && req.Age >= 18
+			})).Return(tc.response, nil)
+			mockTransactionRepo.On("CreateTransaction", mock.MatchedBy(func(t domain.Transaction) bool {
+				return t.Status == tc.expectedStatus && string(t.APIResponse) == string(tc.response.Body)
+			})).Return(&domain.Transaction{Status: tc.expectedStatus}, nil)
+
+			// Execute
+			result, err := service.PayTransaction(context.Background(), request)
+
+			// Assertions
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedStatus, result.Status)
+			mockProvider.AssertExpectations(t)
+			mockTransactionRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestPatientService_PayTransaction_ProviderError(t *testing.T) {
+	// Setup
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	mockPatientRepo := &MockPatientRepository{}
+	mockTransactionRepo := &MockTransactionRepository{}
+	mockProvider := &MockPatientProvider{}
+	mockAttemptRepo := &MockTransactionAttemptRepository{}
+	service := NewPatientService(createRetryConfig(), mockPatientRepo, mockTransactionRepo,
+		WithProvider(mockProvider), WithAttemptRepository(mockAttemptRepo), WithClock(fixedClock(now)))
+
+	patient := createTestPatient()
+	request := domain.PayTransactionRequest{
+		PatientID:   patient.ID,
+		DateOfBirth: mustParseDate("15-03-1990"),
+		RecordType:  "NEW",
+	}
+
+	// Mock expectations
+	var attempt domain.TransactionAttempt
+	mockPatientRepo.On("GetPatient", patient.ID.String()).Return(patient, nil)
+	mockProvider.On("SubmitPatient", mock.Anything).Return(nil, errors.New("provider unreachable"))
+	mockAttemptRepo.On("CreateTransactionAttempt", mock.MatchedBy(func(a domain.TransactionAttempt) bool {
+		attempt = a
+		return true
+	})).Return(nil)
+	mockTransactionRepo.On("CreateTransaction", mock.MatchedBy(func(t domain.Transaction) bool {
+		// the transaction the attempt points at is saved, for RetryFailedTransactions to submit again
+		return t.ID == attempt.TransactionID && t.Status == domain.TransactionStatusFailed &&
+			t.FailureKind == domain.FailureKindTransient && t.NextRetryAt != nil && t.NextRetryAt.Equal(now.Add(time.Minute))
+	})).Return(&domain.Transaction{Status: domain.TransactionStatusFailed, FailureKind: domain.FailureKindTransient, RecordType: "NEW"}, nil)
+
+	// Execute
+	result, err := service.PayTransaction(context.Background(), request)
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, domain.FailureKindTransient, result.FailureKind)
+	mockTransactionRepo.AssertExpectations(t)
+}
+
 func TestPatientService_PayTransaction_InvalidRecordType(t *testing.T) {
 	// Setup
 	cfg := createTestConfig()
 	mockPatientRepo := &MockPatientRepository{}
 	mockTransactionRepo := &MockTransactionRepository{}
-	service := NewPatientService(cfg, mockPatientRepo, mockTransactionRepo)
+	service := NewPatientService(cfg, mockPatientRepo, mockTransactionRepo, WithProvider(provider.NewSimulatedProvider()))
 
 	patient := createTestPatient()
 	patientID := patient.ID
 	request := domain.PayTransactionRequest{
 		PatientID:   patientID,
-		DateOfBirth: "15-03-1990", // Valid adult age
-		RecordType:  "OLD",        // Invalid record type
+		DateOfBirth: mustParseDate("15-03-1990"), // Valid adult age
+		RecordType:  "OLD",                       // Invalid record type
 	}
 
 	expectedTransaction := &domain.Transaction{
 		ID:          uuid.New(),
 		PatientID:   patientID,
 		Status:      domain.TransactionStatusFailed,
-		DateOfBirth: "15-03-1990",
+		DateOfBirth: mustParseDate("15-03-1990"),
 		RecordType:  "OLD",
 		APIResponse: json.RawMessage(`{"error": "Record type must be NEW"}`),
 	}
@@ -213,12 +454,12 @@ func TestPatientService_PayTransaction_InvalidRecordType(t *testing.T) {
 	mockTransactionRepo.On("CreateTransaction", mock.MatchedBy(func(t domain.Transaction) bool {
 		return t.PatientID == patientID &&
 			t.Status == domain.TransactionStatusFailed &&
-			t.DateOfBirth == "15-03-1990" &&
+			t.DateOfBirth.String() == "15-03-1990" &&
 			t.RecordType == "OLD"
 	})).Return(expectedTransaction, nil)
 
 	// Execute
-	result, err := service.PayTransaction(request)
+	result, err := service.PayTransaction(context.Background(), request)
 
 	// Assertions
 	assert.NoError(t, err)
@@ -236,11 +477,12 @@ func TestPatientService_PayTransaction_TransactionCreationFailed_Under18(t *test
 	cfg := createTestConfig()
 	mockPatientRepo := &MockPatientRepository{}
 	mockTransactionRepo := &MockTransactionRepository{}
-	service := NewPatientService(cfg, mockPatientRepo, mockTransactionRepo)
+	service := NewPatientService(cfg, mockPatientRepo, mockTransactionRepo, WithProvider(provider.NewSimulatedProvider()))
 
 	patient := createTestPatient()
 	patientID := patient.ID
-	under18Date := time.Now().AddDate(-10, 0, 0).Format("02-01-2006")
+	under18Date := domain.DateOf(time.Now().AddDate(-10, 0, 0))
+	patient.DateOfBirth = under18Date
 
 	request := domain.PayTransactionRequest{
 		PatientID:   patientID,
@@ -253,7 +495,7 @@ func TestPatientService_PayTransaction_TransactionCreationFailed_Under18(t *test
 	mockTransactionRepo.On("CreateTransaction", mock.AnythingOfType("domain.Transaction")).Return(nil, errors.New("database error"))
 
 	// Execute
-	result, err := service.PayTransaction(request)
+	result, err := service.PayTransaction(context.Background(), request)
 
 	// Assertions
 	assert.Error(t, err)
@@ -269,13 +511,13 @@ func TestPatientService_PayTransaction_TransactionCreationFailed_InvalidRecordTy
 	cfg := createTestConfig()
 	mockPatientRepo := &MockPatientRepository{}
 	mockTransactionRepo := &MockTransactionRepository{}
-	service := NewPatientService(cfg, mockPatientRepo, mockTransactionRepo)
+	service := NewPatientService(cfg, mockPatientRepo, mockTransactionRepo, WithProvider(provider.NewSimulatedProvider()))
 
 	patient := createTestPatient()
 	patientID := patient.ID
 	request := domain.PayTransactionRequest{
 		PatientID:   patientID,
-		DateOfBirth: "15-03-1990",
+		DateOfBirth: mustParseDate("15-03-1990"),
 		RecordType:  "OLD",
 	}
 
@@ -284,7 +526,7 @@ func TestPatientService_PayTransaction_TransactionCreationFailed_InvalidRecordTy
 	mockTransactionRepo.On("CreateTransaction", mock.AnythingOfType("domain.Transaction")).Return(nil, errors.New("database error"))
 
 	// Execute
-	result, err := service.PayTransaction(request)
+	result, err := service.PayTransaction(context.Background(), request)
 
 	// Assertions
 	assert.Error(t, err)
@@ -300,14 +542,14 @@ func TestPatientService_PayTransaction_RandomAPIError(t *testing.T) {
 	cfg := createTestConfig()
 	mockPatientRepo := &MockPatientRepository{}
 	mockTransactionRepo := &MockTransactionRepository{}
-	service := NewPatientService(cfg, mockPatientRepo, mockTransactionRepo)
+	service := NewPatientService(cfg, mockPatientRepo, mockTransactionRepo, WithProvider(provider.NewSimulatedProvider()))
 
 	patient := createTestPatient()
 	patientID := patient.ID
 	request := domain.PayTransactionRequest{
 		PatientID:   patientID,
-		DateOfBirth: "15-03-1990", // Valid adult age
-		RecordType:  "NEW",        // Valid record type
+		DateOfBirth: mustParseDate("15-03-1990"), // Valid adult age
+		RecordType:  "NEW",                       // Valid record type
 	}
 
 	// Mock expectations
@@ -316,26 +558,26 @@ func TestPatientService_PayTransaction_RandomAPIError(t *testing.T) {
 	// We can't predict the random outcome, so we'll accept either success or failed transaction
 	mockTransactionRepo.On("CreateTransaction", mock.MatchedBy(func(t domain.Transaction) bool {
 		return t.PatientID == patientID &&
-			t.DateOfBirth == "15-03-1990" &&
+			t.DateOfBirth.String() == "15-03-1990" &&
 			t.RecordType == "NEW" &&
 			(t.Status == domain.TransactionStatusSuccess || t.Status == domain.TransactionStatusFailed)
 	})).Return(&domain.Transaction{
 		ID:          uuid.New(),
 		PatientID:   patientID,
 		Status:      domain.TransactionStatusSuccess, // We'll return success for this test
-		DateOfBirth: "15-03-1990",
+		DateOfBirth: mustParseDate("15-03-1990"),
 		RecordType:  "NEW",
 		APIResponse: json.RawMessage(`{"message": "Transaction success"}`),
 	}, nil)
 
 	// Execute
-	result, err := service.PayTransaction(request)
+	result, err := service.PayTransaction(context.Background(), request)
 
 	// Assertions
 	assert.NoError(t, err)
 	assert.NotNil(t, result)
 	assert.Equal(t, patientID, result.PatientID)
-	assert.Equal(t, "15-03-1990", result.DateOfBirth)
+	assert.Equal(t, "15-03-1990", result.DateOfBirth.String())
 	assert.Equal(t, "NEW", result.RecordType)
 	// Status can be either success or failed due to random nature
 	assert.True(t, result.Status == domain.TransactionStatusSuccess || result.Status == domain.TransactionStatusFailed)
@@ -351,13 +593,13 @@ func TestPatientService_PayTransaction_SuccessfulFlow_APISuccess(t *testing.T) {
 	cfg := createTestConfig()
 	mockPatientRepo := &MockPatientRepository{}
 	mockTransactionRepo := &MockTransactionRepository{}
-	service := NewPatientService(cfg, mockPatientRepo, mockTransactionRepo)
+	service := NewPatientService(cfg, mockPatientRepo, mockTransactionRepo, WithProvider(provider.NewSimulatedProvider()))
 
 	patient := createTestPatient()
 	patientID := patient.ID
 	request := domain.PayTransactionRequest{
 		PatientID:   patientID,
-		DateOfBirth: "15-03-1990",
+		DateOfBirth: mustParseDate("15-03-1990"),
 		RecordType:  "NEW",
 	}
 
@@ -365,7 +607,7 @@ func TestPatientService_PayTransaction_SuccessfulFlow_APISuccess(t *testing.T) {
 	expectedTransaction := &domain.Transaction{
 		ID:          uuid.New(),
 		PatientID:   patientID,
-		DateOfBirth: "15-03-1990",
+		DateOfBirth: mustParseDate("15-03-1990"),
 		RecordType:  "NEW",
 		Status:      domain.TransactionStatusSuccess, // We'll assume success for this test
 		APIResponse: json.RawMessage(`{"message": "Transaction success"}`),
@@ -377,18 +619,18 @@ func TestPatientService_PayTransaction_SuccessfulFlow_APISuccess(t *testing.T) {
 	// Accept any transaction creation with proper fields
 	mockTransactionRepo.On("CreateTransaction", mock.MatchedBy(func(t domain.Transaction) bool {
 		return t.PatientID == patientID &&
-			t.DateOfBirth == "15-03-1990" &&
+			t.DateOfBirth.String() == "15-03-1990" &&
 			t.RecordType == "NEW"
 	})).Return(expectedTransaction, nil)
 
 	// Execute
-	result, err := service.PayTransaction(request)
+	result, err := service.PayTransaction(context.Background(), request)
 
 	// Assertions
 	assert.NoError(t, err)
 	assert.NotNil(t, result)
 	assert.Equal(t, patientID, result.PatientID)
-	assert.Equal(t, "15-03-1990", result.DateOfBirth)
+	assert.Equal(t, "15-03-1990", result.DateOfBirth.String())
 	assert.Equal(t, "NEW", result.RecordType)
 
 	// Verify that the API response is valid JSON and contains expected content
@@ -407,13 +649,13 @@ func TestPatientService_PayTransaction_TransactionCreationError_OnAPICall(t *tes
 	cfg := createTestConfig()
 	mockPatientRepo := &MockPatientRepository{}
 	mockTransactionRepo := &MockTransactionRepository{}
-	service := NewPatientService(cfg, mockPatientRepo, mockTransactionRepo)
+	service := NewPatientService(cfg, mockPatientRepo, mockTransactionRepo, WithProvider(provider.NewSimulatedProvider()))
 
 	patient := createTestPatient()
 	patientID := patient.ID
 	request := domain.PayTransactionRequest{
 		PatientID:   patientID,
-		DateOfBirth: "15-03-1990",
+		DateOfBirth: mustParseDate("15-03-1990"),
 		RecordType:  "NEW",
 	}
 
@@ -422,7 +664,7 @@ func TestPatientService_PayTransaction_TransactionCreationError_OnAPICall(t *tes
 	mockTransactionRepo.On("CreateTransaction", mock.AnythingOfType("domain.Transaction")).Return(nil, errors.New("database connection failed"))
 
 	// Execute
-	result, err := service.PayTransaction(request)
+	result, err := service.PayTransaction(context.Background(), request)
 
 	// Assertions
 	assert.Error(t, err)
@@ -436,31 +678,31 @@ func TestPatientService_PayTransaction_TransactionCreationError_OnAPICall(t *tes
 func TestPatientService_PayTransaction_EdgeCases(t *testing.T) {
 	testCases := []struct {
 		name          string
-		dateOfBirth   string
+		dateOfBirth   domain.Date
 		expectedValid bool
 		description   string
 	}{
 		{
 			name:          "Exactly 18 years old",
-			dateOfBirth:   time.Now().AddDate(-18, 0, 0).Format("02-01-2006"),
+			dateOfBirth:   domain.DateOf(time.Now().AddDate(-18, 0, 0)),
 			expectedValid: true,
 			description:   "Patient exactly 18 years old should be valid",
 		},
 		{
 			name:          "Just over 18 years old",
-			dateOfBirth:   time.Now().AddDate(-18, 0, -1).Format("02-01-2006"),
+			dateOfBirth:   domain.DateOf(time.Now().AddDate(-18, 0, -1)),
 			expectedValid: true,
 			description:   "Patient just over 18 years old should be valid",
 		},
 		{
 			name:          "Clearly under 18 years old",
-			dateOfBirth:   time.Now().AddDate(-17, -6, 0).Format("02-01-2006"), // 17.5 years old
+			dateOfBirth:   domain.DateOf(time.Now().AddDate(-17, -6, 0)), // 17.5 years old
 			expectedValid: false,
 			description:   "Patient clearly under 18 years old should be invalid",
 		},
 		{
 			name:          "Very old patient",
-			dateOfBirth:   time.Now().AddDate(-100, 0, 0).Format("02-01-2006"),
+			dateOfBirth:   domain.DateOf(time.Now().AddDate(-100, 0, 0)),
 			expectedValid: true,
 			description:   "Very old patient should be valid",
 		},
@@ -472,9 +714,10 @@ func TestPatientService_PayTransaction_EdgeCases(t *testing.T) {
 			cfg := createTestConfig()
 			mockPatientRepo := &MockPatientRepository{}
 			mockTransactionRepo := &MockTransactionRepository{}
-			service := NewPatientService(cfg, mockPatientRepo, mockTransactionRepo)
+			service := NewPatientService(cfg, mockPatientRepo, mockTransactionRepo, WithProvider(provider.NewSimulatedProvider()))
 
 			patient := createTestPatient()
+			patient.DateOfBirth = tc.dateOfBirth
 			patientID := patient.ID
 			request := domain.PayTransactionRequest{
 				PatientID:   patientID,
@@ -515,7 +758,7 @@ func TestPatientService_PayTransaction_EdgeCases(t *testing.T) {
 			}
 
 			// Execute
-			result, err := service.PayTransaction(request)
+			result, err := service.PayTransaction(context.Background(), request)
 
 			// Assertions
 			assert.NoError(t, err, tc.description)
@@ -575,13 +818,13 @@ func TestPatientService_PayTransaction_RecordTypeValidation(t *testing.T) {
 			cfg := createTestConfig()
 			mockPatientRepo := &MockPatientRepository{}
 			mockTransactionRepo := &MockTransactionRepository{}
-			service := NewPatientService(cfg, mockPatientRepo, mockTransactionRepo)
+			service := NewPatientService(cfg, mockPatientRepo, mockTransactionRepo, WithProvider(provider.NewSimulatedProvider()))
 
 			patient := createTestPatient()
 			patientID := patient.ID
 			request := domain.PayTransactionRequest{
 				PatientID:   patientID,
-				DateOfBirth: "15-03-1990", // Valid adult age
+				DateOfBirth: mustParseDate("15-03-1990"), // Valid adult age
 				RecordType:  tc.recordType,
 			}
 
@@ -593,7 +836,7 @@ func TestPatientService_PayTransaction_RecordTypeValidation(t *testing.T) {
 				expectedTransaction := &domain.Transaction{
 					ID:          uuid.New(),
 					PatientID:   patientID,
-					DateOfBirth: "15-03-1990",
+					DateOfBirth: mustParseDate("15-03-1990"),
 					RecordType:  tc.recordType,
 					Status:      domain.TransactionStatusFailed,
 					APIResponse: json.RawMessage(`{"error": "Record type must be NEW"}`),
@@ -606,7 +849,7 @@ func TestPatientService_PayTransaction_RecordTypeValidation(t *testing.T) {
 				expectedTransaction := &domain.Transaction{
 					ID:          uuid.New(),
 					PatientID:   patientID,
-					DateOfBirth: "15-03-1990",
+					DateOfBirth: mustParseDate("15-03-1990"),
 					RecordType:  tc.recordType,
 					Status:      domain.TransactionStatusSuccess,
 					APIResponse: json.RawMessage(`{"message": "Transaction success"}`),
@@ -615,7 +858,7 @@ func TestPatientService_PayTransaction_RecordTypeValidation(t *testing.T) {
 			}
 
 			// Execute
-			result, err := service.PayTransaction(request)
+			result, err := service.PayTransaction(context.Background(), request)
 
 			// Assertions
 			assert.NoError(t, err)
@@ -646,7 +889,7 @@ func TestPatientService_PayTransaction_RandomBehavior(t *testing.T) {
 	patientID := patient.ID
 	request := domain.PayTransactionRequest{
 		PatientID:   patientID,
-		DateOfBirth: "15-03-1990",
+		DateOfBirth: mustParseDate("15-03-1990"),
 		RecordType:  "NEW",
 	}
 
@@ -658,13 +901,13 @@ func TestPatientService_PayTransaction_RandomBehavior(t *testing.T) {
 		// Setup new mocks for each iteration
 		mockPatientRepo := &MockPatientRepository{}
 		mockTransactionRepo := &MockTransactionRepository{}
-		service := NewPatientService(cfg, mockPatientRepo, mockTransactionRepo)
+		service := NewPatientService(cfg, mockPatientRepo, mockTransactionRepo, WithProvider(provider.NewSimulatedProvider()))
 
 		// Create a transaction that will be returned (we can't predict success/failure due to randomness)
 		expectedTransaction := &domain.Transaction{
 			ID:          uuid.New(),
 			PatientID:   patientID,
-			DateOfBirth: "15-03-1990",
+			DateOfBirth: mustParseDate("15-03-1990"),
 			RecordType:  "NEW",
 			Status:      domain.TransactionStatusSuccess, // We'll use success, but real result will vary
 			APIResponse: json.RawMessage(`{"message": "Transaction success"}`),
@@ -673,7 +916,7 @@ func TestPatientService_PayTransaction_RandomBehavior(t *testing.T) {
 		mockPatientRepo.On("GetPatient", patientID.String()).Return(patient, nil)
 		mockTransactionRepo.On("CreateTransaction", mock.AnythingOfType("domain.Transaction")).Return(expectedTransaction, nil)
 
-		result, err := service.PayTransaction(request)
+		result, err := service.PayTransaction(context.Background(), request)
 
 		assert.NoError(t, err)
 		assert.NotNil(t, result)
@@ -693,3 +936,1214 @@ func TestPatientService_PayTransaction_RandomBehavior(t *testing.T) {
 	// This is a probabilistic test, so there's a tiny chance it could fail
 	assert.True(t, successCount > 0 || failCount > 0, "Should have at least some results")
 }
+
+func TestPatientService_SubmitTransaction_EnqueuesPendingTransaction(t *testing.T) {
+	// Setup
+	cfg := createTestConfig()
+	mockPatientRepo := &MockPatientRepository{}
+	mockTransactionRepo := &MockTransactionRepository{}
+	mockProvider := &MockPatientProvider{}
+	mockQueue := &MockJobQueue{}
+	service := NewPatientService(cfg, mockPatientRepo, mockTransactionRepo, WithProvider(mockProvider), WithJobQueue(mockQueue))
+
+	patient := createTestPatient()
+	request := domain.PayTransactionRequest{
+		PatientID:   patient.ID,
+		DateOfBirth: mustParseDate("15-03-1990"),
+		RecordType:  "NEW",
+	}
+
+	// Mock expectations
+	var created domain.Transaction
+	mockPatientRepo.On("GetPatient", patient.ID.String()).Return(patient, nil)
+	mockTransactionRepo.On("CreateTransaction", mock.MatchedBy(func(t domain.Transaction) bool {
+		created = t
+		return t.Status == domain.TransactionStatusPending
+	})).Return(&domain.Transaction{Status: domain.TransactionStatusPending}, nil)
+	mockQueue.On("Enqueue", mock.MatchedBy(func(job domain.Job) bool {
+		return job.Type == domain.JobTypeProcessTransaction && job.TransactionID == created.ID
+	})).Return(nil)
+
+	// Execute
+	result, err := service.SubmitTransaction(context.Background(), request)
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, domain.TransactionStatusPending, result.Status)
+	mockQueue.AssertExpectations(t)
+	mockProvider.AssertNotCalled(t, "SubmitPatient", mock.Anything)
+}
+
+func TestPatientService_SubmitTransaction_RejectsInvalidImmediately(t *testing.T) {
+	// Setup
+	cfg := createTestConfig()
+	mockPatientRepo := &MockPatientRepository{}
+	mockTransactionRepo := &MockTransactionRepository{}
+	mockQueue := &MockJobQueue{}
+	service := NewPatientService(cfg, mockPatientRepo, mockTransactionRepo, WithProvider(provider.NewSimulatedProvider()), WithJobQueue(mockQueue))
+
+	patient := createTestPatient()
+	request := domain.PayTransactionRequest{
+		PatientID:   patient.ID,
+		DateOfBirth: mustParseDate("15-03-1990"),
+		RecordType:  "OLD",
+	}
+
+	// Mock expectations
+	mockPatientRepo.On("GetPatient", patient.ID.String()).Return(patient, nil)
+	mockTransactionRepo.On("CreateTransaction", mock.MatchedBy(func(t domain.Transaction) bool {
+		return t.Status == domain.TransactionStatusFailed
+	})).Return(&domain.Transaction{Status: domain.TransactionStatusFailed}, nil)
+
+	// Execute
+	result, err := service.SubmitTransaction(context.Background(), request)
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, domain.TransactionStatusFailed, result.Status)
+	mockQueue.AssertNotCalled(t, "Enqueue", mock.Anything)
+}
+
+func TestPatientService_SubmitTransaction_WithoutQueue(t *testing.T) {
+	service := NewPatientService(createTestConfig(), &MockPatientRepository{}, &MockTransactionRepository{})
+
+	result, err := service.SubmitTransaction(context.Background(), domain.PayTransactionRequest{})
+
+	assert.EqualError(t, err, "job queue is not configured")
+	assert.Nil(t, result)
+}
+
+func TestPatientService_ProcessTransaction(t *testing.T) {
+	// Setup
+	cfg := createTestConfig()
+	mockPatientRepo := &MockPatientRepository{}
+	mockTransactionRepo := &MockTransactionRepository{}
+	mockProvider := &MockPatientProvider{}
+	recorder := metrics.NewMemoryRecorder()
+	service := NewPatientService(cfg, mockPatientRepo, mockTransactionRepo, WithProvider(mockProvider), WithMetrics(recorder))
+
+	patient := createTestPatient()
+	pending := &domain.Transaction{
+		ID:          uuid.New(),
+		PatientID:   patient.ID,
+		Status:      domain.TransactionStatusPending,
+		RecordType:  "NEW",
+		DateOfBirth: mustParseDate("15-03-1990"),
+	}
+
+	// Mock expectations
+	mockTransactionRepo.On("GetTransaction", pending.ID.String()).Return(pending, nil)
+	mockTransactionRepo.On("ClaimPending", pending.ID.String()).Return(true, nil)
+	mockPatientRepo.On("GetPatient", patient.ID.String()).Return(patient, nil)
+	mockProvider.On("SubmitPatient", mock.MatchedBy(func(req domain.SubmitPatientRequest) bool {
+		return req.Patient.ID == patient.ID.String() && req.Age >= 18
+	})).Return(&domain.ProviderResponse{Success: true, StatusCode: 200, Body: json.RawMessage(`{"message":"Transaction success"}`)}, nil)
+	mockTransactionRepo.On("UpdateTransaction", mock.MatchedBy(func(t domain.Transaction) bool {
+		return t.ID == pending.ID && t.Status == domain.TransactionStatusSuccess
+	})).Return(&domain.Transaction{ID: pending.ID, Status: domain.TransactionStatusSuccess, RecordType: "NEW"}, nil)
+
+	// Execute
+	result, err := service.ProcessTransaction(context.Background(), pending.ID)
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, domain.TransactionStatusSuccess, result.Status)
+	assert.Equal(t, float64(1), recorder.Counter(metrics.TransactionOutcome, metrics.Dimensions{"status": "success", "record_type": "NEW"}))
+	mockTransactionRepo.AssertExpectations(t)
+}
+
+func TestPatientService_ProcessTransaction_AlreadyProcessed(t *testing.T) {
+	// Setup
+	mockPatientRepo := &MockPatientRepository{}
+	mockTransactionRepo := &MockTransactionRepository{}
+	mockProvider := &MockPatientProvider{}
+	service := NewPatientService(createTestConfig(), mockPatientRepo, mockTransactionRepo, WithProvider(mockProvider))
+
+	done := &domain.Transaction{ID: uuid.New(), Status: domain.TransactionStatusSuccess}
+	mockTransactionRepo.On("GetTransaction", done.ID.String()).Return(done, nil)
+
+	// Execute
+	result, err := service.ProcessTransaction(context.Background(), done.ID)
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Same(t, done, result)
+	mockProvider.AssertNotCalled(t, "SubmitPatient", mock.Anything)
+	mockTransactionRepo.AssertNotCalled(t, "UpdateTransaction", mock.Anything)
+}
+
+func TestPatientService_ProcessTransaction_ClaimedByAnotherDelivery(t *testing.T) {
+	// Setup
+	mockPatientRepo := &MockPatientRepository{}
+	mockTransactionRepo := &MockTransactionRepository{}
+	mockProvider := &MockPatientProvider{}
+	service := NewPatientService(createTestConfig(), mockPatientRepo, mockTransactionRepo, WithProvider(mockProvider))
+
+	pending := &domain.Transaction{ID: uuid.New(), Status: domain.TransactionStatusPending}
+	mockTransactionRepo.On("GetTransaction", pending.ID.String()).Return(pending, nil)
+	mockTransactionRepo.On("ClaimPending", pending.ID.String()).Return(false, nil)
+
+	// Execute
+	result, err := service.ProcessTransaction(context.Background(), pending.ID)
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Same(t, pending, result)
+	mockProvider.AssertNotCalled(t, "SubmitPatient", mock.Anything)
+	mockTransactionRepo.AssertNotCalled(t, "UpdateTransaction", mock.Anything)
+}
+
+func TestPatientService_ProcessTransaction_ProviderErrorSchedulesRetry(t *testing.T) {
+	// Setup
+	mockPatientRepo := &MockPatientRepository{}
+	mockTransactionRepo := &MockTransactionRepository{}
+	mockProvider := &MockPatientProvider{}
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	service := NewPatientService(createRetryConfig(), mockPatientRepo, mockTransactionRepo, WithProvider(mockProvider), WithClock(fixedClock(now)))
+
+	patient := createTestPatient()
+	pending := &domain.Transaction{ID: uuid.New(), PatientID: patient.ID, Status: domain.TransactionStatusPending, RecordType: "NEW", DateOfBirth: mustParseDate("15-03-1990")}
+	mockTransactionRepo.On("GetTransaction", pending.ID.String()).Return(pending, nil)
+	mockTransactionRepo.On("ClaimPending", pending.ID.String()).Return(true, nil)
+	mockPatientRepo.On("GetPatient", patient.ID.String()).Return(patient, nil)
+	mockProvider.On("SubmitPatient", mock.Anything).Return(nil, errors.New("provider unreachable"))
+	mockTransactionRepo.On("UpdateTransaction", mock.MatchedBy(func(t domain.Transaction) bool {
+		return t.Status == domain.TransactionStatusFailed && t.FailureKind == domain.FailureKindTransient &&
+			t.Attempts == 1 && t.NextRetryAt != nil && t.NextRetryAt.Equal(now.Add(time.Minute))
+	})).Return(&domain.Transaction{ID: pending.ID, Status: domain.TransactionStatusFailed, FailureKind: domain.FailureKindTransient, RecordType: "NEW"}, nil)
+
+	// Execute
+	result, err := service.ProcessTransaction(context.Background(), pending.ID)
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, domain.FailureKindTransient, result.FailureKind)
+	mockTransactionRepo.AssertExpectations(t)
+}
+
+func TestPatientService_SubmitTransaction_EnqueueFailureSchedulesRetry(t *testing.T) {
+	// Setup
+	mockPatientRepo := &MockPatientRepository{}
+	mockTransactionRepo := &MockTransactionRepository{}
+	mockQueue := &MockJobQueue{}
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	service := NewPatientService(createRetryConfig(), mockPatientRepo, mockTransactionRepo, WithJobQueue(mockQueue), WithClock(fixedClock(now)))
+
+	patient := createTestPatient()
+	request := domain.PayTransactionRequest{PatientID: patient.ID, DateOfBirth: mustParseDate("15-03-1990"), RecordType: "NEW"}
+	mockPatientRepo.On("GetPatient", patient.ID.String()).Return(patient, nil)
+	mockTransactionRepo.On("CreateTransaction", mock.Anything).Return(&domain.Transaction{ID: uuid.New(), Status: domain.TransactionStatusPending}, nil)
+	mockQueue.On("Enqueue", mock.Anything).Return(errors.New("queue unavailable"))
+	mockTransactionRepo.On("UpdateTransaction", mock.MatchedBy(func(t domain.Transaction) bool {
+		return t.Status == domain.TransactionStatusFailed && t.FailureKind == domain.FailureKindTransient &&
+			t.Attempts == 0 && t.NextRetryAt != nil && t.NextRetryAt.Equal(now.Add(time.Minute))
+	})).Return(&domain.Transaction{Status: domain.TransactionStatusFailed, FailureKind: domain.FailureKindTransient, NextRetryAt: &now}, nil)
+
+	// Execute
+	result, err := service.SubmitTransaction(context.Background(), request)
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, domain.TransactionStatusFailed, result.Status)
+	assert.NotNil(t, result.NextRetryAt)
+	mockTransactionRepo.AssertExpectations(t)
+}
+
+func createRetryConfig() *config.Config {
+	cfg := createTestConfig()
+	cfg.Retry = config.RetryConfig{
+		MaxAttempts: 3,
+		BaseDelay:   time.Minute,
+		MaxDelay:    90 * time.Second,
+		BatchSize:   10,
+		Lease:       5 * time.Minute,
+	}
+	return cfg
+}
+
+func TestPatientService_PayTransaction_SchedulesRetryOfTransientFailure(t *testing.T) {
+	testCases := []struct {
+		name                string
+		response            *domain.ProviderResponse
+		expectedFailureKind domain.FailureKind
+		expectRetry         bool
+	}{
+		{
+			name:                "Transient failure",
+			response:            &domain.ProviderResponse{Success: false, StatusCode: 503, Body: json.RawMessage(`{}`), Retryable: true},
+			expectedFailureKind: domain.FailureKindTransient,
+			expectRetry:         true,
+		},
+		{
+			name:                "Rejected by provider",
+			response:            &domain.ProviderResponse{Success: false, StatusCode: 422, Body: json.RawMessage(`{}`)},
+			expectedFailureKind: domain.FailureKindRejected,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Setup
+			mockPatientRepo := &MockPatientRepository{}
+			mockTransactionRepo := &MockTransactionRepository{}
+			mockProvider := &MockPatientProvider{}
+			service := NewPatientService(createRetryConfig(), mockPatientRepo, mockTransactionRepo, WithProvider(mockProvider))
+
+			patient := createTestPatient()
+			mockPatientRepo.On("GetPatient", patient.ID.String()).Return(patient, nil)
+			mockProvider.On("SubmitPatient", mock.Anything).Return(tc.response, nil)
+
+			var saved domain.Transaction
+			mockTransactionRepo.On("CreateTransaction", mock.MatchedBy(func(t domain.Transaction) bool {
+				saved = t
+				return true
+			})).Return(&domain.Transaction{Status: domain.TransactionStatusFailed}, nil)
+
+			// Execute
+			_, err := service.PayTransaction(context.Background(), domain.PayTransactionRequest{
+				PatientID:   patient.ID,
+				DateOfBirth: mustParseDate("15-03-1990"),
+				RecordType:  "NEW",
+			})
+
+			// Assertions
+			assert.NoError(t, err)
+			assert.Equal(t, domain.TransactionStatusFailed, saved.Status)
+			assert.Equal(t, tc.expectedFailureKind, saved.FailureKind)
+			assert.Equal(t, 1, saved.Attempts)
+			if tc.expectRetry {
+				assert.WithinDuration(t, time.Now().Add(time.Minute), *saved.NextRetryAt, 5*time.Second)
+			} else {
+				assert.Nil(t, saved.NextRetryAt)
+			}
+		})
+	}
+}
+
+func TestPatientService_PayTransaction_ValidationFailureIsRejected(t *testing.T) {
+	// Setup
+	mockPatientRepo := &MockPatientRepository{}
+	mockTransactionRepo := &MockTransactionRepository{}
+	service := NewPatientService(createRetryConfig(), mockPatientRepo, mockTransactionRepo, WithProvider(provider.NewSimulatedProvider()))
+
+	patient := createTestPatient()
+	mockPatientRepo.On("GetPatient", patient.ID.String()).Return(patient, nil)
+	mockTransactionRepo.On("CreateTransaction", mock.MatchedBy(func(t domain.Transaction) bool {
+		return t.FailureKind == domain.FailureKindRejected && t.Attempts == 0 && t.NextRetryAt == nil
+	})).Return(&domain.Transaction{Status: domain.TransactionStatusFailed}, nil)
+
+	// Execute
+	_, err := service.PayTransaction(context.Background(), domain.PayTransactionRequest{
+		PatientID:   patient.ID,
+		DateOfBirth: mustParseDate("15-03-1990"),
+		RecordType:  "OLD",
+	})
+
+	// Assertions
+	assert.NoError(t, err)
+	mockTransactionRepo.AssertExpectations(t)
+}
+
+func TestPatientService_ScheduleRetry_Backoff(t *testing.T) {
+	service := NewPatientService(createRetryConfig(), &MockPatientRepository{}, &MockTransactionRepository{})
+
+	testCases := []struct {
+		attempts      int
+		expectedDelay time.Duration
+		exhausted     bool
+	}{
+		{attempts: 1, expectedDelay: time.Minute},
+		// doubled to 2m, capped by MaxDelay
+		{attempts: 2, expectedDelay: 90 * time.Second},
+		{attempts: 3, exhausted: true},
+	}
+
+	for _, tc := range testCases {
+		transaction := &domain.Transaction{Attempts: tc.attempts}
+		service.scheduleRetry(transaction)
+
+		assert.Equal(t, domain.FailureKindTransient, transaction.FailureKind)
+		if tc.exhausted {
+			assert.Nil(t, transaction.NextRetryAt)
+			continue
+		}
+		assert.WithinDuration(t, time.Now().Add(tc.expectedDelay), *transaction.NextRetryAt, 5*time.Second)
+	}
+}
+
+func TestPatientService_RetryFailedTransactions(t *testing.T) {
+	// Setup
+	mockPatientRepo := &MockPatientRepository{}
+	mockTransactionRepo := &MockTransactionRepository{}
+	mockProvider := &MockPatientProvider{}
+	service := NewPatientService(createRetryConfig(), mockPatientRepo, mockTransactionRepo, WithProvider(mockProvider))
+
+	patient := createTestPatient()
+	due := time.Now().Add(-time.Minute)
+	succeeds := domain.Transaction{ID: uuid.New(), PatientID: patient.ID, Status: domain.TransactionStatusFailed, FailureKind: domain.FailureKindTransient, RecordType: "NEW", DateOfBirth: mustParseDate("15-03-1990"), Attempts: 1, NextRetryAt: &due}
+	exhausts := domain.Transaction{ID: uuid.New(), PatientID: patient.ID, Status: domain.TransactionStatusFailed, FailureKind: domain.FailureKindTransient, RecordType: "NEW", DateOfBirth: mustParseDate("20-01-1985"), Attempts: 2, NextRetryAt: &due}
+	taken := domain.Transaction{ID: uuid.New(), PatientID: patient.ID, Status: domain.TransactionStatusFailed, FailureKind: domain.FailureKindTransient, RecordType: "NEW", DateOfBirth: mustParseDate("15-03-1990"), Attempts: 1, NextRetryAt: &due}
+
+	// Mock expectations
+	mockTransactionRepo.On("ListRetryableTransactions", 10).Return([]domain.Transaction{succeeds, exhausts, taken}, nil)
+	mockTransactionRepo.On("ClaimRetry", succeeds.ID.String(), 1).Return(true, nil)
+	mockTransactionRepo.On("ClaimRetry", exhausts.ID.String(), 2).Return(true, nil)
+	mockTransactionRepo.On("ClaimRetry", taken.ID.String(), 1).Return(false, nil)
+	mockPatientRepo.On("GetPatient", patient.ID.String()).Return(patient, nil)
+	mockProvider.On("SubmitPatient", mock.MatchedBy(func(req domain.SubmitPatientRequest) bool {
+		return req.Age < 40
+	})).Return(&domain.ProviderResponse{Success: true, StatusCode: 200, Body: json.RawMessage(`{"message":"Transaction success"}`)}, nil)
+	mockProvider.On("SubmitPatient", mock.MatchedBy(func(req domain.SubmitPatientRequest) bool {
+		return req.Age >= 40
+	})).Return(&domain.ProviderResponse{Success: false, StatusCode: 503, Body: json.RawMessage(`{}`), Retryable: true}, nil)
+	mockTransactionRepo.On("UpdateTransaction", mock.MatchedBy(func(t domain.Transaction) bool {
+		return t.ID == succeeds.ID && t.Status == domain.TransactionStatusSuccess && t.Attempts == 2 && t.FailureKind == "" && t.NextRetryAt == nil
+	})).Return(&succeeds, nil)
+	mockTransactionRepo.On("UpdateTransaction", mock.MatchedBy(func(t domain.Transaction) bool {
+		return t.ID == exhausts.ID && t.Status == domain.TransactionStatusFailed && t.Attempts == 3 && t.NextRetryAt == nil
+	})).Return(&exhausts, nil)
+
+	// Execute
+	result, err := service.RetryFailedTransactions(context.Background())
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, &domain.RetryResult{Retried: 2, Succeeded: 1, Exhausted: 1}, result)
+	mockTransactionRepo.AssertExpectations(t)
+	mockProvider.AssertNumberOfCalls(t, "SubmitPatient", 2)
+}
+
+func TestPatientService_RetryFailedTransactions_ProviderErrorReschedules(t *testing.T) {
+	// Setup
+	mockPatientRepo := &MockPatientRepository{}
+	mockTransactionRepo := &MockTransactionRepository{}
+	mockProvider := &MockPatientProvider{}
+	service := NewPatientService(createRetryConfig(), mockPatientRepo, mockTransactionRepo, WithProvider(mockProvider))
+
+	patient := createTestPatient()
+	due := time.Now().Add(-time.Minute)
+	transaction := domain.Transaction{ID: uuid.New(), PatientID: patient.ID, Status: domain.TransactionStatusFailed, FailureKind: domain.FailureKindTransient, RecordType: "NEW", DateOfBirth: mustParseDate("15-03-1990"), Attempts: 1, NextRetryAt: &due}
+
+	mockTransactionRepo.On("ListRetryableTransactions", 10).Return([]domain.Transaction{transaction}, nil)
+	mockTransactionRepo.On("ClaimRetry", transaction.ID.String(), 1).Return(true, nil)
+	mockPatientRepo.On("GetPatient", patient.ID.String()).Return(patient, nil)
+	mockProvider.On("SubmitPatient", mock.Anything).Return(nil, errors.New("provider unreachable"))
+	mockTransactionRepo.On("UpdateTransaction", mock.MatchedBy(func(t domain.Transaction) bool {
+		return t.Attempts == 2 && t.FailureKind == domain.FailureKindTransient && t.NextRetryAt != nil && t.NextRetryAt.After(time.Now())
+	})).Return(&transaction, nil)
+
+	// Execute
+	result, err := service.RetryFailedTransactions(context.Background())
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, &domain.RetryResult{Retried: 1, Failed: 1}, result)
+	mockTransactionRepo.AssertExpectations(t)
+}
+
+func TestPatientService_PayTransaction_RecordsAttempts(t *testing.T) {
+	testCases := []struct {
+		name          string
+		response      *domain.ProviderResponse
+		providerError error
+		expectedClass domain.AttemptErrorClass
+	}{
+		{
+			name:          "Success",
+			response:      &domain.ProviderResponse{Success: true, StatusCode: 200, Body: json.RawMessage(`{"message":"Transaction success"}`), Headers: map[string]string{"X-Request-Id": "req-1"}},
+			expectedClass: domain.AttemptErrorNone,
+		},
+		{
+			name:          "Rejected",
+			response:      &domain.ProviderResponse{Success: false, StatusCode: 422, Body: json.RawMessage(`{"error":"invalid"}`)},
+			expectedClass: domain.AttemptErrorRejected,
+		},
+		{
+			name:          "Transient",
+			response:      &domain.ProviderResponse{Success: false, StatusCode: 503, Body: json.RawMessage(`{}`), Retryable: true},
+			expectedClass: domain.AttemptErrorTransient,
+		},
+		{
+			name:          "Timeout",
+			providerError: context.DeadlineExceeded,
+			expectedClass: domain.AttemptErrorTimeout,
+		},
+		{
+			name:          "Network",
+			providerError: errors.New("connection refused"),
+			expectedClass: domain.AttemptErrorNetwork,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Setup
+			mockPatientRepo := &MockPatientRepository{}
+			mockTransactionRepo := &MockTransactionRepository{}
+			mockProvider := &MockPatientProvider{}
+			mockAttemptRepo := &MockTransactionAttemptRepository{}
+			// every field is sent, so the attempt would hold all of them unless redacted
+			service := NewPatientService(createRetryConfig(), mockPatientRepo, mockTransactionRepo,
+				WithProvider(mockProvider), WithAttemptRepository(mockAttemptRepo),
+				WithProviderPatientPolicy(domain.ProviderPatientPolicy{Fields: domain.ProviderPatientFields()}))
+
+			patient := createTestPatient()
+			mockPatientRepo.On("GetPatient", patient.ID.String()).Return(patient, nil)
+			if tc.providerError != nil {
+				mockProvider.On("SubmitPatient", mock.Anything).Return(nil, tc.providerError)
+			} else {
+				mockProvider.On("SubmitPatient", mock.Anything).Return(tc.response, nil)
+			}
+			mockTransactionRepo.On("CreateTransaction", mock.Anything).Return(&domain.Transaction{}, nil)
+			// a failure to record the attempt does not fail the transaction
+			mockAttemptRepo.On("CreateTransactionAttempt", mock.Anything).Return(errors.New("database unavailable"))
+
+			// Execute
+			_, _ = service.PayTransaction(context.Background(), domain.PayTransactionRequest{
+				PatientID:   patient.ID,
+				DateOfBirth: mustParseDate("15-03-1990"),
+				RecordType:  "NEW",
+			})
+
+			// Assertions
+			mockAttemptRepo.AssertNumberOfCalls(t, "CreateTransactionAttempt", 1)
+			attempt := mockAttemptRepo.Calls[0].Arguments.Get(0).(domain.TransactionAttempt)
+			assert.Equal(t, 1, attempt.Attempt)
+			assert.Equal(t, tc.expectedClass, attempt.ErrorClass)
+			assert.Contains(t, string(attempt.Request), `"email":"[REDACTED]"`)
+			assert.NotContains(t, string(attempt.Request), patient.Email)
+			assert.Contains(t, string(attempt.Request), `"name":"[REDACTED]"`)
+			assert.NotContains(t, string(attempt.Request), patient.Name)
+			mockTransactionRepo.AssertNumberOfCalls(t, "CreateTransaction", 1)
+			assert.Equal(t, mockTransactionRepo.Calls[0].Arguments.Get(0).(domain.Transaction).ID, attempt.TransactionID)
+			if tc.providerError != nil {
+				assert.Equal(t, tc.providerError.Error(), attempt.Error)
+				assert.Zero(t, attempt.StatusCode)
+			} else {
+				assert.Equal(t, tc.response.StatusCode, attempt.StatusCode)
+				assert.JSONEq(t, string(tc.response.Body), string(attempt.ResponseBody))
+			}
+		})
+	}
+}
+
+func TestPatientService_PurgeTransactionAttempts(t *testing.T) {
+	// Setup
+	mockAttemptRepo := &MockTransactionAttemptRepository{}
+	cfg := createRetryConfig()
+	cfg.Retry.AttemptRetention = 24 * time.Hour
+	service := NewPatientService(cfg, &MockPatientRepository{}, &MockTransactionRepository{}, WithAttemptRepository(mockAttemptRepo))
+
+	mockAttemptRepo.On("DeleteTransactionAttemptsBefore", mock.MatchedBy(func(before time.Time) bool {
+		return time.Until(before) < -23*time.Hour && time.Until(before) > -25*time.Hour
+	})).Return(int64(3), nil)
+
+	// Execute
+	deleted, err := service.PurgeTransactionAttempts(context.Background())
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), deleted)
+	mockAttemptRepo.AssertExpectations(t)
+
+	// zero retention keeps attempts forever
+	cfg.Retry.AttemptRetention = 0
+	deleted, err = service.PurgeTransactionAttempts(context.Background())
+	assert.NoError(t, err)
+	assert.Zero(t, deleted)
+	mockAttemptRepo.AssertNumberOfCalls(t, "DeleteTransactionAttemptsBefore", 1)
+}
+
+type MockEligibilityRuleSource struct {
+	mock.Mock
+}
+
+func (m *MockEligibilityRuleSource) EligibilityRules(ctx context.Context) ([]rules.Rule, error) {
+	args := m.Called()
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]rules.Rule), args.Error(1)
+}
+
+func TestPatientService_PayTransaction_StoresRejectionReasons(t *testing.T) {
+	// Setup
+	mockPatientRepo := &MockPatientRepository{}
+	mockTransactionRepo := &MockTransactionRepository{}
+	mockProvider := &MockPatientProvider{}
+	service := NewPatientService(createTestConfig(), mockPatientRepo, mockTransactionRepo, WithProvider(mockProvider))
+
+	patient := createTestPatient()
+	patient.DateOfBirth = domain.DateOf(time.Now().AddDate(-10, 0, 0))
+	mockPatientRepo.On("GetPatient", patient.ID.String()).Return(patient, nil)
+	mockTransactionRepo.On("CreateTransaction", mock.Anything).Return(&domain.Transaction{}, nil)
+
+	// Execute
+	_, err := service.PayTransaction(context.Background(), domain.PayTransactionRequest{
+		PatientID:   patient.ID,
+		DateOfBirth: patient.DateOfBirth,
+		RecordType:  "OLD",
+	})
+
+	// Assertions
+	assert.NoError(t, err)
+	saved := mockTransactionRepo.Calls[0].Arguments.Get(0).(domain.Transaction)
+	assert.Equal(t, domain.TransactionStatusFailed, saved.Status)
+	assert.Equal(t, domain.FailureKindRejected, saved.FailureKind)
+	assert.Equal(t, domain.RejectionReasons{
+		{Rule: "minimum-age", Kind: "age", Message: "Patient must be more than 18 years old"},
+		{Rule: "record-type", Kind: "record_type", Message: "Record type must be one of NEW, RENEWAL, TRANSFER, CORRECTION"},
+	}, saved.RejectionReasons)
+	// the first reason keeps the historical error response
+	assert.JSONEq(t, `{"error": "Patient must be more than 18 years old"}`, string(saved.APIResponse))
+	mockProvider.AssertNotCalled(t, "SubmitPatient", mock.Anything)
+}
+
+func TestPatientService_PayTransaction_ConfiguredRules(t *testing.T) {
+	patient := createTestPatient()
+	patient.DateOfBirth = domain.DateOf(time.Now().AddDate(-20, -1, 0))
+	minAge := 21
+
+	testCases := []struct {
+		name      string
+		rules     []rules.Rule
+		submitted bool
+		reason    string
+	}{
+		{
+			name:   "State excluded",
+			rules:  []rules.Rule{{Name: "no-state", Kind: rules.KindState, Values: rules.StringList{patient.State}, Exclude: true}},
+			reason: "no-state",
+		},
+		{
+			name:   "Zip outside prefixes",
+			rules:  []rules.Rule{{Name: "west-coast", Kind: rules.KindZip, Values: rules.StringList{"9"}}},
+			reason: "west-coast",
+		},
+		{
+			name:   "Higher minimum age",
+			rules:  []rules.Rule{{Name: "over-21", Kind: rules.KindAge, MinAge: &minAge}},
+			reason: "over-21",
+		},
+		{
+			name:   "Record types narrowed",
+			rules:  []rules.Rule{{Name: "renewals-only", Kind: rules.KindRecordType, Values: rules.StringList{"RENEWAL"}}},
+			reason: "renewals-only",
+		},
+		{
+			name:      "Empty source applies no rule",
+			rules:     []rules.Rule{},
+			submitted: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Setup
+			mockPatientRepo := &MockPatientRepository{}
+			mockTransactionRepo := &MockTransactionRepository{}
+			mockProvider := &MockPatientProvider{}
+			source := &MockEligibilityRuleSource{}
+			service := NewPatientService(createTestConfig(), mockPatientRepo, mockTransactionRepo,
+				WithProvider(mockProvider), WithEligibilityRules(source))
+
+			source.On("EligibilityRules").Return(tc.rules, nil)
+			mockPatientRepo.On("GetPatient", patient.ID.String()).Return(patient, nil)
+			mockProvider.On("SubmitPatient", mock.Anything).Return(&domain.ProviderResponse{Success: true, StatusCode: 200, Body: json.RawMessage(`{}`)}, nil)
+			mockTransactionRepo.On("CreateTransaction", mock.Anything).Return(&domain.Transaction{}, nil)
+
+			// Execute
+			_, err := service.PayTransaction(context.Background(), domain.PayTransactionRequest{
+				PatientID:   patient.ID,
+				DateOfBirth: patient.DateOfBirth,
+				RecordType:  "NEW",
+			})
+
+			// Assertions
+			assert.NoError(t, err)
+			saved := mockTransactionRepo.Calls[0].Arguments.Get(0).(domain.Transaction)
+			if tc.submitted {
+				assert.Equal(t, domain.TransactionStatusSuccess, saved.Status)
+				assert.Empty(t, saved.RejectionReasons)
+				return
+			}
+			assert.Equal(t, domain.TransactionStatusFailed, saved.Status)
+			if assert.Len(t, saved.RejectionReasons, 1) {
+				assert.Equal(t, tc.reason, saved.RejectionReasons[0].Rule)
+			}
+			mockProvider.AssertNotCalled(t, "SubmitPatient", mock.Anything)
+		})
+	}
+}
+
+func TestPatientService_PayTransaction_EmptyRuleSourceSkipsDefaults(t *testing.T) {
+	// Setup
+	mockPatientRepo := &MockPatientRepository{}
+	mockTransactionRepo := &MockTransactionRepository{}
+	mockProvider := &MockPatientProvider{}
+	service := NewPatientService(createTestConfig(), mockPatientRepo, mockTransactionRepo,
+		WithProvider(mockProvider), WithEligibilityRules(rules.Static(nil)))
+
+	patient := createTestPatient()
+	mockPatientRepo.On("GetPatient", patient.ID.String()).Return(patient, nil)
+	mockProvider.On("SubmitPatient", mock.Anything).Return(&domain.ProviderResponse{Success: true, StatusCode: 200, Body: json.RawMessage(`{}`)}, nil)
+	mockTransactionRepo.On("CreateTransaction", mock.Anything).Return(&domain.Transaction{}, nil)
+
+	// Execute
+	_, err := service.PayTransaction(context.Background(), domain.PayTransactionRequest{
+		PatientID:   patient.ID,
+		DateOfBirth: domain.DateOf(time.Now().AddDate(-10, 0, 0)),
+		RecordType:  "NEW",
+	})
+
+	// Assertions
+	assert.NoError(t, err)
+	saved := mockTransactionRepo.Calls[0].Arguments.Get(0).(domain.Transaction)
+	assert.Equal(t, domain.TransactionStatusSuccess, saved.Status)
+	mockProvider.AssertExpectations(t)
+}
+
+func TestPatientService_PayTransaction_RuleSourceError(t *testing.T) {
+	// Setup
+	mockPatientRepo := &MockPatientRepository{}
+	mockTransactionRepo := &MockTransactionRepository{}
+	source := &MockEligibilityRuleSource{}
+	service := NewPatientService(createTestConfig(), mockPatientRepo, mockTransactionRepo, WithEligibilityRules(source))
+
+	patient := createTestPatient()
+	mockPatientRepo.On("GetPatient", patient.ID.String()).Return(patient, nil)
+	source.On("EligibilityRules").Return(nil, errors.New("database unavailable"))
+
+	// Execute
+	result, err := service.PayTransaction(context.Background(), domain.PayTransactionRequest{
+		PatientID:   patient.ID,
+		DateOfBirth: mustParseDate("15-03-1990"),
+		RecordType:  "NEW",
+	})
+
+	// Assertions
+	assert.EqualError(t, err, "database unavailable")
+	assert.Nil(t, result)
+	mockTransactionRepo.AssertNotCalled(t, "CreateTransaction", mock.Anything)
+}
+
+type fixedClock time.Time
+
+func (c fixedClock) Now() time.Time {
+	return time.Time(c)
+}
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func TestAgeOn(t *testing.T) {
+	testCases := []struct {
+		name        string
+		dateOfBirth time.Time
+		today       time.Time
+		expected    int
+	}{
+		{name: "Day before 18th birthday", dateOfBirth: date(2006, time.June, 15), today: date(2024, time.June, 14), expected: 17},
+		{name: "On 18th birthday", dateOfBirth: date(2006, time.June, 15), today: date(2024, time.June, 15), expected: 18},
+		{name: "Month before birthday", dateOfBirth: date(2006, time.June, 15), today: date(2024, time.May, 20), expected: 17},
+		{name: "Month after birthday", dateOfBirth: date(2006, time.June, 15), today: date(2024, time.July, 1), expected: 18},
+		{name: "Born on new year's day", dateOfBirth: date(2006, time.January, 1), today: date(2023, time.December, 31), expected: 17},
+		{name: "Born on new year's eve", dateOfBirth: date(2005, time.December, 31), today: date(2023, time.December, 31), expected: 18},
+		// 18 * 365 days fall short of 18 years because of leap days
+		{name: "Leap days counted", dateOfBirth: date(2004, time.March, 1), today: date(2004, time.March, 1).AddDate(0, 0, 18*365), expected: 17},
+		{name: "Feb 29 birthday in common year, Feb 28", dateOfBirth: date(2004, time.February, 29), today: date(2022, time.February, 28), expected: 17},
+		{name: "Feb 29 birthday in common year, Mar 1", dateOfBirth: date(2004, time.February, 29), today: date(2022, time.March, 1), expected: 18},
+		{name: "Feb 29 birthday in leap year", dateOfBirth: date(2004, time.February, 29), today: date(2024, time.February, 29), expected: 20},
+		{name: "Born today", dateOfBirth: date(2024, time.June, 15), today: date(2024, time.June, 15), expected: 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, ageOn(tc.dateOfBirth, tc.today))
+		})
+	}
+}
+
+func TestPatientService_PayTransaction_AgeInTimeZone(t *testing.T) {
+	sydney, err := time.LoadLocation("Australia/Sydney")
+	assert.NoError(t, err)
+	losAngeles, err := time.LoadLocation("America/Los_Angeles")
+	assert.NoError(t, err)
+
+	// 14:00 UTC on June 14th is already June 15th in Sydney but still June 14th in Los Angeles
+	now := time.Date(2024, time.June, 14, 14, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name     string
+		location *time.Location
+		status   domain.TransactionStatus
+	}{
+		{name: "UTC", location: time.UTC, status: domain.TransactionStatusFailed},
+		{name: "Birthday already reached", location: sydney, status: domain.TransactionStatusSuccess},
+		{name: "Birthday not reached", location: losAngeles, status: domain.TransactionStatusFailed},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Setup
+			mockPatientRepo := &MockPatientRepository{}
+			mockTransactionRepo := &MockTransactionRepository{}
+			mockProvider := &MockPatientProvider{}
+			service := NewPatientService(createTestConfig(), mockPatientRepo, mockTransactionRepo,
+				WithProvider(mockProvider), WithClock(fixedClock(now)), WithTimeZone(tc.location))
+
+			patient := createTestPatient()
+			patient.DateOfBirth = mustParseDate("15-06-2006")
+			mockPatientRepo.On("GetPatient", patient.ID.String()).Return(patient, nil)
+			mockProvider.On("SubmitPatient", mock.MatchedBy(func(req domain.SubmitPatientRequest) bool {
+				return req.Age == 18
+			})).Return(&domain.ProviderResponse{Success: true, StatusCode: 200, Body: json.RawMessage(`{}`)}, nil)
+			mockTransactionRepo.On("CreateTransaction", mock.Anything).Return(&domain.Transaction{}, nil)
+
+			// Execute
+			_, err := service.PayTransaction(context.Background(), domain.PayTransactionRequest{
+				PatientID:   patient.ID,
+				DateOfBirth: mustParseDate("15-06-2006"),
+				RecordType:  "NEW",
+			})
+
+			// Assertions
+			assert.NoError(t, err)
+			saved := mockTransactionRepo.Calls[0].Arguments.Get(0).(domain.Transaction)
+			assert.Equal(t, tc.status, saved.Status)
+		})
+	}
+}
+
+func TestPatientService_ScheduleRetry_UsesClock(t *testing.T) {
+	now := time.Date(2024, time.June, 14, 14, 0, 0, 0, time.UTC)
+	service := NewPatientService(createRetryConfig(), &MockPatientRepository{}, &MockTransactionRepository{}, WithClock(fixedClock(now)))
+
+	transaction := &domain.Transaction{Attempts: 1}
+	service.scheduleRetry(transaction)
+
+	if assert.NotNil(t, transaction.NextRetryAt) {
+		assert.Equal(t, now.Add(time.Minute), *transaction.NextRetryAt)
+	}
+}
+
+func TestPatientService_PayTransaction_RecordTypes(t *testing.T) {
+	patient := createTestPatient()
+	newRecord := &domain.Transaction{ID: uuid.New(), PatientID: patient.ID, Status: domain.TransactionStatusSuccess, RecordType: "NEW"}
+	failedRecord := &domain.Transaction{ID: uuid.New(), PatientID: patient.ID, Status: domain.TransactionStatusFailed, RecordType: "NEW"}
+	otherPatientRecord := &domain.Transaction{ID: uuid.New(), PatientID: uuid.New(), Status: domain.TransactionStatusSuccess, RecordType: "NEW"}
+	correctionRecord := &domain.Transaction{ID: uuid.New(), PatientID: patient.ID, Status: domain.TransactionStatusSuccess, RecordType: "CORRECTION"}
+	missingID := uuid.New()
+
+	testCases := []struct {
+		name     string
+		request  domain.PayTransactionRequest
+		reason   string
+		message  string
+		validate func(t *testing.T, req domain.SubmitPatientRequest)
+	}{
+		{
+			name:    "NEW",
+			request: domain.PayTransactionRequest{RecordType: "NEW"},
+			validate: func(t *testing.T, req domain.SubmitPatientRequest) {
+				assert.Nil(t, req.PreviousTransactionID)
+			},
+		},
+		{
+			name:    "NEW with previous transaction",
+			request: domain.PayTransactionRequest{RecordType: "NEW", PreviousTransactionID: &newRecord.ID},
+			reason:  "record-type-fields",
+			message: "NEW records cannot have a previous transaction",
+		},
+		{
+			name:    "RENEWAL",
+			request: domain.PayTransactionRequest{RecordType: "RENEWAL", PreviousTransactionID: &newRecord.ID},
+			validate: func(t *testing.T, req domain.SubmitPatientRequest) {
+				assert.Equal(t, &newRecord.ID, req.PreviousTransactionID)
+			},
+		},
+		{
+			name:    "RENEWAL without previous transaction",
+			request: domain.PayTransactionRequest{RecordType: "RENEWAL"},
+			reason:  "record-type-fields",
+			message: "RENEWAL records require previous_transaction_id",
+		},
+		{
+			name:    "RENEWAL of a missing transaction",
+			request: domain.PayTransactionRequest{RecordType: "RENEWAL", PreviousTransactionID: &missingID},
+			reason:  "record-type-transition",
+			message: "Previous transaction not found for this patient",
+		},
+		{
+			name:    "RENEWAL of another patient's transaction",
+			request: domain.PayTransactionRequest{RecordType: "RENEWAL", PreviousTransactionID: &otherPatientRecord.ID},
+			reason:  "record-type-transition",
+			message: "Previous transaction not found for this patient",
+		},
+		{
+			name:    "RENEWAL of a failed transaction",
+			request: domain.PayTransactionRequest{RecordType: "RENEWAL", PreviousTransactionID: &failedRecord.ID},
+			reason:  "record-type-transition",
+			message: "Previous transaction was not successful",
+		},
+		{
+			name:    "RENEWAL of a correction",
+			request: domain.PayTransactionRequest{RecordType: "RENEWAL", PreviousTransactionID: &correctionRecord.ID},
+			reason:  "record-type-transition",
+			message: "RENEWAL records cannot follow CORRECTION records",
+		},
+		{
+			name:    "TRANSFER",
+			request: domain.PayTransactionRequest{RecordType: "TRANSFER", PreviousTransactionID: &newRecord.ID, TransferTo: "clinic-42"},
+			validate: func(t *testing.T, req domain.SubmitPatientRequest) {
+				assert.Equal(t, "clinic-42", req.TransferTo)
+				assert.Equal(t, &newRecord.ID, req.PreviousTransactionID)
+			},
+		},
+		{
+			name:    "TRANSFER without destination",
+			request: domain.PayTransactionRequest{RecordType: "TRANSFER", PreviousTransactionID: &newRecord.ID},
+			reason:  "record-type-fields",
+			message: "TRANSFER records require transfer_to",
+		},
+		{
+			name:    "CORRECTION of a correction",
+			request: domain.PayTransactionRequest{RecordType: "CORRECTION", PreviousTransactionID: &correctionRecord.ID, CorrectionReason: "Wrong address"},
+			validate: func(t *testing.T, req domain.SubmitPatientRequest) {
+				assert.Equal(t, "Wrong address", req.CorrectionReason)
+				assert.Empty(t, req.TransferTo)
+			},
+		},
+		{
+			name:    "CORRECTION without reason",
+			request: domain.PayTransactionRequest{RecordType: "CORRECTION", PreviousTransactionID: &newRecord.ID},
+			reason:  "record-type-fields",
+			message: "CORRECTION records require correction_reason",
+		},
+		{
+			name:    "Unknown record type",
+			request: domain.PayTransactionRequest{RecordType: "OLD"},
+			reason:  "record-type",
+			message: "Record type must be one of NEW, RENEWAL, TRANSFER, CORRECTION",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Setup
+			mockPatientRepo := &MockPatientRepository{}
+			mockTransactionRepo := &MockTransactionRepository{}
+			mockProvider := &MockPatientProvider{}
+			service := NewPatientService(createTestConfig(), mockPatientRepo, mockTransactionRepo, WithProvider(mockProvider))
+
+			mockPatientRepo.On("GetPatient", patient.ID.String()).Return(patient, nil)
+			for _, previous := range []*domain.Transaction{newRecord, failedRecord, otherPatientRecord, correctionRecord} {
+				mockTransactionRepo.On("GetTransaction", previous.ID.String()).Return(previous, nil)
+			}
+			mockTransactionRepo.On("GetTransaction", missingID.String()).Return(nil, domain.ErrTransactionNotFound)
+			mockProvider.On("SubmitPatient", mock.Anything).Return(&domain.ProviderResponse{Success: true, StatusCode: 200, Body: json.RawMessage(`{}`)}, nil)
+			mockTransactionRepo.On("CreateTransaction", mock.Anything).Return(&domain.Transaction{}, nil)
+
+			// Execute
+			request := tc.request
+			request.PatientID = patient.ID
+			request.DateOfBirth = mustParseDate("15-03-1990")
+			_, err := service.PayTransaction(context.Background(), request)
+
+			// Assertions
+			assert.NoError(t, err)
+			saved := mockTransactionRepo.Calls[len(mockTransactionRepo.Calls)-1].Arguments.Get(0).(domain.Transaction)
+			if tc.reason == "" {
+				assert.Equal(t, domain.TransactionStatusSuccess, saved.Status)
+				mockProvider.AssertNumberOfCalls(t, "SubmitPatient", 1)
+				tc.validate(t, mockProvider.Calls[0].Arguments.Get(0).(domain.SubmitPatientRequest))
+				return
+			}
+			assert.Equal(t, domain.TransactionStatusFailed, saved.Status)
+			assert.Equal(t, domain.RejectionReasons{{Rule: tc.reason, Kind: "record_type", Message: tc.message}}, saved.RejectionReasons)
+			mockProvider.AssertNotCalled(t, "SubmitPatient", mock.Anything)
+		})
+	}
+}
+
+func TestPatientService_PayTransaction_PreviousTransactionLookupError(t *testing.T) {
+	// Setup
+	mockPatientRepo := &MockPatientRepository{}
+	mockTransactionRepo := &MockTransactionRepository{}
+	service := NewPatientService(createTestConfig(), mockPatientRepo, mockTransactionRepo)
+
+	patient := createTestPatient()
+	previousID := uuid.New()
+	mockPatientRepo.On("GetPatient", patient.ID.String()).Return(patient, nil)
+	mockTransactionRepo.On("GetTransaction", previousID.String()).Return(nil, errors.New("database unavailable"))
+
+	// Execute
+	result, err := service.PayTransaction(context.Background(), domain.PayTransactionRequest{
+		PatientID:             patient.ID,
+		DateOfBirth:           mustParseDate("15-03-1990"),
+		RecordType:            "RENEWAL",
+		PreviousTransactionID: &previousID,
+	})
+
+	// Assertions
+	assert.EqualError(t, err, "database unavailable")
+	assert.Nil(t, result)
+	mockTransactionRepo.AssertNotCalled(t, "CreateTransaction", mock.Anything)
+}
+
+func mustParseDate(s string) domain.Date {
+	d, err := domain.ParseDate(s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func TestPatientService_PayTransaction_VerifiesDateOfBirth(t *testing.T) {
+	testCases := []struct {
+		name            string
+		stored          domain.Date
+		mismatches      int
+		requireDOB      bool
+		claimed         string
+		reason          string
+		countMismatch   bool
+		resetMismatches bool
+	}{
+		{name: "Matching", stored: domain.NewDate(1990, time.March, 15), claimed: "15-03-1990"},
+		{name: "Matching clears earlier mismatches", stored: domain.NewDate(1990, time.March, 15), mismatches: 2, claimed: "15-03-1990", resetMismatches: true},
+		{name: "Mismatch", stored: domain.NewDate(1990, time.March, 15), claimed: "15-03-1980", reason: "date-of-birth-mismatch", countMismatch: true},
+		{name: "Locked after too many mismatches", stored: domain.NewDate(1990, time.March, 15), mismatches: 3, claimed: "15-03-1990", reason: "date-of-birth-locked"},
+		{name: "Not on file", claimed: "15-03-1990"},
+		{name: "Required but not on file", requireDOB: true, claimed: "15-03-1990", reason: "date-of-birth-unknown"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Setup
+			cfg := createTestConfig()
+			cfg.Eligibility.MaxDateOfBirthMismatches = 3
+			cfg.Eligibility.RequireDateOfBirth = tc.requireDOB
+			mockPatientRepo := &MockPatientRepository{}
+			mockTransactionRepo := &MockTransactionRepository{}
+			mockProvider := &MockPatientProvider{}
+			recorder := metrics.NewMemoryRecorder()
+			service := NewPatientService(cfg, mockPatientRepo, mockTransactionRepo, WithProvider(mockProvider), WithMetrics(recorder))
+
+			patient := createTestPatient()
+			patient.DateOfBirth = tc.stored
+			patient.DateOfBirthMismatches = tc.mismatches
+			mockPatientRepo.On("GetPatient", patient.ID.String()).Return(patient, nil)
+			mockPatientRepo.On("RecordDateOfBirthMismatch", patient.ID.String()).Return(1, nil)
+			mockPatientRepo.On("ResetDateOfBirthMismatches", patient.ID.String()).Return(nil)
+			mockProvider.On("SubmitPatient", mock.Anything).Return(&domain.ProviderResponse{Success: true, StatusCode: 200, Body: json.RawMessage(`{}`)}, nil)
+			mockTransactionRepo.On("CreateTransaction", mock.Anything).Return(&domain.Transaction{}, nil)
+
+			// Execute
+			_, err := service.PayTransaction(context.Background(), domain.PayTransactionRequest{
+				PatientID:   patient.ID,
+				DateOfBirth: mustParseDate(tc.claimed),
+				RecordType:  "NEW",
+			})
+
+			// Assertions
+			assert.NoError(t, err)
+			saved := mockTransactionRepo.Calls[0].Arguments.Get(0).(domain.Transaction)
+			assert.Equal(t, mustParseDate(tc.claimed), saved.DateOfBirth)
+			if tc.reason == "" {
+				assert.Equal(t, domain.TransactionStatusSuccess, saved.Status)
+			} else {
+				assert.Equal(t, domain.TransactionStatusFailed, saved.Status)
+				if assert.NotEmpty(t, saved.RejectionReasons) {
+					assert.Equal(t, tc.reason, saved.RejectionReasons[0].Rule)
+					assert.Equal(t, "date_of_birth", saved.RejectionReasons[0].Kind)
+				}
+				mockProvider.AssertNotCalled(t, "SubmitPatient", mock.Anything)
+			}
+			if tc.countMismatch {
+				mockPatientRepo.AssertCalled(t, "RecordDateOfBirthMismatch", patient.ID.String())
+				assert.Equal(t, float64(1), recorder.Counter(metrics.DateOfBirthMismatch, nil))
+			} else {
+				mockPatientRepo.AssertNotCalled(t, "RecordDateOfBirthMismatch", mock.Anything)
+			}
+			if tc.resetMismatches {
+				mockPatientRepo.AssertCalled(t, "ResetDateOfBirthMismatches", patient.ID.String())
+			} else {
+				mockPatientRepo.AssertNotCalled(t, "ResetDateOfBirthMismatches", mock.Anything)
+			}
+		})
+	}
+}
+
+// MockUserRepository mocks the UserRepository interface
+type MockUserRepository struct {
+	mock.Mock
+}
+
+func (m *MockUserRepository) GetUser(ctx context.Context, id string) (*domain.User, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.User), args.Error(1)
+}
+
+func TestPatientService_PayTransaction_MembershipPricing(t *testing.T) {
+	now := time.Date(2024, time.June, 14, 12, 0, 0, 0, time.UTC)
+	lapsed := now.Add(-time.Hour)
+
+	testCases := []struct {
+		name     string
+		userID   string
+		user     *domain.User
+		userErr  error
+		expected domain.Transaction
+		wantErr  bool
+	}{
+		{
+			name:     "Patient without user",
+			expected: domain.Transaction{BaseFeeCents: 5000, FeeCents: 5000, Currency: "AUD"},
+		},
+		{
+			name:     "Basic member",
+			userID:   "user-1",
+			user:     &domain.User{ID: "user-1", MembershipTier: domain.MembershipBasic},
+			expected: domain.Transaction{MembershipTier: domain.MembershipBasic, BaseFeeCents: 5000, DiscountCents: 500, FeeCents: 4500, Currency: "AUD", AppliedBenefit: "basic: 10% discount"},
+		},
+		{
+			name:     "Premium member",
+			userID:   "user-1",
+			user:     &domain.User{ID: "user-1", MembershipTier: domain.MembershipPremium},
+			expected: domain.Transaction{MembershipTier: domain.MembershipPremium, BaseFeeCents: 5000, DiscountCents: 5000, Currency: "AUD", AppliedBenefit: "premium: fee waived"},
+		},
+		{
+			name:     "Lapsed membership",
+			userID:   "user-1",
+			user:     &domain.User{ID: "user-1", MembershipTier: domain.MembershipPremium, MembershipEndsAt: &lapsed},
+			expected: domain.Transaction{BaseFeeCents: 5000, FeeCents: 5000, Currency: "AUD"},
+		},
+		{
+			name:     "User not found",
+			userID:   "user-1",
+			userErr:  domain.ErrUserNotFound,
+			expected: domain.Transaction{BaseFeeCents: 5000, FeeCents: 5000, Currency: "AUD"},
+		},
+		{
+			name:    "User lookup error",
+			userID:  "user-1",
+			userErr: errors.New("connection refused"),
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Setup
+			mockPatientRepo := &MockPatientRepository{}
+			mockTransactionRepo := &MockTransactionRepository{}
+			mockUserRepo := &MockUserRepository{}
+			mockProvider := &MockPatientProvider{}
+			pricer, err := pricing.New(5000, "AUD", nil)
+			assert.NoError(t, err)
+			service := NewPatientService(createTestConfig(), mockPatientRepo, mockTransactionRepo,
+				WithProvider(mockProvider), WithUserRepository(mockUserRepo), WithPricing(pricer), WithClock(fixedClock(now)))
+
+			patient := createTestPatient()
+			patient.UserID = tc.userID
+			mockPatientRepo.On("GetPatient", patient.ID.String()).Return(patient, nil)
+			mockUserRepo.On("GetUser", "user-1").Return(tc.user, tc.userErr)
+			mockProvider.On("SubmitPatient", mock.Anything).Return(&domain.ProviderResponse{Success: true, StatusCode: 200, Body: json.RawMessage(`{}`)}, nil)
+			mockTransactionRepo.On("CreateTransaction", mock.Anything).Return(&domain.Transaction{}, nil)
+
+			// Execute
+			_, err = service.PayTransaction(context.Background(), domain.PayTransactionRequest{
+				PatientID:   patient.ID,
+				DateOfBirth: mustParseDate("01-01-1990"),
+				RecordType:  "NEW",
+			})
+
+			// Assertions
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			saved := mockTransactionRepo.Calls[0].Arguments.Get(0).(domain.Transaction)
+			assert.Equal(t, domain.TransactionStatusSuccess, saved.Status)
+			assert.Equal(t, tc.expected.MembershipTier, saved.MembershipTier)
+			assert.Equal(t, tc.expected.BaseFeeCents, saved.BaseFeeCents)
+			assert.Equal(t, tc.expected.DiscountCents, saved.DiscountCents)
+			assert.Equal(t, tc.expected.FeeCents, saved.FeeCents)
+			assert.Equal(t, tc.expected.Currency, saved.Currency)
+			assert.Equal(t, tc.expected.AppliedBenefit, saved.AppliedBenefit)
+		})
+	}
+}
+
+func TestPatientService_PayTransaction_MembershipRule(t *testing.T) {
+	// Setup
+	mockPatientRepo := &MockPatientRepository{}
+	mockTransactionRepo := &MockTransactionRepository{}
+	mockUserRepo := &MockUserRepository{}
+	members := &MockEligibilityRuleSource{}
+	members.On("EligibilityRules").Return([]rules.Rule{
+		{Name: "members-only", Kind: rules.KindMembership, Values: rules.StringList{"plus", "premium"}},
+	}, nil)
+	pricer, err := pricing.New(5000, "AUD", nil)
+	assert.NoError(t, err)
+	service := NewPatientService(createTestConfig(), mockPatientRepo, mockTransactionRepo,
+		WithUserRepository(mockUserRepo), WithEligibilityRules(members), WithPricing(pricer))
+
+	patient := createTestPatient()
+	patient.UserID = "user-1"
+	mockPatientRepo.On("GetPatient", patient.ID.String()).Return(patient, nil)
+	mockUserRepo.On("GetUser", "user-1").Return(&domain.User{ID: "user-1", MembershipTier: domain.MembershipBasic}, nil)
+	mockTransactionRepo.On("CreateTransaction", mock.Anything).Return(&domain.Transaction{}, nil)
+
+	// Execute
+	_, err = service.PayTransaction(context.Background(), domain.PayTransactionRequest{
+		PatientID:   patient.ID,
+		DateOfBirth: mustParseDate("01-01-1990"),
+		RecordType:  "NEW",
+	})
+
+	// Assertions, rejected transactions are not priced
+	assert.NoError(t, err)
+	saved := mockTransactionRepo.Calls[0].Arguments.Get(0).(domain.Transaction)
+	assert.Equal(t, domain.TransactionStatusFailed, saved.Status)
+	if assert.Len(t, saved.RejectionReasons, 1) {
+		assert.Equal(t, "members-only", saved.RejectionReasons[0].Rule)
+	}
+	assert.Zero(t, saved.FeeCents)
+}
+
+func TestPatientService_PayTransaction_ProviderPatientPolicy(t *testing.T) {
+	// Setup
+	mockPatientRepo := &MockPatientRepository{}
+	mockTransactionRepo := &MockTransactionRepository{}
+	mockProvider := &MockPatientProvider{}
+	policy, err := domain.NewProviderPatientPolicy("acme", []string{"state", "zip"}, bytes.Repeat([]byte{1}, 32))
+	assert.NoError(t, err)
+	service := NewPatientService(createTestConfig(), mockPatientRepo, mockTransactionRepo,
+		WithProvider(mockProvider), WithProviderPatientPolicy(policy))
+
+	patient := createTestPatient()
+	patient.UserID = "user-1"
+	mockPatientRepo.On("GetPatient", patient.ID.String()).Return(patient, nil)
+	mockProvider.On("SubmitPatient", mock.Anything).Return(&domain.ProviderResponse{Success: true, StatusCode: 200}, nil)
+	mockTransactionRepo.On("CreateTransaction", mock.Anything).Return(&domain.Transaction{}, nil)
+
+	// Execute
+	_, err = service.PayTransaction(context.Background(), domain.PayTransactionRequest{
+		PatientID:   patient.ID,
+		DateOfBirth: mustParseDate("15-03-1990"),
+		RecordType:  "NEW",
+	})
+
+	// Assertions, the provider only learns the allowed fields and a token
+	assert.NoError(t, err)
+	sent := mockProvider.Calls[0].Arguments.Get(0).(domain.SubmitPatientRequest)
+	assert.Equal(t, domain.ProviderPatient{ID: policy.PatientID(patient.ID), State: patient.State, Zip: patient.Zip}, sent.Patient)
+	assert.NotEqual(t, patient.ID.String(), sent.Patient.ID)
+}
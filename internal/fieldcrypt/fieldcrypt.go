@@ -0,0 +1,352 @@
+// Package fieldcrypt encrypts struct fields at rest with envelope encryption. Fields tagged
+// `encrypted:"true"` are sealed with AES-GCM under a data key, itself wrapped by the master key
+// of a ports.KeyProvider. Fields tagged `blind_index:"Field"` hold a keyed hash of Field, so
+// it can be looked up by equality without being decrypted.
+package fieldcrypt
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/datphamcode295/go-lambda-pulumi/internal/core/ports"
+)
+
+// prefix marks encrypted values, values without it are plaintext not encrypted yet
+const prefix = "enc.v1."
+
+// minIndexKeySize is the smallest blind index key accepted
+const minIndexKeySize = 32
+
+var encoding = base64.RawURLEncoding
+
+// Encryptor encrypts and decrypts fields. A data key is generated once per master key and
+// reused for the life of the process, unwrapped data keys are cached.
+type Encryptor struct {
+	keys     ports.KeyProvider
+	indexKey []byte
+
+	mu      sync.Mutex
+	current *dataKey
+	opened  map[string]cipher.AEAD
+}
+
+type dataKey struct {
+	keyID   string
+	wrapped []byte
+	aead    cipher.AEAD
+}
+
+// New returns an Encryptor wrapping data keys with keys and hashing blind indexes with
+// indexKey. The index key must never change once values are indexed.
+func New(keys ports.KeyProvider, indexKey []byte) (*Encryptor, error) {
+	if len(indexKey) < minIndexKeySize {
+		return nil, fmt.Errorf("blind index key must be at least %d bytes", minIndexKeySize)
+	}
+	return &Encryptor{keys: keys, indexKey: indexKey, opened: map[string]cipher.AEAD{}}, nil
+}
+
+// Encrypt seals plaintext for the column field under the current master key. Empty values
+// stay empty.
+func (e *Encryptor) Encrypt(ctx context.Context, field, plaintext string) (string, error) {
+	if plaintext == "" {
+		return "", nil
+	}
+
+	key, err := e.currentKey(ctx)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, key.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := key.aead.Seal(nonce, nonce, []byte(plaintext), []byte(field))
+
+	return prefix + strings.Join([]string{
+		encoding.EncodeToString([]byte(key.keyID)),
+		encoding.EncodeToString(key.wrapped),
+		encoding.EncodeToString(sealed),
+	}, "."), nil
+}
+
+// Decrypt opens a value of the column field sealed by Encrypt. Plaintext values are returned
+// as they are, so rows written before encryption was enabled stay readable.
+func (e *Encryptor) Decrypt(ctx context.Context, field, value string) (string, error) {
+	keyID, wrapped, sealed, ok, err := parse(value)
+	if err != nil || !ok {
+		return value, err
+	}
+
+	aead, err := e.open(ctx, keyID, wrapped)
+	if err != nil {
+		return "", fmt.Errorf("field %s: %w", field, err)
+	}
+	if len(sealed) < aead.NonceSize() {
+		return "", fmt.Errorf("field %s: encrypted value is truncated", field)
+	}
+	nonce, sealed := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, sealed, []byte(field))
+	if err != nil {
+		return "", fmt.Errorf("field %s: %w", field, err)
+	}
+	return string(plaintext), nil
+}
+
+// NeedsRotation tells whether value is plaintext or sealed under another master key than the
+// current one
+func (e *Encryptor) NeedsRotation(value string) bool {
+	if value == "" {
+		return false
+	}
+	keyID, _, _, ok, err := parse(value)
+	return err != nil || !ok || keyID != e.keys.KeyID()
+}
+
+// BlindIndex returns the keyed hash of value for the column field. Values are compared
+// ignoring case and surrounding spaces. Empty values have an empty index.
+func (e *Encryptor) BlindIndex(field, value string) string {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if value == "" {
+		return ""
+	}
+	mac := hmac.New(sha256.New, e.indexKey)
+	mac.Write([]byte(field))
+	mac.Write([]byte{0})
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// EncryptFields fills the blind indexes of the struct v points to and encrypts its encrypted
+// fields. Values already encrypted are left as they are.
+func (e *Encryptor) EncryptFields(ctx context.Context, v interface{}) error {
+	fields, err := taggedFields(v)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range fields.indexes {
+		source, err := e.Decrypt(ctx, f.sourceColumn, f.source.String())
+		if err != nil {
+			return err
+		}
+		f.value.SetString(e.BlindIndex(f.sourceColumn, source))
+	}
+	for _, f := range fields.encrypted {
+		if isEncrypted(f.value.String()) {
+			continue
+		}
+		sealed, err := e.Encrypt(ctx, f.column, f.value.String())
+		if err != nil {
+			return err
+		}
+		f.value.SetString(sealed)
+	}
+	return nil
+}
+
+// DecryptFields decrypts the encrypted fields of the struct v points to
+func (e *Encryptor) DecryptFields(ctx context.Context, v interface{}) error {
+	fields, err := taggedFields(v)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range fields.encrypted {
+		plaintext, err := e.Decrypt(ctx, f.column, f.value.String())
+		if err != nil {
+			return err
+		}
+		f.value.SetString(plaintext)
+	}
+	return nil
+}
+
+// FieldsNeedRotation tells whether an encrypted field of the struct v points to needs rotation
+func (e *Encryptor) FieldsNeedRotation(v interface{}) bool {
+	fields, err := taggedFields(v)
+	if err != nil {
+		return false
+	}
+	for _, f := range fields.encrypted {
+		if e.NeedsRotation(f.value.String()) {
+			return true
+		}
+	}
+	return false
+}
+
+// Columns returns the encrypted and blind index columns of the struct v points to, with their
+// values
+func Columns(v interface{}) (map[string]interface{}, error) {
+	fields, err := taggedFields(v)
+	if err != nil {
+		return nil, err
+	}
+
+	columns := map[string]interface{}{}
+	for _, f := range fields.encrypted {
+		columns[f.column] = f.value.String()
+	}
+	for _, f := range fields.indexes {
+		columns[f.column] = f.value.String()
+	}
+	return columns, nil
+}
+
+// EncryptedColumns returns the columns of the encrypted fields of the struct v points to
+func EncryptedColumns(v interface{}) ([]string, error) {
+	fields, err := taggedFields(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var columns []string
+	for _, f := range fields.encrypted {
+		columns = append(columns, f.column)
+	}
+	return columns, nil
+}
+
+func (e *Encryptor) currentKey(ctx context.Context) (*dataKey, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	keyID := e.keys.KeyID()
+	if e.current != nil && e.current.keyID == keyID {
+		return e.current, nil
+	}
+
+	plaintext, wrapped, err := e.keys.GenerateDataKey(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("generate data key: %w", err)
+	}
+	aead, err := newAEAD(plaintext)
+	if err != nil {
+		return nil, err
+	}
+	e.current = &dataKey{keyID: keyID, wrapped: wrapped, aead: aead}
+	e.opened[cacheKey(keyID, wrapped)] = aead
+	return e.current, nil
+}
+
+func (e *Encryptor) open(ctx context.Context, keyID string, wrapped []byte) (cipher.AEAD, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if aead, ok := e.opened[cacheKey(keyID, wrapped)]; ok {
+		return aead, nil
+	}
+	plaintext, err := e.keys.DecryptDataKey(ctx, keyID, wrapped)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt data key: %w", err)
+	}
+	aead, err := newAEAD(plaintext)
+	if err != nil {
+		return nil, err
+	}
+	e.opened[cacheKey(keyID, wrapped)] = aead
+	return aead, nil
+}
+
+func cacheKey(keyID string, wrapped []byte) string {
+	return keyID + "\x00" + string(wrapped)
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func isEncrypted(value string) bool {
+	return strings.HasPrefix(value, prefix)
+}
+
+// parse splits an encrypted value, ok is false for plaintext
+func parse(value string) (keyID string, wrapped, sealed []byte, ok bool, err error) {
+	if !isEncrypted(value) {
+		return "", nil, nil, false, nil
+	}
+
+	parts := strings.Split(strings.TrimPrefix(value, prefix), ".")
+	if len(parts) != 3 {
+		return "", nil, nil, false, errors.New("malformed encrypted value")
+	}
+	decoded := make([][]byte, len(parts))
+	for i, part := range parts {
+		if decoded[i], err = encoding.DecodeString(part); err != nil {
+			return "", nil, nil, false, errors.New("malformed encrypted value")
+		}
+	}
+	return string(decoded[0]), decoded[1], decoded[2], true, nil
+}
+
+type field struct {
+	column string
+	value  reflect.Value
+}
+
+type indexField struct {
+	field
+	sourceColumn string
+	source       reflect.Value
+}
+
+type fields struct {
+	encrypted []field
+	indexes   []indexField
+}
+
+// taggedFields finds the encrypted and blind index string fields of the struct v points to
+func taggedFields(v interface{}) (fields, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Struct {
+		return fields{}, fmt.Errorf("fieldcrypt: %T is not a pointer to a struct", v)
+	}
+	rv = rv.Elem()
+	rt := rv.Type()
+
+	var rs fields
+	for i := 0; i < rt.NumField(); i++ {
+		sf := rt.Field(i)
+		if sf.Tag.Get("encrypted") == "true" {
+			if sf.Type.Kind() != reflect.String {
+				return fields{}, fmt.Errorf("fieldcrypt: encrypted field %s is not a string", sf.Name)
+			}
+			rs.encrypted = append(rs.encrypted, field{column: column(sf), value: rv.Field(i)})
+		}
+		if sourceName := sf.Tag.Get("blind_index"); sourceName != "" {
+			source, ok := rt.FieldByName(sourceName)
+			if !ok || source.Type.Kind() != reflect.String || sf.Type.Kind() != reflect.String {
+				return fields{}, fmt.Errorf("fieldcrypt: blind index %s of %s is not a string field", sf.Name, sourceName)
+			}
+			rs.indexes = append(rs.indexes, indexField{
+				field:        field{column: column(sf), value: rv.Field(i)},
+				sourceColumn: column(source),
+				source:       rv.FieldByIndex(source.Index),
+			})
+		}
+	}
+	return rs, nil
+}
+
+// column is the database column of a field, from its db tag
+func column(sf reflect.StructField) string {
+	if name := strings.Split(sf.Tag.Get("db"), ",")[0]; name != "" {
+		return name
+	}
+	return strings.ToLower(sf.Name)
+}
@@ -0,0 +1,146 @@
+package fieldcrypt_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/datphamcode295/go-lambda-pulumi/internal/adapters/keys"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/core/ports"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/fieldcrypt"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	indexKey = bytes.Repeat([]byte{9}, 32)
+	oldKey   = bytes.Repeat([]byte{1}, 32)
+	newKey   = bytes.Repeat([]byte{2}, 32)
+)
+
+type record struct {
+	Name       string `db:"name" encrypted:"true"`
+	Email      string `db:"email" encrypted:"true"`
+	EmailIndex string `db:"email_index" blind_index:"Email"`
+	City       string `db:"city"`
+}
+
+// countingKeys counts the calls to the key provider
+type countingKeys struct {
+	ports.KeyProvider
+	generated, decrypted int
+}
+
+func (c *countingKeys) GenerateDataKey(ctx context.Context) ([]byte, []byte, error) {
+	c.generated++
+	return c.KeyProvider.GenerateDataKey(ctx)
+}
+
+func (c *countingKeys) DecryptDataKey(ctx context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	c.decrypted++
+	return c.KeyProvider.DecryptDataKey(ctx, keyID, wrapped)
+}
+
+func newEncryptor(t *testing.T, masterKeys map[string][]byte, keyID string) (*fieldcrypt.Encryptor, *countingKeys) {
+	provider, err := keys.NewStaticKeyProvider(masterKeys, keyID)
+	assert.NoError(t, err)
+	counting := &countingKeys{KeyProvider: provider}
+	encryptor, err := fieldcrypt.New(counting, indexKey)
+	assert.NoError(t, err)
+	return encryptor, counting
+}
+
+func TestEncryptor_RoundTrip(t *testing.T) {
+	ctx := context.Background()
+	encryptor, counting := newEncryptor(t, map[string][]byte{"k1": oldKey}, "k1")
+
+	sealed, err := encryptor.Encrypt(ctx, "email", "john@example.com")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(sealed, "enc.v1."))
+	assert.NotContains(t, sealed, "john")
+
+	opened, err := encryptor.Decrypt(ctx, "email", sealed)
+	assert.NoError(t, err)
+	assert.Equal(t, "john@example.com", opened)
+
+	// a value moved to another column does not decrypt
+	_, err = encryptor.Decrypt(ctx, "name", sealed)
+	assert.Error(t, err)
+
+	// one data key for the process, no unwrapping of our own
+	_, err = encryptor.Encrypt(ctx, "name", "John Doe")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, counting.generated)
+	assert.Equal(t, 0, counting.decrypted)
+
+	// plaintext and empty values pass through
+	opened, err = encryptor.Decrypt(ctx, "email", "legacy@example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, "legacy@example.com", opened)
+	sealed, err = encryptor.Encrypt(ctx, "email", "")
+	assert.NoError(t, err)
+	assert.Empty(t, sealed)
+
+	_, err = encryptor.Decrypt(ctx, "email", "enc.v1.broken")
+	assert.EqualError(t, err, "malformed encrypted value")
+}
+
+func TestEncryptor_Fields(t *testing.T) {
+	ctx := context.Background()
+	encryptor, _ := newEncryptor(t, map[string][]byte{"k1": oldKey}, "k1")
+
+	r := record{Name: "John Doe", Email: "John@Example.com", City: "Sydney"}
+	assert.NoError(t, encryptor.EncryptFields(ctx, &r))
+	assert.True(t, strings.HasPrefix(r.Name, "enc.v1."))
+	assert.True(t, strings.HasPrefix(r.Email, "enc.v1."))
+	assert.Equal(t, "Sydney", r.City)
+	assert.Equal(t, encryptor.BlindIndex("email", " john@example.com"), r.EmailIndex)
+	assert.NotEqual(t, encryptor.BlindIndex("name", "john@example.com"), r.EmailIndex)
+
+	// encrypting again changes nothing
+	sealed := r
+	assert.NoError(t, encryptor.EncryptFields(ctx, &r))
+	assert.Equal(t, sealed, r)
+
+	columns, err := fieldcrypt.Columns(&r)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"name": r.Name, "email": r.Email, "email_index": r.EmailIndex}, columns)
+
+	assert.NoError(t, encryptor.DecryptFields(ctx, &r))
+	assert.Equal(t, record{Name: "John Doe", Email: "John@Example.com", EmailIndex: sealed.EmailIndex, City: "Sydney"}, r)
+
+	assert.Error(t, encryptor.EncryptFields(ctx, r))
+}
+
+func TestEncryptor_Rotation(t *testing.T) {
+	ctx := context.Background()
+	previous, _ := newEncryptor(t, map[string][]byte{"k1": oldKey}, "k1")
+	sealed, err := previous.Encrypt(ctx, "email", "john@example.com")
+	assert.NoError(t, err)
+
+	encryptor, counting := newEncryptor(t, map[string][]byte{"k1": oldKey, "k2": newKey}, "k2")
+	assert.True(t, encryptor.NeedsRotation(sealed))
+	assert.True(t, encryptor.NeedsRotation("plaintext"))
+	assert.False(t, encryptor.NeedsRotation(""))
+
+	// unwrapped data keys are cached
+	for i := 0; i < 2; i++ {
+		opened, err := encryptor.Decrypt(ctx, "email", sealed)
+		assert.NoError(t, err)
+		assert.Equal(t, "john@example.com", opened)
+	}
+	assert.Equal(t, 1, counting.decrypted)
+
+	rotated, err := encryptor.Encrypt(ctx, "email", "john@example.com")
+	assert.NoError(t, err)
+	assert.False(t, encryptor.NeedsRotation(rotated))
+	assert.True(t, encryptor.FieldsNeedRotation(&record{Name: rotated, Email: sealed}))
+	assert.False(t, encryptor.FieldsNeedRotation(&record{Name: rotated, Email: rotated}))
+}
+
+func TestNew_ShortIndexKey(t *testing.T) {
+	provider, err := keys.NewStaticKeyProvider(map[string][]byte{"k1": oldKey}, "k1")
+	assert.NoError(t, err)
+	_, err = fieldcrypt.New(provider, []byte("short"))
+	assert.EqualError(t, err, "blind index key must be at least 32 bytes")
+}
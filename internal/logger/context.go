@@ -0,0 +1,37 @@
+package logger
+
+import (
+	"context"
+
+	"github.com/datphamcode295/go-lambda-pulumi/internal/requestctx"
+	"github.com/sirupsen/logrus"
+)
+
+type contextKey struct{}
+
+// WithContext returns a copy of ctx carrying entry as the request-scoped logger
+func WithContext(ctx context.Context, entry *logrus.Entry) context.Context {
+	return context.WithValue(ctx, contextKey{}, entry)
+}
+
+// FromContext returns the request-scoped logger stored in ctx. When there is none
+// it falls back to Log, still tagged with the request id if ctx carries one.
+func FromContext(ctx context.Context) *logrus.Entry {
+	if ctx == nil {
+		return logrus.NewEntry(Log)
+	}
+	if entry, ok := ctx.Value(contextKey{}).(*logrus.Entry); ok {
+		return entry
+	}
+
+	entry := logrus.NewEntry(Log)
+	if id := requestctx.RequestID(ctx); id != "" {
+		entry = entry.WithField("request_id", id)
+	}
+	return entry
+}
+
+// WithFields returns a copy of ctx whose logger carries the extra fields
+func WithFields(ctx context.Context, fields logrus.Fields) context.Context {
+	return WithContext(ctx, FromContext(ctx).WithFields(fields))
+}
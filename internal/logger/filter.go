@@ -0,0 +1,107 @@
+package logger
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// maxSampledMessages bounds the memory used to track repeated messages
+const maxSampledMessages = 1000
+
+// filterFormatter wraps the real formatter and drops entries that are below the level
+// of the package that logged them or that exceed the debug sampling rate. Logrus can
+// only discard entries through its global level, so filtering happens at format time:
+// an empty result writes nothing.
+type filterFormatter struct {
+	next          logrus.Formatter
+	level         logrus.Level
+	packageLevels map[string]logrus.Level
+	sampler       *sampler
+	showCaller    bool
+}
+
+func (f *filterFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	if entry.Level > f.levelFor(entry) {
+		return nil, nil
+	}
+	if entry.Level >= logrus.DebugLevel && f.sampler != nil && !f.sampler.Allow(entry.Message) {
+		return nil, nil
+	}
+	if !f.showCaller {
+		// caller may only have been collected to resolve package levels
+		entry.Caller = nil
+	}
+	return f.next.Format(entry)
+}
+
+func (f *filterFormatter) levelFor(entry *logrus.Entry) logrus.Level {
+	if len(f.packageLevels) == 0 {
+		return f.level
+	}
+
+	pkg, _ := entry.Data["package"].(string)
+	if pkg == "" && entry.Caller != nil {
+		pkg = packagePath(entry.Caller.Function)
+	}
+	for name, level := range f.packageLevels {
+		if pkg == name || strings.HasSuffix(pkg, "/"+name) {
+			return level
+		}
+	}
+	return f.level
+}
+
+// packagePath extracts "github.com/org/repo/internal/pkg" from a function name such as
+// "github.com/org/repo/internal/pkg.(*Type).Method"
+func packagePath(function string) string {
+	lastSlash := strings.LastIndex(function, "/")
+	if dot := strings.Index(function[lastSlash+1:], "."); dot >= 0 {
+		return function[:lastSlash+1+dot]
+	}
+	return function
+}
+
+// sampler lets through the first burst occurrences of a message in every interval
+type sampler struct {
+	burst    int
+	interval time.Duration
+	now      func() time.Time
+
+	mu      sync.Mutex
+	windows map[string]*sampleWindow
+}
+
+type sampleWindow struct {
+	start time.Time
+	count int
+}
+
+func newSampler(burst int, interval time.Duration) *sampler {
+	return &sampler{
+		burst:    burst,
+		interval: interval,
+		now:      time.Now,
+		windows:  map[string]*sampleWindow{},
+	}
+}
+
+func (s *sampler) Allow(message string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	window, ok := s.windows[message]
+	if !ok || now.Sub(window.start) >= s.interval {
+		if !ok && len(s.windows) >= maxSampledMessages {
+			s.windows = map[string]*sampleWindow{}
+		}
+		window = &sampleWindow{start: now}
+		s.windows[message] = window
+	}
+
+	window.count++
+	return window.count <= s.burst
+}
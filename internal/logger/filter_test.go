@@ -0,0 +1,176 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/datphamcode295/go-lambda-pulumi/internal/config"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestLogger(cfg config.LogConfig) (*logrus.Logger, *bytes.Buffer) {
+	var buf bytes.Buffer
+	log := CreateLoggerInstant(cfg)
+	log.SetOutput(&buf)
+	return log, &buf
+}
+
+func TestMyFormatter_NilCaller(t *testing.T) {
+	f := &myFormatter{logrus.TextFormatter{TimestampFormat: "2006-01-02 15:04:05"}}
+	entry := &logrus.Entry{Logger: logrus.New(), Level: logrus.InfoLevel, Message: "hello", Data: logrus.Fields{}}
+
+	out, err := f.Format(entry)
+	assert.NoError(t, err)
+	assert.Contains(t, string(out), "hello")
+	assert.NotContains(t, string(out), "line:")
+}
+
+func TestMyFormatter_WithCaller(t *testing.T) {
+	f := &myFormatter{logrus.TextFormatter{TimestampFormat: "2006-01-02 15:04:05"}}
+	entry := &logrus.Entry{
+		Logger:  logrus.New(),
+		Level:   logrus.InfoLevel,
+		Message: "hello",
+		Data:    logrus.Fields{},
+		Caller:  &runtime.Frame{File: "/src/internal/services/patient.go", Line: 42},
+	}
+
+	out, err := f.Format(entry)
+	assert.NoError(t, err)
+	assert.Contains(t, string(out), "patient.go - [line:42]")
+}
+
+func TestCreateLoggerInstant_LevelAndFormat(t *testing.T) {
+	log, buf := newTestLogger(config.LogConfig{Level: "warn", Format: "json"})
+
+	log.Info("dropped")
+	log.Warn("kept")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Len(t, lines, 1)
+
+	var entry map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(lines[0]), &entry))
+	assert.Equal(t, "kept", entry["msg"])
+	assert.NotContains(t, entry, "file")
+}
+
+func TestCreateLoggerInstant_Logfmt(t *testing.T) {
+	log, buf := newTestLogger(config.LogConfig{Level: "info", Format: "logfmt"})
+
+	log.WithField("patient_id", "p-1").Info("hello")
+
+	assert.Contains(t, buf.String(), `level=info msg=hello patient_id=p-1`)
+}
+
+func TestCreateLoggerInstant_InvalidLevelFallsBack(t *testing.T) {
+	log, _ := newTestLogger(config.LogConfig{Level: "loud"})
+	assert.Equal(t, defaultLevel(), log.GetLevel())
+}
+
+func TestCreateLoggerInstant_PackageLevels(t *testing.T) {
+	log, buf := newTestLogger(config.LogConfig{
+		Level:         "error",
+		Format:        "json",
+		PackageLevels: map[string]string{"logger": "debug", "repository": "info"},
+	})
+
+	// caller resolves to this package, which is overridden to debug
+	log.Debug("from logger package")
+	// explicit package field takes precedence over the caller
+	log.WithField("package", "repository").Debug("repository debug")
+	log.WithField("package", "repository").Info("repository info")
+
+	out := buf.String()
+	assert.Contains(t, out, "from logger package")
+	assert.NotContains(t, out, "repository debug")
+	assert.Contains(t, out, "repository info")
+	// caller was only collected to resolve the package, it must not be printed
+	assert.NotContains(t, out, `"file"`)
+}
+
+func TestSampler_Allow(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := newSampler(2, time.Second)
+	s.now = func() time.Time { return now }
+
+	assert.True(t, s.Allow("a"))
+	assert.True(t, s.Allow("a"))
+	assert.False(t, s.Allow("a"))
+	assert.True(t, s.Allow("b"))
+
+	now = now.Add(time.Second)
+	assert.True(t, s.Allow("a"))
+}
+
+func TestCreateLoggerInstant_SamplesDebugOnly(t *testing.T) {
+	log, buf := newTestLogger(config.LogConfig{
+		Level:          "debug",
+		Format:         "logfmt",
+		SampleBurst:    1,
+		SampleInterval: time.Hour,
+	})
+
+	for i := 0; i < 3; i++ {
+		log.Debug("repetitive")
+		log.Error("important")
+	}
+
+	out := buf.String()
+	assert.Equal(t, 1, strings.Count(out, "repetitive"))
+	assert.Equal(t, 3, strings.Count(out, "important"))
+}
+
+func TestPackagePath(t *testing.T) {
+	assert.Equal(t,
+		"github.com/datphamcode295/go-lambda-pulumi/internal/adapters/repository",
+		packagePath("github.com/datphamcode295/go-lambda-pulumi/internal/adapters/repository.(*DB).CreateTransaction"))
+	assert.Equal(t, "main", packagePath("main.main"))
+}
+
+func TestSetupLogger_RoutesStandardLogInline(t *testing.T) {
+	previous, previousOutput := Log, log.Writer()
+	defer func() {
+		Log = previous
+		log.SetOutput(previousOutput)
+	}()
+
+	SetupLogger(config.LogConfig{Level: "info", Format: "json"})
+	var buf bytes.Buffer
+	Log.SetOutput(&buf)
+
+	log.Printf("connecting as jane@example.com")
+
+	// written before Printf returns, as a log.Fatalf message must be
+	var entry map[string]interface{}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.Equal(t, "connecting as [REDACTED]", entry["msg"])
+	assert.Equal(t, "error", entry["level"])
+}
+
+func TestSetupLogger_StandardLogSurvivesReleaseLevel(t *testing.T) {
+	previous, previousOutput := Log, log.Writer()
+	defer func() {
+		Log = previous
+		log.SetOutput(previousOutput)
+	}()
+	t.Setenv("HEX_ARCH_ENV", "release")
+
+	// release logs errors only, config failures are logged with log.Fatalf before exiting
+	SetupLogger(config.LogConfig{})
+	var buf bytes.Buffer
+	Log.SetOutput(&buf)
+
+	log.Printf("Failed to get DATABASE_URL parameter: %v", "ParameterNotFound")
+
+	var entry map[string]interface{}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.Equal(t, "Failed to get DATABASE_URL parameter: ParameterNotFound", entry["msg"])
+	assert.Equal(t, "error", entry["level"])
+}
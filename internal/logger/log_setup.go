@@ -3,13 +3,18 @@ package logger
 import (
 	"fmt"
 	"io"
+	"log"
 	"os"
 	"strings"
 
+	"github.com/datphamcode295/go-lambda-pulumi/internal/config"
 	"github.com/sirupsen/logrus"
 )
 
-var Log *logrus.Logger
+var (
+	Log      = CreateLoggerInstant(config.LogConfig{ReportCaller: true})
+	redactor = NewRedactor()
+)
 
 func GetLoggingEnv() string {
 	checkRunningEnv := os.Getenv("HEX_ARCH_ENV")
@@ -20,35 +25,121 @@ func GetLoggingEnv() string {
 }
 
 // SetupLogger setup logger.Log
-func SetupLogger() {
-	Log = CreateLoggerInstant()
+func SetupLogger(cfg config.LogConfig) {
+	redactor = NewRedactor(cfg.RedactFields...)
+	Log = CreateLoggerInstant(cfg)
+	routeStandardLog()
+}
+
+// Redact returns a copy of v safe to log, with the configured sensitive fields masked
+func Redact(v interface{}) interface{} {
+	return redactor.Redact(v)
+}
+
+// routeStandardLog sends output of the standard library log package through Log,
+// so ad-hoc log.Println calls are redacted and formatted like everything else
+func routeStandardLog() {
+	log.SetFlags(0)
+	log.SetOutput(standardLogWriter{})
+}
+
+// standardLogWriter logs every write of the standard library log package as an entry of Log.
+// It writes inline, so a log.Fatal message is out before the process exits. A write cannot
+// tell log.Printf from log.Fatalf, so every entry is an error, which no configured level drops.
+type standardLogWriter struct{}
+
+func (standardLogWriter) Write(p []byte) (int, error) {
+	Log.WithField("source", "log").Error(strings.TrimSuffix(string(p), "\n"))
+	return len(p), nil
 }
 
 // CreateLoggerInstant creates a logger instance with the configuration
-func CreateLoggerInstant() *logrus.Logger {
+func CreateLoggerInstant(cfg config.LogConfig) *logrus.Logger {
 	logInstance := logrus.New()
 	logInstance.SetOutput(io.MultiWriter(os.Stdout))
-	logInstance.SetReportCaller(true)
+	logInstance.AddHook(NewRedactionHook(NewRedactor(cfg.RedactFields...)))
 
-	if GetLoggingEnv() == "structured" {
-		logInstance.SetLevel(logrus.ErrorLevel)
-		logInstance.SetFormatter(&logrus.JSONFormatter{
+	level, err := parseLevel(cfg.Level)
+	if err != nil {
+		level = defaultLevel()
+		logInstance.WithError(err).Warnf("Invalid log level, using %s", level)
+	}
+
+	// entries must reach the filter for every package that logs more verbosely than the default
+	filter := &filterFormatter{
+		level:         level,
+		packageLevels: map[string]logrus.Level{},
+		showCaller:    cfg.ReportCaller,
+	}
+	minLevel := level
+	for pkg, value := range cfg.PackageLevels {
+		pkgLevel, err := logrus.ParseLevel(value)
+		if err != nil {
+			logInstance.WithError(err).Warnf("Invalid log level for package %s, ignoring", pkg)
+			continue
+		}
+		filter.packageLevels[pkg] = pkgLevel
+		if pkgLevel > minLevel {
+			minLevel = pkgLevel
+		}
+	}
+	if cfg.SampleBurst > 0 && cfg.SampleInterval > 0 {
+		filter.sampler = newSampler(cfg.SampleBurst, cfg.SampleInterval)
+	}
+
+	format := strings.ToLower(cfg.Format)
+	if format == "" {
+		format = defaultFormat()
+	}
+	switch format {
+	case "json":
+		filter.next = &logrus.JSONFormatter{
+			TimestampFormat: "2006-01-02 15:04:05",
+		}
+	case "logfmt":
+		filter.next = &logrus.TextFormatter{
+			FullTimestamp:   true,
 			TimestampFormat: "2006-01-02 15:04:05",
-		})
-	} else {
-		// if not in production, then default to plain stdout DebugLevel please
-		// this may mean slowdown in UAT/QA but overall, it's probably worth it
-		logInstance.SetLevel(logrus.DebugLevel)
-		logInstance.SetFormatter(&myFormatter{logrus.TextFormatter{
+			DisableColors:   true,
+		}
+	default:
+		filter.next = &myFormatter{logrus.TextFormatter{
 			FullTimestamp:          true,
 			TimestampFormat:        "2006-01-02 15:04:05",
 			ForceColors:            true,
 			DisableLevelTruncation: true,
-		}})
+		}}
 	}
+
+	logInstance.SetLevel(minLevel)
+	logInstance.SetReportCaller(cfg.ReportCaller || len(filter.packageLevels) > 0)
+	logInstance.SetFormatter(filter)
 	return logInstance
 }
 
+func parseLevel(value string) (logrus.Level, error) {
+	if value == "" {
+		return defaultLevel(), nil
+	}
+	return logrus.ParseLevel(value)
+}
+
+func defaultLevel() logrus.Level {
+	if GetLoggingEnv() == "structured" {
+		return logrus.ErrorLevel
+	}
+	// if not in production, then default to plain stdout DebugLevel please
+	// this may mean slowdown in UAT/QA but overall, it's probably worth it
+	return logrus.DebugLevel
+}
+
+func defaultFormat() string {
+	if GetLoggingEnv() == "structured" {
+		return "json"
+	}
+	return "text"
+}
+
 type myFormatter struct {
 	logrus.TextFormatter
 }
@@ -56,9 +147,6 @@ type myFormatter struct {
 func (f *myFormatter) Format(entry *logrus.Entry) ([]byte, error) {
 	// this whole mess of dealing with ansi color codes is required if you want the colored output otherwise you will lose colors in the log levels
 	var levelColor int
-	strList := strings.Split(entry.Caller.File, "/")
-	fileName := strList[len(strList)-1]
-
 	switch entry.Level {
 	case logrus.DebugLevel, logrus.TraceLevel:
 		levelColor = 31 // gray
@@ -69,6 +157,14 @@ func (f *myFormatter) Format(entry *logrus.Entry) ([]byte, error) {
 	default:
 		levelColor = 36 // blue
 	}
-	return []byte(fmt.Sprintf("[%s] - %s - [line:%d] - \x1b[%dm%s\x1b[0m - %s. Data: %v\n", entry.Time.Format(f.TimestampFormat), fileName, entry.Caller.Line, levelColor,
+
+	// caller is nil when report-caller is turned off
+	location := ""
+	if entry.Caller != nil {
+		strList := strings.Split(entry.Caller.File, "/")
+		location = fmt.Sprintf("%s - [line:%d] - ", strList[len(strList)-1], entry.Caller.Line)
+	}
+
+	return []byte(fmt.Sprintf("[%s] - %s\x1b[%dm%s\x1b[0m - %s. Data: %v\n", entry.Time.Format(f.TimestampFormat), location, levelColor,
 		strings.ToUpper(entry.Level.String()), entry.Message, entry.Data)), nil
 }
@@ -0,0 +1,166 @@
+package logger
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/sirupsen/logrus"
+)
+
+const RedactedValue = "[REDACTED]"
+
+// DefaultRedactedFields are always masked, whatever the configuration says.
+// Names are matched case-insensitively and ignoring '-' and '_', so "api_key"
+// also covers "APIKey" and "X-Api-Key" style spellings of "xapikey".
+var DefaultRedactedFields = []string{
+	"api_key",
+	"x-api-key",
+	"authorization",
+	"proxy-authorization",
+	"cookie",
+	"set-cookie",
+	"password",
+	"secret",
+	"token",
+	"name",
+	"guardian_name",
+	"guardian_contact",
+	"email",
+	"phone",
+	"date_of_birth",
+	"dob",
+	"address",
+}
+
+var (
+	emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+	// phone numbers need a country code or separators, bare digit runs are timestamps and IDs
+	phonePattern = regexp.MustCompile(`\+\d{1,3}[\s.\-]?\(?\d{3}\)?[\s.\-]?\d{3}[\s.\-]?\d{4}\b|\(\d{3}\)\s?\d{3}[\s.\-]\d{4}\b|\b\d{3}[\s.\-]\d{3}[\s.\-]\d{4}\b`)
+	bearerToken  = regexp.MustCompile(`(?i)(bearer|basic)\s+[A-Za-z0-9._~+/=\-]+`)
+)
+
+// Redactor masks sensitive values in log fields, messages and arbitrary structs
+type Redactor struct {
+	fields map[string]struct{}
+}
+
+// NewRedactor creates a redactor masking DefaultRedactedFields plus the given field names
+func NewRedactor(fields ...string) *Redactor {
+	r := &Redactor{fields: map[string]struct{}{}}
+	for _, f := range DefaultRedactedFields {
+		r.fields[normalizeKey(f)] = struct{}{}
+	}
+	for _, f := range fields {
+		r.fields[normalizeKey(f)] = struct{}{}
+	}
+	return r
+}
+
+// IsSensitive reports whether values stored under key must be masked
+func (r *Redactor) IsSensitive(key string) bool {
+	_, ok := r.fields[normalizeKey(key)]
+	return ok
+}
+
+// RedactString masks emails, phone numbers and credentials found in free text
+func (r *Redactor) RedactString(s string) string {
+	s = bearerToken.ReplaceAllString(s, RedactedValue)
+	s = emailPattern.ReplaceAllString(s, RedactedValue)
+	return phonePattern.ReplaceAllString(s, RedactedValue)
+}
+
+// Fields returns a copy of fields with sensitive keys masked and nested values redacted
+func (r *Redactor) Fields(fields logrus.Fields) logrus.Fields {
+	redacted := make(logrus.Fields, len(fields))
+	for k, v := range fields {
+		if r.IsSensitive(k) {
+			redacted[k] = RedactedValue
+			continue
+		}
+		redacted[k] = r.value(v)
+	}
+	return redacted
+}
+
+// Redact converts v into its JSON representation with sensitive keys masked.
+// It is meant for logging whole structs, e.g. Log.WithField("patient", Redact(patient)).
+func (r *Redactor) Redact(v interface{}) interface{} {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return RedactedValue
+	}
+
+	var generic interface{}
+	if err := json.Unmarshal(raw, &generic); err != nil {
+		return RedactedValue
+	}
+	return r.walk(generic)
+}
+
+func (r *Redactor) value(v interface{}) interface{} {
+	switch val := v.(type) {
+	case nil:
+		return nil
+	case string:
+		return r.RedactString(val)
+	case error:
+		return r.RedactString(val.Error())
+	case bool, int, int32, int64, uint, uint32, uint64, float32, float64:
+		return val
+	default:
+		return r.Redact(val)
+	}
+}
+
+func (r *Redactor) walk(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for k, item := range val {
+			if r.IsSensitive(k) {
+				val[k] = RedactedValue
+				continue
+			}
+			val[k] = r.walk(item)
+		}
+		return val
+	case []interface{}:
+		for i, item := range val {
+			val[i] = r.walk(item)
+		}
+		return val
+	case string:
+		return r.RedactString(val)
+	default:
+		return val
+	}
+}
+
+func normalizeKey(key string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == '_' || unicode.IsSpace(r) {
+			return -1
+		}
+		return unicode.ToLower(r)
+	}, key)
+}
+
+// RedactionHook masks sensitive data on every entry before it reaches the formatter
+type RedactionHook struct {
+	redactor *Redactor
+}
+
+func NewRedactionHook(redactor *Redactor) *RedactionHook {
+	return &RedactionHook{redactor: redactor}
+}
+
+func (h *RedactionHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (h *RedactionHook) Fire(entry *logrus.Entry) error {
+	entry.Message = h.redactor.RedactString(entry.Message)
+	entry.Data = h.redactor.Fields(entry.Data)
+	return nil
+}
@@ -0,0 +1,148 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedactor_IsSensitive(t *testing.T) {
+	r := NewRedactor("ssn")
+
+	testCases := []struct {
+		key      string
+		expected bool
+	}{
+		{key: "api_key", expected: true},
+		{key: "APIKey", expected: true},
+		{key: "X-Api-Key", expected: true},
+		{key: "Authorization", expected: true},
+		{key: "date_of_birth", expected: true},
+		{key: "DateOfBirth", expected: true},
+		{key: "ssn", expected: true},
+		{key: "name", expected: true},
+		{key: "GuardianName", expected: true},
+		{key: "guardian_contact", expected: true},
+		{key: "patient_id", expected: false},
+		{key: "record_type", expected: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.key, func(t *testing.T) {
+			assert.Equal(t, tc.expected, r.IsSensitive(tc.key))
+		})
+	}
+}
+
+func TestRedactor_RedactString(t *testing.T) {
+	r := NewRedactor()
+
+	testCases := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "Email",
+			input:    "contact john.doe@example.com now",
+			expected: "contact [REDACTED] now",
+		},
+		{
+			name:     "Phone number",
+			input:    "call 123-456-7890",
+			expected: "call [REDACTED]",
+		},
+		{
+			name:     "Phone number with country code",
+			input:    "call +1 415 555 0100 or +14155550100",
+			expected: "call [REDACTED] or [REDACTED]",
+		},
+		{
+			name:     "Phone number with area code in parentheses",
+			input:    "call (123) 456-7890",
+			expected: "call [REDACTED]",
+		},
+		{
+			name:     "Epoch timestamp is kept",
+			input:    "expires at 1716830173",
+			expected: "expires at 1716830173",
+		},
+		{
+			name:     "Bearer token",
+			input:    "Authorization: Bearer abc.def.ghi",
+			expected: "Authorization: [REDACTED]",
+		},
+		{
+			name:     "Date is kept",
+			input:    "created 2025-05-27",
+			expected: "created 2025-05-27",
+		},
+		{
+			name:     "UUID is kept",
+			input:    "patient 9c7006ad-56e0-47cb-a166-f22426586cd2",
+			expected: "patient 9c7006ad-56e0-47cb-a166-f22426586cd2",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, r.RedactString(tc.input))
+		})
+	}
+}
+
+func TestRedactor_Redact_Struct(t *testing.T) {
+	type address struct {
+		Address string `json:"address"`
+		City    string `json:"city"`
+	}
+	type patient struct {
+		Name    string            `json:"name"`
+		Email   string            `json:"email"`
+		Phone   string            `json:"phone"`
+		Home    address           `json:"home"`
+		Headers map[string]string `json:"headers"`
+	}
+
+	r := NewRedactor()
+	redacted := r.Redact(patient{
+		Name:    "John Doe",
+		Email:   "john.doe@example.com",
+		Phone:   "123-456-7890",
+		Home:    address{Address: "123 Main St", City: "Anytown"},
+		Headers: map[string]string{"authorization": "Bearer token", "content-type": "application/json"},
+	})
+
+	raw, err := json.Marshal(redacted)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{
+		"name": "[REDACTED]",
+		"email": "[REDACTED]",
+		"phone": "[REDACTED]",
+		"home": {"address": "[REDACTED]", "city": "Anytown"},
+		"headers": {"authorization": "[REDACTED]", "content-type": "application/json"}
+	}`, string(raw))
+}
+
+func TestRedactionHook_Fire(t *testing.T) {
+	var buf bytes.Buffer
+	log := logrus.New()
+	log.SetOutput(&buf)
+	log.SetFormatter(&logrus.JSONFormatter{})
+	log.AddHook(NewRedactionHook(NewRedactor()))
+
+	log.WithFields(logrus.Fields{
+		"api_key":    "secret-key",
+		"patient_id": "9c7006ad-56e0-47cb-a166-f22426586cd2",
+	}).Info("sent to jane@example.com")
+
+	var entry map[string]interface{}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.Equal(t, RedactedValue, entry["api_key"])
+	assert.Equal(t, "9c7006ad-56e0-47cb-a166-f22426586cd2", entry["patient_id"])
+	assert.Equal(t, "sent to [REDACTED]", entry["msg"])
+	assert.NotContains(t, buf.String(), "secret-key")
+}
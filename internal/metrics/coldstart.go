@@ -0,0 +1,29 @@
+package metrics
+
+import (
+	"sync"
+	"time"
+)
+
+var (
+	coldStartOnce sync.Once
+	// startedAt is when this execution environment was initialised
+	startedAt = time.Now()
+)
+
+// StartedAt returns the time the process started, i.e. the last cold start
+func StartedAt() time.Time {
+	return startedAt
+}
+
+// MarkColdStart counts a cold start the first time it is called in this execution
+// environment and reports whether this invocation was the cold one
+func MarkColdStart(r Recorder, dims Dimensions) bool {
+	cold := false
+	coldStartOnce.Do(func() {
+		cold = true
+		r.Count(ColdStart, 1, dims)
+		ObserveDuration(r, InitDuration, time.Since(startedAt), dims)
+	})
+	return cold
+}
@@ -0,0 +1,89 @@
+package metrics
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// EMFRecorder writes metrics to w using the CloudWatch Embedded Metric Format, one JSON
+// document per sample. In Lambda, writing to stdout is enough for CloudWatch to extract them.
+// See https://docs.aws.amazon.com/AmazonCloudWatch/latest/monitoring/CloudWatch_Embedded_Metric_Format_Specification.html
+type EMFRecorder struct {
+	namespace string
+	defaults  Dimensions
+	now       func() time.Time
+
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewEMFRecorder creates a recorder publishing under namespace. Default dimensions, such as
+// the function name, are added to every metric.
+func NewEMFRecorder(w io.Writer, namespace string, defaults Dimensions) *EMFRecorder {
+	return &EMFRecorder{
+		namespace: namespace,
+		defaults:  defaults,
+		now:       time.Now,
+		w:         w,
+	}
+}
+
+type emfMetadata struct {
+	Timestamp         int64          `json:"Timestamp"`
+	CloudWatchMetrics []emfDirective `json:"CloudWatchMetrics"`
+}
+
+type emfDirective struct {
+	Namespace  string      `json:"Namespace"`
+	Dimensions [][]string  `json:"Dimensions"`
+	Metrics    []emfMetric `json:"Metrics"`
+}
+
+type emfMetric struct {
+	Name string `json:"Name"`
+	Unit Unit   `json:"Unit"`
+}
+
+func (r *EMFRecorder) Count(name string, value float64, dims Dimensions) {
+	r.emit(name, value, UnitCount, dims)
+}
+
+func (r *EMFRecorder) Observe(name string, value float64, unit Unit, dims Dimensions) {
+	r.emit(name, value, unit, dims)
+}
+
+func (r *EMFRecorder) emit(name string, value float64, unit Unit, dims Dimensions) {
+	merged := Dimensions{}
+	for k, v := range r.defaults {
+		merged[k] = v
+	}
+	for k, v := range dims {
+		merged[k] = v
+	}
+
+	doc := map[string]interface{}{
+		"_aws": emfMetadata{
+			Timestamp: r.now().UnixMilli(),
+			CloudWatchMetrics: []emfDirective{{
+				Namespace:  r.namespace,
+				Dimensions: [][]string{merged.keys()},
+				Metrics:    []emfMetric{{Name: name, Unit: unit}},
+			}},
+		},
+		name: value,
+	}
+	for k, v := range merged {
+		doc[k] = v
+	}
+
+	line, err := json.Marshal(doc)
+	if err != nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.w.Write(append(line, '\n'))
+}
@@ -0,0 +1,73 @@
+package metrics
+
+import "sync"
+
+// Sample is a single metric recorded by MemoryRecorder
+type Sample struct {
+	Name  string
+	Value float64
+	Unit  Unit
+	Dims  Dimensions
+}
+
+// MemoryRecorder keeps every metric in memory so tests can assert on them
+type MemoryRecorder struct {
+	mu      sync.Mutex
+	samples []Sample
+}
+
+func NewMemoryRecorder() *MemoryRecorder {
+	return &MemoryRecorder{}
+}
+
+func (r *MemoryRecorder) Count(name string, value float64, dims Dimensions) {
+	r.record(Sample{Name: name, Value: value, Unit: UnitCount, Dims: dims})
+}
+
+func (r *MemoryRecorder) Observe(name string, value float64, unit Unit, dims Dimensions) {
+	r.record(Sample{Name: name, Value: value, Unit: unit, Dims: dims})
+}
+
+func (r *MemoryRecorder) record(s Sample) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.samples = append(r.samples, s)
+}
+
+// Samples returns every recorded metric in order
+func (r *MemoryRecorder) Samples() []Sample {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Sample(nil), r.samples...)
+}
+
+// Counter sums the counter name across samples whose dimensions include dims
+func (r *MemoryRecorder) Counter(name string, dims Dimensions) float64 {
+	var total float64
+	for _, s := range r.Samples() {
+		if s.Name == name && s.Unit == UnitCount && matches(s.Dims, dims) {
+			total += s.Value
+		}
+	}
+	return total
+}
+
+// Observations returns the values of distribution name whose dimensions include dims
+func (r *MemoryRecorder) Observations(name string, dims Dimensions) []float64 {
+	var values []float64
+	for _, s := range r.Samples() {
+		if s.Name == name && s.Unit != UnitCount && matches(s.Dims, dims) {
+			values = append(values, s.Value)
+		}
+	}
+	return values
+}
+
+func matches(have, want Dimensions) bool {
+	for k, v := range want {
+		if have[k] != v {
+			return false
+		}
+	}
+	return true
+}
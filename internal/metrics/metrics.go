@@ -0,0 +1,56 @@
+package metrics
+
+import (
+	"sort"
+	"time"
+)
+
+// Metric names emitted by the application
+const (
+	TransactionOutcome = "TransactionOutcome"
+	HandlerLatency     = "HandlerLatency"
+	ProviderLatency    = "ProviderLatency"
+	ColdStart          = "ColdStart"
+	InitDuration       = "InitDuration"
+	// DateOfBirthMismatch counts pay-transactions whose date of birth did not match the patient
+	DateOfBirthMismatch = "DateOfBirthMismatch"
+)
+
+type Unit string
+
+const (
+	UnitCount        Unit = "Count"
+	UnitMilliseconds Unit = "Milliseconds"
+)
+
+// Dimensions are the name/value pairs a metric is broken down by, e.g. status=success
+type Dimensions map[string]string
+
+// Recorder is the port through which the application reports metrics
+type Recorder interface {
+	// Count adds value to a counter
+	Count(name string, value float64, dims Dimensions)
+	// Observe records one sample of a distribution such as a latency histogram
+	Observe(name string, value float64, unit Unit, dims Dimensions)
+}
+
+// ObserveDuration records d in milliseconds
+func ObserveDuration(r Recorder, name string, d time.Duration, dims Dimensions) {
+	r.Observe(name, float64(d)/float64(time.Millisecond), UnitMilliseconds, dims)
+}
+
+// keys returns the dimension names in a stable order
+func (d Dimensions) keys() []string {
+	keys := make([]string, 0, len(d))
+	for k := range d {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// NoopRecorder discards every metric
+type NoopRecorder struct{}
+
+func (NoopRecorder) Count(string, float64, Dimensions)         {}
+func (NoopRecorder) Observe(string, float64, Unit, Dimensions) {}
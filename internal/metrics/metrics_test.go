@@ -0,0 +1,65 @@
+package metrics
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEMFRecorder_Count(t *testing.T) {
+	var buf bytes.Buffer
+	r := NewEMFRecorder(&buf, "TestNamespace", Dimensions{"service": "api"})
+	r.now = func() time.Time { return time.UnixMilli(1700000000000) }
+
+	r.Count(TransactionOutcome, 1, Dimensions{"status": "success", "record_type": "NEW"})
+
+	assert.JSONEq(t, `{
+		"_aws": {
+			"Timestamp": 1700000000000,
+			"CloudWatchMetrics": [{
+				"Namespace": "TestNamespace",
+				"Dimensions": [["record_type", "service", "status"]],
+				"Metrics": [{"Name": "TransactionOutcome", "Unit": "Count"}]
+			}]
+		},
+		"TransactionOutcome": 1,
+		"record_type": "NEW",
+		"service": "api",
+		"status": "success"
+	}`, buf.String())
+}
+
+func TestEMFRecorder_OneDocumentPerLine(t *testing.T) {
+	var buf bytes.Buffer
+	r := NewEMFRecorder(&buf, "TestNamespace", nil)
+
+	ObserveDuration(r, ProviderLatency, 1500*time.Microsecond, nil)
+	r.Count(TransactionOutcome, 1, nil)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Len(t, lines, 2)
+
+	var doc map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(lines[0]), &doc))
+	assert.Equal(t, 1.5, doc[ProviderLatency])
+	assert.Contains(t, lines[0], `"Unit":"Milliseconds"`)
+}
+
+func TestMemoryRecorder(t *testing.T) {
+	r := NewMemoryRecorder()
+
+	r.Count(TransactionOutcome, 1, Dimensions{"status": "success", "record_type": "NEW"})
+	r.Count(TransactionOutcome, 1, Dimensions{"status": "failed", "record_type": "NEW"})
+	r.Count(TransactionOutcome, 1, Dimensions{"status": "failed", "record_type": "NEW"})
+	ObserveDuration(r, HandlerLatency, 20*time.Millisecond, Dimensions{"route": "/ping"})
+
+	assert.Equal(t, float64(3), r.Counter(TransactionOutcome, nil))
+	assert.Equal(t, float64(2), r.Counter(TransactionOutcome, Dimensions{"status": "failed"}))
+	assert.Equal(t, float64(0), r.Counter(TransactionOutcome, Dimensions{"record_type": "RENEWAL"}))
+	assert.Equal(t, []float64{20}, r.Observations(HandlerLatency, Dimensions{"route": "/ping"}))
+	assert.Len(t, r.Samples(), 4)
+}
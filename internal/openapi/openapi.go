@@ -0,0 +1,223 @@
+// Package openapi describes the routes of a gin router as an OpenAPI 3 document. Paths,
+// methods and path parameters come from the router. Bodies and parameters come from the Go
+// types documented for each handler, read through their json, form, uri and binding tags.
+package openapi
+
+import (
+	"net/http"
+	"reflect"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Operation documents a handler. Types are given as values, e.g. handler.TransactionV1{}.
+type Operation struct {
+	Summary     string
+	Description string
+	// Params is a struct whose uri tagged fields are path parameters and whose form tagged
+	// fields are query parameters
+	Params interface{}
+	// Headers are request headers read by the handler, with their description
+	Headers map[string]string
+	// Request is the JSON request body
+	Request interface{}
+	// Responses maps status codes to their JSON body, nil for responses without one
+	Responses map[int]interface{}
+	// Security names the security schemes, added with Docs.SecurityScheme, the route requires
+	Security []string
+}
+
+// OneOf documents a response whose body is any of the given types
+type OneOf []interface{}
+
+// Docs documents the handlers of a router
+type Docs struct {
+	operations      map[string]Operation
+	validators      map[string]Validator
+	securitySchemes map[string]SecurityScheme
+	ignored         []string
+}
+
+func NewDocs() *Docs {
+	return &Docs{
+		operations:      map[string]Operation{},
+		validators:      map[string]Validator{},
+		securitySchemes: map[string]SecurityScheme{},
+	}
+}
+
+// Add documents every route served by handler
+func (d *Docs) Add(handler gin.HandlerFunc, operation Operation) {
+	d.operations[handlerName(handler)] = operation
+}
+
+// Validator documents a custom binding tag, such as one registered with the gin validator
+func (d *Docs) Validator(tag string, validator Validator) {
+	d.validators[tag] = validator
+}
+
+// SecurityScheme adds a security scheme operations can name in their Security
+func (d *Docs) SecurityScheme(name string, scheme SecurityScheme) {
+	d.securitySchemes[name] = scheme
+}
+
+// Ignore leaves the routes under prefix out of the document, e.g. /debug/pprof
+func (d *Docs) Ignore(prefix string) {
+	d.ignored = append(d.ignored, prefix)
+}
+
+// Undocumented lists the routes neither documented nor ignored, as "METHOD path"
+func (d *Docs) Undocumented(routes gin.RoutesInfo) []string {
+	var rs []string
+	for _, route := range routes {
+		if _, ok := d.operations[route.Handler]; !ok && !d.isIgnored(route.Path) {
+			rs = append(rs, route.Method+" "+route.Path)
+		}
+	}
+	sort.Strings(rs)
+	return rs
+}
+
+// Build returns the document of the documented routes, see Undocumented for the others
+func (d *Docs) Build(info Info, routes gin.RoutesInfo) *Document {
+	doc := &Document{
+		OpenAPI: Version,
+		Info:    info,
+		Paths:   map[string]*PathItem{},
+	}
+	schemas := newSchemas(d.validators)
+
+	for _, route := range routes {
+		operation, ok := d.operations[route.Handler]
+		if !ok || d.isIgnored(route.Path) {
+			continue
+		}
+
+		path := openAPIPath(route.Path)
+		item, ok := doc.Paths[path]
+		if !ok {
+			item = &PathItem{}
+			doc.Paths[path] = item
+		}
+		(*item)[strings.ToLower(route.Method)] = newOperation(schemas, route, operation)
+	}
+
+	doc.Components.Schemas = schemas.components
+	if len(d.securitySchemes) > 0 {
+		doc.Components.SecuritySchemes = d.securitySchemes
+	}
+	return doc
+}
+
+func newOperation(schemas *schemas, route gin.RouteInfo, operation Operation) *OperationObject {
+	rs := &OperationObject{
+		OperationID: operationID(route),
+		Summary:     operation.Summary,
+		Description: operation.Description,
+		Parameters:  parameters(schemas, route.Path, operation),
+		Responses:   map[string]*Response{},
+	}
+	if operation.Request != nil {
+		rs.RequestBody = &RequestBody{
+			Required: true,
+			Content:  map[string]MediaType{"application/json": {Schema: schemas.body(operation.Request)}},
+		}
+	}
+	for code, body := range operation.Responses {
+		response := &Response{Description: http.StatusText(code)}
+		if body != nil {
+			response.Content = map[string]MediaType{"application/json": {Schema: schemas.body(body)}}
+		}
+		rs.Responses[strconv.Itoa(code)] = response
+	}
+	for _, name := range operation.Security {
+		rs.Security = append(rs.Security, map[string][]string{name: {}})
+	}
+	if len(rs.Responses) == 0 {
+		rs.Responses["default"] = &Response{Description: "Response"}
+	}
+	return rs
+}
+
+// parameters documents the path parameters of the route, typed by the uri fields of Params
+// when it has them, then the query parameters of Params and the headers
+func parameters(schemas *schemas, path string, operation Operation) []Parameter {
+	fields := map[string]reflect.StructField{}
+	var query []reflect.StructField
+	if operation.Params != nil {
+		t := indirect(reflect.TypeOf(operation.Params))
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if name, _ := tagName(field.Tag.Get("uri")); name != "" {
+				fields[name] = field
+			} else if name, _ := tagName(field.Tag.Get("form")); name != "" && name != "-" {
+				query = append(query, field)
+			}
+		}
+	}
+
+	var rs []Parameter
+	for _, name := range pathParams(path) {
+		schema := &Schema{Type: "string"}
+		if field, ok := fields[name]; ok {
+			schema, _ = schemas.field(field)
+		}
+		rs = append(rs, Parameter{Name: name, In: "path", Required: true, Schema: schema})
+	}
+	for _, field := range query {
+		name, _ := tagName(field.Tag.Get("form"))
+		schema, required := schemas.field(field)
+		rs = append(rs, Parameter{Name: name, In: "query", Required: required, Schema: schema})
+	}
+
+	headers := make([]string, 0, len(operation.Headers))
+	for name := range operation.Headers {
+		headers = append(headers, name)
+	}
+	sort.Strings(headers)
+	for _, name := range headers {
+		rs = append(rs, Parameter{Name: name, In: "header", Description: operation.Headers[name], Schema: &Schema{Type: "string"}})
+	}
+	return rs
+}
+
+func (d *Docs) isIgnored(path string) bool {
+	for _, prefix := range d.ignored {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+var pathParam = regexp.MustCompile(`[:*]([^/]+)`)
+
+// openAPIPath turns gin path parameters into OpenAPI ones, /transactions/:id into /transactions/{id}
+func openAPIPath(path string) string {
+	return pathParam.ReplaceAllString(path, "{$1}")
+}
+
+func pathParams(path string) []string {
+	var rs []string
+	for _, match := range pathParam.FindAllStringSubmatch(path, -1) {
+		rs = append(rs, match[1])
+	}
+	return rs
+}
+
+var nonWord = regexp.MustCompile(`[^A-Za-z0-9]+`)
+
+// operationID is unique per route, e.g. get_app_v2_transactions_id
+func operationID(route gin.RouteInfo) string {
+	return strings.ToLower(route.Method) + "_" + strings.Trim(nonWord.ReplaceAllString(route.Path, "_"), "_")
+}
+
+// handlerName names a handler the way gin does in RouteInfo.Handler
+func handlerName(handler gin.HandlerFunc) string {
+	return runtime.FuncForPC(reflect.ValueOf(handler).Pointer()).Name()
+}
@@ -0,0 +1,121 @@
+package openapi_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/datphamcode295/go-lambda-pulumi/internal/openapi"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+type audited struct {
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type request struct {
+	audited
+	ID       uuid.UUID       `json:"id" binding:"required"`
+	Birth    string          `json:"birth" binding:"required,ddmmyyyy"`
+	Day      string          `json:"day" binding:"datetime=2006-01-02"`
+	Kind     string          `json:"kind" binding:"oneof=a b"`
+	Count    int             `json:"count" binding:"min=1,max=5"`
+	Note     *string         `json:"note,omitempty"`
+	Raw      json.RawMessage `json:"raw"`
+	Secret   string          `json:"-"`
+	internal string
+}
+
+type response struct {
+	Items []request `json:"items"`
+}
+
+type params struct {
+	ID    uuid.UUID `uri:"id"`
+	Limit int       `form:"limit" binding:"max=100"`
+}
+
+func handle(ctx *gin.Context) {}
+
+func unused(ctx *gin.Context) {}
+
+func newRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/items/:id", handle)
+	router.GET("/others", unused)
+	router.GET("/debug/pprof/", unused)
+	return router
+}
+
+func newDocs() *openapi.Docs {
+	docs := openapi.NewDocs()
+	docs.Ignore("/debug")
+	docs.Validator("ddmmyyyy", func(schema *openapi.Schema, _ string) {
+		schema.Pattern = `^\d{2}-\d{2}-\d{4}$`
+	})
+	docs.Add(handle, openapi.Operation{
+		Summary:   "Handle",
+		Params:    params{},
+		Headers:   map[string]string{"X-Client-Id": "client"},
+		Request:   request{},
+		Responses: map[int]interface{}{http.StatusOK: response{}, http.StatusNoContent: nil},
+	})
+	return docs
+}
+
+func TestDocs_Build(t *testing.T) {
+	router := newRouter()
+	doc := newDocs().Build(openapi.Info{Title: "test", Version: "1"}, router.Routes())
+
+	assert.Equal(t, openapi.Version, doc.OpenAPI)
+	assert.Len(t, doc.Paths, 1)
+	operation := (*doc.Paths["/items/{id}"])["post"]
+	if !assert.NotNil(t, operation) {
+		return
+	}
+	assert.Equal(t, "post_items_id", operation.OperationID)
+	assert.Equal(t, []openapi.Parameter{
+		{Name: "id", In: "path", Required: true, Schema: &openapi.Schema{Type: "string", Format: "uuid"}},
+		{Name: "limit", In: "query", Schema: &openapi.Schema{Type: "integer", Format: "int32", Maximum: floatPtr(100)}},
+		{Name: "X-Client-Id", In: "header", Description: "client", Schema: &openapi.Schema{Type: "string"}},
+	}, operation.Parameters)
+	assert.Equal(t, "#/components/schemas/request", operation.RequestBody.Content["application/json"].Schema.Ref)
+	assert.Equal(t, "OK", operation.Responses["200"].Description)
+	assert.Nil(t, operation.Responses["204"].Content)
+
+	schema := doc.Components.Schemas["request"]
+	assert.ElementsMatch(t, []string{"id", "birth"}, schema.Required)
+	assert.ElementsMatch(t, []string{"created_at", "id", "birth", "day", "kind", "count", "note", "raw"}, keys(schema.Properties))
+	assert.Equal(t, "date-time", schema.Properties["created_at"].Format)
+	assert.Equal(t, `^\d{2}-\d{2}-\d{4}$`, schema.Properties["birth"].Pattern)
+	assert.Equal(t, "date", schema.Properties["day"].Format)
+	assert.Equal(t, []interface{}{"a", "b"}, schema.Properties["kind"].Enum)
+	assert.Equal(t, floatPtr(1), schema.Properties["count"].Minimum)
+	assert.Equal(t, floatPtr(5), schema.Properties["count"].Maximum)
+	assert.True(t, schema.Properties["note"].Nullable)
+	assert.Equal(t, &openapi.Schema{}, schema.Properties["raw"])
+
+	items := doc.Components.Schemas["response"].Properties["items"]
+	assert.Equal(t, "array", items.Type)
+	assert.Equal(t, "#/components/schemas/request", items.Items.Ref)
+}
+
+func TestDocs_Undocumented(t *testing.T) {
+	assert.Equal(t, []string{"GET /others"}, newDocs().Undocumented(newRouter().Routes()))
+}
+
+func floatPtr(f float64) *float64 {
+	return &f
+}
+
+func keys(m map[string]*openapi.Schema) []string {
+	var rs []string
+	for k := range m {
+		rs = append(rs, k)
+	}
+	return rs
+}
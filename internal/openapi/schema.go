@@ -0,0 +1,263 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	uuidType       = reflect.TypeOf(uuid.UUID{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+// Validator documents a custom binding tag on the schema of the field carrying it, param is
+// what follows the "=" of the tag
+type Validator func(schema *Schema, param string)
+
+// schemas builds the schemas of Go types, named structs are added to components and referenced
+type schemas struct {
+	components map[string]*Schema
+	names      map[reflect.Type]string
+	validators map[string]Validator
+}
+
+func newSchemas(validators map[string]Validator) *schemas {
+	return &schemas{
+		components: map[string]*Schema{},
+		names:      map[reflect.Type]string{},
+		validators: validators,
+	}
+}
+
+// body returns the schema of the type of v, or of any of the types of a OneOf
+func (s *schemas) body(v interface{}) *Schema {
+	alternatives, ok := v.(OneOf)
+	if !ok {
+		return s.of(reflect.TypeOf(v))
+	}
+	schema := &Schema{}
+	for _, alternative := range alternatives {
+		schema.OneOf = append(schema.OneOf, s.of(reflect.TypeOf(alternative)))
+	}
+	return schema
+}
+
+// of returns the schema of t
+func (s *schemas) of(t reflect.Type) *Schema {
+	switch t {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case uuidType:
+		return &Schema{Type: "string", Format: "uuid"}
+	case rawMessageType:
+		// any JSON value
+		return &Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		schema := s.of(t.Elem())
+		if schema.Ref == "" {
+			schema.Nullable = true
+		}
+		return schema
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: s.of(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: s.of(t.Elem())}
+	case reflect.Interface:
+		return &Schema{}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return s.object(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + s.component(t)}
+	default:
+		return &Schema{}
+	}
+}
+
+// component adds the schema of the named struct t to the components once and returns its name
+func (s *schemas) component(t reflect.Type) string {
+	if name, ok := s.names[t]; ok {
+		return name
+	}
+
+	name := t.Name()
+	if _, taken := s.components[name]; taken {
+		name = strings.ReplaceAll(t.String(), ".", "")
+	}
+	s.names[t] = name
+	// registered before the fields are walked, so recursive types end in a reference
+	s.components[name] = &Schema{}
+	*s.components[name] = *s.object(t)
+	return name
+}
+
+// object is the schema of the JSON object of struct t, embedded structs are flattened
+func (s *schemas) object(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _ := tagName(field.Tag.Get("json"))
+		if name == "-" || field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+
+		if field.Anonymous && name == "" && indirect(field.Type).Kind() == reflect.Struct {
+			embedded := s.object(indirect(field.Type))
+			for property, propertySchema := range embedded.Properties {
+				schema.Properties[property] = propertySchema
+			}
+			schema.Required = append(schema.Required, embedded.Required...)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		property, required := s.field(field)
+		schema.Properties[name] = property
+		if required {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+	return schema
+}
+
+// field returns the schema of a struct field with its binding tag applied, and whether the
+// binding tag requires it
+func (s *schemas) field(field reflect.StructField) (*Schema, bool) {
+	schema := s.of(field.Type)
+	if layout := field.Tag.Get("time_format"); layout != "" {
+		applyDateLayout(schema, layout)
+	}
+	return schema, s.applyBinding(schema, field.Type, field.Tag.Get("binding"))
+}
+
+// applyBinding documents the rules of a binding tag on schema and tells whether the field is
+// required. Rules neither built in nor registered as a Validator are left out.
+func (s *schemas) applyBinding(schema *Schema, t reflect.Type, binding string) bool {
+	if binding == "" {
+		return false
+	}
+
+	required := false
+	for _, rule := range strings.Split(binding, ",") {
+		tag, param, _ := strings.Cut(rule, "=")
+		switch tag {
+		case "required":
+			required = true
+		case "oneof":
+			schema.Enum = nil
+			for _, value := range strings.Fields(param) {
+				schema.Enum = append(schema.Enum, enumValue(indirect(t), value))
+			}
+		case "min", "max", "len":
+			applyBound(schema, tag, param)
+		case "datetime":
+			applyDateLayout(schema, param)
+		case "email", "uuid", "uri":
+			schema.Format = tag
+		case "url":
+			schema.Format = "uri"
+		case "required_without", "required_with", "required_if", "required_unless":
+			appendDescription(schema, strings.ReplaceAll(tag, "_", " ")+" "+param)
+		default:
+			if validator, ok := s.validators[tag]; ok {
+				validator(schema, param)
+			}
+		}
+	}
+	return required
+}
+
+// applyBound documents a min, max or len rule, as the length of strings and the value of numbers
+func applyBound(schema *Schema, tag, param string) {
+	n, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return
+	}
+	length := int(n)
+	switch schema.Type {
+	case "string":
+		if tag != "max" {
+			schema.MinLength = &length
+		}
+		if tag != "min" {
+			schema.MaxLength = &length
+		}
+	case "integer", "number":
+		if tag != "max" {
+			schema.Minimum = &n
+		}
+		if tag != "min" {
+			schema.Maximum = &n
+		}
+	}
+}
+
+// applyDateLayout documents a Go time layout as a format
+func applyDateLayout(schema *Schema, layout string) {
+	switch layout {
+	case "2006-01-02":
+		schema.Format = "date"
+	case time.RFC3339, time.RFC3339Nano:
+		schema.Format = "date-time"
+	default:
+		appendDescription(schema, "formatted as Go layout "+layout)
+	}
+}
+
+func appendDescription(schema *Schema, text string) {
+	if schema.Description != "" {
+		schema.Description += ", "
+	}
+	schema.Description += text
+}
+
+// enumValue returns value typed as the kind of t, so integer enums are not strings
+func enumValue(t reflect.Type, value string) interface{} {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return n
+		}
+	case reflect.Float32, reflect.Float64:
+		if n, err := strconv.ParseFloat(value, 64); err == nil {
+			return n
+		}
+	}
+	return value
+}
+
+func indirect(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+// tagName splits a json, form or uri tag into its name and options
+func tagName(tag string) (string, string) {
+	name, options, _ := strings.Cut(tag, ",")
+	return name, options
+}
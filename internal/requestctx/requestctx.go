@@ -0,0 +1,69 @@
+package requestctx
+
+import "context"
+
+type contextKey int
+
+const (
+	requestIDKey contextKey = iota
+	actorKey
+	claimedActorKey
+	sourceIPKey
+)
+
+// WithRequestID returns a copy of ctx carrying the correlation id of the current request
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey, requestID)
+}
+
+// RequestID returns the correlation id stored in ctx, or an empty string
+func RequestID(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// WithActor returns a copy of ctx carrying who the current request acts for
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey, actor)
+}
+
+// Actor returns the actor stored in ctx, or an empty string
+func Actor(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	actor, _ := ctx.Value(actorKey).(string)
+	return actor
+}
+
+// WithClaimedActor returns a copy of ctx carrying who the caller says the request acts for,
+// unverified
+func WithClaimedActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, claimedActorKey, actor)
+}
+
+// ClaimedActor returns the claimed actor stored in ctx, or an empty string
+func ClaimedActor(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	actor, _ := ctx.Value(claimedActorKey).(string)
+	return actor
+}
+
+// WithSourceIP returns a copy of ctx carrying the address the current request came from
+func WithSourceIP(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, sourceIPKey, ip)
+}
+
+// SourceIP returns the source address stored in ctx, or an empty string
+func SourceIP(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	ip, _ := ctx.Value(sourceIPKey).(string)
+	return ip
+}
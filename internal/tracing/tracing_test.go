@@ -0,0 +1,58 @@
+package tracing
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/datphamcode295/go-lambda-pulumi/internal/config"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+func TestSetup_FileExporter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "traces.json")
+	shutdown, err := Setup(context.Background(), config.TracingConfig{
+		Exporter:    ExporterFile,
+		FilePath:    path,
+		ServiceName: "test",
+		SampleRatio: 1,
+	})
+	assert.NoError(t, err)
+
+	_, span := Tracer().Start(context.Background(), "test-span")
+	span.End()
+	assert.NoError(t, shutdown(context.Background()))
+
+	content, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Contains(t, string(content), `"Name": "test-span"`)
+}
+
+func TestSetup_UnknownExporter(t *testing.T) {
+	_, err := Setup(context.Background(), config.TracingConfig{Exporter: "carrier-pigeon"})
+	assert.EqualError(t, err, `unknown trace exporter "carrier-pigeon"`)
+}
+
+func TestSetup_NoneStillPropagates(t *testing.T) {
+	_, err := Setup(context.Background(), config.TracingConfig{Exporter: ExporterNone})
+	assert.NoError(t, err)
+
+	carrier := propagation.MapCarrier{"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"}
+	ctx := otel.GetTextMapPropagator().Extract(context.Background(), carrier)
+
+	out := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, out)
+	assert.Equal(t, carrier["traceparent"], out["traceparent"])
+}
+
+func TestPropagator_XRayHeader(t *testing.T) {
+	carrier := propagation.MapCarrier{"X-Amzn-Trace-Id": "Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8;Sampled=1"}
+	ctx := Propagator().Extract(context.Background(), carrier)
+
+	out := propagation.MapCarrier{}
+	Propagator().Inject(ctx, out)
+	assert.Equal(t, "00-5759e988bd862e3fe1be46a994272793-53995c3f42cd8ad8-01", out["traceparent"])
+}
@@ -0,0 +1,164 @@
+package util
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/datphamcode295/go-lambda-pulumi/internal/core/domain"
+	"github.com/go-playground/validator/v10"
+)
+
+// DefaultDateFormats are accepted from clients without formats of their own
+var DefaultDateFormats = []string{"DD-MM-YYYY", "YYYY-MM-DD"}
+
+// ambiguityProbe has a day that is also a valid month, so formats swapping day and month read it differently
+var ambiguityProbe = time.Date(2001, time.March, 2, 0, 0, 0, 0, time.UTC)
+
+// DateFormatter is implemented by requests that carry the date formats accepted from their client
+type DateFormatter interface {
+	AcceptedDateFormats() []string
+}
+
+type dateFormat struct {
+	name   string
+	layout string
+}
+
+// DateParser knows the date formats accepted from each client. Formats are written with
+// DD, MM and YYYY, e.g. "MM/DD/YYYY". Dates are returned as domain.Date, which always
+// marshals as DD-MM-YYYY to JSON and as a date to the database whatever format it came in.
+type DateParser struct {
+	defaults []dateFormat
+	clients  map[string][]dateFormat
+}
+
+// NewDateParser accepts defaults from every client, or DefaultDateFormats when empty, and
+// replaces them by the formats listed in clients for those clients. Every list must be
+// unambiguous: no date may read differently in two of its formats, as 02/03/2001 does in
+// MM/DD/YYYY and DD/MM/YYYY.
+func NewDateParser(defaults []string, clients map[string][]string) (*DateParser, error) {
+	if len(defaults) == 0 {
+		defaults = DefaultDateFormats
+	}
+	p := &DateParser{clients: map[string][]dateFormat{}}
+	var err error
+	if p.defaults, err = parseDateFormats(defaults); err != nil {
+		return nil, fmt.Errorf("date formats: %w", err)
+	}
+	for client, formats := range clients {
+		if p.clients[client], err = parseDateFormats(formats); err != nil {
+			return nil, fmt.Errorf("date formats of client %q: %w", client, err)
+		}
+	}
+	return p, nil
+}
+
+// DefaultDateParser accepts DefaultDateFormats from every client
+func DefaultDateParser() *DateParser {
+	p, _ := NewDateParser(nil, nil)
+	return p
+}
+
+// Formats returns the formats accepted from client
+func (p *DateParser) Formats(client string) []string {
+	var names []string
+	for _, format := range p.formats(client) {
+		names = append(names, format.name)
+	}
+	return names
+}
+
+// Parse reads value in the first of the client's formats it matches
+func (p *DateParser) Parse(client, value string) (domain.Date, error) {
+	return ParseDate(p.Formats(client), value)
+}
+
+// ParseDate reads value in the first of formats it matches, DefaultDateFormats when empty
+func ParseDate(formats []string, value string) (domain.Date, error) {
+	if len(formats) == 0 {
+		formats = DefaultDateFormats
+	}
+	for _, format := range formats {
+		layout, err := dateLayout(format)
+		if err != nil {
+			return domain.Date{}, err
+		}
+		if t, err := time.Parse(layout, value); err == nil {
+			return domain.DateOf(t), nil
+		}
+	}
+	return domain.Date{}, fmt.Errorf("date %q is not in an accepted format (%s)", value, strings.Join(formats, ", "))
+}
+
+// ValidateDate accepts dates in the formats of the validated struct when it is a DateFormatter,
+// and in DefaultDateFormats otherwise
+func ValidateDate(fl validator.FieldLevel) bool {
+	var formats []string
+	if top := fl.Top(); top.IsValid() && top.CanInterface() {
+		if f, ok := top.Interface().(DateFormatter); ok {
+			formats = f.AcceptedDateFormats()
+		}
+	}
+	field := fl.Field()
+	if field.Kind() != reflect.String {
+		return false
+	}
+	_, err := ParseDate(formats, field.String())
+	return err == nil
+}
+
+func (p *DateParser) formats(client string) []dateFormat {
+	if formats, ok := p.clients[client]; ok {
+		return formats
+	}
+	return p.defaults
+}
+
+func parseDateFormats(names []string) ([]dateFormat, error) {
+	var formats []dateFormat
+	for _, name := range names {
+		layout, err := dateLayout(name)
+		if err != nil {
+			return nil, err
+		}
+		for _, other := range formats {
+			if ambiguous(other.layout, layout) {
+				return nil, fmt.Errorf("%s and %s are ambiguous", other.name, name)
+			}
+		}
+		formats = append(formats, dateFormat{name: name, layout: layout})
+	}
+	if len(formats) == 0 {
+		return nil, fmt.Errorf("no format")
+	}
+	return formats, nil
+}
+
+// dateLayout translates a format such as DD-MM-YYYY to a Go time layout. Each of DD, MM and
+// YYYY must appear once, so two digit years are not accepted.
+func dateLayout(format string) (string, error) {
+	layout := format
+	for _, token := range []struct{ name, layout string }{{"YYYY", "2006"}, {"MM", "01"}, {"DD", "02"}} {
+		if strings.Count(layout, token.name) != 1 {
+			return "", fmt.Errorf("format %q must contain %s once", format, token.name)
+		}
+		layout = strings.Replace(layout, token.name, token.layout, 1)
+	}
+	if strings.ContainsAny(layout, "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz") {
+		return "", fmt.Errorf("format %q may only contain DD, MM, YYYY and separators", format)
+	}
+	return layout, nil
+}
+
+// ambiguous reports whether a date written in one layout reads as another date in the other
+func ambiguous(a, b string) bool {
+	for _, pair := range [][2]string{{a, b}, {b, a}} {
+		t, err := time.Parse(pair[1], ambiguityProbe.Format(pair[0]))
+		if err == nil && !t.Equal(ambiguityProbe) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,184 @@
+package util
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewDateParser_RejectsInvalidFormats(t *testing.T) {
+	testCases := []struct {
+		name     string
+		defaults []string
+		clients  map[string][]string
+		expected string
+	}{
+		{
+			name:     "Day and month swapped",
+			defaults: []string{"MM/DD/YYYY", "DD/MM/YYYY"},
+			expected: "date formats: MM/DD/YYYY and DD/MM/YYYY are ambiguous",
+		},
+		{
+			name:     "Ambiguous client formats",
+			clients:  map[string][]string{"partner": {"DD-MM-YYYY", "MM-DD-YYYY"}},
+			expected: `date formats of client "partner": DD-MM-YYYY and MM-DD-YYYY are ambiguous`,
+		},
+		{
+			name:     "Two digit year",
+			defaults: []string{"DD-MM-YY"},
+			expected: `date formats: format "DD-MM-YY" must contain YYYY once`,
+		},
+		{
+			name:     "Unknown token",
+			defaults: []string{"DD MMM YYYY"},
+			expected: `date formats: format "DD MMM YYYY" may only contain DD, MM, YYYY and separators`,
+		},
+		{
+			name:     "Client without formats",
+			clients:  map[string][]string{"partner": nil},
+			expected: `date formats of client "partner": no format`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := NewDateParser(tc.defaults, tc.clients)
+			assert.EqualError(t, err, tc.expected)
+		})
+	}
+}
+
+func TestDateParser_Parse(t *testing.T) {
+	parser, err := NewDateParser(nil, map[string][]string{"us-partner": {"MM/DD/YYYY", "YYYY-MM-DD"}})
+	assert.NoError(t, err)
+
+	testCases := []struct {
+		name     string
+		client   string
+		input    string
+		expected string
+		wantErr  bool
+	}{
+		{name: "Default DD-MM-YYYY", input: "15-03-1990", expected: "15-03-1990"},
+		{name: "Default ISO 8601", input: "1990-03-15", expected: "15-03-1990"},
+		{name: "Unknown client uses defaults", client: "other", input: "1990-03-15", expected: "15-03-1990"},
+		{name: "Default rejects US format", input: "03/15/1990", wantErr: true},
+		{name: "Client US format", client: "us-partner", input: "03/15/1990", expected: "15-03-1990"},
+		{name: "Client ISO 8601", client: "us-partner", input: "1990-03-15", expected: "15-03-1990"},
+		{name: "Client replaces defaults", client: "us-partner", input: "15-03-1990", wantErr: true},
+		{name: "Invalid day", input: "30-02-1990", wantErr: true},
+		{name: "Single digit day", input: "5-03-1990", wantErr: true},
+		{name: "Empty", input: "", wantErr: true},
+
+		// calendar edge cases
+		{name: "Leap day", input: "29-02-2020", expected: "29-02-2020"},
+		{name: "Leap day of a year divisible by 400", input: "29-02-2000", expected: "29-02-2000"},
+		{name: "Leap day of 1600", input: "29-02-1600", expected: "29-02-1600"},
+		{name: "Leap day of a non-leap year", input: "29-02-2021", wantErr: true},
+		{name: "Leap day of 1900, divisible by 100 only", input: "29-02-1900", wantErr: true},
+		{name: "Leap day of 1700, divisible by 100 only", input: "29-02-1700", wantErr: true},
+		{name: "28 February of a non-leap year", input: "28-02-2021", expected: "28-02-2021"},
+		{name: "28 February of a leap year", input: "28-02-2020", expected: "28-02-2020"},
+		{name: "30 February", input: "30-02-2020", wantErr: true},
+		{name: "First day of the year", input: "01-01-2000", expected: "01-01-2000"},
+		{name: "Last day of the year", input: "31-12-2023", expected: "31-12-2023"},
+		{name: "Old date", input: "01-01-1900", expected: "01-01-1900"},
+		{name: "Future date", input: "25-12-2030", expected: "25-12-2030"},
+		{name: "31 January", input: "31-01-2022", expected: "31-01-2022"},
+		{name: "31 March", input: "31-03-2022", expected: "31-03-2022"},
+		{name: "31 May", input: "31-05-2022", expected: "31-05-2022"},
+		{name: "31 July", input: "31-07-2022", expected: "31-07-2022"},
+		{name: "31 August", input: "31-08-2022", expected: "31-08-2022"},
+		{name: "31 October", input: "31-10-2022", expected: "31-10-2022"},
+		{name: "31 December", input: "31-12-2022", expected: "31-12-2022"},
+		{name: "30 April", input: "30-04-2022", expected: "30-04-2022"},
+		{name: "30 June", input: "30-06-2022", expected: "30-06-2022"},
+		{name: "30 September", input: "30-09-2022", expected: "30-09-2022"},
+		{name: "30 November", input: "30-11-2022", expected: "30-11-2022"},
+		{name: "31 April", input: "31-04-2022", wantErr: true},
+		{name: "31 June", input: "31-06-2022", wantErr: true},
+		{name: "31 September", input: "31-09-2022", wantErr: true},
+		{name: "31 November", input: "31-11-2022", wantErr: true},
+		{name: "Day 32", input: "32-01-2022", wantErr: true},
+		{name: "Day 0", input: "00-01-2022", wantErr: true},
+		{name: "Month 13", input: "15-13-2022", wantErr: true},
+		{name: "Month 0", input: "15-00-2022", wantErr: true},
+
+		// malformed input
+		{name: "MM-DD-YYYY", input: "03-15-1990", wantErr: true},
+		{name: "Slashes", input: "15/03/1990", wantErr: true},
+		{name: "Dots", input: "15.03.1990", wantErr: true},
+		{name: "No separators", input: "15031990", wantErr: true},
+		{name: "Single digit month", input: "15-3-1990", wantErr: true},
+		{name: "Single digit day and month", input: "1-1-1990", wantErr: true},
+		{name: "Two digit year", input: "15-03-90", wantErr: true},
+		{name: "Three digit year", input: "15-03-199", wantErr: true},
+		{name: "Five digit year", input: "15-03-19901", wantErr: true},
+		{name: "Three digit day", input: "015-03-1990", wantErr: true},
+		{name: "Three digit month", input: "15-003-1990", wantErr: true},
+		{name: "Trailing characters", input: "15-03-1990a", wantErr: true},
+		{name: "Spaces around separators", input: "15 - 03 - 1990", wantErr: true},
+		{name: "Only dashes", input: "--", wantErr: true},
+		{name: "Too many dashes", input: "15-03-19-90", wantErr: true},
+		{name: "Doubled dashes", input: "15--03--1990", wantErr: true},
+		{name: "Mixed separators", input: "15/03-1990", wantErr: true},
+		{name: "Tabs as separators", input: "15\t03\t1990", wantErr: true},
+		{name: "Leading space", input: " 15-03-1990", wantErr: true},
+		{name: "Trailing space", input: "15-03-1990 ", wantErr: true},
+		{name: "Trailing newline", input: "15-03-1990\n", wantErr: true},
+		{name: "Full-width digits", input: "１５-０３-１９９０", wantErr: true},
+		{name: "Non-numeric day", input: "aa-03-1990", wantErr: true},
+		{name: "Non-numeric month", input: "15-bb-1990", wantErr: true},
+		{name: "Non-numeric year", input: "15-03-cccc", wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			date, err := parser.Parse(tc.client, tc.input)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, date.String())
+		})
+	}
+
+	// the minimum date parses, to the zero date standing for a date not on file
+	date, err := parser.Parse("", "01-01-0001")
+	assert.NoError(t, err)
+	assert.True(t, date.IsZero())
+
+	assert.Equal(t, []string{"DD-MM-YYYY", "YYYY-MM-DD"}, parser.Formats(""))
+	assert.Equal(t, []string{"MM/DD/YYYY", "YYYY-MM-DD"}, parser.Formats("us-partner"))
+}
+
+type datedRequest struct {
+	Date    string
+	formats []string
+}
+
+func (r datedRequest) AcceptedDateFormats() []string { return r.formats }
+
+// topFieldLevel is a MockFieldLevel validating a field of top
+type topFieldLevel struct {
+	MockFieldLevel
+	top reflect.Value
+}
+
+func (f *topFieldLevel) Top() reflect.Value { return f.top }
+
+func TestValidateDate(t *testing.T) {
+	assert.True(t, ValidateDate(createMockFieldLevel("1990-03-15")))
+	assert.True(t, ValidateDate(createMockFieldLevel("15-03-1990")))
+	assert.False(t, ValidateDate(createMockFieldLevel("03/15/1990")))
+	assert.False(t, ValidateDate(&MockFieldLevel{value: reflect.ValueOf(19900315)}))
+
+	request := datedRequest{Date: "03/15/1990", formats: []string{"MM/DD/YYYY"}}
+	fl := &topFieldLevel{MockFieldLevel: MockFieldLevel{value: reflect.ValueOf(request.Date)}, top: reflect.ValueOf(&request)}
+	assert.True(t, ValidateDate(fl))
+
+	fl.value = reflect.ValueOf("1990-03-15")
+	assert.False(t, ValidateDate(fl))
+}
@@ -4,6 +4,7 @@ import (
 	"regexp"
 	"time"
 
+	"github.com/datphamcode295/go-lambda-pulumi/internal/core/domain"
 	"github.com/go-playground/validator/v10"
 )
 
@@ -20,3 +21,9 @@ func ValidateDDMMYYYY(fl validator.FieldLevel) bool {
 	_, err := time.Parse("02-01-2006", dateStr)
 	return err == nil
 }
+
+// ValidateRecordType accepts the record types of the domain registry
+func ValidateRecordType(fl validator.FieldLevel) bool {
+	_, ok := domain.LookupRecordType(fl.Field().String())
+	return ok
+}
@@ -599,3 +599,24 @@ func TestValidateDDMMYYYY_NoPanic(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateRecordType(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected bool
+	}{
+		{input: "NEW", expected: true},
+		{input: "RENEWAL", expected: true},
+		{input: "TRANSFER", expected: true},
+		{input: "CORRECTION", expected: true},
+		{input: "new", expected: false},
+		{input: "OLD", expected: false},
+		{input: "", expected: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			assert.Equal(t, tc.expected, ValidateRecordType(createMockFieldLevel(tc.input)))
+		})
+	}
+}
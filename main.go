@@ -2,79 +2,64 @@ package main
 
 import (
 	"context"
-	"encoding/json"
-	"log"
+	"flag"
+	"fmt"
+	// the provided.al2 runtime has no zoneinfo, ELIGIBILITY_TIME_ZONE is resolved from the binary
+	_ "time/tzdata"
 
-	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
-	ginadapter "github.com/awslabs/aws-lambda-go-api-proxy/gin"
-	"github.com/datphamcode295/go-lambda-pulumi/internal/adapters/handler"
-	"github.com/datphamcode295/go-lambda-pulumi/internal/adapters/repository"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/adapters/dispatcher"
+	"github.com/datphamcode295/go-lambda-pulumi/internal/app"
 	"github.com/datphamcode295/go-lambda-pulumi/internal/config"
-	"github.com/datphamcode295/go-lambda-pulumi/internal/core/domain"
-	"github.com/datphamcode295/go-lambda-pulumi/internal/core/services"
 	"github.com/datphamcode295/go-lambda-pulumi/internal/logger"
-	util "github.com/datphamcode295/go-lambda-pulumi/internal/utils"
-	"github.com/gin-contrib/pprof"
-	"github.com/gin-gonic/gin"
-	"github.com/gin-gonic/gin/binding"
-	"github.com/go-playground/validator/v10"
-	"github.com/jinzhu/gorm"
-	_ "github.com/lib/pq"
 )
 
-var (
-	patientService *services.PatientService
-	ginLambda      *ginadapter.GinLambdaV2
-)
+func main() {
+	job := flag.String("job", "", "run the named job once and exit instead of serving Lambda invocations")
+	flag.Parse()
 
-func Handler(ctx context.Context, req events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
-	// parse json request for debugging
-	reqJson, err := json.Marshal(req)
-	if err != nil {
-		log.Println("Error marshalling request", err)
-	}
+	ctx := context.Background()
 
-	log.Println("Request received", string(reqJson))
-	return ginLambda.ProxyWithContext(ctx, req)
-}
+	// loading the configuration logs through the standard log package, which is only redacted
+	// and formatted once the logger is set up
+	logger.SetupLogger(config.NewLogConfig())
 
-func init() {
-	cfg := config.NewConfig()
-	db, err := gorm.Open("postgres", cfg.DatabaseURL)
+	application, err := app.New(ctx, config.NewConfig())
 	if err != nil {
-		panic(err)
+		logger.Log.WithError(err).Fatal("Failed to start application")
 	}
 
-	logger.SetupLogger()
-
-	// Create or modify the database tables based on the model structs found in the imported package
-	db.AutoMigrate(&domain.User{}, &domain.Patient{}, &domain.Transaction{})
-
-	store := repository.NewDB(db)
-
-	patientService = services.NewPatientService(cfg, store, store)
-
-	InitRoutes()
-}
-
-func InitRoutes() {
-	router := gin.Default()
-	// Register custom validator
-	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
-		v.RegisterValidation("ddmmyyyy", util.ValidateDDMMYYYY)
+	if *job != "" {
+		err := runJob(ctx, application, *job)
+		application.Close(ctx)
+		if err != nil {
+			logger.Log.WithError(err).WithField("job", *job).Fatal("Job failed")
+		}
+		return
 	}
 
-	pprof.Register(router)
-
-	v1 := router.Group("/app")
-
-	patientHandler := handler.NewPatientHandler(*patientService)
-	v1.POST("/patients/pay-transaction", patientHandler.PayTransaction)
+	// the same binary serves API Gateway (REST and HTTP APIs), ALB, SQS, schedules and direct invocations
+	opts := []dispatcher.Option{
+		dispatcher.WithMessageHandler(application.Worker.HandleSQSMessage),
+		dispatcher.WithMetrics(application.Metrics),
+	}
+	for name, run := range application.Jobs {
+		opts = append(opts, dispatcher.WithJob(name, run))
+	}
 
-	ginLambda = ginadapter.NewV2(router)
+	handler := dispatcher.New(application.Router, opts...)
+	lambda.StartWithOptions(handler, lambda.WithEnableSIGTERM(func() {
+		if err := application.Close(ctx); err != nil {
+			logger.Log.WithError(err).Error("Failed to shut down application")
+		}
+	}))
 }
 
-func main() {
-	lambda.Start(Handler)
+// runJob runs a job from the command line, e.g. `go run . -job retry-failed-transactions`
+func runJob(ctx context.Context, application *app.App, name string) error {
+	run, ok := application.Jobs[name]
+	if !ok {
+		return fmt.Errorf("unknown job %q", name)
+	}
+	return run(ctx)
 }
@@ -2,6 +2,7 @@ package main
 
 import (
 	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/apigatewayv2"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/cloudwatch"
 	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/iam"
 	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/lambda"
 	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
@@ -139,6 +140,62 @@ func main() {
 			return err
 		}
 
+		// Retry transient provider failures every five minutes. The function runs the job named
+		// after the rule, so the rule name must match the job.
+		retrySchedule, err := cloudwatch.NewEventRule(ctx, "retryFailedTransactionsSchedule", &cloudwatch.EventRuleArgs{
+			Name:               pulumi.String("retry-failed-transactions"),
+			ScheduleExpression: pulumi.String("rate(5 minutes)"),
+		})
+		if err != nil {
+			return err
+		}
+
+		_, err = cloudwatch.NewEventTarget(ctx, "retryFailedTransactionsTarget", &cloudwatch.EventTargetArgs{
+			Rule: retrySchedule.Name,
+			Arn:  function.Arn,
+		})
+		if err != nil {
+			return err
+		}
+
+		// Grant EventBridge permission to invoke the Lambda function.
+		_, err = lambda.NewPermission(ctx, "retrySchedulePermission", &lambda.PermissionArgs{
+			Action:    pulumi.String("lambda:InvokeFunction"),
+			Function:  function.Name,
+			Principal: pulumi.String("events.amazonaws.com"),
+			SourceArn: retrySchedule.Arn,
+		})
+		if err != nil {
+			return err
+		}
+
+		// Delete provider attempts past TRANSACTION_ATTEMPT_RETENTION once a day.
+		purgeSchedule, err := cloudwatch.NewEventRule(ctx, "purgeTransactionAttemptsSchedule", &cloudwatch.EventRuleArgs{
+			Name:               pulumi.String("purge-transaction-attempts"),
+			ScheduleExpression: pulumi.String("rate(1 day)"),
+		})
+		if err != nil {
+			return err
+		}
+
+		_, err = cloudwatch.NewEventTarget(ctx, "purgeTransactionAttemptsTarget", &cloudwatch.EventTargetArgs{
+			Rule: purgeSchedule.Name,
+			Arn:  function.Arn,
+		})
+		if err != nil {
+			return err
+		}
+
+		_, err = lambda.NewPermission(ctx, "purgeSchedulePermission", &lambda.PermissionArgs{
+			Action:    pulumi.String("lambda:InvokeFunction"),
+			Function:  function.Name,
+			Principal: pulumi.String("events.amazonaws.com"),
+			SourceArn: purgeSchedule.Arn,
+		})
+		if err != nil {
+			return err
+		}
+
 		// Export the API endpoint URL.
 		ctx.Export("apiUrl", api.ApiEndpoint)
 